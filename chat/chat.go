@@ -0,0 +1,100 @@
+// Package chat exposes yap's peer-to-peer chat engine for embedding in other
+// programs without the terminal UI.
+//
+//	c, err := chat.NewChat(chat.Options{Config: chat.Config{Name: "bot", Listen: ":4000"}})
+//	if err != nil {
+//		return err
+//	}
+//	c.Start()
+//	defer c.Shutdown()
+//	go func() {
+//		for msg := range c.Events() {
+//			if msg.Type == chat.ChatMsg {
+//				fmt.Printf("%s: %s\n", msg.From, msg.Body)
+//			}
+//		}
+//	}()
+//	_ = c.Submit("hello from an embedded node")
+//
+// Several Chats may run in one process, for bridges or tests: each owns its
+// sockets, membership, and Events stream, and the package keeps no mutable
+// global state. Give each its own Listen address. Chats sharing a Store should
+// use distinct profiles, since persisted seen sets and history are kept per
+// profile; a shared LogFile interleaves their records.
+package chat
+
+import (
+	ichat "yap/internal/chat"
+	"yap/internal/config"
+)
+
+type (
+	// Chat is a running chat engine.
+	Chat = ichat.Chat
+	// Options configures NewChat.
+	Options = ichat.Options
+	// Message is a chat event or wire message.
+	Message = ichat.Message
+	// MsgType identifies the kind of a Message.
+	MsgType = ichat.MsgType
+	// ShutdownReport describes how the leave notice fared on Shutdown.
+	ShutdownReport = ichat.ShutdownReport
+	// Config is the runtime configuration for a chat engine.
+	Config = config.Config
+	// Store persists saved configuration profiles.
+	Store = config.Store
+	// PresenceEvent is a typed membership transition from Chat.Presence.
+	PresenceEvent = ichat.PresenceEvent
+	// PresenceKind identifies a presence transition.
+	PresenceKind = ichat.PresenceKind
+	// Metrics is a snapshot of traffic counters from Chat.Metrics.
+	Metrics = ichat.Metrics
+)
+
+// Message kinds delivered on the Events stream.
+const (
+	ChatMsg   = ichat.ChatMsg
+	JoinMsg   = ichat.JoinMsg
+	LeaveMsg  = ichat.LeaveMsg
+	ErrorMsg  = ichat.ErrorMsg
+	SystemMsg = ichat.SystemMsg
+	PromptMsg = ichat.PromptMsg
+	EditMsg   = ichat.EditMsg
+	DeleteMsg = ichat.DeleteMsg
+	FilterMsg = ichat.FilterMsg
+	PinMsg    = ichat.PinMsg
+	ReadMsg   = ichat.ReadMsg
+	TypingMsg = ichat.TypingMsg
+	// DeliveryMsg updates the delivery status of the sent message named by Ref.
+	DeliveryMsg = ichat.DeliveryMsg
+	// MemberMsg reports a peer changing status; Body holds a Member status.
+	MemberMsg = ichat.MemberMsg
+	// ClearMsg asks the UI to empty its scrollback.
+	ClearMsg = ichat.ClearMsg
+)
+
+// Member statuses carried in the Body of MemberMsg events.
+const (
+	MemberActive  = ichat.MemberActive
+	MemberPending = ichat.MemberPending
+	MemberFailed  = ichat.MemberFailed
+	MemberLeft    = ichat.MemberLeft
+)
+
+// Presence transitions delivered on the Presence stream.
+const (
+	PresenceJoin   = ichat.PresenceJoin
+	PresenceLeave  = ichat.PresenceLeave
+	PresenceRename = ichat.PresenceRename
+	PresenceAway   = ichat.PresenceAway
+)
+
+// NewChat binds the socket and prepares a chat engine without contacting peers.
+func NewChat(opts Options) (*Chat, error) {
+	return ichat.NewChat(opts)
+}
+
+// LoadStore opens or creates a config store at path.
+func LoadStore(path string) (Store, error) {
+	return config.Load(path)
+}
@@ -0,0 +1,240 @@
+package chat_test
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"yap/chat"
+)
+
+// newChat starts a Chat on a loopback port and returns it with its address.
+func newChat(t *testing.T, name string) (*chat.Chat, string) {
+	t.Helper()
+	var bound string
+	c, err := chat.NewChat(chat.Options{
+		Config: chat.Config{Name: name, Listen: "127.0.0.1:0"},
+		Listen: func(addr string) (net.PacketConn, error) {
+			conn, err := net.ListenPacket("udp", addr)
+			if err == nil {
+				bound = conn.LocalAddr().String()
+			}
+			return conn, err
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _, _ = c.Shutdown() })
+	c.Start()
+	return c, bound
+}
+
+// waitFor reads events from c until match accepts one.
+func waitFor(t *testing.T, c *chat.Chat, match func(chat.Message) bool) chat.Message {
+	t.Helper()
+	deadline := time.After(5 * time.Second)
+	for {
+		select {
+		case msg := <-c.Events():
+			if match(msg) {
+				return msg
+			}
+		case <-deadline:
+			t.Fatal("timed out waiting for event")
+		}
+	}
+}
+
+func TestEmbeddedChatsExchangeMessages(t *testing.T) {
+	alice, aliceAddr := newChat(t, "alice")
+	bob, _ := newChat(t, "bob")
+	if err := bob.Submit("/peer " + aliceAddr); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, bob, func(msg chat.Message) bool {
+		return msg.Type == chat.MemberMsg && msg.Body == chat.MemberActive
+	})
+	if err := bob.Submit("hi alice"); err != nil {
+		t.Fatal(err)
+	}
+	got := waitFor(t, alice, func(msg chat.Message) bool { return msg.Type == chat.ChatMsg })
+	if got.From != "bob" || got.Body != "hi alice" {
+		t.Fatalf("alice got %q from %q", got.Body, got.From)
+	}
+}
+
+func TestShutdownClosesEvents(t *testing.T) {
+	c, _ := newChat(t, "alice")
+	if _, err := c.Shutdown(); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.After(5 * time.Second)
+	for {
+		select {
+		case _, ok := <-c.Events():
+			if !ok {
+				return
+			}
+		case <-deadline:
+			t.Fatal("events still open after Shutdown")
+		}
+	}
+}
+
+func TestNewChatRejectsUnknownCipher(t *testing.T) {
+	_, err := chat.NewChat(chat.Options{Config: chat.Config{Listen: "127.0.0.1:0", Secret: "hunter22", Cipher: "rot13"}})
+	if err == nil {
+		t.Fatal("unknown cipher accepted")
+	}
+}
+
+func TestAddAndRemovePeer(t *testing.T) {
+	alice, aliceAddr := newChat(t, "alice")
+	bob, _ := newChat(t, "bob")
+
+	if err := bob.AddPeer(aliceAddr); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, alice, func(msg chat.Message) bool {
+		return msg.Type == chat.MemberMsg && msg.Body == chat.MemberActive && msg.From == "bob"
+	})
+	// Peers count this node too.
+	if health := bob.Health(); !strings.Contains(health, "peers: 2 active") {
+		t.Fatalf("bob after AddPeer:\n%s", health)
+	}
+
+	if err := bob.RemovePeer(aliceAddr); err != nil {
+		t.Fatal(err)
+	}
+	if health := bob.Health(); !strings.Contains(health, "peers: 1 active, 0 pending") {
+		t.Fatalf("bob after RemovePeer:\n%s", health)
+	}
+	if err := bob.RemovePeer(aliceAddr); err == nil {
+		t.Fatal("removing an unknown peer succeeded")
+	}
+}
+
+func TestAddPeerReportsResolveErrors(t *testing.T) {
+	c, _ := newChat(t, "alice")
+	if err := c.AddPeer("not an address"); err == nil {
+		t.Fatal("unresolvable address accepted")
+	}
+}
+
+func TestSessionsInSeparateGroupsStayApart(t *testing.T) {
+	start := func(name, secret, group string) (*chat.Chat, string) {
+		t.Helper()
+		var bound string
+		c, err := chat.NewChat(chat.Options{
+			Config: chat.Config{Name: name, Listen: "127.0.0.1:0", Secret: secret, GroupID: group},
+			Listen: func(addr string) (net.PacketConn, error) {
+				conn, err := net.ListenPacket("udp", addr)
+				if err == nil {
+					bound = conn.LocalAddr().String()
+				}
+				return conn, err
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { _, _ = c.Shutdown() })
+		c.Start()
+		return c, bound
+	}
+	alice, aliceAddr := start("alice", "red secret", "red")
+	bob, _ := start("bob", "red secret", "red")
+	carol, _ := start("carol", "blue secret", "blue")
+
+	for _, c := range []*chat.Chat{bob, carol} {
+		if err := c.Submit("/peer " + aliceAddr); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Peers count this node too, so bob is alice's only other member.
+	deadline := time.Now().Add(5 * time.Second)
+	for !strings.Contains(alice.Health(), "peers: 2 active") {
+		if time.Now().After(deadline) {
+			t.Fatalf("alice never saw bob:\n%s", alice.Health())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err := alice.Submit("red only"); err != nil {
+		t.Fatal(err)
+	}
+	if err := carol.Submit("blue only"); err != nil {
+		t.Fatal(err)
+	}
+	if got := waitFor(t, bob, func(msg chat.Message) bool { return msg.Type == chat.ChatMsg }); got.Body != "red only" {
+		t.Fatalf("bob got %q", got.Body)
+	}
+
+	// Give stray packets time to arrive before checking for cross-talk.
+	time.Sleep(200 * time.Millisecond)
+	for name, c := range map[string]*chat.Chat{"alice": alice, "bob": bob, "carol": carol} {
+		for _, msg := range pending(c) {
+			if msg.Type == chat.ChatMsg && msg.From != name {
+				t.Errorf("%s received %q from %s across groups", name, msg.Body, msg.From)
+			}
+		}
+	}
+}
+
+// pending returns the events already queued on c.
+func pending(c *chat.Chat) []chat.Message {
+	var out []chat.Message
+	for {
+		select {
+		case msg := <-c.Events():
+			out = append(out, msg)
+		default:
+			return out
+		}
+	}
+}
+
+func TestPresenceReportsJoinAndLeave(t *testing.T) {
+	var aliceAddr string
+	alice, err := chat.NewChat(chat.Options{
+		Config:   chat.Config{Name: "alice", Listen: "127.0.0.1:0"},
+		Presence: true,
+		Listen: func(addr string) (net.PacketConn, error) {
+			conn, err := net.ListenPacket("udp", addr)
+			if err == nil {
+				aliceAddr = conn.LocalAddr().String()
+			}
+			return conn, err
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _, _ = alice.Shutdown() })
+	alice.Start()
+	bob, bobAddr := newChat(t, "bob")
+
+	if err := bob.AddPeer(aliceAddr); err != nil {
+		t.Fatal(err)
+	}
+	next := func() chat.PresenceEvent {
+		t.Helper()
+		select {
+		case ev := <-alice.Presence():
+			return ev
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for presence")
+			return chat.PresenceEvent{}
+		}
+	}
+	if ev := next(); ev.Kind != chat.PresenceJoin || ev.Addr != bobAddr || ev.Name != "bob" {
+		t.Fatalf("join = %+v", ev)
+	}
+	if _, err := bob.Shutdown(); err != nil {
+		t.Fatal(err)
+	}
+	if ev := next(); ev.Kind != chat.PresenceLeave || ev.Addr != bobAddr {
+		t.Fatalf("leave = %+v", ev)
+	}
+}
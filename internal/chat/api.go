@@ -0,0 +1,163 @@
+package chat
+
+import (
+	"fmt"
+	"net"
+	"time"
+
+	"yap/internal/config"
+)
+
+// MsgType identifies the kind of a Message.
+type MsgType = msgType
+
+// Message kinds delivered on the Events stream.
+const (
+	ChatMsg   = chatMsg
+	JoinMsg   = joinMsg
+	LeaveMsg  = leaveMsg
+	ErrorMsg  = errorMsg
+	SystemMsg = systemMsg
+	PromptMsg = promptMsg
+	EditMsg   = editMsg
+	DeleteMsg = deleteMsg
+	FilterMsg = filterMsg
+	PinMsg    = pinMsg
+	ReadMsg   = readMsg
+	TypingMsg = typingMsg
+	// DeliveryMsg updates the delivery status of the sent message named by Ref.
+	DeliveryMsg = deliveryMsg
+	// MemberMsg reports a peer changing status; Body holds MemberActive,
+	// MemberPending, MemberFailed, or MemberLeft.
+	MemberMsg = memberMsg
+	// ClearMsg asks the UI to empty its scrollback.
+	ClearMsg = clearMsg
+)
+
+// Member statuses carried in the Body of MemberMsg events.
+const (
+	MemberActive  = "active"
+	MemberPending = "pending"
+	MemberFailed  = "failed"
+	MemberLeft    = "left"
+)
+
+// Options configures an embeddable chat engine.
+type Options struct {
+	// Config is the resolved runtime configuration; a Secret enables encryption.
+	Config config.Config
+	// Store persists saved profiles; nil disables /group and /switch.
+	Store config.Store
+	// Listen opens the UDP socket, defaulting to net.ListenPacket("udp", addr).
+	Listen func(addr string) (net.PacketConn, error)
+	// Resolve maps peer strings to addresses, defaulting to net.ResolveUDPAddr.
+	Resolve func(addr string) (net.Addr, error)
+	// SendRetries is how many times a failed join send or a transient resolve
+	// failure is retried before giving up; zero uses 3 and a negative value
+	// disables retries.
+	SendRetries int
+	// RetryDelay is the first backoff delay, doubled on each retry; zero uses 200ms.
+	RetryDelay time.Duration
+	// RateLimit is how many packets per second each source may send before
+	// the excess is dropped; zero uses 200 and a negative value disables it.
+	RateLimit int
+	// Presence enables the typed membership stream returned by Chat.Presence.
+	Presence bool
+}
+
+// Chat is a chat engine with no terminal dependency. Consume Events and call
+// Submit to drive it from other code.
+type Chat struct {
+	session *session
+}
+
+// NewChat binds the socket and prepares a session without contacting peers.
+func NewChat(opts Options) (*Chat, error) {
+	var cipher packetCipher
+	if opts.Config.Secret != "" {
+		var err error
+		cipher, err = newPacketCipher(opts.Config)
+		if err != nil {
+			return nil, fmt.Errorf("setup error: %w", err)
+		}
+	}
+
+	session, err := newSession(sessionOptions{
+		config:     opts.Config,
+		listen:     opts.Listen,
+		resolve:    opts.Resolve,
+		cipher:     cipher,
+		store:      opts.Store,
+		retries:    opts.SendRetries,
+		retryDelay: opts.RetryDelay,
+		rateLimit:  opts.RateLimit,
+		presence:   opts.Presence,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &Chat{session: session}, nil
+}
+
+// Start begins receiving packets and announces this node to its bootstrap
+// peers in the background; it returns without waiting for them.
+func (c *Chat) Start() {
+	c.session.start()
+}
+
+// Submit sends chat text or runs a slash command, exactly as typed in the UI.
+func (c *Chat) Submit(text string) error {
+	return c.session.submit(text)
+}
+
+// Events streams chat, membership, and system notices; it closes on Shutdown.
+func (c *Chat) Events() <-chan Message {
+	return c.session.eventStream()
+}
+
+// Presence streams typed join, leave, rename, and away transitions, separate
+// from Events. It is nil unless Options.Presence is set and closes on Shutdown.
+func (c *Chat) Presence() <-chan PresenceEvent {
+	return c.session.presence
+}
+
+// MarkRead reports that msg was shown to the user. When Config.ReadReceipts is
+// set, the sender is told so its UI can show who has read it.
+func (c *Chat) MarkRead(msg Message) {
+	c.session.markRead(msg)
+}
+
+// Typing tells directly connected peers that the user is composing a message.
+// Calls are throttled, so it is safe to call on every keystroke.
+func (c *Chat) Typing() {
+	c.session.sendTyping()
+}
+
+// Health reports socket liveness, peer counts, event backlog, and goroutine
+// count, as shown by /health.
+func (c *Chat) Health() string {
+	return c.session.healthSummary()
+}
+
+// Metrics returns a snapshot of the traffic counters.
+func (c *Chat) Metrics() Metrics {
+	return c.session.metrics()
+}
+
+// AddPeer resolves addr and sends it a join. Resolution and delivery errors
+// are returned; on success the peer is a member of the session.
+func (c *Chat) AddPeer(addr string) error {
+	c.session.start()
+	return c.session.addPeer(addr)
+}
+
+// RemovePeer forgets the member at addr without notifying it. Peers that keep
+// talking to this node will be re-added.
+func (c *Chat) RemovePeer(addr string) error {
+	return c.session.removePeer(addr)
+}
+
+// Shutdown says goodbye to peers and releases the socket.
+func (c *Chat) Shutdown() (ShutdownReport, error) {
+	return c.session.shutdown()
+}
@@ -5,50 +5,156 @@ import (
 	"crypto/cipher"
 	"crypto/rand"
 	"crypto/sha256"
+	"encoding/hex"
 	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/chacha20poly1305"
+
+	"yap/internal/config"
+)
+
+// Cipher suites selectable with Config.Cipher; every peer in a group must use
+// the same one.
+const (
+	suiteAESGCM   = "aes-gcm"
+	suiteChaCha20 = "chacha20-poly1305"
 )
 
+// Key derivation functions selectable with Config.KDF. Every peer in a group
+// must use the same one.
+const (
+	// kdfSHA256 hashes the secret once. It is the legacy default and offers no
+	// resistance to brute-forcing a weak secret.
+	kdfSHA256 = "sha256"
+	// kdfArgon2id stretches the secret with Argon2id (RFC 9106's second
+	// recommended parameters) under the salt "yap/argon2id/v1/" + GroupID.
+	kdfArgon2id = "argon2id"
+)
+
+// Argon2id cost parameters; changing them changes every derived key.
+const (
+	argon2Time    = 3
+	argon2Memory  = 64 * 1024 // KiB
+	argon2Threads = 4
+)
+
+// keySize is the length of every derived key; both suites take 256-bit keys.
+const keySize = 32
+
+// deriveKey turns the shared secret into a cipher key with the named KDF.
+// group only affects Argon2id, where it salts the derivation so different
+// groups sharing a secret still get different keys.
+func deriveKey(secret, kdf, group string) ([]byte, error) {
+	switch strings.ToLower(strings.TrimSpace(kdf)) {
+	case "", kdfSHA256:
+		key := sha256.Sum256([]byte(secret))
+		return key[:], nil
+	case kdfArgon2id:
+		salt := []byte("yap/argon2id/v1/" + group)
+		return argon2.IDKey([]byte(secret), salt, argon2Time, argon2Memory, argon2Threads, keySize), nil
+	default:
+		return nil, fmt.Errorf("unknown kdf %q (want %s or %s)", kdf, kdfSHA256, kdfArgon2id)
+	}
+}
+
 // packetCipher defines the encryption contract used by the transport layer.
+// The aad passed to both sides is authenticated but not encrypted; it binds
+// the cleartext message header to the sealed body.
 type packetCipher interface {
-	Encrypt(plain []byte) ([]byte, []byte, error)
-	Decrypt(nonce, ciphertext []byte) ([]byte, error)
+	Encrypt(plain, aad []byte) ([]byte, []byte, error)
+	Decrypt(nonce, ciphertext, aad []byte) ([]byte, error)
+	// Fingerprint identifies the derived key without revealing it, so peers
+	// can spot a secret mismatch before trying to decrypt.
+	Fingerprint() string
+	// Suite names the AEAD construction, e.g. "aes-gcm".
+	Suite() string
 }
 
-type aesCipher struct {
-	gcm cipher.AEAD
+// aeadCipher seals packets with any AEAD keyed from the shared secret.
+type aeadCipher struct {
+	aead        cipher.AEAD
+	suite       string
+	fingerprint string
 }
 
-// newAESCipher constructs an AES-GCM cipher from the supplied secret.
-func newAESCipher(secret string) (packetCipher, error) {
-	if secret == "" {
+// keyFingerprint hashes a derived key into a short public identifier.
+func keyFingerprint(key []byte) string {
+	sum := sha256.Sum256(append([]byte("yap key fingerprint\x00"), key...))
+	return hex.EncodeToString(sum[:4])
+}
+
+// newPacketCipher constructs the cipher described by cfg: the Cipher suite,
+// AES-GCM when blank, keyed from Secret through the chosen KDF.
+func newPacketCipher(cfg config.Config) (packetCipher, error) {
+	if cfg.Secret == "" {
 		return nil, errors.New("secret cannot be empty")
 	}
 
-	key := sha256.Sum256([]byte(secret))
-	block, err := aes.NewCipher(key[:])
+	key, err := deriveKey(cfg.Secret, cfg.KDF, cfg.GroupID)
 	if err != nil {
 		return nil, err
 	}
-
-	gcm, err := cipher.NewGCM(block)
+	var aead cipher.AEAD
+	suite := strings.ToLower(strings.TrimSpace(cfg.Cipher))
+	switch suite {
+	case "", suiteAESGCM:
+		suite = suiteAESGCM
+		var block cipher.Block
+		if block, err = aes.NewCipher(key); err == nil {
+			aead, err = cipher.NewGCM(block)
+		}
+	case suiteChaCha20:
+		aead, err = chacha20poly1305.New(key)
+	default:
+		return nil, fmt.Errorf("unknown cipher %q (want %s or %s)", cfg.Cipher, suiteAESGCM, suiteChaCha20)
+	}
 	if err != nil {
 		return nil, err
 	}
 
-	return &aesCipher{gcm: gcm}, nil
+	return &aeadCipher{aead: aead, suite: suite, fingerprint: keyFingerprint(key)}, nil
+}
+
+// sameCipherSettings reports whether a and b derive the same cipher.
+func sameCipherSettings(a, b config.Config) bool {
+	return a.Secret == b.Secret && a.Cipher == b.Cipher && a.KDF == b.KDF && a.GroupID == b.GroupID
+}
+
+// Fingerprint returns the short public identifier of the derived key.
+func (c *aeadCipher) Fingerprint() string {
+	return c.fingerprint
+}
+
+// Suite returns the name of the AEAD construction.
+func (c *aeadCipher) Suite() string {
+	return c.suite
 }
 
-// Encrypt applies AES-GCM and returns the nonce alongside the ciphertext.
-func (c *aesCipher) Encrypt(plain []byte) ([]byte, []byte, error) {
-	nonce := make([]byte, c.gcm.NonceSize())
+// Encrypt seals plain under a fresh random nonce and returns the nonce
+// alongside the ciphertext.
+func (c *aeadCipher) Encrypt(plain, aad []byte) ([]byte, []byte, error) {
+	nonce := make([]byte, c.aead.NonceSize())
 	if _, err := rand.Read(nonce); err != nil {
 		return nil, nil, err
 	}
-	ciphertext := c.gcm.Seal(nil, nonce, plain, nil)
+	ciphertext := c.aead.Seal(nil, nonce, plain, aad)
 	return nonce, ciphertext, nil
 }
 
+// errMalformedCiphertext reports a sealed message that cannot be valid under
+// any key, as opposed to one that fails authentication.
+var errMalformedCiphertext = errors.New("malformed ciphertext")
+
 // Decrypt verifies and recovers the plaintext for a sealed message.
-func (c *aesCipher) Decrypt(nonce, ciphertext []byte) ([]byte, error) {
-	return c.gcm.Open(nil, nonce, ciphertext, nil)
+func (c *aeadCipher) Decrypt(nonce, ciphertext, aad []byte) ([]byte, error) {
+	if len(nonce) != c.aead.NonceSize() {
+		return nil, fmt.Errorf("%w: invalid nonce size", errMalformedCiphertext)
+	}
+	if len(ciphertext) < c.aead.Overhead() {
+		return nil, fmt.Errorf("%w: too short", errMalformedCiphertext)
+	}
+	return c.aead.Open(nil, nonce, ciphertext, aad)
 }
@@ -0,0 +1,180 @@
+package chat
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"strings"
+	"testing"
+	"time"
+
+	"yap/internal/config"
+)
+
+// sealedPair returns a sender and receiver sharing secret, both on clock.
+func sealedPair(t *testing.T, clock *fakeClock) (sender, receiver *session) {
+	t.Helper()
+	cfg := config.Config{Secret: "correct horse"}
+	for _, s := range []**session{&sender, &receiver} {
+		cipher, err := newPacketCipher(cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		*s = newTestSessionWith(t, sessionOptions{config: cfg, cipher: cipher, now: clock.Now})
+	}
+	return sender, receiver
+}
+
+func TestSealedHeaderTamperingFailsDecryption(t *testing.T) {
+	sender, receiver := sealedPair(t, newFakeClock())
+	sealed, _, err := sender.transport.prepareMessage(Message{Type: chatMsg, From: "alice", Body: "hi"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msg := sealed
+	if ok, _, err := receiver.transport.verifyAndDecrypt(&msg); !ok || msg.Body != "hi" {
+		t.Fatalf("untouched message rejected: %v", err)
+	}
+
+	for name, tamper := range map[string]func(*Message){
+		"from":      func(m *Message) { m.From = "mallory" },
+		"type":      func(m *Message) { m.Type = pinMsg },
+		"id":        func(m *Message) { m.ID = newMessageID() },
+		"timestamp": func(m *Message) { m.Timestamp++ },
+		"to":        func(m *Message) { m.To = "10.0.0.9:4000" },
+	} {
+		msg := sealed
+		tamper(&msg)
+		ok, reason, _ := receiver.transport.verifyAndDecrypt(&msg)
+		if ok || reason != rejectDecrypt {
+			t.Errorf("flipped %s: ok=%v reason=%q, want a decrypt failure", name, ok, reason)
+		}
+	}
+}
+
+func TestStaleSealedMessageIsDropped(t *testing.T) {
+	clock := newFakeClock()
+	sender, receiver := sealedPair(t, clock)
+	sealed, _, err := sender.transport.prepareMessage(Message{Type: chatMsg, From: "alice", Body: "hi"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clock.advance(maxClockSkew - time.Second)
+	msg := sealed
+	if ok, _, err := receiver.transport.verifyAndDecrypt(&msg); !ok {
+		t.Fatalf("message within the skew window rejected: %v", err)
+	}
+
+	clock.advance(10*time.Minute - maxClockSkew + time.Second)
+	msg = sealed
+	ok, reason, err := receiver.transport.verifyAndDecrypt(&msg)
+	if ok || err == nil {
+		t.Fatal("10 minute old message accepted")
+	}
+	if reason != "" {
+		t.Fatalf("stale message answered with %q; replays should be dropped quietly", reason)
+	}
+}
+
+func TestCipherSuitesRoundTrip(t *testing.T) {
+	for _, suite := range []string{"", suiteAESGCM, suiteChaCha20, "ChaCha20-Poly1305"} {
+		c, err := newPacketCipher(config.Config{Secret: "correct horse", Cipher: suite})
+		if err != nil {
+			t.Fatalf("suite %q: %v", suite, err)
+		}
+		aad := []byte("header")
+		nonce, sealed, err := c.Encrypt([]byte("hello"), aad)
+		if err != nil {
+			t.Fatal(err)
+		}
+		plain, err := c.Decrypt(nonce, sealed, aad)
+		if err != nil || !bytes.Equal(plain, []byte("hello")) {
+			t.Fatalf("suite %q: decrypt = %q, %v", suite, plain, err)
+		}
+	}
+	if _, err := newPacketCipher(config.Config{Secret: "s", Cipher: "rot13"}); err == nil || !strings.Contains(err.Error(), "unknown cipher") {
+		t.Fatalf("unknown suite err = %v", err)
+	}
+}
+
+func TestCrossSuiteMismatchIsReported(t *testing.T) {
+	session := func(suite string) *session {
+		cfg := config.Config{Secret: "correct horse", Cipher: suite}
+		cipher, err := newPacketCipher(cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return newTestSessionWith(t, sessionOptions{config: cfg, cipher: cipher})
+	}
+	aes, chacha := session(suiteAESGCM), session(suiteChaCha20)
+
+	// Joins carry the suite, so the mismatch is named outright.
+	msg, _, err := chacha.transport.prepareMessage(Message{Type: joinMsg, From: "alice"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ok, reason, _ := aes.transport.verifyAndDecrypt(&msg)
+	if ok || reason != "cipher mismatch (peer uses chacha20-poly1305, expected aes-gcm)" {
+		t.Fatalf("ok=%v reason=%q", ok, reason)
+	}
+
+	// Other messages still fail authentication.
+	msg, _, err = chacha.transport.prepareMessage(Message{Type: chatMsg, From: "alice", Body: "hi"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok, reason, _ := aes.transport.verifyAndDecrypt(&msg); ok || reason != rejectDecrypt {
+		t.Fatalf("unlabelled cross-suite message: ok=%v reason=%q", ok, reason)
+	}
+}
+
+func TestDeriveKey(t *testing.T) {
+	legacy, err := deriveKey("correct horse", "", "team")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := sha256.Sum256([]byte("correct horse")); !bytes.Equal(legacy, want[:]) {
+		t.Fatal("legacy kdf is not a plain SHA-256 of the secret")
+	}
+
+	first, err := deriveKey("correct horse", kdfArgon2id, "team")
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := deriveKey("correct horse", "Argon2id", "team")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(first) != keySize || !bytes.Equal(first, again) {
+		t.Fatalf("argon2id key is %d bytes or unstable", len(first))
+	}
+	if bytes.Equal(first, legacy) {
+		t.Fatal("argon2id key equals the legacy key")
+	}
+	other, err := deriveKey("correct horse", kdfArgon2id, "crew")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(first, other) {
+		t.Fatal("group id does not salt the argon2id key")
+	}
+
+	if _, err := deriveKey("correct horse", "md5", ""); err == nil {
+		t.Fatal("unknown kdf accepted")
+	}
+}
+
+func TestKDFMismatchChangesFingerprint(t *testing.T) {
+	legacy, err := newPacketCipher(config.Config{Secret: "correct horse"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	stretched, err := newPacketCipher(config.Config{Secret: "correct horse", KDF: kdfArgon2id})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if legacy.Fingerprint() == stretched.Fingerprint() {
+		t.Fatal("different kdfs share a key fingerprint")
+	}
+}
@@ -0,0 +1,142 @@
+package chat
+
+import (
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"yap/internal/config"
+)
+
+// fakeClock is a sessionOptions.now source that only moves when told to.
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) advance(d time.Duration) {
+	c.mu.Lock()
+	c.now = c.now.Add(d)
+	c.mu.Unlock()
+}
+
+func TestAckSweepFollowsSessionClock(t *testing.T) {
+	clock := newFakeClock()
+	s := newTestSessionWith(t, sessionOptions{config: config.Config{Reliable: true}, now: clock.Now})
+	peer := listenPeer(t, s, "bob")
+	key := canonicalNetAddr(peer.LocalAddr())
+
+	s.expectAcks(Message{ID: "m1", Type: chatMsg}, []byte("raw"), s.activeEndpoints())
+	resends := func() int {
+		s.acks.mu.Lock()
+		defer s.acks.mu.Unlock()
+		if entry := s.acks.pending[ackKey{id: "m1", peer: key}]; entry != nil {
+			return entry.resends
+		}
+		return -1
+	}
+
+	s.sweepAcks()
+	if got := resends(); got != 0 {
+		t.Fatalf("resent %d times before the clock moved", got)
+	}
+	for want := 1; want <= maxAckResends; want++ {
+		clock.advance(ackTimeout)
+		s.sweepAcks()
+		if got := resends(); got != want {
+			t.Fatalf("after %d timeouts resent %d times", want, got)
+		}
+	}
+	clock.advance(ackTimeout)
+	s.sweepAcks()
+	if pendingAcks(s) != 0 || isActive(s, key) {
+		t.Fatal("silent recipient still tracked after the last resend")
+	}
+}
+
+func TestOutboxExpiryFollowsSessionClock(t *testing.T) {
+	clock := newFakeClock()
+	s := newTestSessionWith(t, sessionOptions{config: config.Config{Outbox: 4}, now: clock.Now})
+	peer := listenPeer(t, s, "bob")
+	key := canonicalNetAddr(peer.LocalAddr())
+
+	s.queueFor([]string{key}, []byte(`{"id":"old"}`))
+	clock.advance(outboxMaxAge + time.Second)
+	s.queueFor([]string{key}, []byte(`{"id":"new"}`))
+	s.flushOutbox(key)
+
+	if msg := readMessage(t, peer); msg.ID != "new" {
+		t.Fatalf("replayed %q first, want only the fresh frame", msg.ID)
+	}
+}
+
+func TestHeartbeatStampsPingWithSessionClock(t *testing.T) {
+	clock := newFakeClock()
+	s := newTestSessionWith(t, sessionOptions{config: config.Config{}, now: clock.Now})
+	peer := listenPeer(t, s, "bob")
+
+	s.heartbeatTick()
+	msg := readMessage(t, peer)
+	if want := strconv.FormatInt(clock.Now().UnixNano(), 10); msg.Type != pingMsg || msg.Body != want {
+		t.Fatalf("got %s %q, want ping %s", msg.Type, msg.Body, want)
+	}
+
+	clock.advance(40 * time.Millisecond)
+	s.recordRTT(peer.LocalAddr(), msg.Body)
+	if rec, _ := s.lookupMember(canonicalNetAddr(peer.LocalAddr())); rec.RTT != 40*time.Millisecond {
+		t.Fatalf("rtt = %v, want 40ms", rec.RTT)
+	}
+}
+
+func TestDedupFollowsSessionClock(t *testing.T) {
+	clock := newFakeClock()
+	s := newTestSessionWith(t, sessionOptions{config: config.Config{DedupWindow: "1m"}, now: clock.Now})
+	d := s.transport.seen
+
+	if d.loadOrStore("m1") {
+		t.Fatal("first sighting reported as duplicate")
+	}
+	clock.advance(reforwardGrace / 2)
+	if !d.loadOrStore("m1") || !d.allowReforward("m1", reforwardGrace, maxReforwards) {
+		t.Fatal("resend inside the grace window not passed on")
+	}
+	clock.advance(reforwardGrace)
+	if d.allowReforward("m1", reforwardGrace, maxReforwards) {
+		t.Fatal("resend passed on after the grace window")
+	}
+
+	path := filepath.Join(t.TempDir(), "seen")
+	if err := d.save(path); err != nil {
+		t.Fatal(err)
+	}
+	restored := newDedupCache(time.Minute)
+	restored.now = clock.Now
+	if err := restored.load(path); err != nil {
+		t.Fatal(err)
+	}
+	if restored.size() != 1 {
+		t.Fatalf("restored %d IDs, want the one inside the window", restored.size())
+	}
+
+	clock.advance(time.Minute)
+	if d.loadOrStore("m1") {
+		t.Fatal("ID still a duplicate after the window")
+	}
+	clock.advance(time.Minute + time.Second)
+	d.sweep()
+	if d.size() != 0 {
+		t.Fatalf("sweep kept %d expired IDs", d.size())
+	}
+}
@@ -1,9 +1,11 @@
 package chat
 
 import (
+	"errors"
 	"fmt"
 	"net"
 	"strings"
+	"sync/atomic"
 
 	"yap/internal/config"
 )
@@ -26,29 +28,215 @@ func (s *session) handleCommand(cmd string) error {
 	case cmd == "/peers":
 		s.emitSystem("%s", s.peersSummary())
 		return nil
+	case strings.HasPrefix(cmd, "/peers "):
+		listing, err := s.peersListing(strings.Fields(cmd)[1:])
+		if err != nil {
+			s.emitSystem("%v", err)
+			return nil
+		}
+		s.emitSystem("%s", listing)
+		return nil
+	case strings.HasPrefix(cmd, "/whois"):
+		parts := strings.Fields(cmd)
+		if len(parts) != 2 {
+			s.emitSystem("usage: /whois <address|name>")
+			return nil
+		}
+		s.emitSystem("%s", s.whoisSummary(parts[1]))
+		return nil
+	case cmd == "/events":
+		s.emitSystem("%s", s.eventsSummary())
+		return nil
+	case cmd == "/lastpacket":
+		s.emitSystem("%s", s.lastPacketSummary())
+		return nil
+	case cmd == "/stats":
+		s.emitSystem("%s", s.statsSummary())
+		return nil
+	case cmd == "/health":
+		s.emitSystem("%s", s.healthSummary())
+		return nil
+	case cmd == "/clear":
+		s.emit(Message{Type: clearMsg})
+		return nil
+	case cmd == "/history":
+		s.replayHistory()
+		return nil
+	case cmd == "/config":
+		s.emitSystem("%s", s.configSummary())
+		return nil
 	case cmd == "/quit" || cmd == "/exit" || cmd == "/q":
 		s.emitSystem("goodbye")
 		return errQuit
-	case strings.HasPrefix(cmd, "/group"):
+	case cmd == "/edit" || strings.HasPrefix(cmd, "/edit "):
+		text := strings.TrimSpace(strings.TrimPrefix(cmd, "/edit"))
+		if text == "" {
+			s.emitSystem("usage: /edit <text>")
+			return nil
+		}
+		ref := s.lastSentMessage()
+		if ref == "" {
+			s.emitSystem("nothing to edit yet")
+			return nil
+		}
+		return s.broadcastMessage(Message{Type: editMsg, Ref: ref, Body: text})
+	case strings.HasPrefix(cmd, "/reply"):
+		parts := strings.SplitN(cmd, " ", 3)
+		if len(parts) < 3 || strings.TrimSpace(parts[2]) == "" {
+			s.emitSystem("usage: /reply <id> <text>")
+			return nil
+		}
+		ref, err := s.resolveRecent(parts[1])
+		if err != nil {
+			s.emitSystem("%v", err)
+			return nil
+		}
+		msg := Message{Type: chatMsg, Body: strings.TrimSpace(parts[2]), ReplyTo: ref}
+		if s.cfg.RoomColors {
+			msg.Room = s.cfg.Profile
+		}
+		return s.broadcastMessage(msg)
+	case cmd == "/pin" || strings.HasPrefix(cmd, "/pin "):
+		s.handlePin(strings.Fields(cmd)[1:])
+		return nil
+	case cmd == "/unpin" || strings.HasPrefix(cmd, "/unpin "):
+		s.handleUnpin(strings.Fields(cmd)[1:])
+		return nil
+	case cmd == "/filter" || strings.HasPrefix(cmd, "/filter "):
+		s.handleFilter(strings.Fields(cmd)[1:])
+		return nil
+	case strings.HasPrefix(cmd, "/except"):
+		parts := strings.SplitN(cmd, " ", 3)
+		if len(parts) < 3 || strings.TrimSpace(parts[2]) == "" {
+			s.emitSystem("usage: /except <name,name...> <text>")
+			return nil
+		}
+		sent, err := s.sendExcept(strings.Split(parts[1], ","), strings.TrimSpace(parts[2]))
+		if err != nil {
+			s.emitSystem("message not fully sent: %v", err)
+		}
+		if sent > 0 {
+			s.emitSystem("sent to %d peer(s)", sent)
+		}
+		return nil
+	case strings.HasPrefix(cmd, "/msg"):
+		parts := strings.SplitN(cmd, " ", 3)
+		if len(parts) < 3 || strings.TrimSpace(parts[2]) == "" {
+			s.emitSystem("usage: /msg <address|name> <text>")
+			return nil
+		}
+		if err := s.sendPrivate(parts[1], strings.TrimSpace(parts[2])); err != nil {
+			s.emitSystem("private message not sent: %v", err)
+		}
+		return nil
+	case cmd == "/send" || strings.HasPrefix(cmd, "/send "):
+		filePath := strings.TrimSpace(strings.TrimPrefix(cmd, "/send"))
+		if filePath == "" {
+			s.emitSystem("usage: /send <path>")
+			return nil
+		}
+		if err := s.sendFile(filePath); err != nil {
+			s.emitSystem("file not sent: %v", err)
+		}
+		return nil
+	case cmd == "/accept" || strings.HasPrefix(cmd, "/accept "):
+		s.handleAccept(strings.Fields(cmd)[1:])
+		return nil
+	case cmd == "/unsend":
+		ref := s.lastSentMessage()
+		if ref == "" {
+			s.emitSystem("nothing to unsend")
+			return nil
+		}
+		if err := s.broadcastMessage(Message{Type: deleteMsg, Ref: ref}); err != nil {
+			return err
+		}
+		s.forgetSentMessage(ref)
+		return nil
+	case strings.HasPrefix(cmd, "/kick"):
+		parts := strings.Fields(cmd)
+		if len(parts) != 2 {
+			s.emitSystem("usage: /kick <address|name>")
+			return nil
+		}
+		if err := s.kick(parts[1]); err != nil {
+			s.emitSystem("%v", err)
+		}
+		return nil
+	case cmd == "/muted":
+		s.emitSystem("%s", s.mutedSummary())
+		return nil
+	case cmd == "/mute" || strings.HasPrefix(cmd, "/mute "), cmd == "/unmute" || strings.HasPrefix(cmd, "/unmute "):
+		verb, target, _ := strings.Cut(cmd, " ")
+		if target = strings.TrimSpace(target); target == "" {
+			s.emitSystem("usage: %s <address|name>", verb)
+			return nil
+		}
+		apply := s.mute
+		if verb == "/unmute" {
+			apply = s.unmute
+		}
+		if err := apply(target); err != nil {
+			s.emitSystem("%v", err)
+		}
+		return nil
+	case strings.HasPrefix(cmd, "/rebind"):
 		parts := strings.Fields(cmd)
 		if len(parts) != 2 {
-			s.emitSystem("usage: /group <name>")
+			s.emitSystem("usage: /rebind <address>")
+			return nil
+		}
+		previous, err := s.rebind(parts[1])
+		if err != nil {
+			s.emitSystem("%v", err)
+			return nil
+		}
+		s.announceEndpoint(previous)
+		return nil
+	case strings.HasPrefix(cmd, "/group"):
+		parts := strings.Fields(cmd)
+		if len(parts) < 2 {
+			s.emitSystem("usage: /group <name> | /group delete <name> | /group rename <old> <new>")
+			return nil
+		}
+		if s.cfg.Ephemeral {
+			s.emitSystem("config saving is disabled in ephemeral mode")
 			return nil
 		}
 		if s.store == nil {
 			s.emitSystem("config saving is not available")
 			return nil
 		}
+		switch {
+		case parts[1] == "delete" && len(parts) == 3:
+			s.deleteGroup(parts[2])
+			return nil
+		case parts[1] == "rename" && len(parts) == 4:
+			s.renameGroup(parts[2], parts[3])
+			return nil
+		case len(parts) != 2:
+			s.emitSystem("usage: /group <name> | /group delete <name> | /group rename <old> <new>")
+			return nil
+		}
 		groupName := parts[1]
-		active := s.activeAddrs()
-		pending := s.pendingAddrs()
-		snapshot := config.Snapshot(s.cfg.Name, s.cfg.Listen, s.cfg.Secret, active, pending)
+		snapshot := s.snapshot()
 		if err := s.store.Save(groupName, snapshot); err != nil {
 			s.emitSystem("failed to save config: %v", err)
 		} else {
 			s.emitSystem("saved config %q with %d peers", groupName, len(snapshot.Peers))
 		}
 		return nil
+	case cmd == "/save":
+		if s.cfg.Ephemeral {
+			s.emitSystem("config saving is disabled in ephemeral mode")
+			return nil
+		}
+		if s.store == nil {
+			s.emitSystem("config saving is not available")
+			return nil
+		}
+		s.saveDefault()
+		return nil
 	case strings.HasPrefix(cmd, "/peer"):
 		parts := strings.Fields(cmd)
 		if len(parts) < 2 {
@@ -56,26 +244,25 @@ func (s *session) handleCommand(cmd string) error {
 			return nil
 		}
 
-		contacted := 0
-		for _, raw := range parts[1:] {
-			addr, err := s.resolveAddr(raw)
-			if err != nil {
-				s.emitSystem("failed to resolve %s: %v", raw, err)
-				continue
+		// Resolves and retries can take a while, so contact peers off the input path.
+		go func(targets []string) {
+			var contacted atomic.Int32
+			forEachLimited(targets, contactWorkers, func(raw string) {
+				if err := s.addPeer(raw); err != nil {
+					if !errors.Is(err, errRetryInFlight) {
+						s.emitSystem("%v", err)
+					}
+					return
+				}
+				contacted.Add(1)
+			})
+			if n := contacted.Load(); n > 0 {
+				s.emitSystem("sent join to %d peer(s)", n)
 			}
-			s.markPending(addr)
-			if err := s.sendDirect(addr, joinMsg, s.buildJoinPayload()); err != nil {
-				s.emitSystem("failed to reach %s: %v", raw, err)
-				_ = s.dropPeer(addr, fmt.Sprintf("failed: %v", err))
-				continue
-			}
-			s.markActive(addr, "")
-			contacted++
-		}
-
-		if contacted > 0 {
-			s.emitSystem("sent join to %d peer(s)", contacted)
-		}
+		}(parts[1:])
+		return nil
+	case cmd == "/reload":
+		s.reloadConfig()
 		return nil
 	case strings.HasPrefix(cmd, "/switch"):
 		parts := strings.Fields(cmd)
@@ -83,6 +270,10 @@ func (s *session) handleCommand(cmd string) error {
 			s.emitSystem("usage: /switch <config>")
 			return nil
 		}
+		if s.cfg.Ephemeral {
+			s.emitSystem("config switching is disabled in ephemeral mode")
+			return nil
+		}
 		if s.store == nil {
 			s.emitSystem("config switching is not available")
 			return nil
@@ -97,6 +288,61 @@ func (s *session) handleCommand(cmd string) error {
 	}
 }
 
+// snapshot captures the session's identity, keys, and known peers as a
+// profile to save.
+func (s *session) snapshot() config.Config {
+	snapshot := config.Snapshot(s.cfg.Name, s.cfg.Listen, s.cfg.Secret, s.activeAddrs(), s.pendingAddrs())
+	snapshot.Cipher = s.cfg.Cipher
+	snapshot.KDF = s.cfg.KDF
+	snapshot.GroupID = s.cfg.GroupID
+	snapshot.Blocked = s.blockedAddrs()
+	snapshot.PeerNames = s.peerNames()
+	return snapshot
+}
+
+// saveDefault writes the session snapshot into the default profile, keeping
+// its other settings and adding this session's peers to the ones saved.
+func (s *session) saveDefault() {
+	snapshot := s.snapshot()
+	base, _ := s.store.Default()
+	saved := config.Merge(base, snapshot)
+	saved.Secret = snapshot.Secret
+	saved.Cipher = snapshot.Cipher
+	saved.KDF = snapshot.KDF
+	saved.GroupID = snapshot.GroupID
+	if err := s.store.SaveDefault(saved); err != nil {
+		s.emitSystem("failed to save default config: %v", err)
+		return
+	}
+	s.emitSystem("saved default config with %d peers (%d from this session)", len(saved.Peers), len(snapshot.Peers))
+}
+
+// deleteGroup removes a saved profile from the store.
+func (s *session) deleteGroup(name string) {
+	if err := s.store.Delete(name); err != nil {
+		s.emitSystem("failed to delete config: %v", err)
+		return
+	}
+	if strings.EqualFold(strings.TrimSpace(name), s.cfg.Profile) {
+		s.emitSystem("deleted config %q; this session keeps running with its settings", name)
+		return
+	}
+	s.emitSystem("deleted config %q", name)
+}
+
+// renameGroup renames a saved profile, following it when it is the active one
+// so /reload keeps working.
+func (s *session) renameGroup(oldName, newName string) {
+	if err := s.store.Rename(oldName, newName); err != nil {
+		s.emitSystem("failed to rename config: %v", err)
+		return
+	}
+	if strings.TrimSpace(oldName) == s.cfg.Profile {
+		s.cfg.Profile = strings.TrimSpace(newName)
+	}
+	s.emitSystem("renamed config %q to %q", oldName, newName)
+}
+
 // switchConfig loads a saved profile and applies it to the running session.
 func (s *session) switchConfig(name string) error {
 	trimmed := strings.TrimSpace(name)
@@ -111,14 +357,9 @@ func (s *session) switchConfig(name string) error {
 		return nil
 	}
 
-	if cfg.Listen != "" && cfg.Listen != s.cfg.Listen {
-		s.emitSystem("config %q uses listen %s; restart required to apply (current %s)", trimmed, cfg.Listen, s.cfg.Listen)
-		return nil
-	}
-
 	var newCipher packetCipher
 	if cfg.Secret != "" {
-		newCipher, err = newAESCipher(cfg.Secret)
+		newCipher, err = newPacketCipher(cfg)
 		if err != nil {
 			s.emitSystem("config %q secret rejected: %v", trimmed, err)
 			return nil
@@ -142,10 +383,28 @@ func (s *session) switchConfig(name string) error {
 		}
 	}
 
+	if cfg.Listen != "" && cfg.Listen != s.cfg.Listen {
+		// The leave has to go out from the old socket for peers to match it,
+		// so a failed rebind takes it back with a fresh join.
+		if _, err := s.rebind(cfg.Listen); err != nil {
+			s.emitSystem("config %q not applied: %v", trimmed, err)
+			if known > 0 {
+				if err := s.broadcast(joinMsg, s.buildJoinPayload()); err != nil {
+					s.emitSystem("failed to announce presence: %v", err)
+				}
+			}
+			return nil
+		}
+	}
+
 	prevSecret := s.cfg.Secret
 	s.cfg.Secret = cfg.Secret
+	s.cfg.Cipher = cfg.Cipher
+	s.cfg.KDF = cfg.KDF
+	s.cfg.GroupID = cfg.GroupID
 	if s.transport != nil {
 		s.transport.setCipher(newCipher)
+		s.clearAuthRejects("")
 		s.transport.setName(cfg.Name)
 	}
 
@@ -176,8 +435,10 @@ func (s *session) switchConfig(name string) error {
 	contacted := 0
 	for _, addr := range resolved {
 		s.markPending(addr)
-		if err := s.sendDirect(addr, joinMsg, joinPayload); err != nil {
-			s.emitSystem("failed to reach %s: %v", addr, err)
+		if err := s.sendDirectRetry(addr, joinMsg, joinPayload); err != nil {
+			if errors.Is(err, errRetryInFlight) {
+				continue
+			}
 			_ = s.dropPeer(addr, fmt.Sprintf("failed: %v", err))
 			continue
 		}
@@ -200,6 +461,7 @@ func (s *session) switchConfig(name string) error {
 		s.emitSystem("%s", strings.Join(summary, "\n"))
 	}
 	s.cfg = cfg
+	s.emitFilters()
 	s.recordEvent("switched to %q", trimmed)
 
 	return nil
@@ -0,0 +1,248 @@
+package chat
+
+import (
+	"encoding/json"
+	"net"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"yap/internal/config"
+)
+
+// readMessage reads one datagram from conn and decodes it.
+func readMessage(t *testing.T, conn net.PacketConn) Message {
+	t.Helper()
+	buf := make([]byte, 64<<10)
+	_ = conn.SetReadDeadline(time.Now().Add(testTimeout))
+	n, _, err := conn.ReadFrom(buf)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	var msg Message
+	if err := json.Unmarshal(buf[:n], &msg); err != nil {
+		t.Fatalf("decode %q: %v", buf[:n], err)
+	}
+	return msg
+}
+
+func TestPeerCommandSendsJoinPayload(t *testing.T) {
+	s := newTestSession(t, config.Config{Name: "alice"})
+	peer, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer peer.Close()
+
+	if err := s.handleInput("/peer " + peer.LocalAddr().String()); err != nil {
+		t.Fatal(err)
+	}
+	msg := readMessage(t, peer)
+	if msg.Type != joinMsg {
+		t.Fatalf("got %s, want join", msg.Type)
+	}
+	var payload joinPayload
+	if err := json.Unmarshal([]byte(msg.Body), &payload); err != nil {
+		t.Fatalf("join body %q: %v", msg.Body, err)
+	}
+	if payload.Member.Name != "alice" || payload.Member.Addr != s.localAddr {
+		t.Fatalf("join announces %+v, want alice at %s", payload.Member, s.localAddr)
+	}
+	waitEvent(t, s, systemContaining("sent join to 1 peer(s)"))
+}
+
+func TestPeerCommandUsage(t *testing.T) {
+	s := newTestSession(t, config.Config{})
+	drainEvents(s)
+	if err := s.handleInput("/peer"); err != nil {
+		t.Fatal(err)
+	}
+	waitEvent(t, s, systemContaining("usage: /peer"))
+}
+
+func TestGroupCommandSavesRenamesAndDeletes(t *testing.T) {
+	store, err := config.Load(filepath.Join(t.TempDir(), "yap.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := newTestSessionWith(t, sessionOptions{config: config.Config{Name: "alice"}, store: store})
+	peer := listenPeer(t, s, "bob")
+
+	if err := s.handleInput("/group team"); err != nil {
+		t.Fatal(err)
+	}
+	saved, ok := store.Load("team")
+	if !ok {
+		t.Fatal("group not saved")
+	}
+	if !slices.Contains(saved.Peers, peer.LocalAddr().String()) {
+		t.Fatalf("saved peers %v lack %s", saved.Peers, peer.LocalAddr())
+	}
+
+	if err := s.handleInput("/group rename team crew"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := store.Load("crew"); !ok {
+		t.Fatal("group not renamed")
+	}
+	if err := s.handleInput("/group delete crew"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := store.Load("crew"); ok {
+		t.Fatal("group not deleted")
+	}
+}
+
+func TestGroupCommandRefusedWhenEphemeral(t *testing.T) {
+	store, err := config.Load(filepath.Join(t.TempDir(), "yap.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := newTestSessionWith(t, sessionOptions{config: config.Config{Ephemeral: true}, store: store})
+	if err := s.handleInput("/group team"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := store.Load("team"); ok {
+		t.Fatal("ephemeral session saved a group")
+	}
+	waitEvent(t, s, systemContaining("ephemeral"))
+}
+
+func TestSwitchRejoinsWhenRebindFails(t *testing.T) {
+	store, err := config.Load(filepath.Join(t.TempDir(), "yap.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	taken, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer taken.Close()
+	if err := store.Save("busy", config.Config{Name: "alice", Listen: taken.LocalAddr().String()}); err != nil {
+		t.Fatal(err)
+	}
+	s := newTestSessionWith(t, sessionOptions{config: config.Config{Name: "alice"}, store: store})
+	peer := listenPeer(t, s, "bob")
+	listen := s.cfg.Listen
+
+	if err := s.handleInput("/switch busy"); err != nil {
+		t.Fatal(err)
+	}
+	if msg := readMessage(t, peer); msg.Type != leaveMsg {
+		t.Fatalf("got %s, want leave", msg.Type)
+	}
+	if msg := readMessage(t, peer); msg.Type != joinMsg {
+		t.Fatalf("got %s after failed rebind, want join", msg.Type)
+	}
+	waitEvent(t, s, systemContaining("not applied"))
+	if s.cfg.Listen != listen {
+		t.Fatalf("listen changed to %s after failed rebind", s.cfg.Listen)
+	}
+}
+
+func TestSwitchRefusedWhenEphemeral(t *testing.T) {
+	store, err := config.Load(filepath.Join(t.TempDir(), "yap.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Save("other", config.Config{Name: "bob"}); err != nil {
+		t.Fatal(err)
+	}
+	s := newTestSessionWith(t, sessionOptions{config: config.Config{Name: "alice", Ephemeral: true}, store: store})
+	if err := s.handleInput("/switch other"); err != nil {
+		t.Fatal(err)
+	}
+	waitEvent(t, s, systemContaining("disabled in ephemeral mode"))
+	if s.cfg.Name != "alice" {
+		t.Fatalf("ephemeral session switched to %q", s.cfg.Name)
+	}
+}
+
+func TestEditCommandRevisesLastMessage(t *testing.T) {
+	s := newTestSession(t, config.Config{Name: "alice"})
+	peer := listenPeer(t, s, "bob")
+	if err := s.handleInput("/edit too soon"); err != nil {
+		t.Fatal(err)
+	}
+	waitEvent(t, s, systemContaining("nothing to edit yet"))
+
+	if err := s.handleInput("helo"); err != nil {
+		t.Fatal(err)
+	}
+	sent := readMessage(t, peer)
+	if err := s.handleInput("/edit hello"); err != nil {
+		t.Fatal(err)
+	}
+	edit := readMessage(t, peer)
+	if edit.Type != editMsg || edit.Ref != sent.ID || edit.Body != "hello" {
+		t.Fatalf("got %+v, want an edit of %s", edit, sent.ID)
+	}
+}
+
+func TestUnsendRetractsLastMessageOnce(t *testing.T) {
+	s := newTestSession(t, config.Config{Name: "alice"})
+	peer := listenPeer(t, s, "bob")
+	if err := s.handleInput("oops"); err != nil {
+		t.Fatal(err)
+	}
+	sent := readMessage(t, peer)
+	if err := s.handleInput("/unsend"); err != nil {
+		t.Fatal(err)
+	}
+	if msg := readMessage(t, peer); msg.Type != deleteMsg || msg.Ref != sent.ID {
+		t.Fatalf("got %+v, want a delete of %s", msg, sent.ID)
+	}
+	drainEvents(s)
+	if err := s.handleInput("/unsend"); err != nil {
+		t.Fatal(err)
+	}
+	waitEvent(t, s, systemContaining("nothing to unsend"))
+}
+
+func TestSaveCommandMergesIntoDefault(t *testing.T) {
+	store, err := config.Load(filepath.Join(t.TempDir(), "yap.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.SaveDefault(config.Config{Name: "alice", Peers: []string{"10.0.0.9:4000"}, TimeFormat: "15:04", Secret: "old"}); err != nil {
+		t.Fatal(err)
+	}
+	s := newTestSessionWith(t, sessionOptions{config: config.Config{Name: "alice", Secret: "new"}, store: store})
+	peer := listenPeer(t, s, "bob")
+
+	if err := s.handleInput("/save"); err != nil {
+		t.Fatal(err)
+	}
+	waitEvent(t, s, systemContaining("saved default config with 2 peers (1 from this session)"))
+	saved, ok := store.Default()
+	if !ok {
+		t.Fatal("default profile missing")
+	}
+	for _, addr := range []string{"10.0.0.9:4000", peer.LocalAddr().String()} {
+		if !slices.Contains(saved.Peers, addr) {
+			t.Fatalf("saved peers %v lack %s", saved.Peers, addr)
+		}
+	}
+	if saved.TimeFormat != "15:04" {
+		t.Fatalf("time format = %q, want the default's other settings kept", saved.TimeFormat)
+	}
+	if saved.Secret != "new" {
+		t.Fatalf("secret = %q, want the session's secret", saved.Secret)
+	}
+}
+
+func TestSaveCommandRefusedWhenEphemeral(t *testing.T) {
+	store, err := config.Load(filepath.Join(t.TempDir(), "yap.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := newTestSessionWith(t, sessionOptions{config: config.Config{Name: "alice", Ephemeral: true}, store: store})
+	if err := s.handleInput("/save"); err != nil {
+		t.Fatal(err)
+	}
+	waitEvent(t, s, systemContaining("disabled in ephemeral mode"))
+	if _, ok := store.Default(); ok {
+		t.Fatal("ephemeral session saved the default profile")
+	}
+}
@@ -0,0 +1,48 @@
+package chat
+
+import (
+	"bytes"
+	"compress/flate"
+	"errors"
+	"io"
+)
+
+const (
+	// compressThreshold is the body size above which compression is attempted.
+	compressThreshold = 512
+	// maxInflatedBody bounds how large a compressed body may expand.
+	maxInflatedBody = 4 << 20
+)
+
+// compressBody deflates data, reporting false when that does not shrink it.
+func compressBody(data []byte) ([]byte, bool) {
+	var buf bytes.Buffer
+	w, err := flate.NewWriter(&buf, flate.BestSpeed)
+	if err != nil {
+		return nil, false
+	}
+	if _, err := w.Write(data); err != nil {
+		return nil, false
+	}
+	if err := w.Close(); err != nil {
+		return nil, false
+	}
+	if buf.Len() >= len(data) {
+		return nil, false
+	}
+	return buf.Bytes(), true
+}
+
+// inflateBody reverses compressBody, refusing output beyond maxInflatedBody.
+func inflateBody(data []byte) ([]byte, error) {
+	r := flate.NewReader(bytes.NewReader(data))
+	defer r.Close()
+	out, err := io.ReadAll(io.LimitReader(r, maxInflatedBody+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(out) > maxInflatedBody {
+		return nil, errors.New("decompressed body too large")
+	}
+	return out, nil
+}
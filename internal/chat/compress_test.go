@@ -0,0 +1,87 @@
+package chat
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"yap/internal/config"
+)
+
+// bigPeersPayload returns a peers payload listing n members.
+func bigPeersPayload(t testing.TB, n int) string {
+	t.Helper()
+	var payload peersPayload
+	for i := range n {
+		payload.Peers = append(payload.Peers, memberInfo{Addr: fmt.Sprintf("10.0.%d.%d:4000", i/250, i%250+1), Name: fmt.Sprintf("peer-%d", i)})
+	}
+	data, err := json.Marshal(payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestCompressedPeersPayloadRoundTrip(t *testing.T) {
+	body := bigPeersPayload(t, 100)
+	cipher, err := newPacketCipher(config.Config{Secret: "hunter22"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, c := range map[string]packetCipher{"plain": nil, "encrypted": cipher} {
+		tr := newTransport("alice", nil, c, time.Minute)
+		_, raw, err := tr.prepare("alice", peersMsg, body)
+		if err != nil {
+			t.Fatalf("%s: prepare: %v", name, err)
+		}
+		if len(raw) >= len(body) {
+			t.Errorf("%s: %d byte datagram for a %d byte body", name, len(raw), len(body))
+		}
+		var msg Message
+		if err := json.Unmarshal(raw, &msg); err != nil {
+			t.Fatal(err)
+		}
+		if !msg.Compressed {
+			t.Fatalf("%s: large payload not compressed", name)
+		}
+		if ok, _, err := tr.verifyAndDecrypt(&msg); !ok || err != nil {
+			t.Fatalf("%s: verifyAndDecrypt = %v, %v", name, ok, err)
+		}
+		if msg.Body != body || msg.Compressed {
+			t.Fatalf("%s: body did not survive the round trip", name)
+		}
+	}
+}
+
+func TestSmallBodiesStayUncompressed(t *testing.T) {
+	tr := newTransport("alice", nil, nil, time.Minute)
+	msg, _, err := tr.prepare("alice", chatMsg, "hello")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.Compressed || msg.Body != "hello" {
+		t.Fatalf("got %+v", msg)
+	}
+}
+
+func TestInflateBodyIsBounded(t *testing.T) {
+	packed, ok := compressBody(bytes.Repeat([]byte{'a'}, maxInflatedBody+1))
+	if !ok {
+		t.Fatal("repetitive data did not compress")
+	}
+	if _, err := inflateBody(packed); err == nil {
+		t.Fatal("oversized body inflated")
+	}
+}
+
+func BenchmarkCompressPeersPayload(b *testing.B) {
+	body := []byte(bigPeersPayload(b, 100))
+	b.SetBytes(int64(len(body)))
+	for b.Loop() {
+		if _, ok := compressBody(body); !ok {
+			b.Fatal("payload did not compress")
+		}
+	}
+}
@@ -0,0 +1,224 @@
+package chat
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultDedupWindow is how long message IDs are remembered for duplicate suppression.
+	defaultDedupWindow = 5 * time.Minute
+	// maxPersistedSeen caps how many IDs are written to the seen-set file.
+	maxPersistedSeen = 10000
+	// maxHolders caps how many peers are remembered as having one message.
+	maxHolders = 64
+)
+
+// dedupCache remembers message IDs for a bounded time window.
+type dedupCache struct {
+	window time.Duration
+	seen   sync.Map // id -> time.Time first seen
+	// now supplies the time IDs are stamped and expired against.
+	now func() time.Time
+
+	holdersMu  sync.Mutex
+	holders    map[string]map[string]struct{} // id -> peers known to have it
+	reforwards map[string]int                 // id -> times forwarded again
+}
+
+// newDedupCache builds a cache that forgets IDs older than window.
+func newDedupCache(window time.Duration) *dedupCache {
+	if window <= 0 {
+		window = defaultDedupWindow
+	}
+	return &dedupCache{window: window, now: time.Now}
+}
+
+// loadOrStore records id and reports whether it was already seen within the window.
+func (d *dedupCache) loadOrStore(id string) bool {
+	now := d.now()
+	prev, loaded := d.seen.LoadOrStore(id, now)
+	if !loaded {
+		return false
+	}
+	if now.Sub(prev.(time.Time)) <= d.window {
+		return true
+	}
+	// The previous sighting has expired; treat this as a fresh message.
+	d.seen.Store(id, now)
+	return false
+}
+
+// store marks id as seen now.
+func (d *dedupCache) store(id string) {
+	d.seen.Store(id, d.now())
+}
+
+// size counts the IDs currently remembered.
+func (d *dedupCache) size() int {
+	n := 0
+	d.seen.Range(func(_, _ any) bool {
+		n++
+		return true
+	})
+	return n
+}
+
+// noteHolders records peers known to already have message id, so relays
+// can skip them. Unknown or expired IDs are ignored.
+func (d *dedupCache) noteHolders(id string, addrs ...string) {
+	if id == "" {
+		return
+	}
+	if _, ok := d.seen.Load(id); !ok {
+		return
+	}
+	d.holdersMu.Lock()
+	defer d.holdersMu.Unlock()
+	if d.holders == nil {
+		d.holders = make(map[string]map[string]struct{})
+	}
+	set := d.holders[id]
+	if set == nil {
+		set = make(map[string]struct{})
+		d.holders[id] = set
+	}
+	for _, addr := range addrs {
+		if addr != "" && len(set) < maxHolders {
+			set[addr] = struct{}{}
+		}
+	}
+}
+
+// allowReforward reports whether a duplicate of id may be forwarded once
+// more: only within grace of its first sighting and at most limit times, so
+// duplicates can never circulate indefinitely.
+func (d *dedupCache) allowReforward(id string, grace time.Duration, limit int) bool {
+	first, ok := d.seen.Load(id)
+	if !ok || d.now().Sub(first.(time.Time)) > grace {
+		return false
+	}
+	d.holdersMu.Lock()
+	defer d.holdersMu.Unlock()
+	if d.reforwards[id] >= limit {
+		return false
+	}
+	if d.reforwards == nil {
+		d.reforwards = make(map[string]int)
+	}
+	d.reforwards[id]++
+	return true
+}
+
+// holding returns the peers known to already have message id.
+func (d *dedupCache) holding(id string) map[string]struct{} {
+	d.holdersMu.Lock()
+	defer d.holdersMu.Unlock()
+	return maps.Clone(d.holders[id])
+}
+
+// sweep drops entries older than the window.
+func (d *dedupCache) sweep() {
+	cutoff := d.now().Add(-d.window)
+	d.seen.Range(func(key, value any) bool {
+		if value.(time.Time).Before(cutoff) {
+			d.seen.Delete(key)
+		}
+		return true
+	})
+	d.holdersMu.Lock()
+	for id := range d.holders {
+		if _, ok := d.seen.Load(id); !ok {
+			delete(d.holders, id)
+		}
+	}
+	for id := range d.reforwards {
+		if _, ok := d.seen.Load(id); !ok {
+			delete(d.reforwards, id)
+		}
+	}
+	d.holdersMu.Unlock()
+}
+
+// run sweeps expired entries periodically until stop closes.
+func (d *dedupCache) run(stop <-chan struct{}) {
+	interval := d.window / 2
+	if interval < time.Second {
+		interval = time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			d.sweep()
+		}
+	}
+}
+
+// save writes the IDs still inside the window to path, newest first and capped.
+func (d *dedupCache) save(path string) error {
+	cutoff := d.now().Add(-d.window)
+	type seenEntry struct {
+		id string
+		at time.Time
+	}
+	var entries []seenEntry
+	d.seen.Range(func(key, value any) bool {
+		if at := value.(time.Time); at.After(cutoff) {
+			entries = append(entries, seenEntry{id: key.(string), at: at})
+		}
+		return true
+	})
+	sort.Slice(entries, func(i, j int) bool { return entries[i].at.After(entries[j].at) })
+	if len(entries) > maxPersistedSeen {
+		entries = entries[:maxPersistedSeen]
+	}
+	data := make(map[string]int64, len(entries))
+	for _, entry := range entries {
+		data[entry.id] = entry.at.Unix()
+	}
+	bytes, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("encode seen set: %w", err)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, bytes, 0o600); err != nil {
+		return fmt.Errorf("write seen set: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		_ = os.Remove(tmp)
+		return fmt.Errorf("persist seen set: %w", err)
+	}
+	return nil
+}
+
+// load restores IDs from path that are still inside the window.
+func (d *dedupCache) load(path string) error {
+	bytes, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return fmt.Errorf("read seen set: %w", err)
+	}
+	var data map[string]int64
+	if err := json.Unmarshal(bytes, &data); err != nil {
+		return fmt.Errorf("parse seen set: %w", err)
+	}
+	cutoff := d.now().Add(-d.window)
+	for id, unix := range data {
+		if at := time.Unix(unix, 0); at.After(cutoff) {
+			d.seen.Store(id, at)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+package chat
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"yap/internal/config"
+)
+
+func TestDedupSuppressesWithinWindow(t *testing.T) {
+	d := newDedupCache(time.Minute)
+	if d.loadOrStore("a") {
+		t.Fatal("first sighting reported as duplicate")
+	}
+	if !d.loadOrStore("a") {
+		t.Fatal("second sighting not reported as duplicate")
+	}
+}
+
+func TestDedupForgetsExpiredIDs(t *testing.T) {
+	d := newDedupCache(time.Minute)
+	d.seen.Store("old", time.Now().Add(-2*time.Minute))
+	if d.loadOrStore("old") {
+		t.Fatal("expired ID still treated as a duplicate")
+	}
+	if !d.loadOrStore("old") {
+		t.Fatal("re-recorded ID not treated as a duplicate")
+	}
+}
+
+func TestDedupSweepDropsExpiredEntries(t *testing.T) {
+	d := newDedupCache(time.Minute)
+	d.seen.Store("old", time.Now().Add(-2*time.Minute))
+	d.store("fresh")
+	d.noteHolders("old", "10.0.0.2:4000")
+	d.noteHolders("fresh", "10.0.0.2:4000")
+	d.sweep()
+
+	if d.size() != 1 {
+		t.Fatalf("size = %d after sweep, want 1", d.size())
+	}
+	if _, ok := d.seen.Load("fresh"); !ok {
+		t.Fatal("sweep dropped a fresh ID")
+	}
+	if len(d.holding("old")) != 0 || len(d.holding("fresh")) != 1 {
+		t.Fatal("sweep did not drop holders of expired IDs only")
+	}
+}
+
+func TestDedupDefaultWindow(t *testing.T) {
+	if d := newDedupCache(0); d.window != defaultDedupWindow {
+		t.Fatalf("window = %v, want %v", d.window, defaultDedupWindow)
+	}
+}
+
+func TestSessionUsesConfiguredDedupWindow(t *testing.T) {
+	s := newTestSession(t, config.Config{DedupWindow: "30s"})
+	if got := s.transport.seen.window; got != 30*time.Second {
+		t.Fatalf("window = %v, want 30s", got)
+	}
+	bad := newTestSession(t, config.Config{DedupWindow: "soon"})
+	if got := bad.transport.seen.window; got != defaultDedupWindow {
+		t.Fatalf("window = %v for an invalid setting, want the default", got)
+	}
+	waitEvent(t, bad, systemContaining("dedup window"))
+}
+
+func TestSeenSetSaveAndLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "seen")
+	d := newDedupCache(time.Minute)
+	d.store("fresh")
+	d.seen.Store("stale", time.Now().Add(-2*time.Minute))
+	if err := d.save(path); err != nil {
+		t.Fatal(err)
+	}
+
+	restored := newDedupCache(time.Minute)
+	if err := restored.load(path); err != nil {
+		t.Fatal(err)
+	}
+	if !restored.loadOrStore("fresh") {
+		t.Fatal("saved ID not restored")
+	}
+	if restored.size() != 1 {
+		t.Fatalf("restored %d IDs, want only the fresh one", restored.size())
+	}
+	if err := newDedupCache(time.Minute).load(filepath.Join(t.TempDir(), "missing")); err != nil {
+		t.Fatalf("missing file: %v", err)
+	}
+}
+
+func TestPersistSeenSurvivesRestart(t *testing.T) {
+	store, err := config.Load(filepath.Join(t.TempDir(), "yap.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := sessionOptions{config: config.Config{PersistSeen: true, Profile: "team"}, store: store}
+	first := newTestSessionWith(t, opts)
+	first.transport.seen.store("delivered-before")
+	if _, err := first.shutdown(); err != nil {
+		t.Fatal(err)
+	}
+
+	second := newTestSessionWith(t, opts)
+	if !second.transport.seen.loadOrStore("delivered-before") {
+		t.Fatal("restarted session forgot a seen ID")
+	}
+}
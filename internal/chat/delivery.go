@@ -0,0 +1,86 @@
+package chat
+
+import (
+	"fmt"
+	"sync"
+)
+
+// maxTrackedDeliveries bounds how many sent messages keep a delivery count.
+const maxTrackedDeliveries = 64
+
+// delivery counts acknowledgements for one sent chat message. Acks are
+// hop-by-hop, so only the peers the message was sent to directly count.
+type delivery struct {
+	acked map[string]bool
+	count int
+}
+
+// deliveryTracker keeps delivery counts for the most recently sent messages.
+type deliveryTracker struct {
+	mu    sync.Mutex
+	byID  map[string]*delivery
+	order []string
+}
+
+// trackDelivery starts counting acks for id from targets and reports the
+// initial 0/N status. Acks only flow in reliable mode, so it does nothing
+// otherwise.
+func (s *session) trackDelivery(id string, targets []memberEndpoint) {
+	if !s.cfg.Reliable || id == "" || len(targets) == 0 {
+		return
+	}
+	d := &delivery{acked: make(map[string]bool, len(targets))}
+	for _, target := range targets {
+		d.acked[target.key] = false
+	}
+	s.deliveries.mu.Lock()
+	if s.deliveries.byID == nil {
+		s.deliveries.byID = make(map[string]*delivery)
+	}
+	if _, ok := s.deliveries.byID[id]; !ok {
+		s.deliveries.order = append(s.deliveries.order, id)
+	}
+	s.deliveries.byID[id] = d
+	for len(s.deliveries.order) > maxTrackedDeliveries {
+		delete(s.deliveries.byID, s.deliveries.order[0])
+		s.deliveries.order = s.deliveries.order[1:]
+	}
+	status := d.status()
+	s.deliveries.mu.Unlock()
+	s.emit(Message{Type: deliveryMsg, Ref: id, Body: status})
+}
+
+// noteDelivery counts an ack for id from peer and reports the new status.
+func (s *session) noteDelivery(id, peer string) {
+	s.deliveries.mu.Lock()
+	d, ok := s.deliveries.byID[id]
+	if !ok {
+		s.deliveries.mu.Unlock()
+		return
+	}
+	acked, expected := d.acked[peer]
+	if !expected || acked {
+		s.deliveries.mu.Unlock()
+		return
+	}
+	d.acked[peer] = true
+	d.count++
+	status := d.status()
+	s.deliveries.mu.Unlock()
+	s.emit(Message{Type: deliveryMsg, Ref: id, Body: status})
+}
+
+// status formats the delivery count shown beside a sent message.
+func (d *delivery) status() string {
+	return fmt.Sprintf("delivered %d/%d", d.count, len(d.acked))
+}
+
+// applyDelivery updates the delivery status shown on one of our messages.
+func (m *bubbleModel) applyDelivery(msg Message) {
+	entry := m.findEntry(msg.Ref)
+	if entry == nil {
+		return
+	}
+	entry.status = msg.Body
+	m.refreshPin(*entry)
+}
@@ -0,0 +1,60 @@
+package chat
+
+import (
+	"strings"
+	"testing"
+
+	"yap/internal/config"
+)
+
+func TestDeliveryCountsEachPeerOnce(t *testing.T) {
+	s := newTestSession(t, config.Config{Name: "alice", Reliable: true})
+	targets := []memberEndpoint{{key: "10.0.0.2:4000"}, {key: "10.0.0.3:4000"}}
+
+	s.trackDelivery("m1", targets)
+	s.noteDelivery("m1", "10.0.0.2:4000")
+	s.noteDelivery("m1", "10.0.0.2:4000")
+	s.noteDelivery("m1", "10.0.0.9:4000")
+	s.noteDelivery("other", "10.0.0.3:4000")
+	s.noteDelivery("m1", "10.0.0.3:4000")
+
+	var got []string
+	for _, ev := range drainEvents(s) {
+		if ev.Type == deliveryMsg && ev.Ref == "m1" {
+			got = append(got, ev.Body)
+		}
+	}
+	if want := "delivered 0/2,delivered 1/2,delivered 2/2"; strings.Join(got, ",") != want {
+		t.Fatalf("statuses = %q, want %s", got, want)
+	}
+}
+
+func TestDeliveryStatusUpdatesSentBlock(t *testing.T) {
+	alice := newTestSession(t, config.Config{Name: "alice", Reliable: true})
+	bob := newTestSession(t, config.Config{Name: "bob", Reliable: true})
+	carol := newTestSession(t, config.Config{Name: "carol", Reliable: true})
+	connect(t, alice, bob)
+	connect(t, alice, carol)
+	drainEvents(alice)
+	ui := newBubbleModel("alice", nil, nil, uiOptionsFrom(alice.cfg))
+
+	if err := alice.handleInput("hello"); err != nil {
+		t.Fatal(err)
+	}
+	sent := waitEvent(t, alice, func(m Message) bool { return m.Type == chatMsg })
+	ui.Update(sent)
+	for {
+		ev := waitEvent(t, alice, func(m Message) bool { return m.Type == deliveryMsg && m.Ref == sent.ID })
+		ui.Update(ev)
+		if ev.Body == "delivered 2/2" {
+			break
+		}
+	}
+	view := ui.View()
+	if !strings.Contains(view, "delivered 2/2") || strings.Contains(view, "delivered 1/2") {
+		t.Fatalf("sender block not updated in place:\n%s", view)
+	}
+	if n := strings.Count(view, "hello"); n != 1 {
+		t.Fatalf("message rendered %d times:\n%s", n, view)
+	}
+}
@@ -0,0 +1,90 @@
+package chat
+
+import (
+	"encoding/json"
+	"net"
+	"slices"
+	"testing"
+	"time"
+
+	"yap/internal/config"
+)
+
+// expectSilence fails if conn receives a datagram within a short wait.
+func expectSilence(t *testing.T, conn net.PacketConn) {
+	t.Helper()
+	_ = conn.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+	if n, _, err := conn.ReadFrom(make([]byte, 64<<10)); err == nil {
+		t.Fatalf("unexpected datagram of %d bytes", n)
+	}
+}
+
+// expectNoKind fails if conn receives a datagram of any of kinds within a
+// short wait. Other traffic, such as membership gossip, is ignored.
+func expectNoKind(t *testing.T, conn net.PacketConn, kinds ...msgType) {
+	t.Helper()
+	buf := make([]byte, 64<<10)
+	_ = conn.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+	for {
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		var msg Message
+		if json.Unmarshal(buf[:n], &msg) == nil && slices.Contains(kinds, msg.Type) {
+			t.Fatalf("unexpected %s datagram", msg.Type)
+		}
+	}
+}
+
+func TestMsgReachesOnlyItsRecipient(t *testing.T) {
+	s := newTestSession(t, config.Config{Name: "alice"})
+	bob := listenPeer(t, s, "bob")
+	carol := listenPeer(t, s, "carol")
+
+	if err := s.handleInput("/msg bob psst"); err != nil {
+		t.Fatal(err)
+	}
+	msg := readMessage(t, bob)
+	if msg.Body != "psst" || msg.To != canonicalNetAddr(bob.LocalAddr()) {
+		t.Fatalf("bob got %+v", msg)
+	}
+	expectSilence(t, carol)
+
+	drainEvents(s)
+	if err := s.handleInput("/msg dave hi"); err != nil {
+		t.Fatal(err)
+	}
+	waitEvent(t, s, systemContaining("unknown peer dave"))
+}
+
+func TestPrivateMessagesAreNotRelayed(t *testing.T) {
+	s := newTestSession(t, config.Config{Name: "alice"})
+	bob := listenPeer(t, s, "bob")
+	carol := listenPeer(t, s, "carol")
+	msg := Message{ID: newMessageID(), Type: chatMsg, From: "bob", To: s.localAddr, Body: "psst"}
+	s.handleIncoming(msg, bob.LocalAddr(), []byte(`{}`), true)
+
+	waitEvent(t, s, func(m Message) bool { return m.Type == chatMsg && m.Body == "psst" })
+	// Bob gets an ack; carol gets nothing.
+	expectSilence(t, carol)
+}
+
+func TestExceptSkipsExcludedPeers(t *testing.T) {
+	s := newTestSession(t, config.Config{Name: "alice"})
+	bob := listenPeer(t, s, "bob")
+	carol := listenPeer(t, s, "carol")
+	dave := listenPeer(t, s, "dave")
+
+	if err := s.handleInput("/except carol,zed side note"); err != nil {
+		t.Fatal(err)
+	}
+	for _, conn := range []net.PacketConn{bob, dave} {
+		if msg := readMessage(t, conn); msg.Type != chatMsg || !msg.Direct {
+			t.Fatalf("got %+v, want a direct chat", msg)
+		}
+	}
+	expectSilence(t, carol)
+	waitEvent(t, s, systemContaining("no peer matches zed"))
+	waitEvent(t, s, systemContaining("sent to 2 peer(s)"))
+}
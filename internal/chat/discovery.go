@@ -0,0 +1,151 @@
+package chat
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"yap/internal/config"
+)
+
+const (
+	// discoverInterval is how often this node announces itself on the LAN.
+	discoverInterval = 10 * time.Second
+	// discoverRetry is how long a discovered address is left alone after it
+	// was contacted, so a peer that never answers is not hammered.
+	discoverRetry = time.Minute
+	// discoverService tags announcements so stray datagrams are ignored.
+	discoverService = "yap"
+	// maxAnnouncement bounds the announcement datagrams read from the group.
+	maxAnnouncement = 512
+)
+
+// announcement is the datagram multicast to advertise a node on the LAN. It
+// carries no name or message content; KeyID lets nodes with a different
+// secret skip each other without attempting a join.
+type announcement struct {
+	Service string `json:"service"`
+	Addr    string `json:"addr"`
+	KeyID   string `json:"keyId,omitempty"`
+}
+
+// discovery tracks the LAN discovery socket and which announcers were acted on.
+type discovery struct {
+	group     *net.UDPAddr
+	mu        sync.Mutex
+	contacted map[string]time.Time
+	skipped   map[string]struct{}
+	failed    bool
+}
+
+// listenMulticast joins the IPv4 multicast group on every interface.
+func listenMulticast(group string) (net.PacketConn, error) {
+	addr, err := net.ResolveUDPAddr("udp4", group)
+	if err != nil {
+		return nil, err
+	}
+	return net.ListenMulticastUDP("udp4", nil, addr)
+}
+
+// startDiscovery joins the discovery group and begins announcing this node.
+// Failures only disable discovery; the session keeps running without it.
+func (s *session) startDiscovery() {
+	group := cmp.Or(strings.TrimSpace(s.cfg.DiscoverGroup), config.DefaultDiscoverGroup)
+	addr, err := net.ResolveUDPAddr("udp4", group)
+	if err != nil {
+		s.emitSystem("discovery disabled: %v", err)
+		return
+	}
+	conn, err := s.discoverListen(group)
+	if err != nil {
+		s.emitSystem("discovery disabled: %v", err)
+		return
+	}
+	s.discovery = &discovery{
+		group:     addr,
+		contacted: make(map[string]time.Time),
+		skipped:   make(map[string]struct{}),
+	}
+	go func() {
+		<-s.closed
+		_ = conn.Close()
+	}()
+	go s.discoverLoop(conn)
+	s.announce()
+	s.every(discoverInterval, s.announce)
+}
+
+// discoverLoop reads announcements from the group until the socket closes.
+func (s *session) discoverLoop(conn net.PacketConn) {
+	buf := make([]byte, maxAnnouncement)
+	for {
+		n, from, err := conn.ReadFrom(buf)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			continue
+		}
+		s.handleAnnouncement(buf[:n], from)
+	}
+}
+
+// announce multicasts this node's address from its chat socket, so the
+// source address of the datagram is where peers should send their join.
+func (s *session) announce() {
+	d := s.discovery
+	data, err := json.Marshal(announcement{
+		Service: discoverService,
+		Addr:    s.localInfo().Addr,
+		KeyID:   s.transport.keyID(),
+	})
+	if err != nil {
+		return
+	}
+	err = s.transport.sendRaw(d.group, data)
+	d.mu.Lock()
+	warn := err != nil && !d.failed
+	d.failed = err != nil
+	d.mu.Unlock()
+	if warn {
+		s.emitSystem("discovery announce failed: %v", err)
+	}
+}
+
+// handleAnnouncement contacts a node announced on the LAN unless it is this
+// node, already known, blocked, keyed differently, or was tried recently.
+func (s *session) handleAnnouncement(data []byte, from net.Addr) {
+	var ann announcement
+	if err := json.Unmarshal(data, &ann); err != nil || ann.Service != discoverService {
+		return
+	}
+	source := canonicalNetAddr(from)
+	addr, ok := normalizeAddr(ann.Addr, source)
+	if !ok || s.isLocal(addr) || s.isLocal(source) || s.hasMember(addr) || s.isBlocked(addr) {
+		return
+	}
+	d := s.discovery
+	now := time.Now()
+	d.mu.Lock()
+	if ann.KeyID != s.transport.keyID() {
+		_, seen := d.skipped[addr]
+		d.skipped[addr] = struct{}{}
+		d.mu.Unlock()
+		if !seen {
+			s.recordPeerEvent(addr, "ignoring discovered %s: different secret", addr)
+		}
+		return
+	}
+	if last, ok := d.contacted[addr]; ok && now.Sub(last) < discoverRetry {
+		d.mu.Unlock()
+		return
+	}
+	d.contacted[addr] = now
+	d.mu.Unlock()
+	s.recordPeerEvent(addr, "discovered %s on the local network", addr)
+	go s.contactPeer(addr)
+}
@@ -0,0 +1,72 @@
+package chat
+
+import (
+	"encoding/json"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"yap/internal/config"
+)
+
+// discoverySession starts a session whose discovery group is the unicast
+// socket group, read by the session only when listen is set.
+func discoverySession(t *testing.T, name string, group net.PacketConn, listen bool) *session {
+	t.Helper()
+	cfg := config.Config{Name: name, Discover: true, DiscoverGroup: group.LocalAddr().String()}
+	s := newTestSessionWith(t, sessionOptions{config: cfg, discover: func(string) (net.PacketConn, error) {
+		if listen {
+			return group, nil
+		}
+		return net.ListenPacket("udp4", "127.0.0.1:0")
+	}})
+	s.start()
+	return s
+}
+
+func TestDiscoveryTriggersJoin(t *testing.T) {
+	group, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bob := discoverySession(t, "bob", group, true)
+	alice := discoverySession(t, "alice", group, false)
+
+	waitUntil(t, func() bool { return isActive(alice, bob.localAddr) && isActive(bob, alice.localAddr) })
+	if !strings.Contains(bob.eventsSummary(), "discovered "+alice.localAddr) {
+		t.Fatalf("events lack the discovery:\n%s", bob.eventsSummary())
+	}
+}
+
+func TestDiscoverySkipsOtherSecretsQuietly(t *testing.T) {
+	s := newTestSession(t, config.Config{Name: "alice"})
+	s.discovery = &discovery{contacted: make(map[string]time.Time), skipped: make(map[string]struct{})}
+	data, err := json.Marshal(announcement{Service: discoverService, Addr: "10.0.0.2:4000", KeyID: "deadbeef"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	from := &net.UDPAddr{IP: net.IPv4(10, 0, 0, 2), Port: 4000}
+	for range 3 {
+		s.handleAnnouncement(data, from)
+	}
+
+	if n := strings.Count(s.eventsSummary(), "different secret"); n != 1 {
+		t.Fatalf("logged the mismatch %d times, want once:\n%s", n, s.eventsSummary())
+	}
+	if len(s.discovery.contacted) != 0 || s.hasMember("10.0.0.2:4000") {
+		t.Fatal("peer with a different secret was contacted")
+	}
+}
+
+func TestDiscoveryIgnoresStrayDatagrams(t *testing.T) {
+	s := newTestSession(t, config.Config{Name: "alice"})
+	s.discovery = &discovery{contacted: make(map[string]time.Time), skipped: make(map[string]struct{})}
+	from := &net.UDPAddr{IP: net.IPv4(10, 0, 0, 2), Port: 4000}
+	s.handleAnnouncement([]byte("not json"), from)
+	s.handleAnnouncement([]byte(`{"service":"other","addr":"10.0.0.2:4000"}`), from)
+
+	if len(s.discovery.contacted) != 0 || len(s.discovery.skipped) != 0 {
+		t.Fatal("stray datagram was acted on")
+	}
+}
@@ -0,0 +1,114 @@
+package chat
+
+import (
+	"errors"
+	"net"
+	"net/netip"
+	"strings"
+	"time"
+)
+
+// resolveInterval is how often peers configured by hostname are re-resolved.
+const resolveInterval = 5 * time.Minute
+
+// hostnameOf returns raw trimmed when its host is a DNS name rather than a
+// literal IP, and "" otherwise.
+func hostnameOf(raw string) string {
+	raw = strings.TrimSpace(raw)
+	host, _, err := net.SplitHostPort(raw)
+	if err != nil || host == "" {
+		return ""
+	}
+	if _, err := netip.ParseAddr(host); err == nil {
+		return ""
+	}
+	return raw
+}
+
+// notePeerHost remembers the hostname a peer was configured by so
+// reresolveHosts can follow it to a new address.
+func (s *session) notePeerHost(raw string, addr net.Addr) {
+	host := hostnameOf(raw)
+	key := canonicalNetAddr(addr)
+	if host == "" || key == "" {
+		return
+	}
+	s.membersMu.Lock()
+	defer s.membersMu.Unlock()
+	if rec := s.members[key]; rec != nil {
+		rec.Host = host
+	}
+}
+
+// hostMembers maps the address of each member configured by hostname to that
+// hostname.
+func (s *session) hostMembers() map[string]string {
+	s.membersMu.RLock()
+	defer s.membersMu.RUnlock()
+	hosts := make(map[string]string)
+	for key, rec := range s.members {
+		if rec.Host != "" {
+			hosts[key] = rec.Host
+		}
+	}
+	return hosts
+}
+
+// rehomeMember moves the member at oldKey to newKey as pending, keeping its
+// name and hostname. A member already at newKey inherits the hostname instead.
+func (s *session) rehomeMember(oldKey, newKey string) bool {
+	s.membersMu.Lock()
+	defer s.membersMu.Unlock()
+	rec := s.members[oldKey]
+	if rec == nil || s.blockedLocked(newKey) {
+		return false
+	}
+	delete(s.members, oldKey)
+	delete(s.mentions, oldKey)
+	if existing := s.members[newKey]; existing != nil {
+		existing.Host = rec.Host
+		return true
+	}
+	rec.Addr = newKey
+	rec.Status = statusPending
+	rec.LastSeen = s.now()
+	rec.ClearAddrPort()
+	s.members[newKey] = rec
+	return true
+}
+
+// reresolveHosts looks up every member configured by hostname again and, when
+// the name now points elsewhere, moves the member and joins the new address.
+func (s *session) reresolveHosts() {
+	for key, host := range s.hostMembers() {
+		addr, err := s.resolveAddr(host)
+		if err != nil {
+			s.recordPeerEvent(key, "re-resolving %s failed: %v", host, err)
+			continue
+		}
+		newKey := canonicalNetAddr(addr)
+		if newKey == "" || newKey == key || s.resolvesToSelf(addr) {
+			continue
+		}
+		if !s.rehomeMember(key, newKey) {
+			continue
+		}
+		s.outboxMu.Lock()
+		delete(s.outbox, key)
+		s.outboxMu.Unlock()
+		s.recordPeerEvent(newKey, "%s moved from %s to %s", host, key, newKey)
+		s.emitSystem("%s now resolves to %s (was %s)", host, newKey, key)
+		go s.rejoin(addr)
+	}
+}
+
+// rejoin sends a join to a re-resolved peer, marking it active on success.
+func (s *session) rejoin(addr net.Addr) {
+	if err := s.sendDirectRetry(addr, joinMsg, s.buildJoinPayload()); err != nil {
+		if !errors.Is(err, errRetryInFlight) {
+			_ = s.dropPeer(addr, "failed: "+err.Error())
+		}
+		return
+	}
+	s.markActive(addr, "")
+}
@@ -0,0 +1,102 @@
+package chat
+
+import (
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"yap/internal/config"
+)
+
+func TestHostnameOf(t *testing.T) {
+	cases := map[string]string{
+		" bob.lan:4000 ":    "bob.lan:4000",
+		"10.0.0.2:4000":     "",
+		"[fe80::1%eth0]:80": "",
+		"bob.lan":           "",
+		":4000":             "",
+	}
+	for raw, want := range cases {
+		if got := hostnameOf(raw); got != want {
+			t.Errorf("hostnameOf(%q) = %q, want %q", raw, got, want)
+		}
+	}
+}
+
+// movingResolver resolves every name to whichever address was set last.
+type movingResolver struct {
+	mu   sync.Mutex
+	addr net.Addr
+}
+
+func (r *movingResolver) set(addr net.Addr) {
+	r.mu.Lock()
+	r.addr = addr
+	r.mu.Unlock()
+}
+
+func (r *movingResolver) resolve(string) (net.Addr, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.addr, nil
+}
+
+func TestReresolveFollowsMovedHost(t *testing.T) {
+	oldPeer, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer oldPeer.Close()
+	newPeer, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer newPeer.Close()
+	resolver := &movingResolver{addr: oldPeer.LocalAddr()}
+	s := newTestSessionWith(t, sessionOptions{config: config.Config{Name: "alice"}, resolve: resolver.resolve, retryDelay: time.Millisecond})
+
+	if err := s.addPeer("bob.lan:4001"); err != nil {
+		t.Fatal(err)
+	}
+	oldKey := canonicalNetAddr(oldPeer.LocalAddr())
+	newKey := canonicalNetAddr(newPeer.LocalAddr())
+	if hosts := s.hostMembers(); hosts[oldKey] != "bob.lan:4001" {
+		t.Fatalf("hostnames = %v, want %s tracked for bob.lan:4001", hosts, oldKey)
+	}
+
+	s.reresolveHosts()
+	if _, ok := s.lookupMember(oldKey); !ok {
+		t.Fatal("peer moved although its name still resolves to it")
+	}
+	resolver.set(newPeer.LocalAddr())
+	s.reresolveHosts()
+	waitEvent(t, s, systemContaining("bob.lan:4001 now resolves to "+newKey+" (was "+oldKey+")"))
+	if _, ok := s.lookupMember(oldKey); ok {
+		t.Fatal("old address still a member")
+	}
+	for {
+		if msg := readMessage(t, newPeer); msg.Type == joinMsg {
+			break
+		}
+	}
+	waitUntil(t, func() bool { return isActive(s, newKey) })
+	if hosts := s.hostMembers(); hosts[newKey] != "bob.lan:4001" {
+		t.Fatalf("hostnames = %v, want the hostname kept at %s", hosts, newKey)
+	}
+}
+
+func TestLiteralPeersAreNotReresolved(t *testing.T) {
+	peer, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer peer.Close()
+	s := newTestSession(t, config.Config{Name: "alice"})
+	if err := s.addPeer(peer.LocalAddr().String()); err != nil {
+		t.Fatal(err)
+	}
+	if hosts := s.hostMembers(); len(hosts) != 0 {
+		t.Fatalf("hostnames = %v, want none for a literal address", hosts)
+	}
+}
@@ -3,6 +3,11 @@ package chat
 import (
 	"errors"
 	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/charmbracelet/x/term"
 
 	"yap/internal/config"
 )
@@ -12,27 +17,46 @@ var errQuit = errors.New("quit")
 
 // Run initialises the chat session and drives the terminal UI lifecycle.
 func Run(resolved config.Config, store config.Store) error {
-	var cipher packetCipher
-	if resolved.Secret != "" {
-		var err error
-		cipher, err = newAESCipher(resolved.Secret)
-		if err != nil {
-			return fmt.Errorf("setup error: %w", err)
-		}
-	}
-
-	session, err := newSession(sessionOptions{
-		config: resolved,
-		cipher: cipher,
-		store:  store,
-	})
+	chat, err := NewChat(Options{Config: resolved, Store: store})
 	if err != nil {
 		return err
 	}
 
-	session.start()
-	if err := runBubbleUI(resolved.Name, session.eventStream(), session.submit); err != nil && !errors.Is(err, errQuit) {
-		return fmt.Errorf("ui error: %w", err)
+	chat.Start()
+	defer shutdownOnSignal(chat)()
+	opts := uiOptionsFrom(resolved)
+	opts.markRead = chat.MarkRead
+	opts.typing = chat.Typing
+	var uiErr error
+	if resolved.Plain || !term.IsTerminal(os.Stdout.Fd()) {
+		uiErr = runPlainUI(resolved.Name, chat.Events(), chat.Submit, opts, os.Stdin, os.Stdout)
+	} else {
+		uiErr = runBubbleUI(resolved.Name, chat.Events(), chat.Submit, opts)
+	}
+	_, err = chat.Shutdown()
+	if uiErr != nil && !errors.Is(uiErr, errQuit) {
+		return fmt.Errorf("ui error: %w", uiErr)
+	}
+	return err
+}
+
+// shutdownOnSignal sends the leave notice when the process is interrupted or
+// terminated, so peers learn of the departure even when no UI is attached.
+// Shutdown runs once, so the UI also quitting on the signal is harmless. The
+// returned func stops listening.
+func shutdownOnSignal(chat *Chat) func() {
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-sig:
+			_, _ = chat.Shutdown()
+		case <-done:
+		}
+	}()
+	return func() {
+		signal.Stop(sig)
+		close(done)
 	}
-	return session.shutdown()
 }
@@ -0,0 +1,49 @@
+package chat
+
+import (
+	"os"
+	"runtime"
+	"syscall"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"yap/internal/config"
+)
+
+func TestSignalSendsLeave(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("SIGTERM cannot be delivered to the test process on windows")
+	}
+	s := newTestSession(t, config.Config{Name: "alice"})
+	peer := listenPeer(t, s, "bob")
+	stop := shutdownOnSignal(&Chat{session: s})
+	defer stop()
+
+	self, err := os.FindProcess(os.Getpid())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := self.Signal(syscall.SIGTERM); err != nil {
+		t.Fatal(err)
+	}
+	if msg := readMessage(t, peer); msg.Type != leaveMsg {
+		t.Fatalf("peer got %s, want leave", msg.Type)
+	}
+	waitUntil(t, func() bool {
+		select {
+		case _, open := <-s.eventStream():
+			return !open
+		default:
+			return false
+		}
+	})
+}
+
+func TestClosedEventStreamQuitsUI(t *testing.T) {
+	events := make(chan Message)
+	close(events)
+	if msg := waitForEvent(events)(); msg != (tea.QuitMsg{}) {
+		t.Fatalf("closed stream produced %T, want tea.QuitMsg", msg)
+	}
+}
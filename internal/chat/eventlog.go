@@ -0,0 +1,69 @@
+package chat
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// eventLog appends newline-delimited JSON records describing messages and
+// membership transitions for debugging peer churn.
+type eventLog struct {
+	mu   sync.Mutex
+	file *os.File
+	enc  *json.Encoder
+}
+
+// logRecord is one line of the event log.
+type logRecord struct {
+	Time  string  `json:"time"`
+	Type  msgType `json:"type"`
+	ID    string  `json:"id,omitempty"`
+	From  string  `json:"from,omitempty"`
+	Addr  string  `json:"addr,omitempty"`
+	Body  string  `json:"body,omitempty"`
+	Event string  `json:"event,omitempty"`
+}
+
+// eventRecord is the record type used for status log entries.
+const eventRecord msgType = "event"
+
+// openEventLog opens path for appending, creating it if needed.
+func openEventLog(path string) (*eventLog, error) {
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("open log file: %w", err)
+	}
+	return &eventLog{file: file, enc: json.NewEncoder(file)}, nil
+}
+
+// write appends a record; each record is a single write to the file.
+func (l *eventLog) write(rec logRecord) {
+	if l == nil {
+		return
+	}
+	rec.Time = time.Now().Format(time.RFC3339Nano)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.file == nil {
+		return
+	}
+	_ = l.enc.Encode(rec)
+}
+
+// close releases the file; later writes are ignored.
+func (l *eventLog) close() error {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.file == nil {
+		return nil
+	}
+	err := l.file.Close()
+	l.file = nil
+	return err
+}
@@ -0,0 +1,88 @@
+package chat
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"yap/internal/config"
+)
+
+// readLog decodes every record in the event log at path.
+func readLog(t *testing.T, path string) []logRecord {
+	t.Helper()
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	var records []logRecord
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var rec logRecord
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			t.Fatalf("bad record %q: %v", scanner.Text(), err)
+		}
+		records = append(records, rec)
+	}
+	return records
+}
+
+func TestEventLogRecordsJoinsAndChat(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "yap.log")
+	if err := os.WriteFile(path, []byte(`{"type":"earlier"}`+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	alice := newTestSession(t, config.Config{Name: "alice", LogFile: path})
+	bob := newTestSession(t, config.Config{Name: "bob"})
+	connect(t, alice, bob)
+	if err := bob.handleInput("hello"); err != nil {
+		t.Fatal(err)
+	}
+	waitEvent(t, alice, func(m Message) bool { return m.Type == chatMsg })
+	if _, err := alice.shutdown(); err != nil {
+		t.Fatal(err)
+	}
+
+	records := readLog(t, path)
+	if records[0].Type != "earlier" {
+		t.Fatal("existing log was truncated")
+	}
+	var joined, chatted bool
+	for _, rec := range records {
+		switch {
+		case rec.Type == eventRecord && rec.Addr == bob.localAddr && rec.Event == "connected "+bob.localAddr:
+			joined = true
+		case rec.Type == chatMsg && rec.From == "bob" && rec.Body == "hello":
+			chatted = rec.Time != ""
+		}
+	}
+	if !joined || !chatted {
+		t.Fatalf("joined = %v, chatted = %v in %+v", joined, chatted, records)
+	}
+}
+
+func TestEventLogOpenFailureWarns(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "missing", "yap.log")
+	s := newTestSession(t, config.Config{LogFile: path})
+	waitEvent(t, s, systemContaining("event log disabled"))
+}
+
+func TestClearCommandIsNotLogged(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "yap.log")
+	s := newTestSession(t, config.Config{Name: "alice", LogFile: path})
+	if err := s.handleInput("/clear"); err != nil {
+		t.Fatal(err)
+	}
+	waitEvent(t, s, func(m Message) bool { return m.Type == clearMsg })
+	if _, err := s.shutdown(); err != nil {
+		t.Fatal(err)
+	}
+	for _, rec := range readLog(t, path) {
+		if rec.Type == clearMsg {
+			t.Fatalf("clear logged: %+v", rec)
+		}
+	}
+}
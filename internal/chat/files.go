@@ -0,0 +1,359 @@
+package chat
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+
+	"yap/internal/config"
+)
+
+const (
+	// maxFileSize is the largest file /send transfers.
+	maxFileSize = 1 << 20
+	// fileChunk is the number of file bytes carried by each fileMsg.
+	fileChunk = 8 << 10
+	// fileChunkGap paces chunks so a transfer does not flood peer sockets.
+	// A chunk fragments into about a dozen datagrams, so this keeps a
+	// transfer near half of defaultRateLimit.
+	fileChunkGap = 150 * time.Millisecond
+	// fileTimeout drops transfers that stop receiving chunks, e.g. because
+	// one was lost on the way.
+	fileTimeout = 2 * time.Minute
+	// maxIncomingFiles caps how many transfers may be reassembled at once.
+	maxIncomingFiles = 8
+	// maxHeldFiles caps how many received files wait for /accept; the oldest
+	// is dropped first.
+	maxHeldFiles = 8
+)
+
+// incomingFile collects the chunks of one transfer as they arrive.
+type incomingFile struct {
+	from     string
+	name     string
+	size     int64
+	chunks   [][]byte
+	received int
+	bytes    int64
+	updated  time.Time
+}
+
+// heldFile is a complete transfer waiting for /accept.
+type heldFile struct {
+	ref  string
+	from string
+	name string
+	data []byte
+}
+
+// fileChunkCount returns how many chunks carry a file of size bytes. An empty
+// file still takes one chunk so receivers learn of it.
+func fileChunkCount(size int64) int {
+	return max(1, int((size+fileChunk-1)/fileChunk))
+}
+
+// filePolicy returns the normalized Config.AcceptFiles value.
+func (s *session) filePolicy() string {
+	policy := strings.ToLower(strings.TrimSpace(s.cfg.AcceptFiles))
+	if policy == "" {
+		return "ask"
+	}
+	return policy
+}
+
+// sendFile broadcasts the file at path as a series of fileMsg chunks. The
+// chunks are sent in the background and travel like chat, deduplicated and
+// relayed by every peer, so the call returns once the file has been read.
+func (s *session) sendFile(raw string) error {
+	filePath := strings.TrimSpace(raw)
+	if rest, ok := strings.CutPrefix(filePath, "~/"); ok {
+		if home, err := os.UserHomeDir(); err == nil {
+			filePath = filepath.Join(home, rest)
+		}
+	}
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return err
+	}
+	if !info.Mode().IsRegular() {
+		return fmt.Errorf("%s is not a regular file", filePath)
+	}
+	if info.Size() > maxFileSize {
+		return fmt.Errorf("%s is %s; the limit is %s", filePath, byteSize(info.Size()), byteSize(maxFileSize))
+	}
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return err
+	}
+	if len(data) > maxFileSize {
+		return fmt.Errorf("%s grew past the %s limit", filePath, byteSize(maxFileSize))
+	}
+	name := filepath.Base(filePath)
+	ref := newMessageID()
+	chunks := fileChunkCount(int64(len(data)))
+	s.emitSystem("sending %s (%s) to %d peer(s)", name, byteSize(int64(len(data))), len(s.activeEndpoints()))
+	go func() {
+		for i := range chunks {
+			if i > 0 {
+				select {
+				case <-s.closed:
+					return
+				case <-time.After(fileChunkGap):
+				}
+			}
+			end := min((i+1)*fileChunk, len(data))
+			msg := Message{
+				Type:     fileMsg,
+				Ref:      ref,
+				File:     name,
+				FileSize: int64(len(data)),
+				Chunk:    i,
+				Chunks:   chunks,
+				Body:     base64.StdEncoding.EncodeToString(data[i*fileChunk : end]),
+			}
+			if err := s.broadcastMessage(msg); err != nil {
+				s.emitSystem("sending %s failed: %v", name, err)
+				return
+			}
+		}
+		s.emitSystem("sent %s", name)
+	}()
+	return nil
+}
+
+// receiveFileChunk adds one fileMsg chunk to its transfer and, once every
+// chunk has arrived, checks the length and hands the file to the accept
+// policy. Malformed chunks are dropped; the caller still relays them.
+func (s *session) receiveFileChunk(msg Message) {
+	if s.filePolicy() == "never" || msg.Ref == "" {
+		return
+	}
+	if msg.Chunk == 0 && msg.FileSize > maxFileSize {
+		s.emitSystem("ignored %s from %s: %s exceeds the %s limit", msg.File, msg.From, byteSize(msg.FileSize), byteSize(maxFileSize))
+		return
+	}
+	if msg.FileSize < 0 || msg.FileSize > maxFileSize || msg.Chunks != fileChunkCount(msg.FileSize) || msg.Chunk < 0 || msg.Chunk >= msg.Chunks {
+		return
+	}
+	data, err := base64.StdEncoding.DecodeString(msg.Body)
+	if err != nil || len(data) > fileChunk {
+		return
+	}
+
+	now := s.now()
+	s.filesMu.Lock()
+	stale := s.pruneIncomingLocked(now)
+	in, ok := s.incoming[msg.Ref]
+	if !ok {
+		if len(s.incoming) >= maxIncomingFiles {
+			s.filesMu.Unlock()
+			s.reportStale(stale)
+			return
+		}
+		in = &incomingFile{from: msg.From, name: msg.File, size: msg.FileSize, chunks: make([][]byte, msg.Chunks)}
+		if s.incoming == nil {
+			s.incoming = make(map[string]*incomingFile)
+		}
+		s.incoming[msg.Ref] = in
+	}
+	if in.from != msg.From || in.name != msg.File || in.size != msg.FileSize || len(in.chunks) != msg.Chunks || in.chunks[msg.Chunk] != nil {
+		s.filesMu.Unlock()
+		s.reportStale(stale)
+		return
+	}
+	in.chunks[msg.Chunk] = data
+	in.received++
+	in.bytes += int64(len(data))
+	in.updated = now
+	complete := in.received == len(in.chunks)
+	if complete || in.bytes > in.size {
+		delete(s.incoming, msg.Ref)
+	}
+	s.filesMu.Unlock()
+	s.reportStale(stale)
+
+	if in.bytes > in.size && !complete {
+		s.emitSystem("discarded %s from %s: more data arrived than the %s announced", in.name, in.from, byteSize(in.size))
+		return
+	}
+	if !complete {
+		return
+	}
+	file := bytes.Join(in.chunks, nil)
+	if int64(len(file)) != in.size {
+		s.emitSystem("discarded %s from %s: received %d bytes, expected %d", in.name, in.from, len(file), in.size)
+		return
+	}
+	s.deliverFile(heldFile{ref: msg.Ref, from: in.from, name: in.name, data: file})
+}
+
+// pruneIncomingLocked forgets transfers that have not progressed within
+// fileTimeout and returns them for reporting. filesMu must be held.
+func (s *session) pruneIncomingLocked(now time.Time) []*incomingFile {
+	var stale []*incomingFile
+	for ref, in := range s.incoming {
+		if now.Sub(in.updated) > fileTimeout {
+			stale = append(stale, in)
+			delete(s.incoming, ref)
+		}
+	}
+	return stale
+}
+
+// reportStale notes transfers dropped by pruneIncomingLocked.
+func (s *session) reportStale(stale []*incomingFile) {
+	for _, in := range stale {
+		s.emitSystem("gave up on %s from %s: %d of %d chunks arrived", in.name, in.from, in.received, len(in.chunks))
+	}
+}
+
+// deliverFile saves a complete file or holds it for /accept, depending on
+// Config.AcceptFiles.
+func (s *session) deliverFile(file heldFile) {
+	if s.filePolicy() == "auto" {
+		s.saveFile(file)
+		return
+	}
+	s.filesMu.Lock()
+	s.held = append(s.held, file)
+	if len(s.held) > maxHeldFiles {
+		s.held = s.held[len(s.held)-maxHeldFiles:]
+	}
+	s.filesMu.Unlock()
+	s.emitSystem("%s sent %s (%s); /accept %s to save it", file.from, file.name, byteSize(int64(len(file.data))), shortID(file.ref))
+}
+
+// handleAccept saves the held file whose transfer ID starts with the given
+// prefix, or lists the held files without an argument.
+func (s *session) handleAccept(args []string) {
+	s.filesMu.Lock()
+	switch len(args) {
+	case 0:
+		held := slices.Clone(s.held)
+		s.filesMu.Unlock()
+		if len(held) == 0 {
+			s.emitSystem("no files are waiting")
+			return
+		}
+		lines := []string{"files waiting:"}
+		for _, file := range held {
+			lines = append(lines, fmt.Sprintf("  %s  %s (%s) from %s", shortID(file.ref), file.name, byteSize(int64(len(file.data))), file.from))
+		}
+		s.emitSystem("%s", strings.Join(lines, "\n"))
+		return
+	case 1:
+	default:
+		s.filesMu.Unlock()
+		s.emitSystem("usage: /accept [id]")
+		return
+	}
+	prefix := strings.TrimPrefix(args[0], "#")
+	match := -1
+	for i, file := range s.held {
+		if !strings.HasPrefix(file.ref, prefix) {
+			continue
+		}
+		if match >= 0 {
+			s.filesMu.Unlock()
+			s.emitSystem("file id %q is ambiguous", prefix)
+			return
+		}
+		match = i
+	}
+	if match < 0 {
+		s.filesMu.Unlock()
+		s.emitSystem("no waiting file with id %q", prefix)
+		return
+	}
+	file := s.held[match]
+	s.held = append(s.held[:match:match], s.held[match+1:]...)
+	s.filesMu.Unlock()
+	s.saveFile(file)
+}
+
+// saveFile writes a received file into the downloads directory and reports
+// where it went.
+func (s *session) saveFile(file heldFile) {
+	dir := s.cfg.Downloads
+	if dir == "" {
+		dir = config.DefaultDownloads()
+	}
+	saved, err := writeDownload(dir, file.name, file.data)
+	if err != nil {
+		s.emitSystem("could not save %s from %s: %v", file.name, file.from, err)
+		return
+	}
+	s.emitSystem("saved %s from %s to %s", file.name, file.from, saved)
+}
+
+// writeDownload creates a new file in dir holding data. The peer-supplied
+// name is reduced to a plain base name, and a numeric suffix is added rather
+// than overwriting an existing file.
+func writeDownload(dir, name string, data []byte) (string, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", err
+	}
+	base := safeFileName(name)
+	ext := filepath.Ext(base)
+	stem := strings.TrimSuffix(base, ext)
+	for i := range 100 {
+		candidate := base
+		if i > 0 {
+			candidate = fmt.Sprintf("%s (%d)%s", stem, i, ext)
+		}
+		target := filepath.Join(dir, candidate)
+		f, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+		if errors.Is(err, fs.ErrExist) {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		if _, err := f.Write(data); err != nil {
+			_ = f.Close()
+			_ = os.Remove(target)
+			return "", err
+		}
+		return target, f.Close()
+	}
+	return "", fmt.Errorf("too many files named %s", base)
+}
+
+// safeFileName strips directories, control characters, and leading dots from
+// a peer-supplied file name so it cannot escape the downloads directory or
+// hide itself.
+func safeFileName(name string) string {
+	name = path.Base(strings.ReplaceAll(name, `\`, "/"))
+	name = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, name)
+	name = strings.TrimLeft(strings.TrimSpace(name), ".")
+	if name == "" || name == "/" {
+		return "file"
+	}
+	return name
+}
+
+// byteSize formats a byte count for notices, e.g. "12.3 KiB".
+func byteSize(n int64) string {
+	switch {
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1f MiB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.1f KiB", float64(n)/(1<<10))
+	default:
+		return fmt.Sprintf("%d B", n)
+	}
+}
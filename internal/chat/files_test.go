@@ -0,0 +1,230 @@
+package chat
+
+import (
+	"bytes"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"yap/internal/config"
+)
+
+// fileChunks splits data into the fileMsg chunks a sender would broadcast.
+func fileChunks(ref, from, name string, data []byte) []Message {
+	n := fileChunkCount(int64(len(data)))
+	msgs := make([]Message, n)
+	for i := range n {
+		end := min((i+1)*fileChunk, len(data))
+		msgs[i] = Message{
+			Type:     fileMsg,
+			From:     from,
+			Ref:      ref,
+			File:     name,
+			FileSize: int64(len(data)),
+			Chunk:    i,
+			Chunks:   n,
+			Body:     base64.StdEncoding.EncodeToString(data[i*fileChunk : end]),
+		}
+	}
+	return msgs
+}
+
+func TestSafeFileName(t *testing.T) {
+	cases := map[string]string{
+		"notes.txt":            "notes.txt",
+		"../../etc/passwd":     "passwd",
+		`..\..\boot.ini`:       "boot.ini",
+		"/abs/path/report.pdf": "report.pdf",
+		".bashrc":              "bashrc",
+		"evil\x1b[2J.txt":      "evil[2J.txt",
+		"..":                   "file",
+		"":                     "file",
+		"/":                    "file",
+	}
+	for in, want := range cases {
+		if got := safeFileName(in); got != want {
+			t.Errorf("safeFileName(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestWriteDownloadNeverOverwrites(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "downloads")
+	var paths []string
+	for _, body := range []string{"one", "two"} {
+		saved, err := writeDownload(dir, "../notes.txt", []byte(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		paths = append(paths, saved)
+	}
+	want := []string{filepath.Join(dir, "notes.txt"), filepath.Join(dir, "notes (1).txt")}
+	if paths[0] != want[0] || paths[1] != want[1] {
+		t.Fatalf("saved to %q, want %q", paths, want)
+	}
+	if data, _ := os.ReadFile(want[0]); string(data) != "one" {
+		t.Fatalf("first download overwritten with %q", data)
+	}
+}
+
+func TestReceivedFileWaitsForAccept(t *testing.T) {
+	dir := t.TempDir()
+	s := newTestSession(t, config.Config{Name: "alice", Downloads: dir})
+	data := bytes.Repeat([]byte("0123456789"), 2000)
+	ref := newMessageID()
+	chunks := fileChunks(ref, "bob", "data.bin", data)
+	for _, i := range []int{2, 0, 1} {
+		s.receiveFileChunk(chunks[i])
+	}
+	waitEvent(t, s, systemContaining("bob sent data.bin (19.5 KiB); /accept "+shortID(ref)))
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Fatalf("file saved before /accept: %v", entries)
+	}
+
+	if err := s.handleInput("/accept"); err != nil {
+		t.Fatal(err)
+	}
+	waitEvent(t, s, systemContaining("data.bin (19.5 KiB) from bob"))
+	if err := s.handleInput("/accept nope"); err != nil {
+		t.Fatal(err)
+	}
+	waitEvent(t, s, systemContaining(`no waiting file with id "nope"`))
+	if err := s.handleInput("/accept #" + shortID(ref)); err != nil {
+		t.Fatal(err)
+	}
+	waitEvent(t, s, systemContaining("saved data.bin from bob"))
+	if got, err := os.ReadFile(filepath.Join(dir, "data.bin")); err != nil || !bytes.Equal(got, data) {
+		t.Fatalf("saved file differs (%d bytes, %v)", len(got), err)
+	}
+	if err := s.handleInput("/accept"); err != nil {
+		t.Fatal(err)
+	}
+	waitEvent(t, s, systemContaining("no files are waiting"))
+}
+
+func TestFilePolicies(t *testing.T) {
+	for _, policy := range []string{"auto", "never"} {
+		dir := t.TempDir()
+		s := newTestSession(t, config.Config{Name: "alice", Downloads: dir, AcceptFiles: policy})
+		for _, msg := range fileChunks(newMessageID(), "bob", "hi.txt", []byte("hello")) {
+			s.receiveFileChunk(msg)
+		}
+		got, err := os.ReadFile(filepath.Join(dir, "hi.txt"))
+		switch policy {
+		case "auto":
+			if err != nil || string(got) != "hello" {
+				t.Errorf("auto: saved %q, %v", got, err)
+			}
+		case "never":
+			if err == nil {
+				t.Error("never: file saved")
+			}
+			s.filesMu.Lock()
+			pending := len(s.incoming) + len(s.held)
+			s.filesMu.Unlock()
+			if pending != 0 {
+				t.Errorf("never: %d transfers kept", pending)
+			}
+		}
+	}
+}
+
+func TestMalformedFileChunksAreDropped(t *testing.T) {
+	s := newTestSession(t, config.Config{Name: "alice", Downloads: t.TempDir()})
+	big := fileChunks(newMessageID(), "bob", "big.iso", []byte("x"))[0]
+	big.FileSize = maxFileSize + 1
+	big.Chunks = fileChunkCount(big.FileSize)
+	s.receiveFileChunk(big)
+	waitEvent(t, s, systemContaining("ignored big.iso from bob"))
+
+	good := fileChunks(newMessageID(), "bob", "a.txt", bytes.Repeat([]byte("a"), fileChunk+1))
+	wrongCount := good[0]
+	wrongCount.Chunks = 5
+	overrun := good[1]
+	overrun.Chunk = 2
+	notBase64 := good[1]
+	notBase64.Body = "!!"
+	for _, msg := range []Message{wrongCount, overrun, notBase64} {
+		s.receiveFileChunk(msg)
+	}
+	s.filesMu.Lock()
+	incoming := len(s.incoming)
+	s.filesMu.Unlock()
+	if incoming != 0 {
+		t.Fatalf("%d transfers started from malformed chunks", incoming)
+	}
+
+	// A chunk claiming a different file under the same transfer is ignored.
+	s.receiveFileChunk(good[0])
+	impostor := good[1]
+	impostor.From = "mallory"
+	s.receiveFileChunk(impostor)
+	s.filesMu.Lock()
+	in := s.incoming[good[0].Ref]
+	s.filesMu.Unlock()
+	if in == nil || in.received != 1 {
+		t.Fatalf("transfer state %+v, want one chunk from bob", in)
+	}
+}
+
+func TestStalledTransferIsDropped(t *testing.T) {
+	clock := newFakeClock()
+	s := newTestSessionWith(t, sessionOptions{config: config.Config{Name: "alice", Downloads: t.TempDir()}, now: clock.Now})
+	chunks := fileChunks(newMessageID(), "bob", "slow.bin", bytes.Repeat([]byte("s"), 2*fileChunk))
+	s.receiveFileChunk(chunks[0])
+	clock.advance(fileTimeout + time.Second)
+	s.receiveFileChunk(fileChunks(newMessageID(), "carol", "new.txt", []byte("hi"))[0])
+	waitEvent(t, s, systemContaining("gave up on slow.bin from bob: 1 of 2 chunks arrived"))
+	s.receiveFileChunk(chunks[1])
+	s.filesMu.Lock()
+	held := len(s.held)
+	s.filesMu.Unlock()
+	if held != 1 {
+		t.Fatalf("%d files held, want only carol's", held)
+	}
+}
+
+func TestSendFileRejectsLargeAndIrregularFiles(t *testing.T) {
+	s := newTestSession(t, config.Config{Name: "alice"})
+	dir := t.TempDir()
+	big := filepath.Join(dir, "big.bin")
+	if err := os.WriteFile(big, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Truncate(big, maxFileSize+1); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.sendFile(big); err == nil || !strings.Contains(err.Error(), "the limit is 1.0 MiB") {
+		t.Fatalf("sendFile(big) = %v, want a size error", err)
+	}
+	if err := s.sendFile(dir); err == nil || !strings.Contains(err.Error(), "not a regular file") {
+		t.Fatalf("sendFile(dir) = %v, want a regular-file error", err)
+	}
+	if err := s.handleInput("/send"); err != nil {
+		t.Fatal(err)
+	}
+	waitEvent(t, s, systemContaining("usage: /send <path>"))
+}
+
+func TestSendFileReachesPeer(t *testing.T) {
+	dir := t.TempDir()
+	alice := newTestSession(t, config.Config{Name: "alice"})
+	bob := newTestSession(t, config.Config{Name: "bob", AcceptFiles: "auto", Downloads: dir})
+	connect(t, alice, bob)
+	data := bytes.Repeat([]byte("yap!"), fileChunk/2)
+	src := filepath.Join(t.TempDir(), "notes.txt")
+	if err := os.WriteFile(src, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := alice.handleInput("/send " + src); err != nil {
+		t.Fatal(err)
+	}
+	waitEvent(t, bob, systemContaining("saved notes.txt from alice"))
+	if got, err := os.ReadFile(filepath.Join(dir, "notes.txt")); err != nil || !bytes.Equal(got, data) {
+		t.Fatalf("received %d bytes, %v; want %d", len(got), err, len(data))
+	}
+	waitEvent(t, alice, systemContaining("sent notes.txt"))
+}
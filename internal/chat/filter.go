@@ -0,0 +1,126 @@
+package chat
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// keywordFilters lists words that highlight or hide chat messages in the UI.
+type keywordFilters struct {
+	Highlight []string `json:"highlight,omitempty"`
+	Mute      []string `json:"mute,omitempty"`
+}
+
+// matchKeyword reports whether body contains any of the words, ignoring case.
+func matchKeyword(words []string, body string) bool {
+	if len(words) == 0 {
+		return false
+	}
+	lower := strings.ToLower(body)
+	for _, word := range words {
+		if word != "" && strings.Contains(lower, strings.ToLower(word)) {
+			return true
+		}
+	}
+	return false
+}
+
+// currentFilters returns a copy of the session's keyword lists.
+func (s *session) currentFilters() keywordFilters {
+	return keywordFilters{
+		Highlight: slices.Clone(s.cfg.Highlight),
+		Mute:      slices.Clone(s.cfg.Mute),
+	}
+}
+
+// emitFilters pushes the keyword lists to the UI so rendering picks them up.
+func (s *session) emitFilters() {
+	encoded, err := json.Marshal(s.currentFilters())
+	if err != nil {
+		return
+	}
+	s.emit(Message{Type: filterMsg, Body: string(encoded)})
+}
+
+// handleFilter implements /filter for listing and editing keyword lists.
+func (s *session) handleFilter(args []string) {
+	if len(args) == 0 {
+		s.emitSystem("%s", s.filtersSummary())
+		return
+	}
+	action := strings.ToLower(args[0])
+	word := strings.ToLower(strings.TrimSpace(strings.Join(args[1:], " ")))
+	switch action {
+	case "highlight", "mute":
+		if word == "" {
+			s.emitSystem("usage: /filter %s <word>", action)
+			return
+		}
+		list := &s.cfg.Highlight
+		if action == "mute" {
+			list = &s.cfg.Mute
+		}
+		if slices.Contains(*list, word) {
+			s.emitSystem("already filtering %q", word)
+			return
+		}
+		*list = append(*list, word)
+	case "remove":
+		if word == "" {
+			s.emitSystem("usage: /filter remove <word>")
+			return
+		}
+		before := len(s.cfg.Highlight) + len(s.cfg.Mute)
+		s.cfg.Highlight = slices.DeleteFunc(s.cfg.Highlight, func(w string) bool { return w == word })
+		s.cfg.Mute = slices.DeleteFunc(s.cfg.Mute, func(w string) bool { return w == word })
+		if len(s.cfg.Highlight)+len(s.cfg.Mute) == before {
+			s.emitSystem("no filter for %q", word)
+			return
+		}
+	case "clear":
+		s.cfg.Highlight = nil
+		s.cfg.Mute = nil
+	default:
+		s.emitSystem("usage: /filter [highlight|mute|remove <word> | clear]")
+		return
+	}
+	s.emitFilters()
+	s.emitSystem("%s", s.filtersSummary())
+	if err := s.saveFilters(); err != nil {
+		s.emitSystem("failed to save filters: %v", err)
+	}
+}
+
+// saveFilters writes the keyword lists back to the active profile when one is
+// backed by a store; other profile fields are left untouched.
+func (s *session) saveFilters() error {
+	if s.store == nil || s.cfg.Ephemeral || s.cfg.Profile == "" {
+		return nil
+	}
+	if strings.EqualFold(s.cfg.Profile, "default") {
+		saved, _ := s.store.Default()
+		saved.Highlight = slices.Clone(s.cfg.Highlight)
+		saved.Mute = slices.Clone(s.cfg.Mute)
+		return s.store.SaveDefault(saved)
+	}
+	saved, ok := s.store.Load(s.cfg.Profile)
+	if !ok {
+		return nil
+	}
+	saved.Highlight = slices.Clone(s.cfg.Highlight)
+	saved.Mute = slices.Clone(s.cfg.Mute)
+	return s.store.Save(s.cfg.Profile, saved)
+}
+
+// filtersSummary lists the active keyword filters.
+func (s *session) filtersSummary() string {
+	list := func(words []string) string {
+		if len(words) == 0 {
+			return "none"
+		}
+		return strings.Join(words, ", ")
+	}
+	return fmt.Sprintf("highlight: %s\nmute: %s", list(s.cfg.Highlight), list(s.cfg.Mute))
+}
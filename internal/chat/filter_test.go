@@ -0,0 +1,70 @@
+package chat
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"yap/internal/config"
+)
+
+func TestMatchKeywordIgnoresCase(t *testing.T) {
+	if !matchKeyword([]string{"Deploy"}, "starting the DEPLOY now") {
+		t.Fatal("case-insensitive match missed")
+	}
+	if matchKeyword([]string{"deploy", ""}, "lunch?") || matchKeyword(nil, "anything") {
+		t.Fatal("matched without a keyword present")
+	}
+}
+
+func TestFilterCommandUpdatesUIAndProfile(t *testing.T) {
+	store, err := config.Load(filepath.Join(t.TempDir(), "yap.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Save("team", config.Config{Name: "alice"}); err != nil {
+		t.Fatal(err)
+	}
+	s := newTestSessionWith(t, sessionOptions{config: config.Config{Name: "alice", Profile: "team"}, store: store})
+	drainEvents(s)
+
+	if err := s.handleInput("/filter mute Spoilers"); err != nil {
+		t.Fatal(err)
+	}
+	update := waitEvent(t, s, func(msg Message) bool { return msg.Type == filterMsg })
+	var filters keywordFilters
+	if err := json.Unmarshal([]byte(update.Body), &filters); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(filters.Mute, []string{"spoilers"}) {
+		t.Fatalf("UI got mute list %v", filters.Mute)
+	}
+	if saved, _ := store.Load("team"); !slices.Equal(saved.Mute, []string{"spoilers"}) {
+		t.Fatalf("profile saved mute list %v", saved.Mute)
+	}
+
+	if err := s.handleInput("/filter remove spoilers"); err != nil {
+		t.Fatal(err)
+	}
+	if len(s.cfg.Mute) != 0 {
+		t.Fatalf("mute list %v after remove", s.cfg.Mute)
+	}
+}
+
+func TestMutedKeywordHidesChatInUI(t *testing.T) {
+	m := newBubbleModel("alice", nil, nil, uiOptionsFrom(config.Config{Mute: []string{"spoiler"}}))
+	m.Update(Message{ID: newMessageID(), Type: chatMsg, From: "bob", Body: "big SPOILER ahead"})
+	m.Update(Message{ID: newMessageID(), Type: chatMsg, From: "alice", Body: "my own spoiler"})
+	if len(m.history) != 1 || m.history[0].entries[0].text != "my own spoiler" {
+		t.Fatalf("history = %+v, want only alice's own message", m.history)
+	}
+}
+
+func TestHighlightedKeywordAccentsChat(t *testing.T) {
+	opts := uiOptionsFrom(config.Config{Highlight: []string{"urgent"}})
+	blk := renderMessage(opts, "alice", Message{ID: newMessageID(), Type: chatMsg, From: "bob", Body: "urgent: fix prod"})
+	if blk.entries[0].color != opts.theme.highlight {
+		t.Fatalf("body color %q, want the highlight color", blk.entries[0].color)
+	}
+}
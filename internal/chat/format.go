@@ -0,0 +1,110 @@
+package chat
+
+import (
+	"strings"
+	"unicode"
+)
+
+// ANSI attributes for inline formatting; each span ends with the theme reset
+// followed by the body color again.
+const (
+	ansiBold      = "\033[1m"
+	ansiUnderline = "\033[4m"
+)
+
+// unescapeMarkers drops the backslash from escaped formatting markers.
+var unescapeMarkers = strings.NewReplacer(`\*`, "*", `\_`, "_", "\\`", "`")
+
+// isMarker reports whether r opens an inline span: *bold*, _underline_, or
+// `code`.
+func isMarker(r rune) bool {
+	return r == '*' || r == '_' || r == '`'
+}
+
+// isWordRune reports whether r continues a word, so markers inside words such
+// as snake_case names are left alone.
+func isWordRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// closingMarker returns the index of the marker closing the span opened at
+// start, or -1 when the marker there opens nothing.
+func closingMarker(runes []rune, start int) int {
+	marker := runes[start]
+	if start+1 >= len(runes) || unicode.IsSpace(runes[start+1]) {
+		return -1
+	}
+	if marker != '`' && start > 0 && isWordRune(runes[start-1]) {
+		return -1
+	}
+	for end := start + 2; end < len(runes); end++ {
+		if runes[end] != marker || runes[end-1] == '\\' || unicode.IsSpace(runes[end-1]) {
+			continue
+		}
+		if marker == '`' || end+1 == len(runes) || !isWordRune(runes[end+1]) {
+			return end
+		}
+	}
+	return -1
+}
+
+// urlLength returns how many runes of a URL start at runes[i], or zero.
+func urlLength(runes []rune, i int) int {
+	if i > 0 && !unicode.IsSpace(runes[i-1]) {
+		return 0
+	}
+	rest := string(runes[i:])
+	if !strings.HasPrefix(rest, "https://") && !strings.HasPrefix(rest, "http://") {
+		return 0
+	}
+	n := 0
+	for i+n < len(runes) && !unicode.IsSpace(runes[i+n]) {
+		n++
+	}
+	return n
+}
+
+// formatInline styles one line of a chat body: *bold*, _underline_, and
+// `code` spans, with URLs underlined. A backslash before a marker shows it
+// literally. Themes without escape codes show the line unchanged.
+func formatInline(th theme, line, color string) string {
+	if th.reset == "" {
+		return line
+	}
+	restore := th.reset + color
+	runes := []rune(line)
+	var b strings.Builder
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		if r == '\\' && i+1 < len(runes) && isMarker(runes[i+1]) {
+			b.WriteRune(runes[i+1])
+			i += 2
+			continue
+		}
+		if n := urlLength(runes, i); n > 0 {
+			b.WriteString(ansiUnderline + string(runes[i:i+n]) + restore)
+			i += n
+			continue
+		}
+		end := -1
+		if isMarker(r) {
+			end = closingMarker(runes, i)
+		}
+		if end < 0 {
+			b.WriteRune(r)
+			i++
+			continue
+		}
+		inner := string(runes[i+1 : end])
+		switch r {
+		case '*':
+			b.WriteString(ansiBold + unescapeMarkers.Replace(inner) + restore)
+		case '_':
+			b.WriteString(ansiUnderline + unescapeMarkers.Replace(inner) + restore)
+		default:
+			b.WriteString(th.prompt + inner + restore)
+		}
+		i = end + 1
+	}
+	return b.String()
+}
@@ -0,0 +1,52 @@
+package chat
+
+import (
+	"strings"
+	"testing"
+
+	"yap/internal/config"
+)
+
+func TestFormatInline(t *testing.T) {
+	th := uiOptionsFrom(config.Config{}).theme
+	const color = "<c>"
+	// Spell the escapes as tags so expectations stay readable.
+	tags := strings.NewReplacer(ansiBold, "<b>", ansiUnderline, "<u>", th.reset+color, "</>", th.prompt, "<code>")
+	cases := []struct{ in, want string }{
+		{"plain text", "plain text"},
+		{"a *bold* word", "a <b>bold</> word"},
+		{"_under_ and `x := 1`", "<u>under</> and <code>x := 1</>"},
+		{"snake_case_name stays", "snake_case_name stays"},
+		{"2*3*4 is math", "2*3*4 is math"},
+		{"x_y_ and 2*3* stay", "x_y_ and 2*3* stay"},
+		{"* not bold *", "* not bold *"},
+		{"unclosed *bold", "unclosed *bold"},
+		{`\*literal\* and \_this\_`, "*literal* and _this_"},
+		{"*a \\* b*", "<b>a * b</>"},
+		{"`*raw*`", "<code>*raw*</>"},
+		{"see https://example.com/a_b_c now", "see <u>https://example.com/a_b_c</> now"},
+		{"xhttps://nope", "xhttps://nope"},
+	}
+	for _, tc := range cases {
+		if got := tags.Replace(formatInline(th, tc.in, color)); got != tc.want {
+			t.Errorf("formatInline(%q) = %q, want %q", tc.in, got, tc.want)
+		}
+	}
+}
+
+func TestFormatInlineMonoIsPlain(t *testing.T) {
+	th := uiOptionsFrom(config.Config{Theme: "mono"}).theme
+	if got := formatInline(th, "*bold* _u_ `c`", ""); got != "*bold* _u_ `c`" {
+		t.Fatalf("mono theme styled %q", got)
+	}
+}
+
+func TestFormatIsOptIn(t *testing.T) {
+	for _, format := range []bool{false, true} {
+		m := newBubbleModel("alice", nil, nil, uiOptionsFrom(config.Config{Format: format}))
+		m.Update(Message{ID: newMessageID(), Type: chatMsg, From: "bob", Body: "a *bold* move"})
+		if styled := strings.Contains(m.View(), ansiBold+"bold"); styled != format {
+			t.Errorf("format=%v: bold styled = %v", format, styled)
+		}
+	}
+}
@@ -0,0 +1,253 @@
+package chat
+
+import (
+	"bytes"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"yap/internal/config"
+)
+
+// slowSession builds a session whose writes each take a few milliseconds,
+// with peers standing in as active members.
+func slowSession(t *testing.T, workers, peers int) (*session, *fakeConn) {
+	t.Helper()
+	fc := &fakeConn{writeDelay: 5 * time.Millisecond}
+	s := newTestSessionWith(t, sessionOptions{
+		config: config.Config{SendWorkers: workers},
+		listen: listenFake(fc),
+	})
+	for range peers {
+		listenPeer(t, s, "peer")
+	}
+	return s, fc
+}
+
+func TestForwardRawSendsConcurrently(t *testing.T) {
+	s, fc := slowSession(t, 4, 8)
+	res := s.forwardRaw([]byte(`{}`), nil)
+	if res.attempted != 8 || res.delivered != 8 {
+		t.Fatalf("forwardRaw = %+v", res)
+	}
+	if peak := fc.peakWrites(); peak < 2 || peak > 4 {
+		t.Fatalf("peak concurrent writes = %d, want 2..4", peak)
+	}
+}
+
+func TestRelaySendsConcurrently(t *testing.T) {
+	s, fc := slowSession(t, 4, 8)
+	source, err := net.ResolveUDPAddr("udp", "127.0.0.1:9")
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := Message{ID: newMessageID(), From: "someone", Type: chatMsg}
+	s.transport.seen.store(msg.ID)
+	s.relay(msg, []byte(`{}`), source)
+	if got := s.transport.stats.forwarded.Load(); got != 8 {
+		t.Fatalf("forwarded %d packets, want 8", got)
+	}
+	if peak := fc.peakWrites(); peak < 2 || peak > 4 {
+		t.Fatalf("peak concurrent writes = %d, want 2..4", peak)
+	}
+}
+
+func TestSingleSendWorkerIsSerial(t *testing.T) {
+	s, fc := slowSession(t, 1, 4)
+	s.forwardRaw([]byte(`{}`), nil)
+	if peak := fc.peakWrites(); peak != 1 {
+		t.Fatalf("peak concurrent writes = %d, want 1", peak)
+	}
+}
+
+func TestRelaySkipsPeersKnownToHaveMessage(t *testing.T) {
+	s := newTestSession(t, config.Config{Name: "alice"})
+	bob := listenPeer(t, s, "bob")
+	carol := listenPeer(t, s, "carol")
+	dave := listenPeer(t, s, "dave")
+	erin := listenPeer(t, s, "erin")
+
+	// carol wrote it, bob relayed it to us, and dave already sent a duplicate.
+	msg := Message{ID: newMessageID(), From: "carol", Type: chatMsg}
+	s.transport.seen.store(msg.ID)
+	s.transport.seen.noteHolders(msg.ID, canonicalNetAddr(dave.LocalAddr()))
+	s.relay(msg, []byte(`{}`), bob.LocalAddr())
+
+	readMessage(t, erin)
+	for _, conn := range []net.PacketConn{bob, carol, dave} {
+		expectSilence(t, conn)
+	}
+
+	// A second relay of the same message finds everyone already has it.
+	s.relay(msg, []byte(`{}`), dave.LocalAddr())
+	expectSilence(t, erin)
+}
+
+func TestRelayFanoutCap(t *testing.T) {
+	s := newTestSession(t, config.Config{Name: "alice", Fanout: 2})
+	for range 5 {
+		listenPeer(t, s, "peer")
+	}
+	source, err := net.ResolveUDPAddr("udp", "127.0.0.1:9")
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := Message{ID: newMessageID(), From: "someone", Type: chatMsg}
+	s.transport.seen.store(msg.ID)
+	s.relay(msg, []byte(`{}`), source)
+	if got := s.transport.stats.forwarded.Load(); got != 2 {
+		t.Fatalf("forwarded %d packets, want the fanout of 2", got)
+	}
+}
+
+// slowLink delays datagrams carrying marker on their way to one address.
+type slowLink struct {
+	net.PacketConn
+	marker []byte
+	to     atomic.Value // string
+	delay  time.Duration
+}
+
+func (c *slowLink) WriteTo(p []byte, addr net.Addr) (int, error) {
+	if to, _ := c.to.Load().(string); to == addr.String() && bytes.Contains(p, c.marker) {
+		time.Sleep(c.delay)
+	}
+	return c.PacketConn.WriteTo(p, addr)
+}
+
+func TestMeshRelaySkipsOriginAndHolders(t *testing.T) {
+	const n = 4
+	marker := []byte("hello mesh")
+	arrivals := make([]atomic.Int64, n)
+	link := &slowLink{marker: marker, delay: 100 * time.Millisecond}
+	nodes := make([]*session, n)
+	for i := range nodes {
+		fc := &fakeConn{dropRead: func(data []byte) bool {
+			if bytes.Contains(data, marker) {
+				arrivals[i].Add(1)
+			}
+			return false
+		}}
+		listen := listenFake(fc)
+		if i == 0 {
+			listen = func(addr string) (net.PacketConn, error) {
+				conn, err := net.ListenPacket("udp", addr)
+				link.PacketConn = conn
+				fc.PacketConn = link
+				return fc, err
+			}
+		}
+		nodes[i] = newTestSessionWith(t, sessionOptions{
+			config: config.Config{Name: string(rune('a' + i)), SendWorkers: n},
+			listen: listen,
+		})
+	}
+	for i := 1; i < n; i++ {
+		connect(t, nodes[0], nodes[i])
+	}
+	// Relays recognise the origin by name, so wait for names as well.
+	waitUntil(t, func() bool {
+		for _, a := range nodes {
+			for _, b := range nodes {
+				if rec, _ := a.lookupMember(b.localAddr); a != b && (!isActive(a, b.localAddr) || rec.Name == "") {
+					return false
+				}
+			}
+		}
+		return true
+	})
+
+	// b hears the message from c or d first, so naive flooding, which only
+	// skips the source, would echo it back to the origin a.
+	link.to.Store(nodes[1].localAddr)
+	if err := nodes[0].handleInput(string(marker)); err != nil {
+		t.Fatal(err)
+	}
+	for _, node := range nodes[1:] {
+		waitEvent(t, node, func(m Message) bool { return m.Type == chatMsg && m.Body == string(marker) })
+	}
+	time.Sleep(2 * link.delay)
+
+	if got := arrivals[0].Load(); got != 0 {
+		t.Fatalf("origin received %d relayed copies of its own message", got)
+	}
+	var total int64
+	for i := range arrivals {
+		total += arrivals[i].Load()
+	}
+	if naive := int64((n - 1) + (n-1)*(n-2) + 1); total >= naive {
+		t.Fatalf("%d chat datagrams delivered, want fewer than naive flooding's %d", total, naive)
+	}
+}
+
+func TestRelayHubIgnoresFanout(t *testing.T) {
+	s := newTestSession(t, config.Config{Name: "hub", Fanout: 2, Relay: true})
+	for range 5 {
+		listenPeer(t, s, "peer")
+	}
+	source, err := net.ResolveUDPAddr("udp", "127.0.0.1:9")
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := Message{ID: newMessageID(), From: "someone", Type: chatMsg}
+	s.transport.seen.store(msg.ID)
+	s.relay(msg, []byte(`{}`), source)
+	if got := s.transport.stats.forwarded.Load(); got != 5 {
+		t.Fatalf("hub forwarded %d packets, want all 5 peers", got)
+	}
+}
+
+func TestSpokeNeverRelays(t *testing.T) {
+	s := newTestSession(t, config.Config{Name: "spoke", NoForward: true})
+	for range 3 {
+		listenPeer(t, s, "peer")
+	}
+	source, err := net.ResolveUDPAddr("udp", "127.0.0.1:9")
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := Message{ID: newMessageID(), From: "someone", Type: chatMsg}
+	s.transport.seen.store(msg.ID)
+	s.relay(msg, []byte(`{}`), source)
+	if got := s.transport.stats.forwarded.Load(); got != 0 {
+		t.Fatalf("spoke forwarded %d packets", got)
+	}
+}
+
+func TestSpokeIgnoresGossipAndRelayedJoins(t *testing.T) {
+	s := newTestSession(t, config.Config{Name: "spoke", NoForward: true})
+	if _, err := s.processPeersPayload([]byte(`{"peers":[{"addr":"10.0.0.5:4000","name":"carol"}]}`), "10.0.0.2:4000"); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := s.processJoinPayload(joinFrom(t, "10.0.0.6:4000"), "10.0.0.2:4000", "hub"); err != nil {
+		t.Fatal(err)
+	}
+	for _, addr := range []string{"10.0.0.5:4000", "10.0.0.6:4000"} {
+		if _, ok := s.lookupMember(addr); ok {
+			t.Fatalf("spoke admitted %s learned from another peer", addr)
+		}
+	}
+	if _, _, err := s.processJoinPayload(joinFrom(t, "10.0.0.2:4000"), "10.0.0.2:4000", "hub"); err != nil {
+		t.Fatal(err)
+	}
+	if !isActive(s, "10.0.0.2:4000") {
+		t.Fatal("spoke ignored a direct join")
+	}
+}
+
+func TestSpokesTalkThroughHub(t *testing.T) {
+	hub := newTestSession(t, config.Config{Name: "hub", Relay: true})
+	left := newTestSession(t, config.Config{Name: "left", NoForward: true})
+	right := newTestSession(t, config.Config{Name: "right", NoForward: true})
+	connect(t, hub, left)
+	connect(t, hub, right)
+
+	if err := left.handleInput("via the hub"); err != nil {
+		t.Fatal(err)
+	}
+	waitEvent(t, right, func(m Message) bool { return m.Type == chatMsg && m.Body == "via the hub" })
+	if _, ok := right.lookupMember(left.localAddr); ok {
+		t.Fatal("spoke learned another spoke's address")
+	}
+}
@@ -0,0 +1,205 @@
+package chat
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+const (
+	// maxFrameSize is the largest encoded packet sent as a single datagram.
+	maxFrameSize = 1200
+	// fragmentChunk is the number of raw bytes carried by each fragment.
+	fragmentChunk = 800
+	// fragmentTimeout drops partially assembled messages that stall.
+	fragmentTimeout = 30 * time.Second
+	// maxFragments caps how many fragments one message may claim.
+	maxFragments = 1024
+	// maxMessageSize is the largest encoded packet that can be fragmented.
+	maxMessageSize = maxFragments * fragmentChunk
+	// maxReassemblies caps how many messages may be partially assembled at once.
+	maxReassemblies = 64
+	// maxReassemblyBytes caps the fragment bytes buffered across all messages.
+	maxReassemblyBytes = 4 << 20
+)
+
+// errTooManyFragments rejects a fragment claiming more parts than allowed.
+var errTooManyFragments = errors.New("too many fragments")
+
+// fragmentFrames splits an oversized encoded packet into fragment datagrams
+// that share a common ID and carry their position in FragIndex/FragTotal.
+func fragmentFrames(data []byte) ([][]byte, error) {
+	total := (len(data) + fragmentChunk - 1) / fragmentChunk
+	if total > maxFragments {
+		return nil, fmt.Errorf("message too large: %d bytes", len(data))
+	}
+	id := newMessageID()
+	frames := make([][]byte, 0, total)
+	for i := 0; i < total; i++ {
+		end := min((i+1)*fragmentChunk, len(data))
+		frag := Message{
+			ID:        id,
+			Type:      fragmentMsg,
+			Body:      base64.StdEncoding.EncodeToString(data[i*fragmentChunk : end]),
+			FragIndex: i,
+			FragTotal: total,
+		}
+		raw, err := json.Marshal(frag)
+		if err != nil {
+			return nil, fmt.Errorf("encode fragment: %w", err)
+		}
+		frames = append(frames, raw)
+	}
+	return frames, nil
+}
+
+// partialMessage collects the fragments of one message as they arrive.
+type partialMessage struct {
+	parts   [][]byte
+	have    int
+	size    int
+	started time.Time
+}
+
+// reassembler rebuilds fragmented packets, tolerating out-of-order arrival.
+// Buffered messages and bytes are capped; the oldest partial messages are
+// evicted to make room.
+type reassembler struct {
+	mu       sync.Mutex
+	pending  map[string]*partialMessage
+	buffered int
+	// rejected counts fragments refused outright; evicted counts partial
+	// messages dropped to stay within the caps.
+	rejected int
+	evicted  int
+}
+
+// fragmentStats is a snapshot of the reassembly buffer for diagnostics.
+type fragmentStats struct {
+	inFlight int
+	buffered int
+	rejected int
+	evicted  int
+}
+
+// newReassembler builds an empty reassembly buffer.
+func newReassembler() *reassembler {
+	return &reassembler{pending: make(map[string]*partialMessage)}
+}
+
+// add stores a fragment and returns the original packet once every part is
+// present. Fragments that can never be assembled within the caps are
+// rejected with an error.
+func (r *reassembler) add(addr net.Addr, frag Message) ([]byte, bool, error) {
+	if frag.FragTotal <= 0 || frag.FragIndex < 0 || frag.FragIndex >= frag.FragTotal {
+		return nil, false, r.reject(errors.New("bad fragment index"))
+	}
+	if frag.FragTotal > maxFragments {
+		return nil, false, r.reject(errTooManyFragments)
+	}
+	chunk, err := base64.StdEncoding.DecodeString(frag.Body)
+	if err != nil {
+		return nil, false, r.reject(errors.New("bad fragment body"))
+	}
+	if len(chunk) > fragmentChunk {
+		return nil, false, r.reject(errors.New("oversized fragment"))
+	}
+
+	now := time.Now()
+	key := frag.ID
+	if addr != nil {
+		key = addr.String() + "/" + frag.ID
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.expireLocked(now)
+
+	partial, ok := r.pending[key]
+	if !ok {
+		for len(r.pending) >= maxReassemblies {
+			r.evictOldestLocked("")
+		}
+		partial = &partialMessage{parts: make([][]byte, frag.FragTotal), started: now}
+		r.pending[key] = partial
+	}
+	if len(partial.parts) != frag.FragTotal {
+		r.dropLocked(key)
+		r.rejected++
+		return nil, false, errors.New("inconsistent fragment count")
+	}
+	if partial.parts[frag.FragIndex] == nil {
+		for r.buffered+len(chunk) > maxReassemblyBytes && len(r.pending) > 1 {
+			r.evictOldestLocked(key)
+		}
+		partial.parts[frag.FragIndex] = chunk
+		partial.have++
+		partial.size += len(chunk)
+		r.buffered += len(chunk)
+	}
+	if partial.have < len(partial.parts) {
+		return nil, false, nil
+	}
+
+	r.dropLocked(key)
+	whole := make([]byte, 0, partial.size)
+	for _, part := range partial.parts {
+		whole = append(whole, part...)
+	}
+	return whole, true, nil
+}
+
+// reject counts a refused fragment and returns err.
+func (r *reassembler) reject(err error) error {
+	r.mu.Lock()
+	r.rejected++
+	r.mu.Unlock()
+	return err
+}
+
+// stats snapshots the reassembly buffer.
+func (r *reassembler) stats() fragmentStats {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return fragmentStats{inFlight: len(r.pending), buffered: r.buffered, rejected: r.rejected, evicted: r.evicted}
+}
+
+// dropLocked forgets a partial message and releases its buffered bytes.
+func (r *reassembler) dropLocked(key string) {
+	if partial, ok := r.pending[key]; ok {
+		r.buffered -= partial.size
+		delete(r.pending, key)
+	}
+}
+
+// evictOldestLocked drops the longest-waiting partial message other than keep.
+func (r *reassembler) evictOldestLocked(keep string) {
+	oldest := ""
+	var started time.Time
+	for key, partial := range r.pending {
+		if key == keep {
+			continue
+		}
+		if oldest == "" || partial.started.Before(started) {
+			oldest, started = key, partial.started
+		}
+	}
+	if oldest == "" {
+		return
+	}
+	r.dropLocked(oldest)
+	r.evicted++
+}
+
+// expireLocked discards partial messages older than the fragment timeout.
+func (r *reassembler) expireLocked(now time.Time) {
+	for key, partial := range r.pending {
+		if now.Sub(partial.started) > fragmentTimeout {
+			r.dropLocked(key)
+		}
+	}
+}
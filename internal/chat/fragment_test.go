@@ -0,0 +1,136 @@
+package chat
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/rand/v2"
+	"net"
+	"strings"
+	"testing"
+
+	"yap/internal/config"
+)
+
+// fragmentsOf splits data with fragmentFrames and decodes each frame.
+func fragmentsOf(t *testing.T, data []byte) []Message {
+	t.Helper()
+	frames, err := fragmentFrames(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	frags := make([]Message, len(frames))
+	for i, frame := range frames {
+		if len(frame) > maxFrameSize {
+			t.Fatalf("frame %d is %d bytes, over %d", i, len(frame), maxFrameSize)
+		}
+		if err := json.Unmarshal(frame, &frags[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return frags
+}
+
+func TestReassemblyOutOfOrder(t *testing.T) {
+	data := bytes.Repeat([]byte("0123456789"), 500)
+	frags := fragmentsOf(t, data)
+	if len(frags) < 3 {
+		t.Fatalf("only %d fragments", len(frags))
+	}
+	rand.Shuffle(len(frags), func(i, j int) { frags[i], frags[j] = frags[j], frags[i] })
+
+	r := newReassembler()
+	src := &net.UDPAddr{IP: net.IPv4(10, 0, 0, 2), Port: 4000}
+	for i, frag := range frags {
+		whole, done, err := r.add(src, frag)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if done != (i == len(frags)-1) {
+			t.Fatalf("fragment %d: done = %v", i, done)
+		}
+		if done {
+			if !bytes.Equal(whole, data) {
+				t.Fatal("reassembled data differs")
+			}
+			break
+		}
+		// A repeated fragment must not complete or corrupt the message.
+		if _, again, err := r.add(src, frag); err != nil || again {
+			t.Fatalf("duplicate fragment: done=%v err=%v", again, err)
+		}
+	}
+	if st := r.stats(); st.inFlight != 0 || st.buffered != 0 {
+		t.Fatalf("buffer not released: %+v", st)
+	}
+}
+
+func TestReassemblyKeepsSourcesApart(t *testing.T) {
+	frags := fragmentsOf(t, bytes.Repeat([]byte("x"), 2*fragmentChunk))
+	r := newReassembler()
+	a := &net.UDPAddr{IP: net.IPv4(10, 0, 0, 2), Port: 4000}
+	b := &net.UDPAddr{IP: net.IPv4(10, 0, 0, 3), Port: 4000}
+	if _, done, _ := r.add(a, frags[0]); done {
+		t.Fatal("completed early")
+	}
+	if _, done, _ := r.add(b, frags[1]); done {
+		t.Fatal("fragments from different sources were combined")
+	}
+}
+
+func TestReassemblyRejectsBadFragments(t *testing.T) {
+	r := newReassembler()
+	for _, frag := range []Message{
+		{ID: "x", FragIndex: 2, FragTotal: 2, Body: ""},
+		{ID: "x", FragIndex: 0, FragTotal: maxFragments + 1},
+		{ID: "x", FragIndex: 0, FragTotal: 2, Body: "!!"},
+	} {
+		if _, _, err := r.add(nil, frag); err == nil {
+			t.Errorf("accepted %+v", frag)
+		}
+	}
+}
+
+func TestLargeMessageCrossesTheWire(t *testing.T) {
+	a := newTestSession(t, config.Config{Name: "alice", Secret: "hunter22"})
+	b := newTestSession(t, config.Config{Name: "bob", Secret: "hunter22"})
+	connect(t, a, b)
+
+	// Random text barely compresses, so the packet must be fragmented.
+	var sb strings.Builder
+	for sb.Len() < 3*maxFrameSize {
+		sb.WriteByte(byte('a' + rand.IntN(26)))
+	}
+	body := sb.String()
+	if err := b.handleInput(body); err != nil {
+		t.Fatal(err)
+	}
+	waitEvent(t, a, func(msg Message) bool { return msg.Type == chatMsg && msg.Body == body })
+}
+
+func TestReassemblyFloodStaysBounded(t *testing.T) {
+	r := newReassembler()
+	chunk := base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{'x'}, fragmentChunk))
+	for i := range 20000 {
+		// Bogus headers spread over many IDs, each claiming the most parts
+		// allowed and never completing.
+		frag := Message{ID: fmt.Sprintf("bogus-%d", i%200), FragIndex: i / 200, FragTotal: maxFragments, Body: chunk}
+		if _, done, err := r.add(nil, frag); err != nil || done {
+			t.Fatalf("fragment %d: done = %v, err = %v", i, done, err)
+		}
+		if st := r.stats(); st.inFlight > maxReassemblies || st.buffered > maxReassemblyBytes {
+			t.Fatalf("after %d fragments: %d in flight, %d bytes buffered", i+1, st.inFlight, st.buffered)
+		}
+	}
+	if st := r.stats(); st.evicted == 0 {
+		t.Fatal("flood evicted nothing")
+	}
+
+	for range 100 {
+		_, _, _ = r.add(nil, Message{ID: "huge", FragIndex: 0, FragTotal: 1 << 30, Body: chunk})
+	}
+	if st := r.stats(); st.rejected != 100 {
+		t.Fatalf("rejected %d oversized claims, want 100", st.rejected)
+	}
+}
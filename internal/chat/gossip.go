@@ -0,0 +1,40 @@
+package chat
+
+import "time"
+
+// gossipDebounce is how long membership changes are coalesced before the
+// active peer list is broadcast.
+const gossipDebounce = 500 * time.Millisecond
+
+// scheduleGossip arranges a single peers broadcast after gossipDebounce;
+// further changes inside the window ride along with the pending one.
+func (s *session) scheduleGossip() {
+	s.gossipMu.Lock()
+	defer s.gossipMu.Unlock()
+	if s.gossipPending {
+		return
+	}
+	s.gossipPending = true
+	time.AfterFunc(gossipDebounce, s.flushGossip)
+}
+
+// flushGossip broadcasts the current active peer list to every active member.
+func (s *session) flushGossip() {
+	s.gossipMu.Lock()
+	s.gossipPending = false
+	s.gossipMu.Unlock()
+	select {
+	case <-s.closed:
+		return
+	default:
+	}
+	data, err := s.buildPeersPayloadData("")
+	if err != nil || len(data) == 0 {
+		return
+	}
+	_, raw, err := s.transport.prepare(s.cfg.Name, peersMsg, string(data))
+	if err != nil {
+		return
+	}
+	s.forwardRaw(raw, nil)
+}
@@ -0,0 +1,40 @@
+package chat
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"yap/internal/config"
+)
+
+func TestGossipCoalescesMembershipChanges(t *testing.T) {
+	s := newTestSession(t, config.Config{Name: "alice"})
+	bob := listenPeer(t, s, "bob")
+	for _, addr := range []string{"127.0.0.1:40001", "127.0.0.1:40002", "127.0.0.1:40003"} {
+		s.markMemberActive(addr, "")
+	}
+
+	var gossip []Message
+	buf := make([]byte, 64<<10)
+	_ = bob.SetReadDeadline(time.Now().Add(gossipDebounce + time.Second))
+	for {
+		n, _, err := bob.ReadFrom(buf)
+		if err != nil {
+			break
+		}
+		var msg Message
+		if json.Unmarshal(buf[:n], &msg) == nil && msg.Type == peersMsg {
+			gossip = append(gossip, msg)
+		}
+	}
+	if len(gossip) != 1 {
+		t.Fatalf("got %d peers broadcasts, want 1", len(gossip))
+	}
+	for _, addr := range []string{"127.0.0.1:40001", "127.0.0.1:40003"} {
+		if !strings.Contains(gossip[0].Body, addr) {
+			t.Fatalf("gossip %s is missing %s", gossip[0].Body, addr)
+		}
+	}
+}
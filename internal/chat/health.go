@@ -0,0 +1,109 @@
+package chat
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// healthReadTimeout bounds how long a /healthz client may take to send its
+// request headers.
+const healthReadTimeout = 5 * time.Second
+
+// lastPacketSummary describes the most recently received datagram.
+func (s *session) lastPacketSummary() string {
+	if !s.transport.debug {
+		return "/lastpacket requires debug mode"
+	}
+	info, ok := s.transport.lastPacketInfo()
+	if !ok {
+		return "no packets received yet"
+	}
+	kind := string(info.kind)
+	if kind == "" {
+		kind = "none"
+	}
+	lines := []string{
+		fmt.Sprintf("last packet (%s ago):", time.Since(info.at).Round(time.Millisecond)),
+		fmt.Sprintf("  source: %s", info.from),
+		fmt.Sprintf("  size: %d bytes", info.size),
+		fmt.Sprintf("  type: %s", kind),
+		fmt.Sprintf("  encrypted: %t", info.encrypted),
+		fmt.Sprintf("  outcome: %s", info.outcome),
+	}
+	if info.trailing > 0 {
+		lines = append(lines, fmt.Sprintf("  trailing: %d bytes ignored", info.trailing))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// socketAlive reports whether the session is open and still reading from at
+// least one socket.
+func (s *session) socketAlive() bool {
+	select {
+	case <-s.closed:
+		return false
+	default:
+		return s.transport.readers.Load() > 0
+	}
+}
+
+// healthSummary reports liveness indicators for spotting a wedged session.
+func (s *session) healthSummary() string {
+	socket := "down"
+	select {
+	case <-s.closed:
+		socket = "closed"
+	default:
+		if n := s.transport.readers.Load(); n > 0 {
+			socket = "alive"
+			if n > 1 {
+				socket = fmt.Sprintf("alive (%d sockets)", n)
+			}
+		}
+	}
+	if last := s.transport.lastPacket.Load(); last > 0 {
+		socket += fmt.Sprintf(" (last packet %s ago)", time.Since(time.Unix(0, last)).Round(time.Second))
+	}
+	active, pending := s.membersSnapshot()
+	frags := s.transport.frags.stats()
+	lines := []string{
+		"health:",
+		fmt.Sprintf("  listen socket: %s", socket),
+		fmt.Sprintf("  peers: %d active, %d pending", len(active), len(pending)),
+		fmt.Sprintf("  events queue: %d/%d (%d dropped)", len(s.events), cap(s.events), s.droppedEvents.Load()),
+		fmt.Sprintf("  fragments: %d in flight (%d bytes), %d rejected, %d evicted", frags.inFlight, frags.buffered, frags.rejected, frags.evicted),
+		fmt.Sprintf("  goroutines: %d", runtime.NumGoroutine()),
+	}
+	return strings.Join(lines, "\n")
+}
+
+// startHealthServer serves the health summary over HTTP at /healthz, answering
+// 503 once the listen socket is down so probes can restart a wedged session.
+// Failures only disable the endpoint; the session keeps running without it.
+func (s *session) startHealthServer() {
+	ln, err := net.Listen("tcp", strings.TrimSpace(s.cfg.HealthListen))
+	if err != nil {
+		s.emitSystem("health endpoint disabled: %v", err)
+		return
+	}
+	s.healthAddr = ln.Addr()
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		if !s.socketAlive() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		_, _ = io.WriteString(w, s.healthSummary()+"\n")
+	})
+	srv := &http.Server{Handler: mux, ReadHeaderTimeout: healthReadTimeout}
+	go func() {
+		<-s.closed
+		_ = srv.Close()
+	}()
+	go func() { _ = srv.Serve(ln) }()
+}
@@ -0,0 +1,171 @@
+package chat
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"yap/internal/config"
+)
+
+func TestHealthReportsSocketLifecycle(t *testing.T) {
+	s := newTestSession(t, config.Config{})
+	if got := s.healthSummary(); !strings.Contains(got, "listen socket: down") {
+		t.Fatalf("before start:\n%s", got)
+	}
+	s.start()
+	waitUntil(t, func() bool { return strings.Contains(s.healthSummary(), "listen socket: alive") })
+	active, _ := s.membersSnapshot()
+	listenPeer(t, s, "bob")
+	if got := s.healthSummary(); !strings.Contains(got, fmt.Sprintf("peers: %d active, 0 pending", len(active)+1)) || !strings.Contains(got, "goroutines:") {
+		t.Fatalf("running:\n%s", got)
+	}
+	if _, err := s.shutdown(); err != nil {
+		t.Fatal(err)
+	}
+	if got := s.healthSummary(); !strings.Contains(got, "listen socket: closed") {
+		t.Fatalf("after shutdown:\n%s", got)
+	}
+}
+
+func TestHealthCommand(t *testing.T) {
+	s := newTestSession(t, config.Config{})
+	drainEvents(s)
+	if err := s.handleInput("/health"); err != nil {
+		t.Fatal(err)
+	}
+	waitEvent(t, s, systemContaining("events queue:"))
+}
+
+func TestHealthzServesSummary(t *testing.T) {
+	s := newTestSession(t, config.Config{HealthListen: "127.0.0.1:0"})
+	s.start()
+	listenPeer(t, s, "bob")
+	url := "http://" + s.healthAddr.String() + "/healthz"
+	get := func() (int, string) {
+		t.Helper()
+		resp, err := http.Get(url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp.StatusCode, string(body)
+	}
+
+	waitUntil(t, s.socketAlive)
+	code, body := get()
+	if code != http.StatusOK || !strings.Contains(body, "listen socket: alive") || !strings.Contains(body, "peers: 2 active, 0 pending") {
+		t.Fatalf("GET /healthz = %d:\n%s", code, body)
+	}
+
+	// A session whose socket stopped reading reports itself unhealthy.
+	_ = s.transport.close()
+	waitUntil(t, func() bool { return !s.socketAlive() })
+	if code, body := get(); code != http.StatusServiceUnavailable || !strings.Contains(body, "listen socket: down") {
+		t.Fatalf("GET /healthz with the socket down = %d:\n%s", code, body)
+	}
+
+	// The transport is already closed, so only the endpoint matters here.
+	_, _ = s.shutdown()
+	waitUntil(t, func() bool {
+		resp, err := http.Get(url)
+		if err == nil {
+			resp.Body.Close()
+		}
+		return err != nil
+	})
+}
+
+func TestHealthzIsOffByDefault(t *testing.T) {
+	s := newTestSession(t, config.Config{})
+	s.start()
+	if s.healthAddr != nil {
+		t.Fatalf("health endpoint served on %s without healthListen", s.healthAddr)
+	}
+}
+
+func TestHealthzBindFailureKeepsSession(t *testing.T) {
+	taken, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer taken.Close()
+	s := newTestSession(t, config.Config{HealthListen: taken.Addr().String()})
+	drainEvents(s)
+	s.start()
+	waitEvent(t, s, systemContaining("health endpoint disabled"))
+	waitUntil(t, s.socketAlive)
+}
+
+func TestLastPacketReportsDatagram(t *testing.T) {
+	s := newTestSession(t, config.Config{Name: "alice", Debug: true})
+	s.start()
+	peer, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer peer.Close()
+
+	raw, err := json.Marshal(Message{ID: newMessageID(), Type: chatMsg, From: "bob", Body: "hi", Timestamp: time.Now().Unix(), Version: protocolVersion})
+	if err != nil {
+		t.Fatal(err)
+	}
+	to, _ := net.ResolveUDPAddr("udp", s.localAddr)
+	if _, err := peer.WriteTo(raw, to); err != nil {
+		t.Fatal(err)
+	}
+	waitEvent(t, s, func(m Message) bool { return m.Type == chatMsg && m.Body == "hi" })
+
+	got := s.lastPacketSummary()
+	for _, want := range []string{
+		"source: " + peer.LocalAddr().String(),
+		fmt.Sprintf("size: %d bytes", len(raw)),
+		"type: chat",
+		"encrypted: false",
+		"outcome: handled",
+	} {
+		if !strings.Contains(got, want) {
+			t.Fatalf("missing %q in:\n%s", want, got)
+		}
+	}
+}
+
+func TestLastPacketNeedsDebug(t *testing.T) {
+	s := newTestSession(t, config.Config{})
+	if err := s.handleInput("/lastpacket"); err != nil {
+		t.Fatal(err)
+	}
+	waitEvent(t, s, systemContaining("requires debug mode"))
+}
+
+func TestTrailingBytesAreIgnored(t *testing.T) {
+	s := newTestSession(t, config.Config{Name: "alice", Debug: true})
+	s.start()
+	peer, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer peer.Close()
+
+	raw, err := json.Marshal(Message{ID: newMessageID(), Type: chatMsg, From: "bob", Body: "padded", Timestamp: time.Now().Unix(), Version: protocolVersion})
+	if err != nil {
+		t.Fatal(err)
+	}
+	to, _ := net.ResolveUDPAddr("udp", s.localAddr)
+	if _, err := peer.WriteTo(append(raw, "\x00\x00junk"...), to); err != nil {
+		t.Fatal(err)
+	}
+	waitEvent(t, s, func(m Message) bool { return m.Type == chatMsg && m.Body == "padded" })
+	if got := s.lastPacketSummary(); !strings.Contains(got, "trailing: 6 bytes ignored") {
+		t.Fatalf("trailing bytes not reported:\n%s", got)
+	}
+}
@@ -0,0 +1,174 @@
+package chat
+
+import (
+	"errors"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"yap/internal/config"
+)
+
+// testTimeout bounds how long a test waits for network activity.
+const testTimeout = 5 * time.Second
+
+// newTestSession binds an unstarted session to a loopback port and shuts it
+// down when the test ends.
+func newTestSession(t *testing.T, cfg config.Config) *session {
+	t.Helper()
+	return newTestSessionWith(t, sessionOptions{config: cfg})
+}
+
+// newTestSessionWith is newTestSession with full control over the options.
+func newTestSessionWith(t *testing.T, opts sessionOptions) *session {
+	t.Helper()
+	if opts.config.Name == "" {
+		opts.config.Name = "tester"
+	}
+	if opts.config.Listen == "" {
+		opts.config.Listen = "127.0.0.1:0"
+	}
+	s, err := newSession(opts)
+	if err != nil {
+		t.Fatalf("newSession: %v", err)
+	}
+	t.Cleanup(func() { _, _ = s.shutdown() })
+	return s
+}
+
+// drainEvents returns the events queued on s without waiting.
+func drainEvents(s *session) []Message {
+	var out []Message
+	for {
+		select {
+		case msg, ok := <-s.events:
+			if !ok {
+				return out
+			}
+			out = append(out, msg)
+		default:
+			return out
+		}
+	}
+}
+
+// waitEvent reads events from s until match accepts one.
+func waitEvent(t *testing.T, s *session, match func(Message) bool) Message {
+	t.Helper()
+	deadline := time.After(testTimeout)
+	for {
+		select {
+		case msg, ok := <-s.events:
+			if !ok {
+				t.Fatal("event stream closed")
+			}
+			if match(msg) {
+				return msg
+			}
+		case <-deadline:
+			t.Fatal("timed out waiting for event")
+		}
+	}
+}
+
+// systemContaining matches system notices whose body contains text.
+func systemContaining(text string) func(Message) bool {
+	return func(msg Message) bool {
+		return msg.Type == systemMsg && strings.Contains(msg.Body, text)
+	}
+}
+
+// waitUntil polls cond until it holds.
+func waitUntil(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(testTimeout)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met in time")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// connect starts a and b and waits until each lists the other as active.
+func connect(t *testing.T, a, b *session) {
+	t.Helper()
+	a.start()
+	b.start()
+	if err := b.addPeer(a.localAddr); err != nil {
+		t.Fatalf("addPeer: %v", err)
+	}
+	waitUntil(t, func() bool { return isActive(a, b.localAddr) && isActive(b, a.localAddr) })
+}
+
+// isActive reports whether s lists addr as an active member.
+func isActive(s *session, addr string) bool {
+	rec, ok := s.lookupMember(addr)
+	return ok && rec.Status == statusActive
+}
+
+// fakeConn wraps a real socket so tests can slow or drop datagrams and see
+// how many writes run at once.
+type fakeConn struct {
+	net.PacketConn
+	// writeDelay stalls every write.
+	writeDelay time.Duration
+	// dropRead discards matching inbound datagrams before the session sees them.
+	dropRead func([]byte) bool
+	// failWrites makes that many writes fail before any reach the socket.
+	failWrites int
+
+	mu          sync.Mutex
+	inflight    int
+	maxInflight int
+}
+
+// listenFake returns a sessionOptions.listen func that binds a loopback
+// socket wrapped by fc.
+func listenFake(fc *fakeConn) func(string) (net.PacketConn, error) {
+	return func(addr string) (net.PacketConn, error) {
+		conn, err := net.ListenPacket("udp", addr)
+		if err != nil {
+			return nil, err
+		}
+		fc.PacketConn = conn
+		return fc, nil
+	}
+}
+
+func (c *fakeConn) ReadFrom(p []byte) (int, net.Addr, error) {
+	for {
+		n, addr, err := c.PacketConn.ReadFrom(p)
+		if err != nil || c.dropRead == nil || !c.dropRead(p[:n]) {
+			return n, addr, err
+		}
+	}
+}
+
+func (c *fakeConn) WriteTo(p []byte, addr net.Addr) (int, error) {
+	c.mu.Lock()
+	if c.failWrites > 0 {
+		c.failWrites--
+		c.mu.Unlock()
+		return 0, errors.New("injected write failure")
+	}
+	c.inflight++
+	c.maxInflight = max(c.maxInflight, c.inflight)
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		c.inflight--
+		c.mu.Unlock()
+	}()
+	time.Sleep(c.writeDelay)
+	return c.PacketConn.WriteTo(p, addr)
+}
+
+// peakWrites reports the most writes that were in flight at once.
+func (c *fakeConn) peakWrites() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.maxInflight
+}
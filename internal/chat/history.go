@@ -0,0 +1,156 @@
+package chat
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+)
+
+// maxSharedHistory caps how many messages are replayed to a joining peer.
+const maxSharedHistory = 50
+
+// recordHistory appends a chat message to the bounded history ring. Private
+// messages and sequence metadata are left out.
+func (s *session) recordHistory(msg Message) {
+	if s.cfg.History <= 0 || msg.ID == "" || msg.Type != chatMsg || msg.To != "" {
+		return
+	}
+	entry := Message{
+		ID:        msg.ID,
+		From:      msg.From,
+		Body:      msg.Body,
+		Type:      chatMsg,
+		Timestamp: msg.Timestamp,
+		Room:      msg.Room,
+		ReplyTo:   msg.ReplyTo,
+	}
+	s.historyMu.Lock()
+	defer s.historyMu.Unlock()
+	s.history = append(s.history, entry)
+	if len(s.history) > s.cfg.History {
+		s.history = s.history[len(s.history)-s.cfg.History:]
+	}
+}
+
+// reviseHistory applies an edit or retraction from the original sender.
+func (s *session) reviseHistory(msg Message) {
+	if msg.Ref == "" {
+		return
+	}
+	s.historyMu.Lock()
+	defer s.historyMu.Unlock()
+	for i := range s.history {
+		if s.history[i].ID != msg.Ref || !namesEqual(s.history[i].From, msg.From, s.cfg.FoldNames) {
+			continue
+		}
+		if msg.Type == deleteMsg {
+			s.history = append(s.history[:i], s.history[i+1:]...)
+		} else {
+			s.history[i].Body = msg.Body
+		}
+		return
+	}
+}
+
+// historySnapshot returns up to limit of the newest history entries, oldest first.
+func (s *session) historySnapshot(limit int) []Message {
+	s.historyMu.Lock()
+	defer s.historyMu.Unlock()
+	start := 0
+	if limit > 0 && len(s.history) > limit {
+		start = len(s.history) - limit
+	}
+	return append([]Message(nil), s.history[start:]...)
+}
+
+// replayHistory re-emits the retained history to the UI.
+func (s *session) replayHistory() {
+	entries := s.historySnapshot(0)
+	if len(entries) == 0 {
+		s.emitSystem("no history recorded")
+		return
+	}
+	s.emitSystem("history (%d messages):", len(entries))
+	for _, entry := range entries {
+		s.emit(entry)
+	}
+}
+
+// shareHistory sends recent history to a peer that just joined.
+func (s *session) shareHistory(addr net.Addr) {
+	entries := s.historySnapshot(maxSharedHistory)
+	if len(entries) == 0 {
+		return
+	}
+	data, err := json.Marshal(entries)
+	if err != nil {
+		return
+	}
+	if err := s.sendDirect(addr, historyMsg, string(data)); err != nil {
+		s.emitSystem("failed to share history with %s: %v", addr, err)
+	}
+}
+
+// handleHistoryPayload emits shared history entries that have not been seen yet.
+func (s *session) handleHistoryPayload(body string, from string) {
+	var entries []Message
+	if err := json.Unmarshal([]byte(body), &entries); err != nil {
+		return
+	}
+	fresh := 0
+	for _, entry := range entries {
+		if entry.ID == "" || entry.Type != chatMsg || entry.To != "" {
+			continue
+		}
+		if s.transport.seen.loadOrStore(entry.ID) {
+			continue
+		}
+		if fresh == 0 {
+			s.emitSystem("catching up on history from %s", from)
+		}
+		fresh++
+		s.recordHistory(entry)
+		s.rememberRecent(entry)
+		s.emit(entry)
+	}
+}
+
+// loadHistory restores persisted history and marks its IDs as seen.
+func (s *session) loadHistory(path string) error {
+	bytes, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return fmt.Errorf("read history: %w", err)
+	}
+	var entries []Message
+	if err := json.Unmarshal(bytes, &entries); err != nil {
+		return fmt.Errorf("parse history: %w", err)
+	}
+	for _, entry := range entries {
+		s.transport.seen.store(entry.ID)
+		s.recordHistory(entry)
+		s.rememberRecent(entry)
+	}
+	return nil
+}
+
+// saveHistory writes the retained history to path atomically.
+func (s *session) saveHistory(path string) error {
+	bytes, err := json.Marshal(s.historySnapshot(0))
+	if err != nil {
+		return fmt.Errorf("encode history: %w", err)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, bytes, 0o600); err != nil {
+		return fmt.Errorf("write history: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		_ = os.Remove(tmp)
+		return fmt.Errorf("persist history: %w", err)
+	}
+	return nil
+}
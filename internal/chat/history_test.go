@@ -0,0 +1,97 @@
+package chat
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"testing"
+
+	"yap/internal/config"
+)
+
+// historyBodies lists the bodies in s's history, oldest first.
+func historyBodies(s *session) []string {
+	var bodies []string
+	for _, msg := range s.historySnapshot(0) {
+		bodies = append(bodies, msg.Body)
+	}
+	return bodies
+}
+
+func TestHistoryKeepsNewestPublicChat(t *testing.T) {
+	s := newTestSession(t, config.Config{History: 2})
+	s.recordHistory(Message{ID: "1", Type: chatMsg, From: "bob", Body: "one"})
+	s.recordHistory(Message{ID: "p", Type: chatMsg, From: "bob", Body: "private", To: "10.0.0.1:4000"})
+	s.recordHistory(Message{ID: "2", Type: chatMsg, From: "bob", Body: "two"})
+	s.recordHistory(Message{ID: "3", Type: chatMsg, From: "bob", Body: "three"})
+
+	if got := historyBodies(s); len(got) != 2 || got[0] != "two" || got[1] != "three" {
+		t.Fatalf("history = %q, want [two three]", got)
+	}
+}
+
+func TestHistoryAppliesSenderRevisions(t *testing.T) {
+	s := newTestSession(t, config.Config{History: 10})
+	s.recordHistory(Message{ID: "1", Type: chatMsg, From: "bob", Body: "helo"})
+	s.recordHistory(Message{ID: "2", Type: chatMsg, From: "bob", Body: "oops"})
+
+	s.reviseHistory(Message{Type: editMsg, From: "carol", Ref: "1", Body: "forged"})
+	s.reviseHistory(Message{Type: editMsg, From: "bob", Ref: "1", Body: "hello"})
+	s.reviseHistory(Message{Type: deleteMsg, From: "bob", Ref: "2"})
+	if got := historyBodies(s); len(got) != 1 || got[0] != "hello" {
+		t.Fatalf("history = %q, want [hello]", got)
+	}
+}
+
+func TestHistorySaveAndLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history")
+	s := newTestSession(t, config.Config{History: 10})
+	s.recordHistory(Message{ID: "abc123", Type: chatMsg, From: "bob", Body: "remember me"})
+	if err := s.saveHistory(path); err != nil {
+		t.Fatal(err)
+	}
+
+	restored := newTestSession(t, config.Config{History: 10})
+	if err := restored.loadHistory(path); err != nil {
+		t.Fatal(err)
+	}
+	if got := historyBodies(restored); len(got) != 1 || got[0] != "remember me" {
+		t.Fatalf("restored history = %q", got)
+	}
+	if !restored.transport.seen.loadOrStore("abc123") {
+		t.Fatal("restored message would be shown again if relayed")
+	}
+}
+
+func TestSharedHistorySkipsSeenMessages(t *testing.T) {
+	s := newTestSession(t, config.Config{History: 10})
+	s.transport.seen.store("old")
+	entries := []Message{
+		{ID: "old", Type: chatMsg, From: "bob", Body: "already seen"},
+		{ID: "new", Type: chatMsg, From: "bob", Body: "missed"},
+		{ID: "dm", Type: chatMsg, From: "bob", Body: "private", To: "10.0.0.1:4000"},
+	}
+	data, err := json.Marshal(entries)
+	if err != nil {
+		t.Fatal(err)
+	}
+	drainEvents(s)
+	s.handleHistoryPayload(string(data), "bob")
+
+	var shown []string
+	for _, msg := range drainEvents(s) {
+		if msg.Type == chatMsg {
+			shown = append(shown, msg.Body)
+		}
+	}
+	if len(shown) != 1 || shown[0] != "missed" {
+		t.Fatalf("shown %q, want only the missed message", shown)
+	}
+}
+
+func TestJoinTriggersHistoryShare(t *testing.T) {
+	a := newTestSession(t, config.Config{Name: "alice", History: 10, ShareHistory: true})
+	a.recordHistory(Message{ID: newMessageID(), Type: chatMsg, From: "alice", Body: "before you came"})
+	b := newTestSession(t, config.Config{Name: "bob"})
+	connect(t, a, b)
+	waitEvent(t, b, func(msg Message) bool { return msg.Type == chatMsg && msg.Body == "before you came" })
+}
@@ -0,0 +1,108 @@
+package chat
+
+import (
+	"slices"
+	"strings"
+	"unicode"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// ansiCursor draws the input cursor in reverse video.
+const ansiCursor = "\033[7m"
+
+// edit applies a line-editing key to the input, reporting whether key was one.
+func (m *bubbleModel) edit(msg tea.KeyMsg) bool {
+	m.cursor = min(max(m.cursor, 0), len(m.input))
+	if msg.Paste || (msg.Type == tea.KeyRunes && len(msg.Runes) > 1 && !msg.Alt) {
+		// Bracketed pastes, and runs of runes from terminals without them,
+		// land in the input whole instead of being submitted line by line.
+		m.paste(msg.Runes)
+		return true
+	}
+	switch msg.Type {
+	case tea.KeyLeft:
+		m.cursor = max(m.cursor-1, 0)
+	case tea.KeyRight:
+		m.cursor = min(m.cursor+1, len(m.input))
+	case tea.KeyHome, tea.KeyCtrlA:
+		m.cursor = 0
+	case tea.KeyEnd, tea.KeyCtrlE:
+		m.cursor = len(m.input)
+	case tea.KeyBackspace, tea.KeyCtrlH:
+		if m.cursor > 0 {
+			m.input = append(m.input[:m.cursor-1], m.input[m.cursor:]...)
+			m.cursor--
+		}
+	case tea.KeyDelete:
+		if m.cursor < len(m.input) {
+			m.input = append(m.input[:m.cursor], m.input[m.cursor+1:]...)
+		}
+	case tea.KeyCtrlU:
+		m.input = m.input[:0]
+		m.cursor = 0
+	case tea.KeyCtrlW:
+		start := m.cursor
+		for start > 0 && unicode.IsSpace(m.input[start-1]) {
+			start--
+		}
+		for start > 0 && !unicode.IsSpace(m.input[start-1]) {
+			start--
+		}
+		m.input = append(m.input[:start], m.input[m.cursor:]...)
+		m.cursor = start
+	default:
+		s := msg.String()
+		if s == "" || len([]rune(s)) != 1 || msg.Alt {
+			return false
+		}
+		m.insert([]rune(s)[0])
+	}
+	return true
+}
+
+// insert places r at the cursor and advances past it.
+func (m *bubbleModel) insert(r rune) {
+	m.input = append(m.input, 0)
+	copy(m.input[m.cursor+1:], m.input[m.cursor:])
+	m.input[m.cursor] = r
+	m.cursor++
+}
+
+// paste inserts a bracketed paste at the cursor in one go. Line breaks are
+// kept, so a multi-line paste is sent as one multi-line message once Enter
+// is pressed; other control characters are dropped.
+func (m *bubbleModel) paste(runes []rune) {
+	text := strings.ReplaceAll(string(runes), "\r\n", "\n")
+	text = strings.ReplaceAll(text, "\r", "\n")
+	kept := []rune(strings.Map(func(r rune) rune {
+		if r != '\n' && r != '\t' && unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, text))
+	m.input = slices.Insert(m.input, m.cursor, kept...)
+	m.cursor += len(kept)
+}
+
+// renderInput draws the input line with the cursor highlighted; themes
+// without escape codes show no cursor.
+func (m *bubbleModel) renderInput() string {
+	if m.opts.theme.reset == "" {
+		return m.inputText(m.input)
+	}
+	cursor := min(max(m.cursor, 0), len(m.input))
+	under := " "
+	after := ""
+	if cursor < len(m.input) {
+		under = m.inputText(m.input[cursor : cursor+1])
+		after = m.inputText(m.input[cursor+1:])
+	}
+	return m.inputText(m.input[:cursor]) + ansiCursor + under + m.opts.theme.reset + after
+}
+
+// inputText renders input runes on one line, showing pasted line breaks as
+// the newline glyph.
+func (m *bubbleModel) inputText(runes []rune) string {
+	return strings.ReplaceAll(string(runes), "\n", m.opts.glyphs.newline)
+}
@@ -0,0 +1,109 @@
+package chat
+
+import (
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"yap/internal/config"
+)
+
+// typeKeys feeds keys to m, treating plain strings as typed text.
+func typeKeys(m *bubbleModel, keys ...any) {
+	for _, key := range keys {
+		switch k := key.(type) {
+		case string:
+			for _, r := range k {
+				m.edit(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{r}})
+			}
+		case tea.KeyType:
+			m.edit(tea.KeyMsg{Type: k})
+		}
+	}
+}
+
+func TestInputEditing(t *testing.T) {
+	cases := []struct {
+		name   string
+		keys   []any
+		input  string
+		cursor int
+	}{
+		{"insert mid-line", []any{"helo", tea.KeyLeft, "l"}, "hello", 4},
+		{"home and delete", []any{"xhello", tea.KeyHome, tea.KeyDelete}, "hello", 0},
+		{"backspace mid-line", []any{"helllo", tea.KeyLeft, tea.KeyLeft, tea.KeyBackspace}, "hello", 3},
+		{"end after moving", []any{"hell", tea.KeyHome, tea.KeyRight, tea.KeyEnd, "o"}, "hello", 5},
+		{"cursor stays in bounds", []any{"hi", tea.KeyRight, tea.KeyRight, tea.KeyHome, tea.KeyLeft, tea.KeyBackspace}, "hi", 0},
+		{"delete at end is a no-op", []any{"hi", tea.KeyDelete}, "hi", 2},
+		{"ctrl+u clears", []any{"hello world", tea.KeyLeft, tea.KeyCtrlU}, "", 0},
+		{"ctrl+w deletes a word", []any{"hello big  ", tea.KeyCtrlW}, "hello ", 6},
+		{"ctrl+w mid-line", []any{"one two three", tea.KeyLeft, tea.KeyLeft, tea.KeyLeft, tea.KeyLeft, tea.KeyLeft, tea.KeyCtrlW}, "one three", 4},
+	}
+	for _, tc := range cases {
+		m := newBubbleModel("alice", nil, nil, uiOptionsFrom(config.Config{}))
+		typeKeys(m, tc.keys...)
+		if string(m.input) != tc.input || m.cursor != tc.cursor {
+			t.Errorf("%s: input %q cursor %d, want %q cursor %d", tc.name, string(m.input), m.cursor, tc.input, tc.cursor)
+		}
+	}
+}
+
+func TestInputRendersCursor(t *testing.T) {
+	m := newBubbleModel("alice", nil, nil, uiOptionsFrom(config.Config{}))
+	typeKeys(m, "abc", tea.KeyLeft)
+	if got := m.renderInput(); !strings.Contains(got, "ab"+ansiCursor+"c") {
+		t.Fatalf("cursor not drawn on c: %q", got)
+	}
+}
+
+func TestPasteLandsWhole(t *testing.T) {
+	var sent []string
+	submit := func(text string) error {
+		sent = append(sent, text)
+		return nil
+	}
+	m := newBubbleModel("alice", nil, submit, uiOptionsFrom(config.Config{}))
+	typeKeys(m, "<>", tea.KeyLeft)
+	m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("one\r\ntwo\rthree\x07\tend"), Paste: true})
+	if want := "<one\ntwo\nthree\tend>"; string(m.input) != want || m.cursor != len([]rune(want))-1 {
+		t.Fatalf("input %q cursor %d, want %q with the cursor after the paste", string(m.input), m.cursor, want)
+	}
+	if len(sent) != 0 {
+		t.Fatalf("paste submitted %q", sent)
+	}
+
+	m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	if len(sent) != 1 || sent[0] != "<one\ntwo\nthree\tend>" {
+		t.Fatalf("submitted %q, want one multi-line message", sent)
+	}
+}
+
+func TestUnbracketedRuneRunIsPasted(t *testing.T) {
+	m := newBubbleModel("alice", nil, nil, uiOptionsFrom(config.Config{}))
+	m.edit(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("a\nb")})
+	if string(m.input) != "a\nb" {
+		t.Fatalf("input %q, want the run kept whole", string(m.input))
+	}
+	// Alt chords arrive as rune runs too and are not text.
+	m.edit(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("xy"), Alt: true})
+	if string(m.input) != "a\nb" {
+		t.Fatalf("alt chord inserted: %q", string(m.input))
+	}
+}
+
+func TestPastedNewlinesRenderAsGlyph(t *testing.T) {
+	for _, tc := range []struct {
+		cfg  config.Config
+		want string
+	}{
+		{config.Config{Theme: "mono"}, "a↵b"},
+		{config.Config{Theme: "mono", ASCII: true}, "a^Jb"},
+	} {
+		m := newBubbleModel("alice", nil, nil, uiOptionsFrom(tc.cfg))
+		m.paste([]rune("a\nb"))
+		if got := m.renderInput(); got != tc.want {
+			t.Errorf("ascii=%v: rendered %q, want %q", tc.cfg.ASCII, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package chat
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"yap/internal/config"
+)
+
+func TestKeepalivesReachActivePeers(t *testing.T) {
+	s := newTestSession(t, config.Config{Keepalive: "20ms", Heartbeat: "off"})
+	peer := listenPeer(t, s, "bob")
+	s.start()
+
+	// Skip the join sent on start.
+	buf := make([]byte, 64<<10)
+	_ = peer.SetReadDeadline(time.Now().Add(testTimeout))
+	for {
+		n, _, err := peer.ReadFrom(buf)
+		if err != nil {
+			t.Fatalf("no keepalive arrived: %v", err)
+		}
+		if bytes.Equal(buf[:n], keepaliveFrame) {
+			return
+		}
+	}
+}
+
+func TestKeepaliveFramesAreIgnored(t *testing.T) {
+	s := newTestSession(t, config.Config{Keepalive: "off", Heartbeat: "off"})
+	peer := listenPeer(t, s, "bob")
+	s.start()
+	drainEvents(s)
+
+	if _, err := peer.WriteTo(keepaliveFrame, s.transport.localAddr()); err != nil {
+		t.Fatal(err)
+	}
+	waitUntil(t, func() bool { return s.metrics().PacketsReceived == 1 })
+	if m := s.metrics(); m.MessagesReceived != 0 || m.Dropped != 0 {
+		t.Fatalf("keepalive counted as a message or a drop: %+v", m)
+	}
+	if events := drainEvents(s); len(events) != 0 {
+		t.Fatalf("keepalive surfaced events: %+v", events)
+	}
+}
+
+func TestHeartbeatDemotesSilentPeers(t *testing.T) {
+	clock := newFakeClock()
+	s := newTestSessionWith(t, sessionOptions{config: config.Config{PeerTimeout: "1m"}, now: clock.Now})
+	quiet := listenPeer(t, s, "bob")
+	clock.advance(45 * time.Second)
+	chatty := listenPeer(t, s, "carol")
+	clock.advance(30 * time.Second)
+	drainEvents(s)
+
+	s.heartbeatTick()
+	if isActive(s, canonicalNetAddr(quiet.LocalAddr())) {
+		t.Fatal("silent peer still active")
+	}
+	if !isActive(s, canonicalNetAddr(chatty.LocalAddr())) {
+		t.Fatal("recently heard peer demoted")
+	}
+	waitEvent(t, s, func(msg Message) bool {
+		return msg.Type == memberMsg && msg.Body == MemberPending && msg.Reason == "timed out"
+	})
+}
+
+func TestPingIsAnsweredWithPong(t *testing.T) {
+	s := newTestSession(t, config.Config{Name: "alice"})
+	peer := listenPeer(t, s, "bob")
+	s.handleIncoming(Message{ID: newMessageID(), Type: pingMsg, From: "bob", Body: "12345"}, peer.LocalAddr(), nil, true)
+	if msg := readMessage(t, peer); msg.Type != pongMsg || msg.Body != "12345" {
+		t.Fatalf("got %s %q, want the ping echoed in a pong", msg.Type, msg.Body)
+	}
+}
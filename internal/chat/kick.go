@@ -0,0 +1,98 @@
+package chat
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// blockKey canonicalizes a peer address for the kick blocklist.
+func blockKey(raw string) string {
+	addr, ok := normalizeAddr(raw, raw)
+	if !ok {
+		addr = strings.TrimSpace(raw)
+	}
+	return addr
+}
+
+// blockedLocked reports whether addr has been kicked. The caller must hold membersMu.
+func (s *session) blockedLocked(addr string) bool {
+	_, ok := s.blocked[addr]
+	return ok
+}
+
+// isBlocked reports whether raw names a kicked address.
+func (s *session) isBlocked(raw string) bool {
+	if s == nil {
+		return false
+	}
+	addr := blockKey(raw)
+	s.membersMu.RLock()
+	defer s.membersMu.RUnlock()
+	return s.blockedLocked(addr)
+}
+
+// blockAddrs adds addresses to the blocklist so they are never contacted or
+// accepted as members again in this session.
+func (s *session) blockAddrs(addrs ...string) {
+	s.membersMu.Lock()
+	defer s.membersMu.Unlock()
+	if s.blocked == nil {
+		s.blocked = make(map[string]struct{})
+	}
+	for _, raw := range addrs {
+		if addr := blockKey(raw); addr != "" {
+			s.blocked[addr] = struct{}{}
+		}
+	}
+}
+
+// blockedAddrs lists the blocklist in sorted order.
+func (s *session) blockedAddrs() []string {
+	s.membersMu.RLock()
+	defer s.membersMu.RUnlock()
+	out := make([]string, 0, len(s.blocked))
+	for addr := range s.blocked {
+		out = append(out, addr)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// forgetAddr discards everything the session remembers about a peer address:
+// its member entry, unacknowledged messages, gossip mentions, and replays.
+func (s *session) forgetAddr(addr string) {
+	s.removeMember(addr)
+	s.forgetAcks(addr)
+	s.membersMu.Lock()
+	delete(s.mentions, addr)
+	s.membersMu.Unlock()
+	s.outboxMu.Lock()
+	delete(s.outbox, addr)
+	s.outboxMu.Unlock()
+}
+
+// kick removes the peer named by an address or display name and blocks its
+// address, so it stays out even when other peers keep advertising it.
+func (s *session) kick(target string) error {
+	keys, _ := s.memberKeysByName([]string{target})
+	switch {
+	case len(keys) > 1:
+		return fmt.Errorf("%q matches %d peers; kick one address at a time", target, len(keys))
+	case len(keys) == 0:
+		addr, ok := normalizeAddr(target, target)
+		if !ok {
+			return fmt.Errorf("unknown peer %q", target)
+		}
+		keys = []string{addr}
+	}
+	addr := keys[0]
+	if s.isLocal(addr) {
+		return fmt.Errorf("cannot kick this node")
+	}
+	s.blockAddrs(addr)
+	s.forgetAddr(addr)
+	s.recordPeerEvent(addr, "kicked %s", addr)
+	s.emitSystem("kicked %s; it will not be re-added this session", addr)
+	return nil
+}
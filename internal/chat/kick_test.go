@@ -0,0 +1,52 @@
+package chat
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"yap/internal/config"
+)
+
+func TestKickedPeerStaysOut(t *testing.T) {
+	s := newTestSession(t, config.Config{Name: "alice"})
+	bob := listenPeer(t, s, "bob")
+	carol := listenPeer(t, s, "carol")
+	bobAddr := canonicalNetAddr(bob.LocalAddr())
+
+	if err := s.handleInput("/kick bob"); err != nil {
+		t.Fatal(err)
+	}
+	waitEvent(t, s, systemContaining("kicked "+bobAddr))
+	if s.hasMember(bobAddr) {
+		t.Fatal("kicked peer still a member")
+	}
+
+	// Carol keeps advertising bob.
+	body, err := json.Marshal(peersPayload{Peers: []memberInfo{{Addr: bobAddr, Name: "bob"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.handleIncoming(Message{ID: newMessageID(), Type: peersMsg, From: "carol", Body: string(body)}, carol.LocalAddr(), nil, true)
+	// Bob's own traffic is ignored too.
+	s.handleIncoming(Message{ID: newMessageID(), Type: chatMsg, From: "bob", Body: "let me in"}, bob.LocalAddr(), nil, true)
+	s.contactPeer(bobAddr)
+	if s.hasMember(bobAddr) {
+		t.Fatal("kicked peer re-added")
+	}
+	for _, msg := range drainEvents(s) {
+		if msg.Type == chatMsg {
+			t.Fatalf("chat from a kicked peer shown: %+v", msg)
+		}
+	}
+	if err := s.addPeer(bobAddr); err == nil || !strings.Contains(err.Error(), "kicked") {
+		t.Fatalf("addPeer = %v, want a kicked error", err)
+	}
+}
+
+func TestKickRefusesSelf(t *testing.T) {
+	s := newTestSession(t, config.Config{Name: "alice"})
+	if err := s.kick(s.localAddr); err == nil {
+		t.Fatal("kicked this node")
+	}
+}
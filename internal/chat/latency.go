@@ -0,0 +1,38 @@
+package chat
+
+import (
+	"net"
+	"strconv"
+	"time"
+)
+
+// maxRTT discards pong echoes too old to describe the current link.
+const maxRTT = time.Minute
+
+// recordRTT stores the round-trip time implied by a pong echoing the send
+// time of one of our pings.
+func (s *session) recordRTT(addr net.Addr, echo string) {
+	sent, err := strconv.ParseInt(echo, 10, 64)
+	if err != nil {
+		return
+	}
+	rtt := s.now().Sub(time.Unix(0, sent))
+	if rtt <= 0 || rtt > maxRTT {
+		return
+	}
+	key := canonicalNetAddr(addr)
+	s.membersMu.Lock()
+	defer s.membersMu.Unlock()
+	if rec := s.members[key]; rec != nil {
+		rec.RTT = rtt
+	}
+}
+
+// formatRTT rounds a round-trip time for display, keeping sub-millisecond
+// loopback times readable.
+func formatRTT(rtt time.Duration) string {
+	if rtt < time.Millisecond {
+		return rtt.Round(time.Microsecond).String()
+	}
+	return rtt.Round(100 * time.Microsecond).String()
+}
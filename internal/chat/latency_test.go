@@ -0,0 +1,91 @@
+package chat
+
+import (
+	"encoding/json"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"yap/internal/config"
+)
+
+func TestHeartbeatMeasuresLoopbackRTT(t *testing.T) {
+	alice := newTestSession(t, config.Config{Name: "alice"})
+	bob := newTestSession(t, config.Config{Name: "bob"})
+	connect(t, alice, bob)
+
+	alice.heartbeatTick()
+	waitUntil(t, func() bool {
+		rec, _ := alice.lookupMember(bob.localAddr)
+		return rec.RTT > 0
+	})
+
+	drainEvents(alice)
+	if err := alice.handleInput("/whois bob"); err != nil {
+		t.Fatal(err)
+	}
+	whois := waitEvent(t, alice, systemContaining("latency:"))
+	if strings.Contains(whois.Body, "not measured") {
+		t.Fatalf("whois = %q", whois.Body)
+	}
+}
+
+func TestPingIsEchoedButNotRelayed(t *testing.T) {
+	s := newTestSession(t, config.Config{Name: "bob"})
+	s.start()
+	alice := listenPeer(t, s, "alice")
+	carol := listenPeer(t, s, "carol")
+	to, _ := net.ResolveUDPAddr("udp", s.localAddr)
+	send := func(kind msgType, body string) {
+		t.Helper()
+		raw, err := json.Marshal(Message{ID: newMessageID(), Type: kind, From: "alice", Body: body, Timestamp: time.Now().Unix(), Version: protocolVersion})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := alice.WriteTo(raw, to); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	sent := strconv.FormatInt(time.Now().UnixNano(), 10)
+	send(pingMsg, sent)
+	msg := readMessage(t, alice)
+	for msg.Type == joinMsg {
+		msg = readMessage(t, alice)
+	}
+	if msg.Type != pongMsg || msg.Body != sent {
+		t.Fatalf("got %+v, want the pong echo", msg)
+	}
+	send(pongMsg, sent)
+	expectNoKind(t, carol, pingMsg, pongMsg)
+}
+
+func TestRecordRTTIgnoresBadEchoes(t *testing.T) {
+	clock := newFakeClock()
+	s := newTestSessionWith(t, sessionOptions{now: clock.Now})
+	peer := listenPeer(t, s, "bob")
+
+	for _, echo := range []string{
+		"garbage",
+		strconv.FormatInt(clock.Now().Add(time.Second).UnixNano(), 10),
+		strconv.FormatInt(clock.Now().Add(-2*maxRTT).UnixNano(), 10),
+	} {
+		s.recordRTT(peer.LocalAddr(), echo)
+	}
+	if rec, _ := s.lookupMember(canonicalNetAddr(peer.LocalAddr())); rec.RTT != 0 {
+		t.Fatalf("rtt = %v, want unmeasured", rec.RTT)
+	}
+}
+
+func TestFormatRTT(t *testing.T) {
+	for rtt, want := range map[time.Duration]string{
+		123456 * time.Nanosecond:   "123µs",
+		12345678 * time.Nanosecond: "12.3ms",
+	} {
+		if got := formatRTT(rtt); got != want {
+			t.Errorf("formatRTT(%v) = %q, want %q", rtt, got, want)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package chat
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"yap/internal/config"
+)
+
+func TestListenOnSeveralAddresses(t *testing.T) {
+	s := newTestSession(t, config.Config{Name: "alice", Listen: "127.0.0.1:0, [::1]:0"})
+	addrs := s.transport.localAddrs()
+	if len(addrs) != 2 {
+		t.Skipf("IPv6 loopback unavailable: bound %v", addrs)
+	}
+	s.start()
+
+	for _, local := range addrs {
+		host := local.(*net.UDPAddr).IP.String()
+
+		// Each socket feeds the same session...
+		bob := newTestSession(t, config.Config{Name: "bob", Listen: net.JoinHostPort(host, "0")})
+		bob.start()
+		if err := bob.addPeer(local.String()); err != nil {
+			t.Fatal(err)
+		}
+		waitUntil(t, func() bool { return isActive(s, bob.localAddr) })
+
+		// ...and sends leave from the socket of the matching family.
+		peer, err := net.ListenPacket("udp", net.JoinHostPort(host, "0"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer peer.Close()
+		if err := s.sendDirect(peer.LocalAddr(), chatMsg, "hi"); err != nil {
+			t.Fatalf("send to %s: %v", peer.LocalAddr(), err)
+		}
+		_ = peer.SetReadDeadline(time.Now().Add(testTimeout))
+		if _, from, err := peer.ReadFrom(make([]byte, 64<<10)); err != nil || from.String() != local.String() {
+			t.Fatalf("datagram from %v (%v), want %s", from, err, local)
+		}
+	}
+}
+
+func TestListenContinuesWhenOneBindFails(t *testing.T) {
+	s := newTestSession(t, config.Config{Name: "alice", Listen: "127.0.0.1:0,192.0.2.1:1"})
+
+	if n := len(s.transport.localAddrs()); n != 1 {
+		t.Fatalf("bound %d sockets, want 1", n)
+	}
+	waitEvent(t, s, systemContaining("continuing without it"))
+}
@@ -2,7 +2,9 @@ package chat
 
 import (
 	"encoding/json"
+	"net"
 	"net/netip"
+	"slices"
 	"sort"
 	"strings"
 	"time"
@@ -15,11 +17,28 @@ const (
 	statusActive
 )
 
+// String renders the status for display.
+func (st status) String() string {
+	switch st {
+	case statusActive:
+		return "active"
+	case statusPending:
+		return "pending"
+	default:
+		return "unknown"
+	}
+}
+
 type member struct {
 	Addr     string
 	Name     string
 	Status   status
 	LastSeen time.Time
+	// RTT is the last round-trip time measured by a heartbeat ping.
+	RTT time.Duration
+	// Host is the hostname the peer was configured by, re-resolved
+	// periodically; empty for peers given as literal addresses.
+	Host     string
 	endpoint netip.AddrPort
 }
 
@@ -94,8 +113,13 @@ func (s *session) resetMembership(localAddr string) {
 	}
 	s.membersMu.Lock()
 	s.members = make(map[string]*member)
+	s.mentions = nil
 	s.setLocalAddrLocked(localAddr)
 	s.membersMu.Unlock()
+
+	s.outboxMu.Lock()
+	s.outbox = nil
+	s.outboxMu.Unlock()
 }
 
 // setLocalAddr updates the local advertised address and member record.
@@ -111,6 +135,91 @@ func (s *session) setLocalAddr(addr string) {
 	s.membersMu.Unlock()
 }
 
+// moveLocalAddr replaces the local member entry after the session rebinds,
+// leaving every other member in place.
+func (s *session) moveLocalAddr(addr string) {
+	if s == nil {
+		return
+	}
+	s.membersMu.Lock()
+	defer s.membersMu.Unlock()
+	if s.members == nil {
+		s.members = make(map[string]*member)
+	}
+	if s.localAddr != "" {
+		delete(s.members, s.localAddr)
+	}
+	s.setLocalAddrLocked(addr)
+}
+
+// relocateMember moves the member that was reachable at one of previous, or
+// failing that the only member named name, to the endpoint source. Unspecified
+// previous addresses match members on the same host as source. It returns the
+// old key when a member was moved.
+func (s *session) relocateMember(previous []string, name string, source netip.AddrPort) (string, bool) {
+	if s == nil || !source.IsValid() {
+		return "", false
+	}
+	newKey := formatAddrPort(source)
+	if s.isLocal(newKey) {
+		return "", false
+	}
+	var prev []netip.AddrPort
+	for _, raw := range previous {
+		if ap, err := netip.ParseAddrPort(canonicalAddrString(raw)); err == nil {
+			prev = append(prev, netip.AddrPortFrom(ap.Addr().Unmap(), ap.Port()))
+		}
+	}
+
+	s.membersMu.Lock()
+	defer s.membersMu.Unlock()
+	oldKey := ""
+	for key := range s.members {
+		ap, err := netip.ParseAddrPort(key)
+		if err != nil || key == newKey || key == s.localAddr {
+			continue
+		}
+		ap = netip.AddrPortFrom(ap.Addr().Unmap(), ap.Port())
+		for _, p := range prev {
+			sameHost := p.Addr().IsUnspecified() && ap.Port() == p.Port() && ap.Addr().WithZone("") == source.Addr().Unmap().WithZone("")
+			if sameScopedHost(ap, p) || sameHost {
+				oldKey = key
+				break
+			}
+		}
+		if oldKey != "" {
+			break
+		}
+	}
+	if oldKey == "" && name != "" {
+		for key, rec := range s.members {
+			if key == newKey || key == s.localAddr || !namesEqual(rec.Name, name, s.cfg.FoldNames) {
+				continue
+			}
+			if oldKey != "" {
+				// Ambiguous names are left for the usual join flow.
+				return "", false
+			}
+			oldKey = key
+		}
+	}
+	if oldKey == "" {
+		return "", false
+	}
+
+	rec := s.members[oldKey]
+	delete(s.members, oldKey)
+	if existing := s.members[newKey]; existing != nil && existing.Name != "" {
+		rec.Name = existing.Name
+	}
+	rec.Addr = newKey
+	rec.Status = statusActive
+	rec.LastSeen = s.now()
+	rec.SetAddrPort(source)
+	s.members[newKey] = rec
+	return oldKey, true
+}
+
 // refreshLocalIdentity reapplies the local member metadata after config changes.
 func (s *session) refreshLocalIdentity() {
 	if s == nil {
@@ -152,12 +261,31 @@ func (s *session) setLocalAddrLocked(addr string) {
 	}
 	rec.Name = s.cfg.Name
 	rec.Status = statusActive
-	rec.LastSeen = time.Now()
+	rec.LastSeen = s.now()
 	if parsed.IsValid() {
 		rec.SetAddrPort(parsed)
 	}
 }
 
+// setAdvertised records the externally reachable address announced to peers.
+func (s *session) setAdvertised(raw string) {
+	if s == nil {
+		return
+	}
+	raw = strings.TrimSpace(raw)
+	s.membersMu.Lock()
+	defer s.membersMu.Unlock()
+	if raw == "" {
+		s.advertised = ""
+		return
+	}
+	canon, ok := normalizeAddr(raw, s.localAddr)
+	if !ok {
+		canon = raw
+	}
+	s.advertised = canon
+}
+
 // localInfo builds an Info payload for the local participant.
 func (s *session) localInfo() memberInfo {
 	if s == nil {
@@ -165,11 +293,26 @@ func (s *session) localInfo() memberInfo {
 	}
 	s.membersMu.RLock()
 	addr := s.localAddr
+	if s.advertised != "" {
+		addr = s.advertised
+	}
 	name := s.cfg.Name
 	s.membersMu.RUnlock()
 	return memberInfo{Addr: addr, Name: name}
 }
 
+// setBoundAddrs records every socket address so isLocal recognises them all.
+func (s *session) setBoundAddrs(addrs []net.Addr) {
+	s.membersMu.Lock()
+	defer s.membersMu.Unlock()
+	s.boundAddrs = s.boundAddrs[:0]
+	for _, addr := range addrs {
+		if canon := canonicalNetAddr(addr); canon != "" {
+			s.boundAddrs = append(s.boundAddrs, canon)
+		}
+	}
+}
+
 // isLocal reports whether the provided address resolves to this session.
 func (s *session) isLocal(raw string) bool {
 	if s == nil {
@@ -181,13 +324,15 @@ func (s *session) isLocal(raw string) bool {
 	}
 	s.membersMu.RLock()
 	localAddr := s.localAddr
+	advertised := s.advertised
 	localIP := s.localIP
 	localPort := s.localPort
+	bound := slices.Contains(s.boundAddrs, addr)
 	s.membersMu.RUnlock()
 	if addr == "" || localAddr == "" {
 		return false
 	}
-	if addr == localAddr {
+	if bound || addr == localAddr || (advertised != "" && addr == advertised) {
 		return true
 	}
 	ap, err := netip.ParseAddrPort(addr)
@@ -200,7 +345,7 @@ func (s *session) isLocal(raw string) bool {
 	if !localIP.IsValid() || localIP.IsUnspecified() {
 		return true
 	}
-	if ap.Addr() == localIP {
+	if ap.Addr().WithZone("") == localIP.WithZone("") {
 		return true
 	}
 	if localIP.IsLoopback() && ap.Addr().IsLoopback() {
@@ -209,6 +354,29 @@ func (s *session) isLocal(raw string) bool {
 	return false
 }
 
+// resolvesToSelf reports whether addr reaches one of this node's own
+// sockets: the port of a bound socket and either its address or, for a
+// wildcard bind, a loopback or interface address of this host.
+func (s *session) resolvesToSelf(addr net.Addr) bool {
+	ap, ok := addrPortFromNet(addr)
+	if !ok {
+		return false
+	}
+	for _, bound := range s.transport.localAddrs() {
+		local, ok := addrPortFromNet(bound)
+		if !ok || local.Port() != ap.Port() {
+			continue
+		}
+		switch {
+		case local.Addr().WithZone("") == ap.Addr().WithZone(""):
+			return true
+		case local.Addr().IsUnspecified() && (ap.Addr().IsLoopback() || hostHasIP(ap.Addr())):
+			return true
+		}
+	}
+	return false
+}
+
 // addPendingMember records a member hint in the pending state.
 func (s *session) addPendingMember(raw string) bool {
 	if s == nil || s.isLocal(raw) {
@@ -218,24 +386,110 @@ func (s *session) addPendingMember(raw string) bool {
 	if !ok {
 		addr = strings.TrimSpace(raw)
 	}
+	var evicted member
+	var didEvict bool
+	defer func() {
+		// Runs after membersMu is released below.
+		if didEvict {
+			s.noteEvicted(evicted)
+		}
+	}()
 	s.membersMu.Lock()
 	defer s.membersMu.Unlock()
 	if s.members == nil {
 		s.members = make(map[string]*member)
 	}
+	if twin, ok := s.zoneTwinLocked(addr); ok {
+		addr = twin
+	}
+	if s.blockedLocked(addr) {
+		return false
+	}
 	rec, ok := s.members[addr]
 	if !ok {
-		s.members[addr] = &member{Addr: addr, Status: statusPending, LastSeen: time.Now()}
+		evicted, didEvict = s.makeRoomLocked()
+		s.members[addr] = &member{Addr: addr, Status: statusPending, LastSeen: s.now()}
 		return true
 	}
 	if rec.Status != statusPending {
 		rec.Status = statusPending
-		rec.LastSeen = time.Now()
+		rec.LastSeen = s.now()
 		return true
 	}
 	return false
 }
 
+// makeRoomLocked evicts one member when adding another would exceed
+// Config.MaxPeers: the least recently seen pending member, or the least
+// recently seen active one when every member is active. The caller must hold
+// membersMu.
+func (s *session) makeRoomLocked() (member, bool) {
+	limit := s.cfg.MaxPeers
+	peers := len(s.members)
+	if _, ok := s.members[s.localAddr]; ok {
+		peers--
+	}
+	if limit <= 0 || peers < limit {
+		return member{}, false
+	}
+	var victim *member
+	for key, rec := range s.members {
+		if key == s.localAddr {
+			continue
+		}
+		if victim == nil || evictsBefore(rec, victim) {
+			victim = rec
+		}
+	}
+	if victim == nil {
+		return member{}, false
+	}
+	delete(s.members, victim.Addr)
+	delete(s.mentions, victim.Addr)
+	return *victim, true
+}
+
+// evictsBefore orders eviction candidates: pending before active, then least
+// recently seen first.
+func evictsBefore(a, b *member) bool {
+	if (a.Status == statusActive) != (b.Status == statusActive) {
+		return a.Status != statusActive
+	}
+	return a.LastSeen.Before(b.LastSeen)
+}
+
+// noteEvicted reports a member dropped to respect Config.MaxPeers and
+// discards what was queued for it. It must be called without membersMu.
+func (s *session) noteEvicted(rec member) {
+	s.forgetAcks(rec.Addr)
+	s.outboxMu.Lock()
+	delete(s.outbox, rec.Addr)
+	s.outboxMu.Unlock()
+	if rec.Status == statusActive {
+		s.emitPresence(PresenceLeave, rec.Addr, rec.Name, "")
+	}
+	s.recordPeerEvent(rec.Addr, "evicted %s %s to stay within %d peers", rec.Status, rec.Addr, s.cfg.MaxPeers)
+	s.emitSystem("peer limit %d reached; dropped %s peer %s", s.cfg.MaxPeers, rec.Status, rec.Addr)
+}
+
+// zoneTwinLocked finds another member key naming the same scoped host as addr
+// with or without an IPv6 zone. The caller must hold membersMu.
+func (s *session) zoneTwinLocked(addr string) (string, bool) {
+	ap, err := netip.ParseAddrPort(addr)
+	if err != nil || !ap.Addr().Is6() || !ap.Addr().IsLinkLocalUnicast() {
+		return "", false
+	}
+	for key := range s.members {
+		if key == addr {
+			continue
+		}
+		if other, err := netip.ParseAddrPort(key); err == nil && sameScopedHost(ap, other) {
+			return key, true
+		}
+	}
+	return "", false
+}
+
 // markMemberActive transitions a member into the active set, updating metadata.
 func (s *session) markMemberActive(raw, name string) bool {
 	if s == nil || s.isLocal(raw) {
@@ -246,12 +500,29 @@ func (s *session) markMemberActive(raw, name string) bool {
 		addr = strings.TrimSpace(raw)
 	}
 	s.membersMu.Lock()
-	defer s.membersMu.Unlock()
 	if s.members == nil {
 		s.members = make(map[string]*member)
 	}
+	if twin, ok := s.zoneTwinLocked(addr); ok {
+		// Prefer the zoned key: only it can be used to send to a link-local peer.
+		if strings.Contains(addr, "%") {
+			if rec := s.members[twin]; rec != nil && s.members[addr] == nil {
+				s.members[addr] = rec
+			}
+			delete(s.members, twin)
+		} else {
+			addr = twin
+		}
+	}
+	if s.blockedLocked(addr) {
+		s.membersMu.Unlock()
+		return false
+	}
 	rec := s.members[addr]
+	var evicted member
+	var didEvict bool
 	if rec == nil {
+		evicted, didEvict = s.makeRoomLocked()
 		rec = &member{Addr: addr}
 		s.members[addr] = rec
 	}
@@ -261,10 +532,23 @@ func (s *session) markMemberActive(raw, name string) bool {
 	}
 	changed := rec.Status != statusActive
 	rec.Status = statusActive
-	if name != "" {
+	previous := rec.Name
+	if name = normalizeName(name); name != "" {
 		rec.Name = name
 	}
-	rec.LastSeen = time.Now()
+	current := rec.Name
+	rec.LastSeen = s.now()
+	s.membersMu.Unlock()
+	if didEvict {
+		s.noteEvicted(evicted)
+	}
+	if changed {
+		s.emitPresence(PresenceJoin, addr, current, "")
+		s.flushOutbox(addr)
+		s.scheduleGossip()
+	} else if current != previous {
+		s.emitPresence(PresenceRename, addr, current, previous)
+	}
 	return changed
 }
 
@@ -307,12 +591,38 @@ func (s *session) markMemberFailed(raw string) bool {
 	if !ok {
 		return false
 	}
+	if rec.Status == statusActive {
+		defer s.emitPresence(PresenceAway, addr, rec.Name, "")
+	}
 	rec.Status = statusPending
-	rec.LastSeen = time.Now()
+	rec.LastSeen = s.now()
 	rec.ClearAddrPort()
 	return true
 }
 
+// expireMembers demotes active members not seen within maxAge to pending and
+// returns their addresses.
+func (s *session) expireMembers(maxAge time.Duration) []string {
+	if s == nil || maxAge <= 0 {
+		return nil
+	}
+	cutoff := s.now().Add(-maxAge)
+	s.membersMu.Lock()
+	defer s.membersMu.Unlock()
+	var expired []string
+	for addr, rec := range s.members {
+		if addr == s.localAddr || rec.Status != statusActive || !rec.LastSeen.Before(cutoff) {
+			continue
+		}
+		rec.Status = statusPending
+		rec.ClearAddrPort()
+		expired = append(expired, addr)
+		defer s.emitPresence(PresenceAway, addr, rec.Name, "")
+	}
+	sort.Strings(expired)
+	return expired
+}
+
 // removeMember deletes a member from the map entirely.
 func (s *session) removeMember(raw string) bool {
 	if s == nil || s.isLocal(raw) {
@@ -327,13 +637,57 @@ func (s *session) removeMember(raw string) bool {
 	if s.members == nil {
 		s.members = make(map[string]*member)
 	}
-	if _, ok := s.members[addr]; !ok {
+	rec, ok := s.members[addr]
+	if !ok {
 		return false
 	}
 	delete(s.members, addr)
+	defer s.emitPresence(PresenceLeave, addr, rec.Name, "")
 	return true
 }
 
+// memberKeysByName maps names or addresses to member keys, returning any
+// entries that matched no member.
+func (s *session) memberKeysByName(names []string) (keys []string, unknown []string) {
+	s.membersMu.RLock()
+	defer s.membersMu.RUnlock()
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		matched := false
+		for key, rec := range s.members {
+			if key == name || namesEqual(rec.Name, name, s.cfg.FoldNames) {
+				keys = append(keys, key)
+				matched = true
+			}
+		}
+		if !matched {
+			unknown = append(unknown, name)
+		}
+	}
+	return keys, unknown
+}
+
+// lookupMember returns a copy of the member stored under the given address.
+func (s *session) lookupMember(raw string) (member, bool) {
+	if s == nil {
+		return member{}, false
+	}
+	addr, ok := normalizeAddr(raw, raw)
+	if !ok {
+		addr = strings.TrimSpace(raw)
+	}
+	s.membersMu.RLock()
+	defer s.membersMu.RUnlock()
+	rec, exists := s.members[addr]
+	if !exists {
+		return member{}, false
+	}
+	return *rec, true
+}
+
 // hasMember reports whether the member is known to the session.
 func (s *session) hasMember(raw string) bool {
 	if s == nil || s.isLocal(raw) {
@@ -379,12 +733,18 @@ func (s *session) activeAddrs(excludes ...string) []string {
 	return out
 }
 
-// activeEndpoints returns active peers with cached endpoints suitable for send.
-func (s *session) activeEndpoints(exclude string) []memberEndpoint {
+// activeEndpoints returns active peers with cached endpoints suitable for send,
+// skipping any member keyed by one of the excluded addresses.
+func (s *session) activeEndpoints(exclude ...string) []memberEndpoint {
 	if s == nil {
 		return nil
 	}
-	exclude = strings.TrimSpace(exclude)
+	skip := make(map[string]struct{}, len(exclude))
+	for _, key := range exclude {
+		if key = strings.TrimSpace(key); key != "" {
+			skip[key] = struct{}{}
+		}
+	}
 	s.membersMu.RLock()
 	defer s.membersMu.RUnlock()
 	local := s.localAddr
@@ -393,7 +753,7 @@ func (s *session) activeEndpoints(exclude string) []memberEndpoint {
 		if member.Status != statusActive {
 			continue
 		}
-		if key == exclude || key == local {
+		if _, skipped := skip[key]; skipped || key == local {
 			continue
 		}
 		if ap, ok := member.AddrPort(); ok {
@@ -486,11 +846,17 @@ func (s *session) processJoinPayload(data []byte, remoteAddr, remoteName string)
 	if name == "" {
 		name = remoteName
 	}
-	if addr != "" && !s.isLocal(addr) {
-		s.markMemberActive(addr, name)
+	if remote, _ := normalizeAddr(remoteAddr, remoteAddr); s.cfg.NoForward && addr != remote {
+		// A spoke only admits joins sent straight to it, not relayed ones.
+		addr = ""
+	}
+	if addr != "" && !s.isLocal(addr) && s.markMemberActive(addr, name) {
+		s.noteConnected(addr)
 	}
 
-	additional := s.collectUnknown(payload.Peers, addr)
+	// Mentions count against the packet's source, not the address the
+	// payload claims, so one peer cannot pose as several quorum sources.
+	additional := s.collectUnknown(payload.Peers, remoteAddr)
 	response, err := s.buildPeersPayloadData(addr)
 	if err != nil {
 		return nil, additional, err
@@ -513,7 +879,8 @@ func (s *session) processPeersPayload(data []byte, remoteAddr string) ([]string,
 
 // collectUnknown records any peers we have not seen and returns addresses to contact.
 func (s *session) collectUnknown(infos []memberInfo, remote string) []string {
-	if s == nil {
+	if s == nil || s.cfg.NoForward {
+		// Spokes keep to the peers they were given or that contact them.
 		return nil
 	}
 	remoteCanon, okRemote := normalizeAddr(remote, remote)
@@ -523,10 +890,14 @@ func (s *session) collectUnknown(infos []memberInfo, remote string) []string {
 		if !ok {
 			continue
 		}
-		if (okRemote && addr == remoteCanon) || s.isLocal(addr) {
+		if (okRemote && addr == remoteCanon) || s.isLocal(addr) || s.isBlocked(addr) {
+			continue
+		}
+		if !s.hasMember(addr) && !s.confirmMention(addr, remoteCanon) {
 			continue
 		}
 		if s.markMemberActive(addr, info.Name) {
+			s.noteConnected(addr)
 			out = append(out, addr)
 			continue
 		}
@@ -539,6 +910,40 @@ func (s *session) collectUnknown(infos []memberInfo, remote string) []string {
 	return out
 }
 
+// maxMentionCandidates bounds how many unconfirmed gossip addresses are tracked.
+const maxMentionCandidates = 1024
+
+// confirmMention records that source advertised addr and reports whether enough
+// distinct sources have done so to satisfy the configured gossip quorum.
+func (s *session) confirmMention(addr, source string) bool {
+	quorum := s.cfg.GossipQuorum
+	if quorum <= 1 {
+		return true
+	}
+	if source == "" {
+		return false
+	}
+	s.membersMu.Lock()
+	defer s.membersMu.Unlock()
+	if s.mentions == nil {
+		s.mentions = make(map[string]map[string]struct{})
+	}
+	sources, ok := s.mentions[addr]
+	if !ok {
+		if len(s.mentions) >= maxMentionCandidates {
+			return false
+		}
+		sources = make(map[string]struct{})
+		s.mentions[addr] = sources
+	}
+	sources[source] = struct{}{}
+	if len(sources) < quorum {
+		return false
+	}
+	delete(s.mentions, addr)
+	return true
+}
+
 // activeInfos produces Info payloads for the active membership, excluding the target.
 func (s *session) activeInfos(exclude string) []memberInfo {
 	if s == nil {
@@ -578,7 +983,7 @@ func normalizeAddr(advertised, fallback string) (string, bool) {
 					ap = netip.AddrPortFrom(fp.Addr(), ap.Port())
 				}
 			}
-			return ap.String(), true
+			return canonicalZone(ap).String(), true
 		}
 	}
 	if fb != "" {
@@ -586,10 +991,10 @@ func normalizeAddr(advertised, fallback string) (string, bool) {
 			if adv != "" {
 				if host, err2 := netip.ParseAddr(adv); err2 == nil {
 					ap := netip.AddrPortFrom(host, fp.Port())
-					return ap.String(), true
+					return canonicalZone(ap).String(), true
 				}
 			}
-			return fp.String(), true
+			return canonicalZone(fp).String(), true
 		}
 	}
 	if adv != "" {
@@ -0,0 +1,215 @@
+package chat
+
+import (
+	"encoding/json"
+	"strconv"
+	"testing"
+	"time"
+
+	"yap/internal/config"
+)
+
+func joinFrom(t *testing.T, claimed string, mentions ...string) []byte {
+	t.Helper()
+	payload := joinPayload{Member: memberInfo{Addr: claimed, Name: "peer"}}
+	for _, addr := range mentions {
+		payload.Peers = append(payload.Peers, memberInfo{Addr: addr})
+	}
+	data, err := json.Marshal(payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestGossipQuorumIgnoresRepeatsFromOneSource(t *testing.T) {
+	s := newTestSession(t, config.Config{GossipQuorum: 3})
+	const hint = "10.9.9.9:4000"
+	// One sender claiming a different address in each join is still one source.
+	for _, claimed := range []string{"10.1.0.1:4000", "10.1.0.2:4000", "10.1.0.3:4000"} {
+		if _, extra, err := s.processJoinPayload(joinFrom(t, claimed, hint), "10.1.0.1:4000", "mallory"); err != nil || len(extra) != 0 {
+			t.Fatalf("join from %s: extra=%v err=%v", claimed, extra, err)
+		}
+	}
+	if s.hasMember(hint) {
+		t.Fatal("hint confirmed by a single source")
+	}
+}
+
+func TestGossipQuorumConfirmsDistinctSources(t *testing.T) {
+	s := newTestSession(t, config.Config{GossipQuorum: 3})
+	const hint = "10.9.9.9:4000"
+	sources := []string{"10.1.0.1:4000", "10.1.0.2:4000", "10.1.0.3:4000"}
+	for i, source := range sources {
+		_, extra, err := s.processJoinPayload(joinFrom(t, source, hint), source, "peer")
+		if err != nil {
+			t.Fatal(err)
+		}
+		confirmed := len(extra) == 1 && extra[0] == hint
+		if want := i == len(sources)-1; confirmed != want {
+			t.Fatalf("after %d sources: extra=%v", i+1, extra)
+		}
+	}
+	if !s.hasMember(hint) {
+		t.Fatal("hint not tracked after quorum")
+	}
+}
+
+func TestGossipWithoutQuorumContactsImmediately(t *testing.T) {
+	s := newTestSession(t, config.Config{})
+	extra, err := s.processPeersPayload([]byte(`{"peers":[{"addr":"10.9.9.9:4000"}]}`), "10.1.0.1:4000")
+	if err != nil || len(extra) != 1 {
+		t.Fatalf("extra=%v err=%v", extra, err)
+	}
+}
+
+func TestJoinAnnouncesAdvertisedAddress(t *testing.T) {
+	const external = "203.0.113.7:4000"
+	s := newTestSession(t, config.Config{Name: "alice", Advertise: external})
+	data, err := s.buildJoinPayloadData()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var payload joinPayload
+	if err := json.Unmarshal(data, &payload); err != nil {
+		t.Fatal(err)
+	}
+	if payload.Member.Addr != external {
+		t.Fatalf("join announces %s, want %s", payload.Member.Addr, external)
+	}
+	// Gossip about the advertised address must not be mistaken for a peer.
+	if !s.isLocal(external) || !s.isLocal(s.localAddr) {
+		t.Fatal("advertised or bound address not recognised as local")
+	}
+}
+
+func TestJoinAnnouncesBoundAddressByDefault(t *testing.T) {
+	s := newTestSession(t, config.Config{Name: "alice"})
+	if got := s.localInfo().Addr; got != s.localAddr {
+		t.Fatalf("announces %s, want the bound %s", got, s.localAddr)
+	}
+}
+
+// memberEvents lists the queued member events as "addr status" pairs.
+func memberEvents(s *session) []string {
+	var out []string
+	for _, msg := range drainEvents(s) {
+		if msg.Type == memberMsg {
+			out = append(out, msg.Addr+" "+msg.Body)
+		}
+	}
+	return out
+}
+
+func TestJoinReportsMemberActive(t *testing.T) {
+	s := newTestSession(t, config.Config{Name: "alice"})
+	drainEvents(s)
+	if _, _, err := s.processJoinPayload(joinFrom(t, "10.1.0.1:4000"), "10.1.0.1:4000", "bob"); err != nil {
+		t.Fatal(err)
+	}
+	if got := memberEvents(s); len(got) != 1 || got[0] != "10.1.0.1:4000 "+MemberActive {
+		t.Fatalf("member events = %q", got)
+	}
+
+	// A repeated join is not a transition.
+	if _, _, err := s.processJoinPayload(joinFrom(t, "10.1.0.1:4000"), "10.1.0.1:4000", "bob"); err != nil {
+		t.Fatal(err)
+	}
+	if got := memberEvents(s); len(got) != 0 {
+		t.Fatalf("repeat join reported %q", got)
+	}
+}
+
+func TestGossipedPeerReportsMemberActive(t *testing.T) {
+	s := newTestSession(t, config.Config{Name: "alice"})
+	s.markMemberActive("10.9.9.9:4000", "carol")
+	s.markMemberFailed("10.9.9.9:4000")
+	drainEvents(s)
+	if _, err := s.processPeersPayload([]byte(`{"peers":[{"addr":"10.9.9.9:4000","name":"carol"}]}`), "10.1.0.1:4000"); err != nil {
+		t.Fatal(err)
+	}
+	if got := memberEvents(s); len(got) != 1 || got[0] != "10.9.9.9:4000 "+MemberActive {
+		t.Fatalf("member events = %q", got)
+	}
+}
+
+func TestConnectReportsMemberActiveOnBothSides(t *testing.T) {
+	alice := newTestSession(t, config.Config{Name: "alice"})
+	bob := newTestSession(t, config.Config{Name: "bob"})
+	connect(t, alice, bob)
+
+	// alice learns bob's name from his join; bob adds alice by address.
+	joined := waitEvent(t, alice, func(m Message) bool { return m.Type == memberMsg && m.Body == MemberActive })
+	if joined.Addr != bob.localAddr || joined.From != "bob" {
+		t.Fatalf("alice saw %+v, want bob at %s", joined, bob.localAddr)
+	}
+	added := waitEvent(t, bob, func(m Message) bool { return m.Type == memberMsg && m.Body == MemberActive })
+	if added.Addr != alice.localAddr {
+		t.Fatalf("bob saw %+v, want alice at %s", added, alice.localAddr)
+	}
+}
+
+func TestMaxPeersEvictsOldestPendingFirst(t *testing.T) {
+	clock := newFakeClock()
+	s := newTestSessionWith(t, sessionOptions{config: config.Config{Name: "alice", MaxPeers: 3, Outbox: 2}, now: clock.Now})
+	s.markMemberActive("127.0.0.1:4001", "bob")
+	clock.advance(time.Second)
+	s.addPendingMember("127.0.0.1:4002")
+	clock.advance(time.Second)
+	s.addPendingMember("127.0.0.1:4003")
+	s.queueForPending([]byte("queued"))
+	drainEvents(s)
+
+	clock.advance(time.Second)
+	s.addPendingMember("127.0.0.1:4004")
+	if _, ok := s.lookupMember("127.0.0.1:4002"); ok {
+		t.Fatal("oldest pending peer was kept")
+	}
+	for _, addr := range []string{"127.0.0.1:4001", "127.0.0.1:4003", "127.0.0.1:4004"} {
+		if _, ok := s.lookupMember(addr); !ok {
+			t.Fatalf("%s was evicted", addr)
+		}
+	}
+	waitEvent(t, s, systemContaining("peer limit 3 reached; dropped pending peer 127.0.0.1:4002"))
+	s.outboxMu.Lock()
+	_, queued := s.outbox["127.0.0.1:4002"]
+	s.outboxMu.Unlock()
+	if queued {
+		t.Fatal("evicted peer kept its outbox")
+	}
+}
+
+func TestMaxPeersEvictsLeastRecentlySeenActive(t *testing.T) {
+	clock := newFakeClock()
+	s := newTestSessionWith(t, sessionOptions{config: config.Config{Name: "alice", MaxPeers: 2}, now: clock.Now, presence: true})
+	s.markMemberActive("127.0.0.1:4001", "bob")
+	clock.advance(time.Second)
+	s.markMemberActive("127.0.0.1:4002", "carol")
+	clock.advance(time.Second)
+	s.markMemberActive("127.0.0.1:4001", "bob")
+	for len(s.presence) > 0 {
+		<-s.presence
+	}
+
+	clock.advance(time.Second)
+	s.markMemberActive("127.0.0.1:4003", "dave")
+	if _, ok := s.lookupMember("127.0.0.1:4002"); ok {
+		t.Fatal("least recently seen peer was kept")
+	}
+	if _, ok := s.lookupMember("127.0.0.1:4001"); !ok {
+		t.Fatal("recently seen peer was evicted")
+	}
+	if ev := nextPresence(t, s); ev.Kind != PresenceLeave || ev.Name != "carol" {
+		t.Fatalf("eviction presence = %+v, want carol leaving", ev)
+	}
+}
+
+func TestMaxPeersZeroIsUnlimited(t *testing.T) {
+	s := newTestSession(t, config.Config{Name: "alice"})
+	for i := range 50 {
+		s.addPendingMember("127.0.0.1:" + strconv.Itoa(5000+i))
+	}
+	if got := len(s.pendingAddrs()); got != 50 {
+		t.Fatalf("tracked %d peers, want all 50", got)
+	}
+}
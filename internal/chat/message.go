@@ -3,6 +3,7 @@ package chat
 import (
 	"crypto/rand"
 	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"time"
 )
@@ -18,24 +19,116 @@ const startupLogo = `
    ╚═╝   ╚═╝  ╚═╝╚═╝
 `
 
+const asciiLogo = `
+__   __ _    ____
+\ \ / // \  |  _ \
+ \ V // _ \ | |_) |
+  | |/ ___ \|  __/
+  |_/_/   \_\_|
+`
+
 const (
-	chatMsg   msgType = "chat"
-	joinMsg   msgType = "join"
-	leaveMsg  msgType = "leave"
-	errorMsg  msgType = "error"
-	systemMsg msgType = "system"
-	promptMsg msgType = "prompt"
-	peersMsg  msgType = "peers"
+	chatMsg    msgType = "chat"
+	joinMsg    msgType = "join"
+	leaveMsg   msgType = "leave"
+	errorMsg   msgType = "error"
+	systemMsg  msgType = "system"
+	promptMsg  msgType = "prompt"
+	peersMsg   msgType = "peers"
+	editMsg    msgType = "edit"
+	deleteMsg  msgType = "delete"
+	pingMsg    msgType = "ping"
+	pongMsg    msgType = "pong"
+	filterMsg  msgType = "filter"
+	historyMsg msgType = "history"
+	ackMsg     msgType = "ack"
+	pinMsg     msgType = "pin"
+	readMsg    msgType = "read"
+	typingMsg  msgType = "typing"
+	// fileMsg carries one base64 chunk of a file sent with /send; Ref names
+	// the transfer.
+	fileMsg msgType = "file"
+	// deliveryMsg is local only: Ref names a sent message and Body its
+	// "delivered N/M" status.
+	deliveryMsg msgType = "delivery"
+	// memberMsg is local only: it reports a membership transition, with From
+	// naming the peer, Addr its address, Body its new status, and Reason the
+	// cause when known.
+	memberMsg msgType = "member"
+	// clearMsg is local only: it tells the UI to empty its scrollback.
+	clearMsg msgType = "clear"
+
+	endpointUpdateMsg msgType = "endpoint"
+
+	fragmentMsg msgType = "frag"
 )
 
 type Message struct {
-	ID        string  `json:"id"`
-	From      string  `json:"from"`
-	Body      string  `json:"body"`
-	Type      msgType `json:"kind"`
-	Timestamp int64   `json:"timestamp"`
-	Cipher    string  `json:"cipher,omitempty"`
-	Nonce     string  `json:"nonce,omitempty"`
+	ID         string  `json:"id"`
+	From       string  `json:"from"`
+	Body       string  `json:"body"`
+	Type       msgType `json:"kind"`
+	Timestamp  int64   `json:"timestamp"`
+	Cipher     string  `json:"cipher,omitempty"`
+	Nonce      string  `json:"nonce,omitempty"`
+	Seq        uint64  `json:"seq,omitempty"`
+	Room       string  `json:"room,omitempty"`
+	Ref        string  `json:"ref,omitempty"`
+	ReplyTo    string  `json:"replyTo,omitempty"`
+	To         string  `json:"to,omitempty"`
+	Direct     bool    `json:"direct,omitempty"`     // sent straight to chosen peers and never relayed
+	Compressed bool    `json:"compressed,omitempty"` // body is deflated before encryption
+	KeyID      string  `json:"keyId,omitempty"`      // sender's key fingerprint, sent on joins
+	Suite      string  `json:"suite,omitempty"`      // sender's cipher suite, sent on joins
+	FragIndex  int     `json:"fragIndex,omitempty"`
+	FragTotal  int     `json:"fragTotal,omitempty"`
+	Version    int     `json:"version,omitempty"` // wire protocol version; zero means 1
+	File       string  `json:"file,omitempty"`    // file name carried by fileMsg chunks
+	FileSize   int64   `json:"fileSize,omitempty"`
+	Chunk      int     `json:"chunk,omitempty"`
+	Chunks     int     `json:"chunks,omitempty"`
+
+	// Gap is set locally when messages from this sender appear to be missing.
+	Gap bool `json:"-"`
+	// Late is set locally when a later message from this sender arrived first.
+	Late bool `json:"-"`
+	// Addr and Reason are set locally on member events.
+	Addr   string `json:"-"`
+	Reason string `json:"-"`
+	// NameTag is set locally on chat messages whose sender shares its name
+	// with another member, and is shown after the name, e.g. "@bob#4001".
+	NameTag string `json:"-"`
+	// Origin is set locally on chat, edit, and delete messages to the address
+	// of the member that sent them, or "" when a relayed copy arrived first.
+	Origin string `json:"-"`
+}
+
+// protocolVersion is the wire protocol version this build speaks and stamps
+// on every message it sends. Messages from older versions, including those
+// that send no Version, are still accepted; newer ones are rejected.
+const protocolVersion = 2
+
+// version returns the wire protocol version the message was written for.
+func (m Message) version() int {
+	if m.Version == 0 {
+		return 1
+	}
+	return m.Version
+}
+
+// associatedData serializes the header fields an encrypted message
+// authenticates, so a relay or attacker cannot alter the sender, kind, ID,
+// timestamp, or routing without breaking decryption. The sealed body, nonce,
+// key fingerprint, and fragment framing are excluded.
+func (m Message) associatedData() []byte {
+	m.Body = ""
+	m.Cipher = ""
+	m.Nonce = ""
+	m.KeyID = ""
+	m.FragIndex = 0
+	m.FragTotal = 0
+	data, _ := json.Marshal(m)
+	return data
 }
 
 // newMessageID produces a random hexadecimal identifier for transport deduping.
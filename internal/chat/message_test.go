@@ -0,0 +1,76 @@
+package chat
+
+import (
+	"fmt"
+	"testing"
+
+	"yap/internal/config"
+)
+
+func TestPrepareMessageStampsProtocolVersion(t *testing.T) {
+	s := newTestSession(t, config.Config{})
+	msg, _, err := s.transport.prepareMessage(Message{Type: chatMsg, From: "alice", Body: "hi"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.Version != protocolVersion {
+		t.Fatalf("version = %d, want %d", msg.Version, protocolVersion)
+	}
+}
+
+func TestVerifyRejectsFutureProtocolVersions(t *testing.T) {
+	s := newTestSession(t, config.Config{})
+	for _, version := range []int{0, 1, protocolVersion} {
+		msg := Message{ID: newMessageID(), Type: chatMsg, From: "peer", Body: "hi", Version: version}
+		if ok, _, err := s.transport.verifyAndDecrypt(&msg); !ok || err != nil {
+			t.Fatalf("version %d rejected: %v", version, err)
+		}
+	}
+
+	msg := Message{ID: newMessageID(), Type: chatMsg, From: "new", Body: "hi", Version: protocolVersion + 1}
+	ok, reason, err := s.transport.verifyAndDecrypt(&msg)
+	if ok || err == nil {
+		t.Fatal("future version accepted")
+	}
+	if want := fmt.Sprintf("incompatible protocol version %d (supported up to %d)", protocolVersion+1, protocolVersion); reason != want {
+		t.Fatalf("reason = %q, want %q", reason, want)
+	}
+}
+
+func TestProtocolMismatchStopsContact(t *testing.T) {
+	s := newTestSession(t, config.Config{})
+	peer := listenPeer(t, s, "old")
+	s.transport.noteSent(peer.LocalAddr())
+	for range maxAuthRejects {
+		s.noteAuthReject(peer.LocalAddr(), "incompatible protocol version 2 (this peer speaks 3)")
+	}
+	waitEvent(t, s, systemContaining("protocol version"))
+}
+
+func TestDecodeLeading(t *testing.T) {
+	const first = `{"id":"a","type":"chat","body":"one"}`
+	cases := []struct {
+		name, data string
+		wantErr    bool
+	}{
+		{"exact", first, false},
+		{"padding", first + "\n\x00\x00", false},
+		{"second message", first + `{"id":"b","type":"chat","body":"two"}`, false},
+		{"garbage", first + "not json", false},
+		{"truncated", first[:10], true},
+		{"not json", "hello", true},
+	}
+	for _, tc := range cases {
+		var msg Message
+		used, err := decodeLeading([]byte(tc.data), &msg)
+		if tc.wantErr {
+			if err == nil {
+				t.Errorf("%s: decoded %+v, want an error", tc.name, msg)
+			}
+			continue
+		}
+		if err != nil || string(used) != first || msg.ID != "a" || msg.Body != "one" {
+			t.Errorf("%s: decodeLeading = %q, %+v, %v; want the first message only", tc.name, used, msg, err)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package chat
+
+import (
+	"fmt"
+	"strings"
+	"sync/atomic"
+)
+
+// Metrics is a point-in-time snapshot of a session's traffic counters.
+type Metrics struct {
+	PacketsSent     uint64
+	BytesSent       uint64
+	PacketsReceived uint64
+	BytesReceived   uint64
+	// MessagesReceived counts decoded messages that were handled.
+	MessagesReceived uint64
+	// Forwarded counts packets relayed on behalf of other peers.
+	Forwarded uint64
+	// Duplicates counts messages dropped as already seen.
+	Duplicates uint64
+	// AuthRejects counts messages refused for failing decryption or policy.
+	AuthRejects uint64
+	// Dropped counts truncated, rate limited, and malformed packets.
+	Dropped uint64
+	// EventsDropped counts events shed from a full event queue.
+	EventsDropped uint64
+	ActivePeers   int
+	PendingPeers  int
+	// DedupEntries is how many message IDs the duplicate filter holds.
+	DedupEntries int
+}
+
+// counters are the transport's running totals.
+type counters struct {
+	packetsOut atomic.Uint64
+	bytesOut   atomic.Uint64
+	packetsIn  atomic.Uint64
+	bytesIn    atomic.Uint64
+	handled    atomic.Uint64
+	forwarded  atomic.Uint64
+	duplicates atomic.Uint64
+	rejected   atomic.Uint64
+	dropped    atomic.Uint64
+}
+
+// countOutcome files a received packet under its readLoop outcome.
+func (c *counters) countOutcome(outcome string) {
+	switch {
+	case strings.HasPrefix(outcome, "handled"):
+		c.handled.Add(1)
+	case outcome == "deduped":
+		c.duplicates.Add(1)
+	case strings.HasPrefix(outcome, "rejected"):
+		c.rejected.Add(1)
+	case outcome == "keepalive", strings.HasSuffix(outcome, "buffered"):
+	default:
+		c.dropped.Add(1)
+	}
+}
+
+// metrics snapshots the session counters and membership sizes.
+func (s *session) metrics() Metrics {
+	c := &s.transport.stats
+	active, pending := s.membersSnapshot()
+	return Metrics{
+		PacketsSent:      c.packetsOut.Load(),
+		BytesSent:        c.bytesOut.Load(),
+		PacketsReceived:  c.packetsIn.Load(),
+		BytesReceived:    c.bytesIn.Load(),
+		MessagesReceived: c.handled.Load(),
+		Forwarded:        c.forwarded.Load(),
+		Duplicates:       c.duplicates.Load(),
+		AuthRejects:      c.rejected.Load(),
+		Dropped:          c.dropped.Load(),
+		EventsDropped:    uint64(s.droppedEvents.Load()),
+		ActivePeers:      len(active),
+		PendingPeers:     len(pending),
+		DedupEntries:     s.transport.seen.size(),
+	}
+}
+
+// statsSummary renders the metrics for /stats.
+func (s *session) statsSummary() string {
+	m := s.metrics()
+	lines := []string{
+		"stats:",
+		fmt.Sprintf("  sent: %d packets, %d bytes", m.PacketsSent, m.BytesSent),
+		fmt.Sprintf("  received: %d packets, %d bytes, %d messages", m.PacketsReceived, m.BytesReceived, m.MessagesReceived),
+		fmt.Sprintf("  forwarded: %d", m.Forwarded),
+		fmt.Sprintf("  dropped: %d duplicates, %d rejected, %d malformed or throttled, %d events", m.Duplicates, m.AuthRejects, m.Dropped, m.EventsDropped),
+		fmt.Sprintf("  peers: %d active, %d pending", m.ActivePeers, m.PendingPeers),
+		fmt.Sprintf("  dedup cache: %d ids", m.DedupEntries),
+	}
+	return strings.Join(lines, "\n")
+}
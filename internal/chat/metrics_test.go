@@ -0,0 +1,74 @@
+package chat
+
+import (
+	"net"
+	"strings"
+	"testing"
+
+	"yap/internal/config"
+)
+
+func TestCountOutcome(t *testing.T) {
+	var c counters
+	for _, outcome := range []string{"handled chat", "deduped", "rejected: decrypt", "keepalive", "fragment buffered", "truncated", "rate limited"} {
+		c.countOutcome(outcome)
+	}
+	if c.handled.Load() != 1 || c.duplicates.Load() != 1 || c.rejected.Load() != 1 || c.dropped.Load() != 2 {
+		t.Fatalf("handled=%d duplicates=%d rejected=%d dropped=%d, want 1/1/1/2",
+			c.handled.Load(), c.duplicates.Load(), c.rejected.Load(), c.dropped.Load())
+	}
+}
+
+func TestMetricsCountTrafficAndDuplicates(t *testing.T) {
+	alice := newTestSession(t, config.Config{Name: "alice"})
+	bob := newTestSession(t, config.Config{Name: "bob"})
+	connect(t, alice, bob)
+
+	_, raw, err := alice.transport.prepareMessage(Message{From: "alice", Type: chatMsg, Body: "hi"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	before := bob.metrics()
+	to, err := net.ResolveUDPAddr("udp", bob.localAddr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for range 2 {
+		if err := alice.transport.sendRaw(to, raw); err != nil {
+			t.Fatal(err)
+		}
+	}
+	waitUntil(t, func() bool { return bob.metrics().Duplicates > before.Duplicates })
+
+	got := bob.metrics()
+	if got.MessagesReceived <= before.MessagesReceived {
+		t.Fatalf("messages received = %d, want more than %d", got.MessagesReceived, before.MessagesReceived)
+	}
+	if got.PacketsReceived < before.PacketsReceived+2 || got.BytesReceived < before.BytesReceived+2*uint64(len(raw)) {
+		t.Fatalf("received %d packets, %d bytes; want at least two more datagrams of %d bytes", got.PacketsReceived, got.BytesReceived, len(raw))
+	}
+	// Like /health, the active count includes this node.
+	if got.ActivePeers != 2 || got.PendingPeers != 0 {
+		t.Fatalf("peers = %d active, %d pending; want bob and this node active", got.ActivePeers, got.PendingPeers)
+	}
+	if got.DedupEntries == 0 {
+		t.Fatal("dedup cache reported empty")
+	}
+	if sent := alice.metrics(); sent.PacketsSent < 2 || sent.BytesSent < 2*uint64(len(raw)) {
+		t.Fatalf("alice sent %d packets, %d bytes", sent.PacketsSent, sent.BytesSent)
+	}
+}
+
+func TestStatsCommand(t *testing.T) {
+	s := newTestSession(t, config.Config{Name: "alice"})
+	s.addPendingMember("10.0.0.2:4000")
+	if err := s.handleInput("/stats"); err != nil {
+		t.Fatal(err)
+	}
+	msg := waitEvent(t, s, systemContaining("stats:"))
+	for _, want := range []string{"sent: ", "received: ", "forwarded: 0", "peers: 1 active, 1 pending", "dedup cache: "} {
+		if !strings.Contains(msg.Body, want) {
+			t.Fatalf("stats %q lack %q", msg.Body, want)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package chat
+
+import (
+	"net"
+	"strings"
+	"time"
+)
+
+const (
+	// maxAuthRejects is how many rejects from one peer are taken as proof
+	// that its secret, cipher, or protocol version differs from ours.
+	maxAuthRejects = 3
+	// rejectReplyWindow is how long after we last wrote to a peer its
+	// rejects still count as replies to our traffic.
+	rejectReplyWindow = time.Minute
+)
+
+// noteAuthReject counts a reject a peer sent us because it could not
+// authenticate our packets. Rejects are unauthenticated, so only those from
+// an address we recently wrote to count. After repeated rejects it names the
+// cause once and stops contacting the peer until /peer names it again or our
+// keys change.
+func (s *session) noteAuthReject(addr net.Addr, reason string) {
+	key := canonicalNetAddr(addr)
+	what := "secret"
+	switch {
+	case strings.HasPrefix(reason, "cipher mismatch"):
+		what = "cipher"
+	case strings.HasPrefix(reason, "secret mismatch"):
+	case strings.HasPrefix(reason, "incompatible protocol version"):
+		what = "protocol version"
+	case reason == rejectDecrypt:
+	default:
+		return
+	}
+	if !s.transport.sentRecently(addr, rejectReplyWindow) {
+		return
+	}
+	s.authMu.Lock()
+	if s.authRejects == nil {
+		s.authRejects = make(map[string]int)
+	}
+	before := s.authRejects[key]
+	after := before + 1
+	s.authRejects[key] = after
+	s.authMu.Unlock()
+	if before < maxAuthRejects && after >= maxAuthRejects {
+		s.recordPeerEvent(key, "%s mismatch with %s", what, key)
+		s.emitSystem("%s mismatch with %s; not contacting it again until /peer %s", what, key, key)
+	}
+}
+
+// mismatched reports whether the peer at raw has been given up on after
+// repeated authentication rejects.
+func (s *session) mismatched(raw string) bool {
+	key := blockKey(raw)
+	s.authMu.Lock()
+	defer s.authMu.Unlock()
+	return s.authRejects[key] >= maxAuthRejects
+}
+
+// clearAuthRejects forgets reject counts, for one peer or for all of them
+// when addr is empty.
+func (s *session) clearAuthRejects(addr string) {
+	s.authMu.Lock()
+	defer s.authMu.Unlock()
+	if addr == "" {
+		clear(s.authRejects)
+		return
+	}
+	delete(s.authRejects, blockKey(addr))
+}
@@ -0,0 +1,149 @@
+package chat
+
+import (
+	"encoding/json"
+	"net"
+	"strings"
+	"testing"
+
+	"yap/internal/config"
+)
+
+// newSecretSession starts a session encrypting with secret.
+func newSecretSession(t *testing.T, name, secret string) *session {
+	t.Helper()
+	cfg := config.Config{Name: name, Secret: secret}
+	cipher, err := newPacketCipher(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := newTestSessionWith(t, sessionOptions{config: cfg, cipher: cipher})
+	s.start()
+	return s
+}
+
+func TestJoinDetectsSecretMismatch(t *testing.T) {
+	alice := newSecretSession(t, "alice", "correct horse")
+	bob := newSecretSession(t, "bob", "battery staple")
+
+	if err := bob.addPeer(alice.localAddr); err != nil {
+		t.Fatal(err)
+	}
+	// Alice rejects the join and every packet after it.
+	for range maxAuthRejects - 1 {
+		if err := bob.sendDirect(alice.transport.localAddr(), pingMsg, ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	waitEvent(t, bob, systemContaining("secret mismatch with "+alice.localAddr))
+	if !bob.mismatched(alice.localAddr) {
+		t.Fatal("bob keeps contacting alice")
+	}
+	if isActive(alice, bob.localAddr) {
+		t.Fatal("alice admitted a peer with another secret")
+	}
+}
+
+func TestJoinWithMatchingSecret(t *testing.T) {
+	alice := newSecretSession(t, "alice", "correct horse")
+	bob := newSecretSession(t, "bob", "correct horse")
+
+	if err := bob.addPeer(alice.localAddr); err != nil {
+		t.Fatal(err)
+	}
+	waitUntil(t, func() bool { return isActive(alice, bob.localAddr) })
+	if bob.mismatched(alice.localAddr) {
+		t.Fatal("matching secrets reported as a mismatch")
+	}
+}
+
+func TestRepeatedDecryptRejectsMeanMismatch(t *testing.T) {
+	s := newTestSession(t, config.Config{Name: "alice"})
+	drainEvents(s)
+	peer := &net.UDPAddr{IP: net.IPv4(10, 0, 0, 2), Port: 4000}
+	s.transport.noteSent(peer)
+
+	for range maxAuthRejects - 1 {
+		s.noteAuthReject(peer, rejectDecrypt)
+		s.noteAuthReject(peer, rejectMalformed)
+	}
+	if s.mismatched(peer.String()) {
+		t.Fatal("gave up before enough decrypt rejects")
+	}
+	expectNoEvent(t, s, systemMsg)
+
+	s.noteAuthReject(peer, rejectDecrypt)
+	s.noteAuthReject(peer, rejectDecrypt)
+	if !s.mismatched(peer.String()) {
+		t.Fatal("still contacting a peer that keeps rejecting us")
+	}
+	var warnings int
+	for _, msg := range drainEvents(s) {
+		if msg.Type == systemMsg && strings.Contains(msg.Body, "secret mismatch with "+peer.String()) {
+			warnings++
+		}
+	}
+	if warnings != 1 {
+		t.Fatalf("%d mismatch warnings, want 1", warnings)
+	}
+
+	s.clearAuthRejects(peer.String())
+	if s.mismatched(peer.String()) {
+		t.Fatal("/peer did not reset the mismatch")
+	}
+}
+
+func TestForgedRejectDoesNotBlockContact(t *testing.T) {
+	s := newTestSession(t, config.Config{Name: "alice"})
+	s.start()
+	drainEvents(s)
+	peer, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = peer.Close() })
+
+	// Rejects from an address alice never wrote to are not replies to her.
+	for range maxAuthRejects {
+		forged, _ := json.Marshal(Message{ID: newMessageID(), Type: errorMsg, From: "mallory", Body: "secret mismatch (key x, expected y)"})
+		if _, err := peer.WriteTo(forged, s.transport.localAddr()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for range maxAuthRejects {
+		waitEvent(t, s, func(m Message) bool { return m.Type == errorMsg })
+	}
+	if s.mismatched(peer.LocalAddr().String()) {
+		t.Fatal("forged rejects marked the peer as mismatched")
+	}
+
+	s.contactPeer(peer.LocalAddr().String())
+	if msg := readMessage(t, peer); msg.Type != joinMsg {
+		t.Fatalf("got %s, want a join", msg.Type)
+	}
+}
+
+func TestRejectReasonSeparatesWrongSecretFromCorruption(t *testing.T) {
+	alice := newSecretSession(t, "alice", "correct horse")
+	bob := newSecretSession(t, "bob", "battery staple")
+	sealed, _, err := alice.transport.prepareMessage(Message{Type: chatMsg, From: "alice", Body: "hi"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msg := sealed
+	msg.KeyID = ""
+	if _, reason, _ := bob.transport.verifyAndDecrypt(&msg); reason != rejectDecrypt {
+		t.Fatalf("wrong secret rejected with %q", reason)
+	}
+	for name, corrupt := range map[string]func(*Message){
+		"nonce":      func(m *Message) { m.Nonce = "!!" },
+		"ciphertext": func(m *Message) { m.Cipher = "AAAA" },
+	} {
+		msg := sealed
+		corrupt(&msg)
+		if _, reason, _ := alice.transport.verifyAndDecrypt(&msg); reason != rejectMalformed {
+			t.Errorf("corrupt %s rejected with %q", name, reason)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package chat
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// muteName resolves a /mute target to the display name it hides: an address
+// is mapped to the name of the member there, and anything else is taken as
+// a name so a peer can be muted before it speaks.
+func (s *session) muteName(target string) (string, error) {
+	target = strings.TrimSpace(target)
+	if rec, ok := s.lookupMember(target); ok {
+		if rec.Name == "" {
+			return "", fmt.Errorf("%s has not sent its name yet", target)
+		}
+		return rec.Name, nil
+	}
+	if _, ok := normalizeAddr(target, target); ok {
+		return "", fmt.Errorf("unknown peer %q", target)
+	}
+	return normalizeName(target), nil
+}
+
+// isMuted reports whether messages from name are hidden locally.
+func (s *session) isMuted(name string) bool {
+	s.muteMu.Lock()
+	defer s.muteMu.Unlock()
+	return slices.ContainsFunc(s.muted, func(muted string) bool { return namesEqual(muted, name, s.cfg.FoldNames) })
+}
+
+// mute hides chat from the peer named by target without affecting relaying.
+func (s *session) mute(target string) error {
+	name, err := s.muteName(target)
+	if err != nil {
+		return err
+	}
+	if namesEqual(name, s.cfg.Name, s.cfg.FoldNames) {
+		return fmt.Errorf("cannot mute yourself")
+	}
+	if s.isMuted(name) {
+		return fmt.Errorf("%s is already muted", name)
+	}
+	s.muteMu.Lock()
+	s.muted = append(s.muted, name)
+	s.muteMu.Unlock()
+	s.emitSystem("muted %s; their messages are still relayed to others", name)
+	return nil
+}
+
+// unmute shows chat from the peer named by target again.
+func (s *session) unmute(target string) error {
+	name, err := s.muteName(target)
+	if err != nil {
+		return err
+	}
+	s.muteMu.Lock()
+	before := len(s.muted)
+	s.muted = slices.DeleteFunc(s.muted, func(muted string) bool { return namesEqual(muted, name, s.cfg.FoldNames) })
+	removed := len(s.muted) < before
+	s.muteMu.Unlock()
+	if !removed {
+		return fmt.Errorf("%s is not muted", name)
+	}
+	s.emitSystem("unmuted %s", name)
+	return nil
+}
+
+// mutedSummary lists the muted peers for /muted.
+func (s *session) mutedSummary() string {
+	s.muteMu.Lock()
+	names := slices.Clone(s.muted)
+	s.muteMu.Unlock()
+	if len(names) == 0 {
+		return "no muted peers"
+	}
+	slices.Sort(names)
+	return "muted: " + strings.Join(names, ", ")
+}
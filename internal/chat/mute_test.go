@@ -0,0 +1,93 @@
+package chat
+
+import (
+	"testing"
+
+	"yap/internal/config"
+)
+
+// shownChat returns the bodies of the chat events queued on s.
+func shownChat(s *session) []string {
+	var bodies []string
+	for _, msg := range drainEvents(s) {
+		if msg.Type == chatMsg {
+			bodies = append(bodies, msg.Body)
+		}
+	}
+	return bodies
+}
+
+func TestMuteHidesPublicAndPrivateChat(t *testing.T) {
+	s := newTestSession(t, config.Config{Name: "alice"})
+	peer := listenPeer(t, s, "bob")
+	addr := peer.LocalAddr()
+	if err := s.mute("bob"); err != nil {
+		t.Fatal(err)
+	}
+	drainEvents(s)
+
+	for _, msg := range []Message{
+		{ID: newMessageID(), Type: chatMsg, From: "bob", Body: "public"},
+		{ID: newMessageID(), Type: chatMsg, From: "bob", To: s.localAddr, Body: "private"},
+		{ID: newMessageID(), Type: chatMsg, From: "bob", Direct: true, Body: "direct"},
+	} {
+		s.handleIncoming(msg, addr, nil, true)
+	}
+	if shown := shownChat(s); len(shown) != 0 {
+		t.Fatalf("muted peer's chat shown: %q", shown)
+	}
+
+	if err := s.unmute("bob"); err != nil {
+		t.Fatal(err)
+	}
+	s.handleIncoming(Message{ID: newMessageID(), Type: chatMsg, From: "bob", To: s.localAddr, Body: "again"}, addr, nil, true)
+	if shown := shownChat(s); len(shown) != 1 || shown[0] != "again" {
+		t.Fatalf("after unmute shown %q, want [again]", shown)
+	}
+}
+
+func TestMutedChatIsStillRelayed(t *testing.T) {
+	s := newTestSession(t, config.Config{Name: "alice"})
+	bob := listenPeer(t, s, "bob")
+	carol := listenPeer(t, s, "carol")
+	if err := s.handleInput("/mute " + canonicalNetAddr(bob.LocalAddr())); err != nil {
+		t.Fatal(err)
+	}
+	drainEvents(s)
+
+	msg := Message{ID: newMessageID(), Type: chatMsg, From: "bob", Body: "spam"}
+	s.transport.seen.store(msg.ID)
+	s.handleIncoming(msg, bob.LocalAddr(), []byte(`{"relayed":true}`), true)
+
+	if got := readMessage(t, carol); got.Type != "" {
+		t.Fatalf("relayed %+v, want the raw packet", got)
+	}
+	if shown := shownChat(s); len(shown) != 0 {
+		t.Fatalf("muted peer's chat shown: %q", shown)
+	}
+}
+
+func TestMutedCommandListsMutes(t *testing.T) {
+	s := newTestSession(t, config.Config{Name: "alice"})
+	listenPeer(t, s, "bob")
+	listenPeer(t, s, "carol")
+
+	for _, input := range []string{"/muted", "/mute carol", "/mute bob", "/muted"} {
+		if err := s.handleInput(input); err != nil {
+			t.Fatalf("%s: %v", input, err)
+		}
+	}
+	waitEvent(t, s, systemContaining("no muted peers"))
+	waitEvent(t, s, systemContaining("muted: bob, carol"))
+
+	for input, want := range map[string]string{
+		"/mute alice":  "cannot mute yourself",
+		"/mute bob":    "bob is already muted",
+		"/unmute dave": "dave is not muted",
+	} {
+		if err := s.handleInput(input); err != nil {
+			t.Fatal(err)
+		}
+		waitEvent(t, s, systemContaining(want))
+	}
+}
@@ -0,0 +1,116 @@
+package chat
+
+import (
+	"net"
+	"net/netip"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// normalizeName trims a display name and collapses runs of whitespace.
+func normalizeName(raw string) string {
+	return strings.Join(strings.Fields(raw), " ")
+}
+
+// namesEqual compares two names after normalization, ignoring case when fold is set.
+func namesEqual(a, b string, fold bool) bool {
+	a, b = normalizeName(a), normalizeName(b)
+	if fold {
+		return strings.EqualFold(a, b)
+	}
+	return a == b
+}
+
+// nameKey returns the form of a name used for grouping and lookups.
+func nameKey(name string, fold bool) string {
+	name = normalizeName(name)
+	if fold {
+		return strings.ToLower(name)
+	}
+	return name
+}
+
+// senderTag returns a short suffix telling a chat sender apart from other
+// members sharing its name: the port when that is unique among them, the full
+// address otherwise, or "?" when the message was relayed and its origin is
+// unknown. It returns "" when no other member shares the name.
+func (s *session) senderTag(name, key string) string {
+	if s.cfg.HideNameTags {
+		return ""
+	}
+	var clash []string
+	s.membersMu.RLock()
+	for addr, rec := range s.members {
+		other := rec.Name
+		if addr == s.localAddr {
+			other = s.cfg.Name
+		} else if rec.Status != statusActive {
+			continue
+		}
+		if namesEqual(other, name, s.cfg.FoldNames) {
+			clash = append(clash, addr)
+		}
+	}
+	s.membersMu.RUnlock()
+	if len(clash) < 2 {
+		return ""
+	}
+	if !slices.Contains(clash, key) {
+		return "?"
+	}
+	ap, err := netip.ParseAddrPort(key)
+	if err != nil {
+		return key
+	}
+	for _, addr := range clash {
+		if other, err := netip.ParseAddrPort(addr); addr != key && (err != nil || other.Port() == ap.Port()) {
+			return key
+		}
+	}
+	return strconv.Itoa(int(ap.Port()))
+}
+
+// peerNames maps the address of every named member except this node to its
+// name, for saving with the peer list.
+func (s *session) peerNames() map[string]string {
+	s.membersMu.RLock()
+	defer s.membersMu.RUnlock()
+	var names map[string]string
+	for addr, rec := range s.members {
+		if addr == s.localAddr || rec.Name == "" {
+			continue
+		}
+		if names == nil {
+			names = make(map[string]string)
+		}
+		names[addr] = rec.Name
+	}
+	return names
+}
+
+// restorePeerNames labels members that have no name yet with the names saved
+// for their addresses.
+func (s *session) restorePeerNames(names map[string]string) {
+	s.membersMu.Lock()
+	defer s.membersMu.Unlock()
+	for raw, name := range names {
+		addr, ok := normalizeAddr(raw, raw)
+		if !ok {
+			continue
+		}
+		if rec := s.members[addr]; rec != nil && rec.Name == "" {
+			rec.Name = normalizeName(name)
+		}
+	}
+}
+
+// originKey returns the address of the member that sent a message from name:
+// the direct source when it goes by that name, or "" for relayed copies.
+func (s *session) originKey(name string, source net.Addr) string {
+	key := canonicalNetAddr(source)
+	if rec, ok := s.lookupMember(key); ok && namesEqual(rec.Name, name, s.cfg.FoldNames) {
+		return key
+	}
+	return ""
+}
@@ -0,0 +1,211 @@
+package chat
+
+import (
+	"encoding/json"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"yap/internal/config"
+)
+
+func TestNamesEqual(t *testing.T) {
+	cases := []struct {
+		a, b string
+		fold bool
+		want bool
+	}{
+		{"alice", " alice ", false, true},
+		{"Alice  Smith", "Alice Smith", false, true},
+		{"Alice", "alice", false, false},
+		{"Alice", "alice", true, true},
+		{"alice", "bob", true, false},
+	}
+	for _, tc := range cases {
+		if got := namesEqual(tc.a, tc.b, tc.fold); got != tc.want {
+			t.Errorf("namesEqual(%q, %q, %v) = %v, want %v", tc.a, tc.b, tc.fold, got, tc.want)
+		}
+	}
+}
+
+func TestFoldNamesResolvesCaseVariants(t *testing.T) {
+	s := newTestSession(t, config.Config{Name: "tester", FoldNames: true})
+	bob := listenPeer(t, s, "  Bob ")
+	carol := listenPeer(t, s, "Carol   Jones")
+
+	rec, ok := s.lookupMember(carol.LocalAddr().String())
+	if !ok || rec.Name != "Carol Jones" {
+		t.Fatalf("member = %+v, want the tidied display name", rec)
+	}
+	keys, unknown := s.memberKeysByName([]string{"carol jones", "BOB"})
+	if len(keys) != 2 || len(unknown) != 0 {
+		t.Fatalf("keys = %v, unknown = %v", keys, unknown)
+	}
+	if err := s.handleInput("/msg bOB hi"); err != nil {
+		t.Fatal(err)
+	}
+	for {
+		msg := readMessage(t, bob)
+		if msg.Type == chatMsg {
+			if msg.Body != "hi" {
+				t.Fatalf("bob got %+v", msg)
+			}
+			break
+		}
+	}
+	if rec, _ := s.lookupMember(bob.LocalAddr().String()); rec.Name != "Bob" {
+		t.Fatalf("display name = %q, want %q", rec.Name, "Bob")
+	}
+}
+
+func TestNamesAreCaseSensitiveByDefault(t *testing.T) {
+	s := newTestSession(t, config.Config{Name: "tester"})
+	listenPeer(t, s, "Bob")
+
+	if _, unknown := s.memberKeysByName([]string{"bob"}); len(unknown) != 1 {
+		t.Fatalf("bob matched Bob without FoldNames: unknown = %v", unknown)
+	}
+}
+
+func TestFoldNamesMutesCaseVariants(t *testing.T) {
+	s := newTestSession(t, config.Config{Name: "tester", FoldNames: true})
+	bob := listenPeer(t, s, "Bob")
+	if err := s.handleInput("/mute bob"); err != nil {
+		t.Fatal(err)
+	}
+	drainEvents(s)
+
+	msg := Message{ID: newMessageID(), Type: chatMsg, From: "BOB", Body: "hidden"}
+	s.handleIncoming(msg, bob.LocalAddr(), []byte(`{}`), true)
+	if shown := shownChat(s); len(shown) != 0 {
+		t.Fatalf("muted sender shown: %v", shown)
+	}
+}
+
+func TestRelayedCopyKeepsRelayName(t *testing.T) {
+	s := newTestSession(t, config.Config{Name: "bob"})
+	s.start()
+	dave := listenPeer(t, s, "dave")
+	to, _ := net.ResolveUDPAddr("udp", s.localAddr)
+
+	raw, err := json.Marshal(Message{ID: newMessageID(), Type: chatMsg, From: "alice", Body: "hi", Timestamp: time.Now().Unix(), Version: protocolVersion})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := dave.WriteTo(raw, to); err != nil {
+		t.Fatal(err)
+	}
+	got := waitEvent(t, s, func(m Message) bool { return m.Type == chatMsg && m.From == "alice" })
+
+	if rec, _ := s.lookupMember(canonicalNetAddr(dave.LocalAddr())); rec.Name != "dave" {
+		t.Fatalf("relay renamed to %q by the copy it forwarded", rec.Name)
+	}
+	if got.Origin != "" {
+		t.Fatalf("relayed copy attributed to %s", got.Origin)
+	}
+}
+
+func TestSenderTagDistinguishesSharedNames(t *testing.T) {
+	s := newTestSession(t, config.Config{Name: "alice"})
+	s.markMemberActive("10.0.0.2:4001", "bob")
+	s.markMemberActive("10.0.0.5:4005", "bob")
+	s.markMemberFailed("10.0.0.5:4005")
+	if tag := s.senderTag("bob", "10.0.0.2:4001"); tag != "" {
+		t.Fatalf("name shared only with an inactive peer tagged %q", tag)
+	}
+	s.markMemberActive("10.0.0.3:4002", "bob")
+	s.markMemberActive("10.0.0.4:4002", "bob")
+
+	cases := []struct{ key, want string }{
+		{"10.0.0.2:4001", "4001"},
+		{"10.0.0.3:4002", "10.0.0.3:4002"},
+		{"", "?"},
+	}
+	for _, tc := range cases {
+		if got := s.senderTag("bob", tc.key); got != tc.want {
+			t.Errorf("senderTag(bob, %q) = %q, want %q", tc.key, got, tc.want)
+		}
+	}
+
+	s.markMemberActive("10.0.0.6:4006", "alice")
+	if tag := s.senderTag("alice", s.localAddr); tag == "" {
+		t.Fatal("own name not tagged while another peer shares it")
+	}
+
+	s.cfg.HideNameTags = true
+	if tag := s.senderTag("bob", "10.0.0.2:4001"); tag != "" {
+		t.Fatalf("tag %q shown with name tags hidden", tag)
+	}
+}
+
+func TestOriginKeyIgnoresRelayedCopies(t *testing.T) {
+	s := newTestSession(t, config.Config{Name: "alice"})
+	s.markMemberActive("10.0.0.2:4001", "bob")
+	source := &net.UDPAddr{IP: net.ParseIP("10.0.0.2"), Port: 4001}
+	if got := s.originKey("bob", source); got != "10.0.0.2:4001" {
+		t.Fatalf("direct origin = %q", got)
+	}
+	if got := s.originKey("carol", source); got != "" {
+		t.Fatalf("relayed copy attributed to %q", got)
+	}
+}
+
+func TestNameTagSplitsGroupsAndLabels(t *testing.T) {
+	m := newBubbleModel("alice", nil, nil, uiOptionsFrom(config.Config{}))
+	m.Update(Message{ID: newMessageID(), Type: chatMsg, From: "bob", NameTag: "4001", Body: "one"})
+	m.Update(Message{ID: newMessageID(), Type: chatMsg, From: "bob", NameTag: "4002", Body: "two"})
+	if len(m.history) != 2 {
+		t.Fatalf("%d blocks, want same-named senders kept apart", len(m.history))
+	}
+	if view := m.View(); !strings.Contains(view, "@bob#4001") || !strings.Contains(view, "@bob#4002") {
+		t.Fatalf("tags missing from view:\n%s", view)
+	}
+}
+
+func TestPeerNamesSurviveSaveAndRestart(t *testing.T) {
+	store, err := config.Load(filepath.Join(t.TempDir(), "yap.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := newTestSessionWith(t, sessionOptions{config: config.Config{Name: "alice"}, store: store})
+	peer := listenPeer(t, s, "bob")
+	s.addPendingMember("10.0.0.9:4000")
+	if err := s.handleInput("/group team"); err != nil {
+		t.Fatal(err)
+	}
+	saved, ok := store.Load("team")
+	if !ok {
+		t.Fatal("group not saved")
+	}
+	addr := canonicalNetAddr(peer.LocalAddr())
+	if len(saved.PeerNames) != 1 || saved.PeerNames[addr] != "bob" {
+		t.Fatalf("saved names = %v, want only %s as bob", saved.PeerNames, addr)
+	}
+
+	restarted := newTestSession(t, saved)
+	if rec, ok := restarted.lookupMember(addr); !ok || rec.Name != "bob" {
+		t.Fatalf("restored member = %+v, want bob", rec)
+	}
+	restarted.markMemberActive(addr, "robert")
+	if rec, _ := restarted.lookupMember(addr); rec.Name != "robert" {
+		t.Fatalf("live name %q did not replace the saved one", rec.Name)
+	}
+}
+
+func TestRestorePeerNamesKeepsKnownNames(t *testing.T) {
+	s := newTestSession(t, config.Config{Name: "alice"})
+	s.markMemberActive("10.0.0.2:4000", "bob")
+	s.addPendingMember("10.0.0.3:4000")
+	s.restorePeerNames(map[string]string{"10.0.0.2:4000": "old", " 10.0.0.3:4000 ": " carol ", "10.0.0.4:4000": "dave"})
+	if rec, _ := s.lookupMember("10.0.0.2:4000"); rec.Name != "bob" {
+		t.Fatalf("known name overwritten with %q", rec.Name)
+	}
+	if rec, _ := s.lookupMember("10.0.0.3:4000"); rec.Name != "carol" {
+		t.Fatalf("pending member named %q, want carol", rec.Name)
+	}
+	if _, ok := s.lookupMember("10.0.0.4:4000"); ok {
+		t.Fatal("saved name added a member")
+	}
+}
@@ -1,8 +1,10 @@
 package chat
 
 import (
+	"fmt"
 	"net"
 	"net/netip"
+	"strconv"
 	"strings"
 )
 
@@ -17,12 +19,12 @@ func canonicalAddrPort(advertised, fallback string) (netip.AddrPort, bool) {
 					ap = netip.AddrPortFrom(fbPort.Addr(), ap.Port())
 				}
 			}
-			return ap, true
+			return canonicalZone(ap), true
 		}
 	}
 	if fb != "" {
 		if ap, err := netip.ParseAddrPort(fb); err == nil {
-			return ap, true
+			return canonicalZone(ap), true
 		}
 	}
 	return netip.AddrPort{}, false
@@ -39,14 +41,48 @@ func addrPortFromNet(addr net.Addr) (netip.AddrPort, bool) {
 			break
 		}
 		if ip, ok := netip.AddrFromSlice(v.IP); ok {
-			return netip.AddrPortFrom(ip, uint16(v.Port)), true
+			// net stores IPv4 in 16 bytes; unmap it so 1.2.3.4 never also
+			// appears as ::ffff:1.2.3.4.
+			ip = ip.Unmap()
+			if v.Zone != "" && ip.Is6() {
+				ip = ip.WithZone(v.Zone)
+			}
+			return canonicalZone(netip.AddrPortFrom(ip, uint16(v.Port))), true
 		}
 	}
 	ap, err := netip.ParseAddrPort(strings.TrimSpace(addr.String()))
 	if err != nil {
 		return netip.AddrPort{}, false
 	}
-	return ap, true
+	return canonicalZone(ap), true
+}
+
+// canonicalZone rewrites a numeric IPv6 zone to its interface name so the
+// same scoped address always renders identically.
+func canonicalZone(ap netip.AddrPort) netip.AddrPort {
+	zone := ap.Addr().Zone()
+	if zone == "" {
+		return ap
+	}
+	if index, err := strconv.Atoi(zone); err == nil {
+		if ifi, err := net.InterfaceByIndex(index); err == nil && ifi.Name != "" {
+			return netip.AddrPortFrom(ap.Addr().WithZone(ifi.Name), ap.Port())
+		}
+	}
+	return ap
+}
+
+// sameScopedHost reports whether two addresses name the same host and port
+// when at most one of them carries an IPv6 zone.
+func sameScopedHost(a, b netip.AddrPort) bool {
+	if a.Port() != b.Port() {
+		return false
+	}
+	za, zb := a.Addr().Zone(), b.Addr().Zone()
+	if za != "" && zb != "" && za != zb {
+		return false
+	}
+	return a.Addr().WithZone("") == b.Addr().WithZone("")
 }
 
 // formatAddrPort renders an AddrPort as the canonical string, returning "" if invalid.
@@ -79,3 +115,39 @@ func canonicalNetAddr(addr net.Addr) string {
 	}
 	return canonicalAddrString(addr.String())
 }
+
+// hostHasIP reports whether ip is assigned to one of this host's interfaces.
+func hostHasIP(ip netip.Addr) bool {
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return false
+	}
+	ip = ip.Unmap().WithZone("")
+	for _, addr := range addrs {
+		if prefix, ok := addr.(*net.IPNet); ok {
+			if own, ok := netip.AddrFromSlice(prefix.IP); ok && own.Unmap() == ip {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// checkUnicast rejects destinations that cannot identify a single peer:
+// unspecified, limited broadcast, and multicast addresses.
+func checkUnicast(ap netip.AddrPort) error {
+	addr := ap.Addr().Unmap()
+	ap = netip.AddrPortFrom(addr, ap.Port())
+	switch {
+	case addr.IsUnspecified():
+		return fmt.Errorf("%s is an unspecified address, not a peer", ap)
+	case addr == netip.AddrFrom4([4]byte{255, 255, 255, 255}):
+		return fmt.Errorf("%s is a broadcast address, not a peer", ap)
+	case addr.IsMulticast():
+		return fmt.Errorf("%s is a multicast address, not a peer", ap)
+	}
+	if ap.Port() == 0 {
+		return fmt.Errorf("%s has no port", ap)
+	}
+	return nil
+}
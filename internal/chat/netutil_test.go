@@ -0,0 +1,165 @@
+package chat
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/netip"
+	"strings"
+	"testing"
+
+	"yap/internal/config"
+)
+
+func TestCheckUnicast(t *testing.T) {
+	cases := map[string]string{
+		"10.0.0.2:4000":                 "",
+		"[::1]:4000":                    "",
+		"0.0.0.0:4000":                  "unspecified",
+		"[::]:4000":                     "unspecified",
+		"255.255.255.255:4000":          "broadcast",
+		"[::ffff:255.255.255.255]:4000": "broadcast",
+		"224.0.0.251:4000":              "multicast",
+		"[ff02::1]:4000":                "multicast",
+		"10.0.0.2:0":                    "no port",
+	}
+	for raw, want := range cases {
+		err := checkUnicast(netip.MustParseAddrPort(raw))
+		switch {
+		case want == "" && err != nil:
+			t.Errorf("%s: unexpected error %v", raw, err)
+		case want != "" && (err == nil || !strings.Contains(err.Error(), want)):
+			t.Errorf("%s: error = %v, want it to mention %q", raw, err, want)
+		}
+	}
+}
+
+func TestPeerRejectsBroadcastAddress(t *testing.T) {
+	s := newTestSession(t, config.Config{Name: "alice"})
+
+	if err := s.addPeer("255.255.255.255:4000"); err == nil || !strings.Contains(err.Error(), "broadcast") {
+		t.Fatalf("addPeer = %v, want a broadcast error", err)
+	}
+	if s.hasMember("255.255.255.255:4000") {
+		t.Fatal("broadcast address stored as a member")
+	}
+}
+
+func TestGossipIgnoresMulticastHints(t *testing.T) {
+	s := newTestSession(t, config.Config{Name: "alice"})
+
+	s.contactPeer("224.0.0.251:4000")
+	if s.hasMember("224.0.0.251:4000") {
+		t.Fatal("multicast hint stored as a member")
+	}
+}
+
+func TestNormalizeAddrKeepsZone(t *testing.T) {
+	got, ok := normalizeAddr("[fe80::1%eth0]:4000", "")
+	if !ok || got != "[fe80::1%eth0]:4000" {
+		t.Fatalf("normalizeAddr = %q, %v", got, ok)
+	}
+	lo, err := net.InterfaceByName("lo")
+	if err != nil {
+		t.Skip("no lo interface")
+	}
+	numeric := fmt.Sprintf("[fe80::1%%%d]:4000", lo.Index)
+	if got, _ := normalizeAddr(numeric, ""); got != "[fe80::1%lo]:4000" {
+		t.Fatalf("normalizeAddr(%s) = %q, want the interface name", numeric, got)
+	}
+}
+
+func TestSameScopedHost(t *testing.T) {
+	zoned := netip.MustParseAddrPort("[fe80::1%eth0]:4000")
+	cases := []struct {
+		other string
+		want  bool
+	}{
+		{"[fe80::1]:4000", true},
+		{"[fe80::1%eth0]:4000", true},
+		{"[fe80::1%eth1]:4000", false},
+		{"[fe80::1]:4001", false},
+		{"[fe80::2]:4000", false},
+	}
+	for _, tc := range cases {
+		if got := sameScopedHost(zoned, netip.MustParseAddrPort(tc.other)); got != tc.want {
+			t.Errorf("sameScopedHost(%s, %s) = %v, want %v", zoned, tc.other, got, tc.want)
+		}
+	}
+}
+
+func TestScopedMembers(t *testing.T) {
+	s := newTestSession(t, config.Config{Name: "alice"})
+	const addr = "[fe80::1%eth0]:4000"
+
+	if s.isLocal(addr) {
+		t.Fatal("link-local peer reported as local")
+	}
+	s.addPendingMember(addr)
+	s.markMemberActive(addr, "bob")
+	rec, ok := s.lookupMember(addr)
+	if !ok || rec.Addr != addr || rec.Status != statusActive {
+		t.Fatalf("member = %+v, %v", rec, ok)
+	}
+
+	data, err := json.Marshal(memberInfo{Addr: rec.Addr, Name: rec.Name})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var info memberInfo
+	if err := json.Unmarshal(data, &info); err != nil || info.Addr != addr {
+		t.Fatalf("round trip = %+v, %v", info, err)
+	}
+}
+
+func TestPeerRejectsOwnAddress(t *testing.T) {
+	s := newTestSession(t, config.Config{Name: "alice"})
+	self := s.transport.localAddrs()[0].String()
+
+	if err := s.addPeer(self); err == nil || !strings.Contains(err.Error(), "own address") {
+		t.Fatalf("addPeer(%s) = %v, want an own-address error", self, err)
+	}
+	if len(s.pendingAddrs()) != 0 {
+		t.Fatalf("own address stored as a member: %v", s.pendingAddrs())
+	}
+}
+
+func TestBootstrapSkipsOwnWildcardAddress(t *testing.T) {
+	probe, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := probe.LocalAddr().(*net.UDPAddr).Port
+	_ = probe.Close()
+	self := fmt.Sprintf("127.0.0.1:%d", port)
+
+	s := newTestSession(t, config.Config{Name: "alice", Listen: fmt.Sprintf("0.0.0.0:%d", port), Peers: []string{self, "127.0.0.1:9"}})
+	events := drainEvents(s)
+	var skipped, waiting bool
+	for _, msg := range events {
+		skipped = skipped || strings.Contains(msg.Body, "skipping peer "+self)
+		waiting = waiting || strings.Contains(msg.Body, "no peers provided")
+	}
+	if !skipped || waiting {
+		t.Fatalf("events %+v: want %s skipped and the other peer kept", events, self)
+	}
+	if len(s.bootstrap) != 1 || s.bootstrap[0].String() != "127.0.0.1:9" {
+		t.Fatalf("bootstrap = %v, want only 127.0.0.1:9", s.bootstrap)
+	}
+}
+
+func TestAddrPortFromNetUnmapsIPv4(t *testing.T) {
+	ap, ok := addrPortFromNet(&net.UDPAddr{IP: net.ParseIP("10.0.0.2"), Port: 4000})
+	if !ok || ap.String() != "10.0.0.2:4000" {
+		t.Fatalf("addrPortFromNet = %v, %v; want 10.0.0.2:4000", ap, ok)
+	}
+}
+
+func TestHostHasIP(t *testing.T) {
+	if !hostHasIP(netip.MustParseAddr("127.0.0.1")) {
+		t.Fatal("loopback not reported as a host address")
+	}
+	if hostHasIP(netip.MustParseAddr("192.0.2.1")) {
+		t.Fatal("documentation address reported as a host address")
+	}
+}
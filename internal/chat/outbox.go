@@ -0,0 +1,75 @@
+package chat
+
+import (
+	"net"
+	"net/netip"
+	"time"
+)
+
+// outboxMaxAge bounds how stale a buffered message may be when replayed.
+const outboxMaxAge = 2 * time.Minute
+
+// queuedFrame is an encoded chat packet waiting for a pending peer.
+type queuedFrame struct {
+	raw    []byte
+	queued time.Time
+}
+
+// queueForPending buffers an encoded chat packet for every pending peer so it
+// can be replayed once that peer becomes active.
+func (s *session) queueForPending(raw []byte) {
+	s.queueFor(s.pendingAddrs(), raw)
+}
+
+// queueFor buffers an encoded chat packet for the given peers, dropping each
+// queue's oldest frames beyond Config.Outbox.
+func (s *session) queueFor(pending []string, raw []byte) {
+	limit := s.cfg.Outbox
+	if limit <= 0 || len(pending) == 0 {
+		return
+	}
+	now := s.now()
+	s.outboxMu.Lock()
+	defer s.outboxMu.Unlock()
+	if s.outbox == nil {
+		s.outbox = make(map[string][]queuedFrame)
+	}
+	for _, addr := range pending {
+		queue := append(s.outbox[addr], queuedFrame{raw: raw, queued: now})
+		if len(queue) > limit {
+			queue = queue[len(queue)-limit:]
+		}
+		s.outbox[addr] = queue
+	}
+}
+
+// flushOutbox replays buffered chat packets to a peer that just became active.
+func (s *session) flushOutbox(addr string) {
+	s.outboxMu.Lock()
+	queue := s.outbox[addr]
+	delete(s.outbox, addr)
+	s.outboxMu.Unlock()
+	if len(queue) == 0 || s.transport == nil {
+		return
+	}
+	ap, err := netip.ParseAddrPort(addr)
+	if err != nil {
+		return
+	}
+	target := net.UDPAddrFromAddrPort(ap)
+	cutoff := s.now().Add(-outboxMaxAge)
+	replayed := 0
+	for _, frame := range queue {
+		if frame.queued.Before(cutoff) {
+			continue
+		}
+		if err := s.transport.sendRaw(target, frame.raw); err != nil {
+			s.emitSystem("replay to %s failed: %v", addr, err)
+			return
+		}
+		replayed++
+	}
+	if replayed > 0 {
+		s.recordEvent("replayed %d message(s) to %s", replayed, addr)
+	}
+}
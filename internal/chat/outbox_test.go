@@ -0,0 +1,120 @@
+package chat
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"yap/internal/config"
+)
+
+// pendingPeer opens a UDP socket standing in for a peer that s knows of but
+// has not heard from yet.
+func pendingPeer(t *testing.T, s *session) net.PacketConn {
+	t.Helper()
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	s.addPendingMember(conn.LocalAddr().String())
+	return conn
+}
+
+func TestOutboxReplaysNewestChatOnActivation(t *testing.T) {
+	s := newTestSession(t, config.Config{Name: "alice", Outbox: 2})
+	peer := pendingPeer(t, s)
+	for _, text := range []string{"one", "two", "three"} {
+		if err := s.handleInput(text); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s.markActive(peer.LocalAddr(), "bob")
+	var got []string
+	for range 2 {
+		msg := readMessage(t, peer)
+		got = append(got, msg.Body)
+	}
+	if got[0] != "two" || got[1] != "three" {
+		t.Fatalf("replayed %q, want the newest two messages in order", got)
+	}
+	_ = peer.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+	if n, _, err := peer.ReadFrom(make([]byte, 64<<10)); err == nil {
+		t.Fatalf("unexpected extra datagram of %d bytes", n)
+	}
+}
+
+func TestOutboxDisabledByDefault(t *testing.T) {
+	s := newTestSession(t, config.Config{Name: "alice"})
+	peer := pendingPeer(t, s)
+	if err := s.handleInput("hello"); err != nil {
+		t.Fatal(err)
+	}
+	s.outboxMu.Lock()
+	queued := len(s.outbox[canonicalNetAddr(peer.LocalAddr())])
+	s.outboxMu.Unlock()
+	if queued != 0 {
+		t.Fatalf("queued %d frames with the outbox off", queued)
+	}
+}
+
+func TestOutboxSkipsStaleFrames(t *testing.T) {
+	clock := newFakeClock()
+	s := newTestSessionWith(t, sessionOptions{config: config.Config{Name: "alice", Outbox: 4}, now: clock.Now})
+	peer := pendingPeer(t, s)
+	if err := s.handleInput("stale"); err != nil {
+		t.Fatal(err)
+	}
+	clock.advance(outboxMaxAge + time.Second)
+	if err := s.handleInput("fresh"); err != nil {
+		t.Fatal(err)
+	}
+
+	s.markActive(peer.LocalAddr(), "bob")
+	if msg := readMessage(t, peer); msg.Body != "fresh" {
+		t.Fatalf("replayed %q, want only the fresh message", msg.Body)
+	}
+	_ = peer.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+	if n, _, err := peer.ReadFrom(make([]byte, 64<<10)); err == nil {
+		t.Fatalf("stale frame replayed (%d bytes)", n)
+	}
+}
+
+func TestOutboxQueuesPrivateMessageForPendingPeer(t *testing.T) {
+	s := newTestSession(t, config.Config{Name: "alice", Outbox: 2})
+	peer := pendingPeer(t, s)
+	other := pendingPeer(t, s)
+	addr := canonicalNetAddr(peer.LocalAddr())
+	if err := s.handleInput("/msg " + addr + " psst"); err != nil {
+		t.Fatal(err)
+	}
+	waitEvent(t, s, systemContaining("message queued until it connects"))
+	s.outboxMu.Lock()
+	otherQueued := len(s.outbox[canonicalNetAddr(other.LocalAddr())])
+	s.outboxMu.Unlock()
+	if otherQueued != 0 {
+		t.Fatalf("private message queued for %d frame(s) at another peer", otherQueued)
+	}
+
+	s.markActive(peer.LocalAddr(), "bob")
+	msg := readMessage(t, peer)
+	if msg.Body != "psst" || msg.To != addr {
+		t.Fatalf("replayed %+v, want the private message to %s", msg, addr)
+	}
+}
+
+func TestPrivateMessageToPendingPeerNeedsOutbox(t *testing.T) {
+	s := newTestSession(t, config.Config{Name: "alice"})
+	peer := pendingPeer(t, s)
+	if err := s.handleInput("/msg " + canonicalNetAddr(peer.LocalAddr()) + " psst"); err != nil {
+		t.Fatal(err)
+	}
+	waitEvent(t, s, systemContaining("private messages need an active peer"))
+	s.outboxMu.Lock()
+	queued := len(s.outbox)
+	s.outboxMu.Unlock()
+	if queued != 0 {
+		t.Fatalf("queued frames for %d peer(s) with the outbox off", queued)
+	}
+}
@@ -0,0 +1,122 @@
+package chat
+
+import "strings"
+
+// maxPins bounds how many messages the UI keeps pinned at once.
+const maxPins = 5
+
+// Bodies carried by pinMsg events to the UI.
+const (
+	pinAction   = "pin"
+	unpinAction = "unpin"
+)
+
+// lastRecentID returns the ID of the newest chat message seen or sent.
+func (s *session) lastRecentID() string {
+	s.statusMu.RLock()
+	defer s.statusMu.RUnlock()
+	if len(s.recent) == 0 {
+		return ""
+	}
+	return s.recent[len(s.recent)-1].ID
+}
+
+// handlePin pins the given recent message, or the newest one, in the UI.
+// Pins are local to this session and are never sent to peers.
+func (s *session) handlePin(args []string) {
+	if len(args) > 1 {
+		s.emitSystem("usage: /pin [id]")
+		return
+	}
+	ref := s.lastRecentID()
+	if len(args) == 1 {
+		var err error
+		if ref, err = s.resolveRecent(args[0]); err != nil {
+			s.emitSystem("%v", err)
+			return
+		}
+	}
+	if ref == "" {
+		s.emitSystem("nothing to pin yet")
+		return
+	}
+	s.emit(Message{Type: pinMsg, Body: pinAction, Ref: ref})
+}
+
+// handleUnpin removes one pinned message, or all of them without an argument.
+func (s *session) handleUnpin(args []string) {
+	switch len(args) {
+	case 0:
+		s.emit(Message{Type: pinMsg, Body: unpinAction})
+	case 1:
+		ref, err := s.resolveRecent(args[0])
+		if err != nil {
+			s.emitSystem("%v", err)
+			return
+		}
+		s.emit(Message{Type: pinMsg, Body: unpinAction, Ref: ref})
+	default:
+		s.emitSystem("usage: /unpin [id]")
+	}
+}
+
+// applyPin updates the pinned section from a pinMsg event. Pinned entries
+// are copied so they survive the scrollback being trimmed.
+func (m *bubbleModel) applyPin(msg Message) {
+	switch msg.Body {
+	case pinAction:
+		for _, pin := range m.pins {
+			if pin.id == msg.Ref {
+				return
+			}
+		}
+		entry := m.findEntry(msg.Ref)
+		if entry == nil {
+			m.append(renderSystem(m.opts, "message #"+shortID(msg.Ref)+" is no longer on screen"))
+			return
+		}
+		pinned := *entry
+		pinned.lines = append([]string(nil), entry.lines...)
+		m.pins = append(m.pins, pinned)
+		if len(m.pins) > maxPins {
+			m.pins = m.pins[len(m.pins)-maxPins:]
+		}
+	case unpinAction:
+		if msg.Ref == "" {
+			m.pins = nil
+			return
+		}
+		kept := m.pins[:0]
+		for _, pin := range m.pins {
+			if pin.id != msg.Ref {
+				kept = append(kept, pin)
+			}
+		}
+		m.pins = kept
+	}
+}
+
+// refreshPin replaces a pinned copy after its message was edited or deleted.
+func (m *bubbleModel) refreshPin(entry blockEntry) {
+	for i := range m.pins {
+		if m.pins[i].id == entry.id {
+			m.pins[i] = entry
+			m.pins[i].lines = append([]string(nil), entry.lines...)
+		}
+	}
+}
+
+// renderPins formats the pinned section shown above the scrollback.
+func renderPins(opts uiOptions, pins []blockEntry) string {
+	if len(pins) == 0 {
+		return ""
+	}
+	blk := block{border: opts.theme.borderSystem, header: opts.theme.system + "pinned" + opts.theme.reset}
+	for _, pin := range pins {
+		lines := make([]string, len(pin.lines))
+		copy(lines, pin.lines)
+		lines[0] = opts.theme.name + "@" + pin.from + opts.theme.reset + " " + strings.TrimLeft(lines[0], " ")
+		blk.entries = append(blk.entries, blockEntry{lines: lines})
+	}
+	return renderBlockString(opts, blk)
+}
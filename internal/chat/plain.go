@@ -0,0 +1,128 @@
+package chat
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+)
+
+// plainOptions adapts UI options for line output: no colors, ASCII glyphs,
+// and no inline styling.
+func plainOptions(opts uiOptions) uiOptions {
+	opts.theme = themes["mono"]
+	opts.glyphs = asciiGlyphs
+	opts.format = false
+	return opts
+}
+
+// runPlainUI writes chat events to out as plain lines and submits each line
+// read from in. It is used when output is not a terminal, where the Bubble
+// Tea UI and escape sequences would only produce garbage. It returns once the
+// event stream closes; the end of input alone does not stop it.
+func runPlainUI(user string, events <-chan Message, submit func(string) error, opts uiOptions, in io.Reader, out io.Writer) error {
+	opts = plainOptions(opts)
+	lines := make(chan string)
+	go func() {
+		defer close(lines)
+		scanner := bufio.NewScanner(in)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+	}()
+	w := bufio.NewWriter(out)
+	for {
+		select {
+		case line, ok := <-lines:
+			if !ok {
+				lines = nil
+				continue
+			}
+			text := strings.TrimSpace(line)
+			if text == "" || submit == nil {
+				continue
+			}
+			if err := submit(text); err != nil && !errors.Is(err, errQuit) {
+				fmt.Fprintln(w, renderPlainBlock(renderSystem(opts, err.Error())))
+			}
+		case msg, ok := <-events:
+			if !ok {
+				return w.Flush()
+			}
+			text, show := renderPlain(&opts, &user, msg)
+			if !show {
+				continue
+			}
+			fmt.Fprintln(w, text)
+			if msg.Type == chatMsg && opts.markRead != nil && !namesEqual(msg.From, user, opts.foldNames) {
+				opts.markRead(msg)
+			}
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+	}
+}
+
+// renderPlain formats one event for line output, reporting false for events
+// that only adjust UI state. Prompt and filter updates are applied to user
+// and opts as the Bubble Tea UI would.
+func renderPlain(opts *uiOptions, user *string, msg Message) (string, bool) {
+	switch msg.Type {
+	case promptMsg:
+		if trimmed := strings.TrimSpace(msg.Body); trimmed != "" {
+			*user = trimmed
+		}
+		return "", false
+	case filterMsg:
+		var filters keywordFilters
+		if err := json.Unmarshal([]byte(msg.Body), &filters); err == nil {
+			opts.filters = filters
+		}
+		return "", false
+	case pinMsg, readMsg, deliveryMsg, typingMsg, clearMsg, editMsg, deleteMsg:
+		return "", false
+	case memberMsg:
+		if msg.Body == MemberActive || msg.Body == MemberLeft {
+			return "", false
+		}
+	case chatMsg:
+		if !namesEqual(msg.From, *user, opts.foldNames) && matchKeyword(opts.filters.Mute, msg.Body) {
+			return "", false
+		}
+	}
+	return renderPlainBlock(renderMessage(*opts, *user, msg)), true
+}
+
+// renderPlainBlock flattens a block rendered with plainOptions into lines
+// without borders: the header starts the first line and continuation lines
+// are indented beneath it.
+func renderPlainBlock(blk block) string {
+	var b strings.Builder
+	b.WriteString(blk.header)
+	for _, entry := range blk.entries {
+		for i, line := range entry.lines {
+			if i == 0 {
+				b.WriteString(" ")
+			} else {
+				b.WriteString("\n  ")
+			}
+			b.WriteString(strings.TrimRight(line, " "))
+		}
+	}
+	return stripControl(b.String())
+}
+
+// stripControl drops control characters other than newline and tab, so a
+// peer cannot smuggle escape sequences into plain output.
+func stripControl(text string) string {
+	return strings.Map(func(r rune) rune {
+		if r != '\n' && r != '\t' && unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, text)
+}
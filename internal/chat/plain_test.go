@@ -0,0 +1,88 @@
+package chat
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"yap/internal/config"
+)
+
+func TestPlainUIPrintsLinesAndSubmitsInput(t *testing.T) {
+	events := make(chan Message)
+	submitted := make(chan string, 4)
+	submit := func(text string) error {
+		submitted <- text
+		return nil
+	}
+	var read []string
+	opts := uiOptionsFrom(config.Config{Format: true})
+	opts.markRead = func(msg Message) { read = append(read, msg.Body) }
+	var out bytes.Buffer
+	done := make(chan error, 1)
+	go func() {
+		done <- runPlainUI("alice", events, submit, opts, strings.NewReader("hello\n\n  \n"), &out)
+	}()
+
+	select {
+	case text := <-submitted:
+		if text != "hello" {
+			t.Fatalf("submitted %q, want hello", text)
+		}
+	case <-time.After(testTimeout):
+		t.Fatal("input line not submitted")
+	}
+	ts := time.Now().Unix()
+	events <- Message{ID: newMessageID(), Type: chatMsg, From: "bob", Body: "a *bold*\nsecond \x1b[31mline", Timestamp: ts}
+	events <- Message{Type: typingMsg, From: "bob"}
+	events <- Message{ID: newMessageID(), Type: chatMsg, From: "alice", Body: "mine", Timestamp: ts}
+	close(events)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	got := out.String()
+	if strings.ContainsRune(got, '\x1b') {
+		t.Fatalf("escape codes in plain output: %q", got)
+	}
+	lines := strings.Split(strings.TrimSuffix(got, "\n"), "\n")
+	if len(lines) != 3 || !strings.Contains(lines[0], "@bob") || !strings.Contains(lines[0], "@bob a *bold*") ||
+		lines[1] != "  second [31mline" || !strings.Contains(lines[2], "@alice mine") {
+		t.Fatalf("plain output:\n%s", got)
+	}
+	if len(submitted) != 0 {
+		t.Fatalf("blank lines submitted: %d", len(submitted))
+	}
+	if len(read) != 1 || read[0] != "a *bold*\nsecond \x1b[31mline" {
+		t.Fatalf("marked read %q, want only bob's message", read)
+	}
+}
+
+func TestRenderPlainAppliesFiltersAndPrompt(t *testing.T) {
+	opts := plainOptions(uiOptionsFrom(config.Config{}))
+	user := "alice"
+	filters, err := json.Marshal(keywordFilters{Mute: []string{"spoiler"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, show := renderPlain(&opts, &user, Message{Type: filterMsg, Body: string(filters)}); show {
+		t.Fatal("filter update printed")
+	}
+	if _, show := renderPlain(&opts, &user, Message{Type: chatMsg, From: "bob", Body: "big spoiler here"}); show {
+		t.Fatal("muted message printed")
+	}
+	if _, show := renderPlain(&opts, &user, Message{Type: promptMsg, Body: " alicia "}); show || user != "alicia" {
+		t.Fatalf("prompt update printed or ignored; user = %q", user)
+	}
+	if _, show := renderPlain(&opts, &user, Message{Type: chatMsg, From: "alicia", Body: "my spoiler"}); !show {
+		t.Fatal("own message muted after a rename")
+	}
+}
+
+func TestStripControl(t *testing.T) {
+	if got := stripControl("a\x1b[2Jb\tc\nd\x07"); got != "a[2Jb\tc\nd" {
+		t.Fatalf("stripControl = %q", got)
+	}
+}
@@ -0,0 +1,59 @@
+package chat
+
+import "time"
+
+// PresenceKind identifies a membership transition on the presence stream.
+type PresenceKind string
+
+// Presence transitions delivered on the Presence stream.
+const (
+	// PresenceJoin reports a member that became active.
+	PresenceJoin PresenceKind = "join"
+	// PresenceLeave reports a member that said goodbye or was removed.
+	PresenceLeave PresenceKind = "leave"
+	// PresenceRename reports a new display name for an active member.
+	PresenceRename PresenceKind = "rename"
+	// PresenceAway reports an active member that stopped responding.
+	PresenceAway PresenceKind = "away"
+)
+
+// PresenceEvent is one typed membership transition.
+type PresenceEvent struct {
+	Kind PresenceKind
+	// Addr is the member's canonical address.
+	Addr string
+	// Name is the member's display name, if known.
+	Name string
+	// Previous is the old name of a renamed member; it is empty when the
+	// name was learned for the first time.
+	Previous string
+	At       time.Time
+}
+
+// presenceBuffer sizes the presence channel; the oldest events are dropped
+// when a slow consumer lets it fill.
+const presenceBuffer = 64
+
+// emitPresence queues a presence event when the stream is enabled.
+func (s *session) emitPresence(kind PresenceKind, addr, name, previous string) {
+	if s.presence == nil {
+		return
+	}
+	event := PresenceEvent{Kind: kind, Addr: addr, Name: name, Previous: previous, At: time.Now()}
+	s.emitMu.RLock()
+	defer s.emitMu.RUnlock()
+	if s.eventsClosed {
+		return
+	}
+	for {
+		select {
+		case s.presence <- event:
+			return
+		default:
+		}
+		select {
+		case <-s.presence:
+		default:
+		}
+	}
+}
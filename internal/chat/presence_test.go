@@ -0,0 +1,56 @@
+package chat
+
+import (
+	"testing"
+
+	"yap/internal/config"
+)
+
+// nextPresence returns the next queued presence event.
+func nextPresence(t *testing.T, s *session) PresenceEvent {
+	t.Helper()
+	select {
+	case ev := <-s.presence:
+		return ev
+	default:
+		t.Fatal("no presence event queued")
+		return PresenceEvent{}
+	}
+}
+
+func TestPresenceTransitions(t *testing.T) {
+	s := newTestSessionWith(t, sessionOptions{config: config.Config{Name: "alice"}, presence: true})
+	const addr = "127.0.0.1:4001"
+
+	s.markMemberActive(addr, "bob")
+	if ev := nextPresence(t, s); ev.Kind != PresenceJoin || ev.Addr != addr || ev.Name != "bob" {
+		t.Fatalf("join = %+v", ev)
+	}
+	s.markMemberActive(addr, "robert")
+	if ev := nextPresence(t, s); ev.Kind != PresenceRename || ev.Name != "robert" || ev.Previous != "bob" {
+		t.Fatalf("rename = %+v", ev)
+	}
+	s.markMemberFailed(addr)
+	if ev := nextPresence(t, s); ev.Kind != PresenceAway || ev.Addr != addr {
+		t.Fatalf("away = %+v", ev)
+	}
+	s.markMemberActive(addr, "")
+	nextPresence(t, s)
+	s.removeMember(addr)
+	if ev := nextPresence(t, s); ev.Kind != PresenceLeave || ev.Name != "robert" {
+		t.Fatalf("leave = %+v", ev)
+	}
+	for _, msg := range drainEvents(s) {
+		if msg.Type == memberMsg {
+			t.Fatalf("presence leaked into the event stream: %+v", msg)
+		}
+	}
+}
+
+func TestPresenceIsOptIn(t *testing.T) {
+	s := newTestSession(t, config.Config{Name: "alice"})
+	s.markMemberActive("127.0.0.1:4001", "bob")
+	if s.presence != nil {
+		t.Fatal("presence stream created without being requested")
+	}
+}
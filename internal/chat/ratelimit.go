@@ -0,0 +1,85 @@
+package chat
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// defaultRateLimit is the per-source packet rate allowed before dropping.
+	defaultRateLimit = 200
+	// rateLimitIdle is how long a quiet source keeps its bucket.
+	rateLimitIdle = time.Minute
+	// rateNoticeInterval spaces out drop notices for the same source.
+	rateNoticeInterval = 30 * time.Second
+)
+
+// rateLimiter enforces a per-source token bucket on inbound packets.
+type rateLimiter struct {
+	mu      sync.Mutex
+	rate    float64
+	buckets map[string]*tokenBucket
+}
+
+// tokenBucket tracks the remaining allowance for one source.
+type tokenBucket struct {
+	tokens  float64
+	last    time.Time
+	noticed time.Time
+}
+
+// newRateLimiter allows perSecond packets per source with an equal burst.
+func newRateLimiter(perSecond int) *rateLimiter {
+	return &rateLimiter{rate: float64(perSecond), buckets: make(map[string]*tokenBucket)}
+}
+
+// allow consumes a token for source. When the packet must be dropped, notify
+// reports whether this is the first drop worth telling the user about.
+func (r *rateLimiter) allow(source string, now time.Time) (ok bool, notify bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	b := r.buckets[source]
+	if b == nil {
+		b = &tokenBucket{tokens: r.rate, last: now}
+		r.buckets[source] = b
+	}
+	b.tokens += now.Sub(b.last).Seconds() * r.rate
+	if b.tokens > r.rate {
+		b.tokens = r.rate
+	}
+	b.last = now
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, false
+	}
+	if now.Sub(b.noticed) < rateNoticeInterval {
+		return false, false
+	}
+	b.noticed = now
+	return false, true
+}
+
+// prune forgets sources that have been quiet for longer than idle.
+func (r *rateLimiter) prune(now time.Time, idle time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for source, b := range r.buckets {
+		if now.Sub(b.last) > idle {
+			delete(r.buckets, source)
+		}
+	}
+}
+
+// run prunes idle sources periodically until stop closes.
+func (r *rateLimiter) run(stop <-chan struct{}) {
+	ticker := time.NewTicker(rateLimitIdle)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case now := <-ticker.C:
+			r.prune(now, rateLimitIdle)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package chat
+
+import (
+	"encoding/json"
+	"net"
+	"testing"
+	"time"
+
+	"yap/internal/config"
+)
+
+func TestRateLimiterBucket(t *testing.T) {
+	r := newRateLimiter(3)
+	now := time.Unix(1000, 0)
+	for i := range 3 {
+		if ok, _ := r.allow("a", now); !ok {
+			t.Fatalf("packet %d dropped within the burst", i)
+		}
+	}
+	if ok, notify := r.allow("a", now); ok || !notify {
+		t.Fatalf("first excess packet = %v, %v; want dropped with a notice", ok, notify)
+	}
+	if ok, notify := r.allow("a", now); ok || notify {
+		t.Fatalf("second excess packet = %v, %v; want dropped quietly", ok, notify)
+	}
+	if ok, _ := r.allow("b", now); !ok {
+		t.Fatal("other sources share the bucket")
+	}
+	if ok, _ := r.allow("a", now.Add(time.Second/2)); !ok {
+		t.Fatal("bucket did not refill")
+	}
+}
+
+func TestRateLimiterPrunesQuietSources(t *testing.T) {
+	r := newRateLimiter(3)
+	now := time.Unix(1000, 0)
+	r.allow("a", now)
+	r.allow("b", now.Add(time.Minute))
+	r.prune(now.Add(time.Minute+time.Second), time.Minute)
+	if _, ok := r.buckets["a"]; ok {
+		t.Fatal("quiet source kept")
+	}
+	if _, ok := r.buckets["b"]; !ok {
+		t.Fatal("recent source pruned")
+	}
+}
+
+func TestBurstFromOneSourceIsThrottled(t *testing.T) {
+	s := newTestSessionWith(t, sessionOptions{config: config.Config{Name: "alice"}, rateLimit: 5})
+	s.start()
+	peer, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer peer.Close()
+	to, _ := net.ResolveUDPAddr("udp", s.localAddr)
+
+	for range 20 {
+		raw, _ := json.Marshal(Message{ID: newMessageID(), Type: chatMsg, From: "bob", Body: "spam", Timestamp: time.Now().Unix(), Version: protocolVersion})
+		if _, err := peer.WriteTo(raw, to); err != nil {
+			t.Fatal(err)
+		}
+	}
+	chats, notices := 0, 0
+	waitEvent(t, s, func(msg Message) bool {
+		if msg.Type == chatMsg {
+			chats++
+		}
+		return systemContaining("throttling packets from")(msg)
+	})
+	time.Sleep(100 * time.Millisecond)
+	for _, msg := range drainEvents(s) {
+		switch {
+		case msg.Type == chatMsg:
+			chats++
+		case systemContaining("throttling")(msg):
+			notices++
+		}
+	}
+	if notices != 0 {
+		t.Fatalf("%d repeated throttle notices, want a single one", notices)
+	}
+	if chats == 0 || chats > 5 {
+		t.Fatalf("%d chats got through a limit of 5", chats)
+	}
+}
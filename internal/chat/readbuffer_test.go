@@ -0,0 +1,47 @@
+package chat
+
+import (
+	"encoding/json"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"yap/internal/config"
+)
+
+func TestOversizedDatagramWarns(t *testing.T) {
+	s := newTestSession(t, config.Config{Name: "alice", ReadBuffer: 512})
+	s.start()
+	peer, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer peer.Close()
+	to, _ := net.ResolveUDPAddr("udp", s.localAddr)
+
+	raw, err := json.Marshal(Message{ID: newMessageID(), Type: chatMsg, From: "bob", Body: strings.Repeat("x", 2000), Timestamp: time.Now().Unix(), Version: protocolVersion})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := peer.WriteTo(raw, to); err != nil {
+		t.Fatal(err)
+	}
+	warning := waitEvent(t, s, systemContaining("likely truncated"))
+	if !strings.Contains(warning.Body, "512-byte read buffer") {
+		t.Fatalf("warning = %q", warning.Body)
+	}
+	if got := s.metrics().Dropped; got != 1 {
+		t.Fatalf("dropped = %d, want 1", got)
+	}
+
+	// A datagram that fits is still delivered.
+	raw, err = json.Marshal(Message{ID: newMessageID(), Type: chatMsg, From: "bob", Body: "short", Timestamp: time.Now().Unix(), Version: protocolVersion})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := peer.WriteTo(raw, to); err != nil {
+		t.Fatal(err)
+	}
+	waitEvent(t, s, func(m Message) bool { return m.Type == chatMsg && m.Body == "short" })
+}
@@ -0,0 +1,115 @@
+package chat
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// endpointPayload tells peers which addresses a rebound node used to listen on.
+type endpointPayload struct {
+	Previous []string `json:"previous"`
+}
+
+// rebind moves the session onto sockets bound to raw without touching the UI,
+// the events stream, or membership, and returns the addresses it left. If
+// nothing can be bound there, the previous addresses are bound again and an
+// error is returned.
+func (s *session) rebind(raw string) ([]string, error) {
+	addrs := listenAddrs(raw)
+	if len(addrs) == 0 {
+		return nil, fmt.Errorf("listen on %q: no addresses", raw)
+	}
+	var previous []string
+	for _, addr := range s.transport.localAddrs() {
+		previous = append(previous, addr.String())
+	}
+
+	// Release the old sockets first so the new address may reuse their port.
+	if err := s.transport.close(); err != nil {
+		s.emitSystem("closing old socket: %v", err)
+	}
+	conns, bindErrs := bindAll(s.bind, addrs)
+	if len(conns) == 0 {
+		restored, restoreErrs := bindAll(s.bind, previous)
+		if len(restored) == 0 {
+			return nil, fmt.Errorf("rebind failed and old sockets could not be restored: %w", errors.Join(append(bindErrs, restoreErrs...)...))
+		}
+		s.transport.replaceConns(restored)
+		return nil, fmt.Errorf("rebind failed; still listening on %s: %w", strings.Join(previous, ", "), errors.Join(bindErrs...))
+	}
+	for _, err := range bindErrs {
+		s.emitSystem("%v; continuing without it", err)
+	}
+
+	s.transport.replaceConns(conns)
+	s.moveLocalAddr(s.transport.localAddr().String())
+	s.setBoundAddrs(s.transport.localAddrs())
+	s.cfg.Listen = raw
+
+	bound := make([]string, 0, len(conns))
+	for _, addr := range s.transport.localAddrs() {
+		bound = append(bound, addr.String())
+	}
+	s.recordEvent("rebound to %s", strings.Join(bound, ", "))
+	s.emitSystem("listening on %s", strings.Join(bound, ", "))
+	return previous, nil
+}
+
+// announceEndpoint tells directly connected peers that this node now sends
+// from a new address, so they move its member entry instead of timing it out.
+func (s *session) announceEndpoint(previous []string) {
+	body, err := json.Marshal(endpointPayload{Previous: previous})
+	if err != nil {
+		return
+	}
+	_, raw, err := s.transport.prepare(s.cfg.Name, endpointUpdateMsg, string(body))
+	if err != nil {
+		s.emitSystem("failed to announce new endpoint: %v", err)
+		return
+	}
+	res := s.forwardRaw(raw, nil)
+	if res.attempted > 0 {
+		s.emitSystem("told %d peer(s) about the new address", res.delivered)
+	}
+}
+
+// handleEndpointUpdate moves a rebound peer's member entry to the address its
+// update arrived from. Updates are never relayed, so the source is the peer.
+func (s *session) handleEndpointUpdate(msg Message, addr net.Addr) {
+	var payload endpointPayload
+	if err := json.Unmarshal([]byte(msg.Body), &payload); err != nil {
+		return
+	}
+	source, ok := addrPortFromNet(addr)
+	if !ok {
+		return
+	}
+	oldKey, moved := s.relocateMember(payload.Previous, normalizeName(msg.From), source)
+	if !moved {
+		s.markActive(addr, msg.From)
+		return
+	}
+	newKey := formatAddrPort(source)
+	s.setMemberEndpoint(newKey, source)
+	s.forgetAcks(oldKey)
+	s.recordPeerEvent(newKey, "%s moved to %s", oldKey, newKey)
+	s.scheduleGossip()
+}
+
+// bindAll opens a socket for every address, collecting the failures.
+func bindAll(listen func(string) (net.PacketConn, error), addrs []string) ([]net.PacketConn, []error) {
+	var conns []net.PacketConn
+	var errs []error
+	for _, addr := range addrs {
+		conn, err := listen(addr)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("listen on %q: %w", addr, err))
+			continue
+		}
+		conns = append(conns, conn)
+	}
+	return conns, errs
+}
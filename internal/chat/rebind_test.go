@@ -0,0 +1,39 @@
+package chat
+
+import (
+	"testing"
+
+	"yap/internal/config"
+)
+
+func TestRebindUpdatesPeerEndpoints(t *testing.T) {
+	alice := newTestSession(t, config.Config{Name: "alice"})
+	bob := newTestSession(t, config.Config{Name: "bob"})
+	connect(t, alice, bob)
+	old := alice.localAddr
+
+	if err := alice.handleInput("/rebind 127.0.0.1:0"); err != nil {
+		t.Fatal(err)
+	}
+	moved := alice.localAddr
+	if moved == old {
+		t.Fatal("rebind kept the old address")
+	}
+	waitEvent(t, alice, systemContaining("told 1 peer(s) about the new address"))
+	waitUntil(t, func() bool { return isActive(bob, moved) })
+
+	rec, _ := bob.lookupMember(moved)
+	if ap, ok := rec.AddrPort(); !ok || ap.String() != moved || rec.Name != "alice" {
+		t.Fatalf("bob's entry = %+v, want alice at %s", rec, moved)
+	}
+	if bob.hasMember(old) {
+		t.Fatalf("bob still lists the old address %s", old)
+	}
+
+	// Traffic now reaches alice on the new socket.
+	drainEvents(alice)
+	if err := bob.handleInput("still there?"); err != nil {
+		t.Fatal(err)
+	}
+	waitEvent(t, alice, func(m Message) bool { return m.Type == chatMsg && m.Body == "still there?" })
+}
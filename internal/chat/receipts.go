@@ -0,0 +1,60 @@
+package chat
+
+import (
+	"net"
+	"slices"
+)
+
+// markRead tells the sender of a chat message that this UI has shown it.
+// Receipts go straight to the sender and are skipped when it is not an
+// active member.
+func (s *session) markRead(msg Message) {
+	if !s.cfg.ReadReceipts || msg.Type != chatMsg || msg.ID == "" || namesEqual(msg.From, s.cfg.Name, s.cfg.FoldNames) {
+		return
+	}
+	keys, _ := s.memberKeysByName([]string{msg.From})
+	if len(keys) != 1 {
+		return
+	}
+	rec, ok := s.lookupMember(keys[0])
+	if !ok || rec.Status != statusActive {
+		return
+	}
+	ap, ok := rec.AddrPort()
+	if !ok {
+		return
+	}
+	_, raw, err := s.transport.prepareMessage(Message{From: s.cfg.Name, Type: readMsg, Ref: msg.ID})
+	if err != nil {
+		return
+	}
+	_ = s.transport.sendRaw(net.UDPAddrFromAddrPort(ap), raw)
+}
+
+// sentRecently reports whether id is a recent chat message sent by this session.
+func (s *session) sentRecently(id string) bool {
+	if id == "" {
+		return false
+	}
+	s.statusMu.RLock()
+	defer s.statusMu.RUnlock()
+	for i := len(s.recent) - 1; i >= 0; i-- {
+		if s.recent[i].ID == id {
+			return namesEqual(s.recent[i].From, s.cfg.Name, s.cfg.FoldNames)
+		}
+	}
+	return false
+}
+
+// applyRead records that a peer has shown one of our messages.
+func (m *bubbleModel) applyRead(msg Message) {
+	entry := m.findEntry(msg.Ref)
+	if entry == nil || msg.From == "" {
+		return
+	}
+	if slices.ContainsFunc(entry.readBy, func(name string) bool { return namesEqual(name, msg.From, m.opts.foldNames) }) {
+		return
+	}
+	entry.readBy = append(entry.readBy, msg.From)
+	m.refreshPin(*entry)
+}
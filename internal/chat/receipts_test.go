@@ -0,0 +1,69 @@
+package chat
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"yap/internal/config"
+)
+
+func TestRenderedMessageIsMarkedRead(t *testing.T) {
+	alice := newTestSession(t, config.Config{Name: "alice", ReadReceipts: true})
+	bob := newTestSession(t, config.Config{Name: "bob", ReadReceipts: true})
+	connect(t, alice, bob)
+	aliceUI := newBubbleModel("alice", nil, nil, uiOptionsFrom(alice.cfg))
+	bobOpts := uiOptionsFrom(bob.cfg)
+	bobOpts.markRead = bob.markRead
+	bobUI := newBubbleModel("bob", nil, nil, bobOpts)
+
+	if err := alice.handleInput("hello"); err != nil {
+		t.Fatal(err)
+	}
+	sent := waitEvent(t, alice, func(m Message) bool { return m.Type == chatMsg })
+	aliceUI.Update(sent)
+	if strings.Contains(aliceUI.View(), "read by") {
+		t.Fatal("read before anyone rendered it")
+	}
+
+	// Receiving alone sends no receipt; rendering does.
+	got := waitEvent(t, bob, func(m Message) bool { return m.Type == chatMsg })
+	expectNoEvent(t, alice, readMsg)
+	bobUI.Update(got)
+	read := waitEvent(t, alice, func(m Message) bool { return m.Type == readMsg })
+	if read.Ref != sent.ID || read.From != "bob" {
+		t.Fatalf("receipt = %+v", read)
+	}
+	aliceUI.Update(read)
+	if !strings.Contains(aliceUI.View(), "read by 1") {
+		t.Fatalf("sender block lacks the receipt:\n%s", aliceUI.View())
+	}
+}
+
+func TestReadReceiptsAreOptIn(t *testing.T) {
+	alice := newTestSession(t, config.Config{Name: "alice"})
+	bob := newTestSession(t, config.Config{Name: "bob"})
+	connect(t, alice, bob)
+
+	if err := alice.handleInput("hello"); err != nil {
+		t.Fatal(err)
+	}
+	bob.markRead(waitEvent(t, bob, func(m Message) bool { return m.Type == chatMsg }))
+	expectNoEvent(t, alice, readMsg)
+}
+
+// expectNoEvent fails if s emits an event of kind within a short wait.
+func expectNoEvent(t *testing.T, s *session, kind msgType) {
+	t.Helper()
+	deadline := time.After(100 * time.Millisecond)
+	for {
+		select {
+		case msg := <-s.events:
+			if msg.Type == kind {
+				t.Fatalf("unexpected %s event: %+v", kind, msg)
+			}
+		case <-deadline:
+			return
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package chat
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	// maxRecentMessages bounds the ring of chat messages kept for replies.
+	maxRecentMessages = 200
+	// shortIDLen is the number of ID characters shown to users.
+	shortIDLen = 6
+)
+
+// rememberRecent appends a chat message to the bounded recent-message ring.
+func (s *session) rememberRecent(msg Message) {
+	if msg.ID == "" {
+		return
+	}
+	s.statusMu.Lock()
+	defer s.statusMu.Unlock()
+	s.recent = append(s.recent, msg)
+	if len(s.recent) > maxRecentMessages {
+		s.recent = s.recent[len(s.recent)-maxRecentMessages:]
+	}
+}
+
+// resolveRecent expands a short message ID prefix to a full recent message ID.
+func (s *session) resolveRecent(prefix string) (string, error) {
+	prefix = strings.TrimPrefix(strings.TrimSpace(prefix), "#")
+	if len(prefix) < 4 {
+		return "", fmt.Errorf("message id %q is too short", prefix)
+	}
+	s.statusMu.RLock()
+	defer s.statusMu.RUnlock()
+	match := ""
+	for i := len(s.recent) - 1; i >= 0; i-- {
+		id := s.recent[i].ID
+		if !strings.HasPrefix(id, prefix) || id == match {
+			continue
+		}
+		if match != "" {
+			return "", fmt.Errorf("message id %q is ambiguous", prefix)
+		}
+		match = id
+	}
+	if match == "" {
+		return "", fmt.Errorf("no recent message matches %q", prefix)
+	}
+	return match, nil
+}
+
+// shortID trims a message ID to its display form.
+func shortID(id string) string {
+	if len(id) > shortIDLen {
+		return id[:shortIDLen]
+	}
+	return id
+}
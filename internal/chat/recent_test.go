@@ -0,0 +1,49 @@
+package chat
+
+import (
+	"strings"
+	"testing"
+
+	"yap/internal/config"
+)
+
+func TestResolveRecentPrefixes(t *testing.T) {
+	s := newTestSession(t, config.Config{})
+	s.rememberRecent(Message{ID: "abcd1111"})
+	s.rememberRecent(Message{ID: "abcd2222"})
+
+	if id, err := s.resolveRecent("#abcd1"); err != nil || id != "abcd1111" {
+		t.Fatalf("resolveRecent(#abcd1) = %q, %v", id, err)
+	}
+	for prefix, want := range map[string]string{
+		"abc":  "too short",
+		"abcd": "ambiguous",
+		"ffff": "no recent message",
+	} {
+		if _, err := s.resolveRecent(prefix); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("resolveRecent(%q) err = %v, want %q", prefix, err, want)
+		}
+	}
+}
+
+func TestReplyCarriesReference(t *testing.T) {
+	s := newTestSession(t, config.Config{Name: "alice"})
+	peer := listenPeer(t, s, "bob")
+	s.rememberRecent(Message{ID: "feedbeef00", From: "bob", Body: "question?"})
+	if err := s.handleInput("/reply feed answer"); err != nil {
+		t.Fatal(err)
+	}
+	msg := readMessage(t, peer)
+	if msg.ReplyTo != "feedbeef00" || msg.Body != "answer" {
+		t.Fatalf("got %+v, want a reply to feedbeef00", msg)
+	}
+}
+
+func TestReplyQuotesOriginal(t *testing.T) {
+	m, id := newTestModel(t, "")
+	m.Update(Message{ID: newMessageID(), Type: chatMsg, From: "alice", Body: "answer", ReplyTo: id})
+	last := m.history[len(m.history)-1]
+	if !strings.Contains(last.entries[0].lines[0], "@bob: original") {
+		t.Fatalf("reply lacks a quote: %q", last.entries[0].lines)
+	}
+}
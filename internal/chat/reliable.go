@@ -0,0 +1,173 @@
+package chat
+
+import (
+	"net"
+	"sync"
+	"time"
+)
+
+const (
+	// ackTimeout is how long a recipient has to acknowledge a chat message
+	// before it is resent.
+	ackTimeout = time.Second
+	// maxAckResends bounds how many times an unacknowledged message is resent
+	// before the recipient is demoted.
+	maxAckResends = 3
+	// reforwardGrace is how long after first seeing a message a relay passes
+	// resends of it on again; it spans the whole resend schedule.
+	reforwardGrace = ackTimeout * (maxAckResends + 1)
+	// maxReforwards bounds how often one message is passed on again.
+	maxReforwards = maxAckResends
+)
+
+// ackKey identifies one outstanding acknowledgement.
+type ackKey struct {
+	id   string
+	peer string
+}
+
+// pendingAck is an encoded chat packet awaiting acknowledgement from one peer.
+type pendingAck struct {
+	raw     []byte
+	target  net.Addr
+	sent    time.Time
+	resends int
+}
+
+// ackTracker records chat messages that still need an ack from a direct recipient.
+type ackTracker struct {
+	mu      sync.Mutex
+	pending map[ackKey]*pendingAck
+}
+
+// reliableKind reports whether messages of kind are acknowledged and resent
+// in reliable mode. Control traffic such as typing notices and pings stays
+// best-effort, so high-frequency messages never multiply into acks.
+func reliableKind(kind msgType) bool {
+	return kind == chatMsg || kind == fileMsg
+}
+
+// expectAcks starts tracking raw for every target when reliable mode is on
+// and msg is of a reliableKind.
+func (s *session) expectAcks(msg Message, raw []byte, targets []memberEndpoint) {
+	id := msg.ID
+	if !s.cfg.Reliable || !reliableKind(msg.Type) || id == "" || len(targets) == 0 {
+		return
+	}
+	now := s.now()
+	s.acks.mu.Lock()
+	defer s.acks.mu.Unlock()
+	if s.acks.pending == nil {
+		s.acks.pending = make(map[ackKey]*pendingAck)
+	}
+	for _, target := range targets {
+		udp := net.UDPAddrFromAddrPort(target.ap)
+		if udp == nil {
+			continue
+		}
+		s.acks.pending[ackKey{id: id, peer: target.key}] = &pendingAck{raw: raw, target: udp, sent: now}
+	}
+}
+
+// handleAck clears the outstanding acknowledgement for id from addr.
+func (s *session) handleAck(id string, addr net.Addr) {
+	if id == "" {
+		return
+	}
+	peer := canonicalNetAddr(addr)
+	s.acks.mu.Lock()
+	delete(s.acks.pending, ackKey{id: id, peer: peer})
+	s.acks.mu.Unlock()
+	s.noteDelivery(id, peer)
+}
+
+// sendAck acknowledges a chat message or file chunk back to the peer that
+// delivered it. Only reliable sessions send acks, so best-effort meshes carry
+// no ack traffic at all.
+func (s *session) sendAck(id string, addr net.Addr) {
+	if !s.cfg.Reliable || id == "" || addr == nil {
+		return
+	}
+	_, raw, err := s.transport.prepareMessage(Message{From: s.cfg.Name, Type: ackMsg, Ref: id})
+	if err != nil {
+		return
+	}
+	_ = s.transport.sendRaw(addr, raw)
+}
+
+// reforward passes on a resend of a message this node has already seen, so
+// peers downstream that missed the original still get it. Only duplicates
+// arriving straight from the sender count as resends; copies from other
+// relays are ordinary mesh redundancy. The resend goes to every active peer
+// but the sender, including those this node believes already hold it, since
+// the forward to them may be what was lost.
+func (s *session) reforward(msg Message, raw []byte, source net.Addr) {
+	if s.cfg.NoForward || msg.To != "" || msg.Direct {
+		return
+	}
+	origin, _ := s.memberKeysByName([]string{msg.From})
+	sourceKey := canonicalNetAddr(source)
+	if len(origin) != 1 || origin[0] != sourceKey {
+		return
+	}
+	if !s.transport.seen.allowReforward(msg.ID, reforwardGrace, maxReforwards) {
+		return
+	}
+	res := s.sendToEndpoints(s.activeEndpoints(sourceKey), raw)
+	s.transport.stats.forwarded.Add(uint64(res.delivered))
+}
+
+// forgetAcks drops every outstanding acknowledgement owed by peer.
+func (s *session) forgetAcks(peer string) {
+	s.acks.mu.Lock()
+	defer s.acks.mu.Unlock()
+	for key := range s.acks.pending {
+		if key.peer == peer {
+			delete(s.acks.pending, key)
+		}
+	}
+}
+
+// sweepAcks resends chat messages whose acknowledgement timed out and demotes
+// recipients that stay silent after maxAckResends attempts.
+func (s *session) sweepAcks() {
+	now := s.now()
+	type resend struct {
+		key ackKey
+		raw []byte
+		to  net.Addr
+	}
+	var resends []resend
+	failed := make(map[string]net.Addr)
+
+	s.acks.mu.Lock()
+	for key, entry := range s.acks.pending {
+		if now.Sub(entry.sent) < ackTimeout {
+			continue
+		}
+		if entry.resends >= maxAckResends {
+			delete(s.acks.pending, key)
+			failed[key.peer] = entry.target
+			continue
+		}
+		entry.resends++
+		entry.sent = now
+		resends = append(resends, resend{key: key, raw: entry.raw, to: entry.target})
+	}
+	s.acks.mu.Unlock()
+
+	for _, r := range resends {
+		if _, dropped := failed[r.key.peer]; dropped {
+			continue
+		}
+		if err := s.transport.sendRaw(r.to, r.raw); err != nil {
+			s.emitSystem("resend to %s failed: %v", r.key.peer, err)
+			continue
+		}
+		s.recordPeerEvent(r.key.peer, "resent %s to %s", r.key.id, r.key.peer)
+	}
+	for peer, addr := range failed {
+		s.forgetAcks(peer)
+		s.dropPeer(addr, "no acknowledgement")
+	}
+}
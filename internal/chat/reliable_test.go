@@ -0,0 +1,267 @@
+package chat
+
+import (
+	"bytes"
+	"encoding/json"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"yap/internal/config"
+)
+
+// listenPeer opens a UDP socket standing in for a peer and registers it as
+// an active member of s.
+func listenPeer(t *testing.T, s *session, name string) net.PacketConn {
+	t.Helper()
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	s.markMemberActive(conn.LocalAddr().String(), name)
+	return conn
+}
+
+func pendingAcks(s *session) int {
+	s.acks.mu.Lock()
+	defer s.acks.mu.Unlock()
+	return len(s.acks.pending)
+}
+
+func TestReliableTracksOnlyConversationTraffic(t *testing.T) {
+	s := newTestSession(t, config.Config{Reliable: true})
+	listenPeer(t, s, "bob")
+
+	if err := s.broadcast(typingMsg, ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.broadcast(pingMsg, "1"); err != nil {
+		t.Fatal(err)
+	}
+	if n := pendingAcks(s); n != 0 {
+		t.Fatalf("control traffic tracked for retransmit: %d pending", n)
+	}
+	if err := s.broadcast(chatMsg, "hello"); err != nil {
+		t.Fatal(err)
+	}
+	if n := pendingAcks(s); n != 1 {
+		t.Fatalf("chat message not tracked: %d pending", n)
+	}
+	if err := s.broadcastMessage(Message{Type: fileMsg, Ref: "x", File: "a.txt", Chunks: 1, Body: "aGk="}); err != nil {
+		t.Fatal(err)
+	}
+	if n := pendingAcks(s); n != 2 {
+		t.Fatalf("file chunk not tracked: %d pending", n)
+	}
+}
+
+func TestReliableKind(t *testing.T) {
+	for kind, want := range map[msgType]bool{
+		chatMsg:   true,
+		fileMsg:   true,
+		typingMsg: false,
+		pingMsg:   false,
+		pongMsg:   false,
+		ackMsg:    false,
+		joinMsg:   false,
+	} {
+		if got := reliableKind(kind); got != want {
+			t.Errorf("reliableKind(%s) = %v, want %v", kind, got, want)
+		}
+	}
+}
+
+func TestUnreliableSessionTracksNothing(t *testing.T) {
+	s := newTestSession(t, config.Config{})
+	listenPeer(t, s, "bob")
+	if err := s.broadcast(chatMsg, "hello"); err != nil {
+		t.Fatal(err)
+	}
+	if n := pendingAcks(s); n != 0 {
+		t.Fatalf("tracked %d messages without reliable mode", n)
+	}
+}
+
+func TestUnreliableSessionSendsNoAcks(t *testing.T) {
+	s := newTestSession(t, config.Config{Name: "alice"})
+	s.start()
+	bob := listenPeer(t, s, "bob")
+	sender := newTestSession(t, config.Config{Name: "bob"})
+	drainEvents(s)
+
+	_, chat, err := sender.transport.prepareMessage(Message{Type: chatMsg, From: "bob", Body: "hi"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, chunk, err := sender.transport.prepareMessage(Message{Type: fileMsg, From: "bob", File: "a.txt", FileSize: 2, Chunks: 1, Body: "aGk="})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The chat message twice, so the duplicate path is covered too.
+	for _, raw := range [][]byte{chat, chat, chunk} {
+		if _, err := bob.WriteTo(raw, s.transport.localAddr()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	waitEvent(t, s, func(m Message) bool { return m.Type == chatMsg && m.Body == "hi" })
+	expectNoKind(t, bob, ackMsg)
+
+	if err := s.broadcast(chatMsg, "hello"); err != nil {
+		t.Fatal(err)
+	}
+	expectNoEvent(t, s, deliveryMsg)
+}
+
+// dropFirst returns a fakeConn read filter discarding the first datagram
+// that contains marker.
+func dropFirst(marker string) func([]byte) bool {
+	var once sync.Once
+	return func(p []byte) bool {
+		dropped := false
+		if bytes.Contains(p, []byte(marker)) {
+			once.Do(func() { dropped = true })
+		}
+		return dropped
+	}
+}
+
+func TestResendReachesPeerBehindRelay(t *testing.T) {
+	// alice and carol are spokes that only reach each other through bob.
+	// carol loses bob's forward of the original and alice loses bob's ack,
+	// so alice resends to bob, who has already seen the ID.
+	aliceConn := &fakeConn{dropRead: dropFirst(`"kind":"ack"`)}
+	carolConn := &fakeConn{dropRead: dropFirst(`"kind":"chat"`)}
+	alice := newTestSessionWith(t, sessionOptions{config: config.Config{Name: "alice", NoForward: true, Reliable: true}, listen: listenFake(aliceConn)})
+	bob := newTestSession(t, config.Config{Name: "bob", Relay: true})
+	carol := newTestSessionWith(t, sessionOptions{config: config.Config{Name: "carol", NoForward: true}, listen: listenFake(carolConn)})
+	connect(t, bob, alice)
+	connect(t, bob, carol)
+	drainEvents(carol)
+
+	if err := alice.broadcast(chatMsg, "anyone there?"); err != nil {
+		t.Fatal(err)
+	}
+	got := waitEvent(t, carol, func(m Message) bool { return m.Type == chatMsg })
+	if got.Body != "anyone there?" || got.From != "alice" {
+		t.Fatalf("carol got %+v", got)
+	}
+}
+
+func TestReforwardIsBounded(t *testing.T) {
+	d := newDedupCache(time.Minute)
+	d.store("id")
+	for i := range maxReforwards {
+		if !d.allowReforward("id", time.Minute, maxReforwards) {
+			t.Fatalf("re-forward %d refused", i+1)
+		}
+	}
+	if d.allowReforward("id", time.Minute, maxReforwards) {
+		t.Fatal("re-forward allowed past the limit")
+	}
+	if d.allowReforward("unknown", time.Minute, maxReforwards) {
+		t.Fatal("re-forward allowed for an unseen ID")
+	}
+	d.seen.Store("old", time.Now().Add(-2*time.Minute))
+	if d.allowReforward("old", time.Minute, maxReforwards) {
+		t.Fatal("re-forward allowed after the grace window")
+	}
+}
+
+func TestLostAckTriggersResend(t *testing.T) {
+	clock := newFakeClock()
+	s := newTestSessionWith(t, sessionOptions{config: config.Config{Name: "alice", Reliable: true}, now: clock.Now})
+	bob := listenPeer(t, s, "bob")
+
+	if err := s.broadcast(chatMsg, "hello"); err != nil {
+		t.Fatal(err)
+	}
+	first := readMessage(t, bob)
+	clock.advance(ackTimeout / 2)
+	s.sweepAcks()
+	expectSilence(t, bob)
+
+	clock.advance(ackTimeout)
+	s.sweepAcks()
+	if again := readMessage(t, bob); again.ID != first.ID || again.Body != "hello" {
+		t.Fatalf("resend = %+v, want a copy of %s", again, first.ID)
+	}
+
+	s.handleAck(first.ID, bob.LocalAddr())
+	if n := pendingAcks(s); n != 0 {
+		t.Fatalf("%d acks pending after the ack arrived", n)
+	}
+}
+
+func TestSilentPeerIsDemoted(t *testing.T) {
+	clock := newFakeClock()
+	s := newTestSessionWith(t, sessionOptions{config: config.Config{Name: "alice", Reliable: true}, now: clock.Now})
+	bob := listenPeer(t, s, "bob")
+	if err := s.broadcast(chatMsg, "hello"); err != nil {
+		t.Fatal(err)
+	}
+	for range maxAckResends + 1 {
+		clock.advance(ackTimeout)
+		s.sweepAcks()
+	}
+	if isActive(s, bob.LocalAddr().String()) {
+		t.Fatal("silent peer still active")
+	}
+	if n := pendingAcks(s); n != 0 {
+		t.Fatalf("%d acks still pending", n)
+	}
+	if !dropped(drainEvents(s)) {
+		t.Fatal("no member failure reported")
+	}
+}
+
+func TestForgedDuplicateIsNotAckedOrReforwarded(t *testing.T) {
+	cfg := config.Config{Name: "alice", Secret: "correct horse", Reliable: true}
+	cipher, err := newPacketCipher(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := newTestSessionWith(t, sessionOptions{config: cfg, cipher: cipher})
+	s.start()
+	bob := listenPeer(t, s, "bob")
+	carol := listenPeer(t, s, "carol")
+	sealer := newSecretSession(t, "bob", "correct horse")
+	readKind := func(conn net.PacketConn, kind msgType) Message {
+		t.Helper()
+		for {
+			if msg := readMessage(t, conn); msg.Type == kind {
+				return msg
+			}
+		}
+	}
+
+	sealed, raw, err := sealer.transport.prepareMessage(Message{Type: chatMsg, From: "bob", Body: "hi"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := bob.WriteTo(raw, s.transport.localAddr()); err != nil {
+		t.Fatal(err)
+	}
+	readKind(bob, ackMsg)
+	readKind(carol, chatMsg)
+
+	// A copy reusing the ID but failing authentication is dropped.
+	forged := sealed
+	forged.Cipher = "AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA"
+	data, _ := json.Marshal(forged)
+	if _, err := bob.WriteTo(data, s.transport.localAddr()); err != nil {
+		t.Fatal(err)
+	}
+	expectNoKind(t, bob, ackMsg)
+	expectNoKind(t, carol, chatMsg)
+
+	// A genuine resend is still acknowledged and passed on.
+	if _, err := bob.WriteTo(raw, s.transport.localAddr()); err != nil {
+		t.Fatal(err)
+	}
+	if ack := readKind(bob, ackMsg); ack.Ref != sealed.ID {
+		t.Fatalf("ack = %+v, want one for %s", ack, sealed.ID)
+	}
+	readKind(carol, chatMsg)
+}
@@ -0,0 +1,114 @@
+package chat
+
+import (
+	"reflect"
+	"slices"
+	"strings"
+
+	"yap/internal/config"
+)
+
+// reloadConfig re-reads the config file and applies whatever changed in the
+// active profile since it was last read, leaving command-line overrides and
+// membership intact. Listen changes need a restart.
+func (s *session) reloadConfig() {
+	if s.cfg.Ephemeral {
+		s.emitSystem("config reloading is disabled in ephemeral mode")
+		return
+	}
+	if s.store == nil || s.store.Path() == "" {
+		s.emitSystem("config reloading is not available")
+		return
+	}
+
+	prev, _ := config.ResolveProfile(s.store, s.cfg.Profile)
+	store, err := config.Load(s.store.Path())
+	if err != nil {
+		s.emitSystem("failed to reload config: %v", err)
+		return
+	}
+	next, err := config.ResolveProfile(store, s.cfg.Profile)
+	if err != nil {
+		s.emitSystem("failed to reload config %q: %v", s.cfg.Profile, err)
+		return
+	}
+
+	cfg := overlayChanged(s.cfg, prev, next)
+	cfg.Listen = s.cfg.Listen
+	cfg.Peers = config.MergePeers(s.cfg.Peers, next.Peers)
+
+	var newCipher packetCipher
+	if cfg.Secret != "" {
+		newCipher, err = newPacketCipher(cfg)
+		if err != nil {
+			s.emitSystem("reloaded secret rejected: %v", err)
+			return
+		}
+	}
+
+	var changes []string
+	if next.Listen != prev.Listen && next.Listen != s.cfg.Listen {
+		changes = append(changes, "listen changed to "+next.Listen+"; restart required to apply")
+	}
+	if !sameCipherSettings(cfg, s.cfg) {
+		s.transport.setCipher(newCipher)
+		s.clearAuthRejects("")
+		switch {
+		case cfg.Secret == "":
+			changes = append(changes, "encryption disabled")
+		case s.cfg.Secret == "":
+			changes = append(changes, "encryption enabled")
+		case cfg.Secret != s.cfg.Secret:
+			changes = append(changes, "secret changed")
+		default:
+			changes = append(changes, "encryption settings changed (key "+newCipher.Fingerprint()+")")
+		}
+	}
+	if cfg.Name != s.cfg.Name {
+		s.transport.setName(cfg.Name)
+		s.emitPromptUpdate(cfg.Name)
+		changes = append(changes, "now chatting as "+cfg.Name)
+	}
+	var added []string
+	for _, peer := range cfg.Peers {
+		if !slices.Contains(s.cfg.Peers, peer) {
+			added = append(added, peer)
+		}
+	}
+	if len(added) > 0 {
+		changes = append(changes, "new peers: "+strings.Join(added, ", "))
+	}
+	filtersChanged := !slices.Equal(cfg.Highlight, s.cfg.Highlight) || !slices.Equal(cfg.Mute, s.cfg.Mute)
+
+	s.store = store
+	s.cfg = cfg
+	if filtersChanged {
+		s.emitFilters()
+		changes = append(changes, "filters updated")
+	}
+	for _, peer := range added {
+		s.contactPeer(peer)
+	}
+
+	s.recordEvent("reloaded config %q", cfg.Profile)
+	if len(changes) == 0 {
+		s.emitSystem("reloaded config %q; no changes", cfg.Profile)
+		return
+	}
+	s.emitSystem("reloaded config %q:\n  %s", cfg.Profile, strings.Join(changes, "\n  "))
+}
+
+// overlayChanged returns cur with every field that differs between prev and
+// next taken from next. Fields the file left alone keep their running value,
+// so flags such as -advertise or -debug survive a reload.
+func overlayChanged(cur, prev, next config.Config) config.Config {
+	out := reflect.ValueOf(&cur).Elem()
+	was := reflect.ValueOf(prev)
+	now := reflect.ValueOf(next)
+	for i := range out.NumField() {
+		if !reflect.DeepEqual(was.Field(i).Interface(), now.Field(i).Interface()) {
+			out.Field(i).Set(now.Field(i))
+		}
+	}
+	return cur
+}
@@ -0,0 +1,79 @@
+package chat
+
+import (
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"yap/internal/config"
+)
+
+func TestReloadKeepsCommandLineOverrides(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "yap.json")
+	store, err := config.Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Save("team", config.Config{Name: "alice"}); err != nil {
+		t.Fatal(err)
+	}
+	s := newTestSessionWith(t, sessionOptions{
+		config: config.Config{Name: "alice", Profile: "team", Advertise: "127.0.0.1:9999", ASCII: true, Debug: true, Discover: true, Plain: true},
+		store:  store,
+	})
+
+	// Edit the file through a second handle, as another process would.
+	editor, err := config.Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := editor.Save("team", config.Config{Name: "alice", Mute: []string{"spam"}}); err != nil {
+		t.Fatal(err)
+	}
+	s.reloadConfig()
+
+	if !slices.Equal(s.cfg.Mute, []string{"spam"}) {
+		t.Fatalf("mute = %v, want the reloaded [spam]", s.cfg.Mute)
+	}
+	if s.cfg.Advertise != "127.0.0.1:9999" || !s.cfg.ASCII || !s.cfg.Debug || !s.cfg.Discover || !s.cfg.Plain {
+		t.Fatalf("reload dropped overrides: %+v", s.cfg)
+	}
+	waitEvent(t, s, systemContaining("filters updated"))
+}
+
+func TestReloadAppliesFieldsChangedInFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "yap.json")
+	store, err := config.Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Save("team", config.Config{Name: "alice"}); err != nil {
+		t.Fatal(err)
+	}
+	s := newTestSessionWith(t, sessionOptions{config: config.Config{Name: "alice", Profile: "team"}, store: store})
+
+	editor, err := config.Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := editor.Save("team", config.Config{Name: "alicia", Debug: true}); err != nil {
+		t.Fatal(err)
+	}
+	s.reloadConfig()
+
+	if s.cfg.Name != "alicia" || !s.cfg.Debug {
+		t.Fatalf("reload kept name %q and debug %v, want alicia and true", s.cfg.Name, s.cfg.Debug)
+	}
+	waitEvent(t, s, systemContaining("now chatting as alicia"))
+}
+
+func TestOverlayChanged(t *testing.T) {
+	cur := config.Config{Name: "cli", Theme: "light", Debug: true}
+	prev := config.Config{Name: "file", Theme: "dark"}
+	next := config.Config{Name: "file", Theme: "mono"}
+
+	got := overlayChanged(cur, prev, next)
+	if got.Name != "cli" || got.Theme != "mono" || !got.Debug {
+		t.Fatalf("overlayChanged = %+v, want name cli, theme mono, debug kept", got)
+	}
+}
@@ -0,0 +1,94 @@
+package chat
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultSendRetries is how many times a failed direct send is retried.
+	defaultSendRetries = 3
+	// defaultRetryDelay is the first backoff delay; each retry doubles it.
+	defaultRetryDelay = 200 * time.Millisecond
+)
+
+// contactWorkers bounds how many peers are contacted concurrently.
+const contactWorkers = 8
+
+// defaultSendWorkers bounds how many peers a broadcast writes to at once when
+// Config.SendWorkers is unset.
+const defaultSendWorkers = 8
+
+// forEachLimited calls fn for every item using at most limit goroutines and
+// returns once all calls have finished.
+func forEachLimited[T any](items []T, limit int, fn func(T)) {
+	sem := make(chan struct{}, limit)
+	var wg sync.WaitGroup
+	for _, item := range items {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			fn(item)
+		}()
+	}
+	wg.Wait()
+}
+
+// errRetryInFlight reports that another goroutine is already retrying the address.
+var errRetryInFlight = errors.New("retry already in progress")
+
+// isTransientResolve reports whether a resolve failure is worth retrying:
+// DNS timeouts and temporary server failures are, missing names are not.
+func isTransientResolve(err error) bool {
+	var dnsErr *net.DNSError
+	if !errors.As(err, &dnsErr) {
+		return false
+	}
+	if dnsErr.IsNotFound {
+		return false
+	}
+	return dnsErr.IsTimeout || dnsErr.IsTemporary
+}
+
+// sendDirectRetry delivers a message like sendDirect, retrying transient
+// failures with exponential backoff. Only one retry loop runs per address.
+func (s *session) sendDirectRetry(addr net.Addr, kind msgType, body string) error {
+	err := s.sendDirect(addr, kind, body)
+	if err == nil || s.retries <= 0 {
+		return err
+	}
+
+	key := canonicalNetAddr(addr)
+	s.retryMu.Lock()
+	if _, busy := s.retrying[key]; busy {
+		s.retryMu.Unlock()
+		return errRetryInFlight
+	}
+	s.retrying[key] = struct{}{}
+	s.retryMu.Unlock()
+	defer func() {
+		s.retryMu.Lock()
+		delete(s.retrying, key)
+		s.retryMu.Unlock()
+	}()
+
+	delay := s.retryDelay
+	for attempt := 0; attempt < s.retries; attempt++ {
+		timer := time.NewTimer(delay)
+		select {
+		case <-s.closed:
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+		if err = s.sendDirect(addr, kind, body); err == nil {
+			return nil
+		}
+		delay *= 2
+	}
+	return err
+}
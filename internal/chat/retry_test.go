@@ -0,0 +1,253 @@
+package chat
+
+import (
+	"errors"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"yap/internal/config"
+)
+
+func TestAddPeerRetriesFailedSends(t *testing.T) {
+	fc := &fakeConn{failWrites: 2}
+	s := newTestSessionWith(t, sessionOptions{listen: listenFake(fc), retryDelay: 5 * time.Millisecond})
+	peer, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer peer.Close()
+
+	if err := s.addPeer(peer.LocalAddr().String()); err != nil {
+		t.Fatalf("addPeer: %v", err)
+	}
+	if !isActive(s, peer.LocalAddr().String()) {
+		t.Fatal("peer should be active once the third send succeeds")
+	}
+	if msg := readMessage(t, peer); msg.Type != joinMsg {
+		t.Fatalf("got %s, want the join", msg.Type)
+	}
+	if dropped(drainEvents(s)) {
+		t.Fatal("a send that succeeds on retry must not drop the peer")
+	}
+}
+
+func TestAddPeerDropsAfterRetriesRunOut(t *testing.T) {
+	fc := &fakeConn{failWrites: 3}
+	s := newTestSessionWith(t, sessionOptions{listen: listenFake(fc), retries: 2, retryDelay: 5 * time.Millisecond})
+
+	err := s.addPeer("127.0.0.1:9")
+	if err == nil {
+		t.Fatal("addPeer should fail once every retry has failed")
+	}
+	if !dropped(drainEvents(s)) {
+		t.Fatal("peer should be dropped after the last retry")
+	}
+}
+
+func TestSendDirectRetryRunsOneLoopPerAddress(t *testing.T) {
+	fc := &fakeConn{failWrites: 3}
+	s := newTestSessionWith(t, sessionOptions{listen: listenFake(fc), retryDelay: 50 * time.Millisecond})
+	addr, err := net.ResolveUDPAddr("udp", "127.0.0.1:9")
+	if err != nil {
+		t.Fatalf("resolve: %v", err)
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- s.sendDirectRetry(addr, joinMsg, "") }()
+	waitUntil(t, func() bool {
+		s.retryMu.Lock()
+		defer s.retryMu.Unlock()
+		return len(s.retrying) == 1
+	})
+	if err := s.sendDirectRetry(addr, joinMsg, ""); !errors.Is(err, errRetryInFlight) {
+		t.Fatalf("concurrent retry = %v, want errRetryInFlight", err)
+	}
+	if err := <-done; err != nil {
+		t.Fatalf("first retry loop: %v", err)
+	}
+}
+
+// dropped reports whether events include a member failure.
+func dropped(events []Message) bool {
+	for _, msg := range events {
+		if msg.Type == memberMsg && msg.Body == MemberFailed {
+			return true
+		}
+	}
+	return false
+}
+
+func TestResolveRetriesTransientFailures(t *testing.T) {
+	peer, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer peer.Close()
+	var calls int
+	resolve := func(target string) (net.Addr, error) {
+		calls++
+		if calls <= 2 {
+			return nil, &net.DNSError{Err: "server misbehaving", Name: target, IsTemporary: true}
+		}
+		return peer.LocalAddr(), nil
+	}
+	s := newTestSessionWith(t, sessionOptions{resolve: resolve, retryDelay: time.Millisecond})
+
+	if err := s.addPeer("bob.lan:4001"); err != nil {
+		t.Fatalf("addPeer: %v", err)
+	}
+	if calls != 3 {
+		t.Fatalf("%d lookups, want 3", calls)
+	}
+	if msg := readMessage(t, peer); msg.Type != joinMsg {
+		t.Fatalf("got %s, want the join", msg.Type)
+	}
+}
+
+func TestResolveGivesUpOnMissingNames(t *testing.T) {
+	var calls int
+	resolve := func(target string) (net.Addr, error) {
+		calls++
+		return nil, &net.DNSError{Err: "no such host", Name: target, IsNotFound: true}
+	}
+	s := newTestSessionWith(t, sessionOptions{resolve: resolve, retryDelay: time.Millisecond})
+
+	if _, err := s.resolveAddr("nobody.lan:4001"); err == nil {
+		t.Fatal("missing name resolved")
+	}
+	if calls != 1 {
+		t.Fatalf("%d lookups for a missing name, want 1", calls)
+	}
+}
+
+func TestForEachLimitedBoundsConcurrency(t *testing.T) {
+	var mu sync.Mutex
+	running, peak, done := 0, 0, 0
+	forEachLimited(make([]int, 20), 4, func(int) {
+		mu.Lock()
+		running++
+		peak = max(peak, running)
+		mu.Unlock()
+		time.Sleep(5 * time.Millisecond)
+		mu.Lock()
+		running--
+		done++
+		mu.Unlock()
+	})
+	if done != 20 || peak > 4 || peak < 2 {
+		t.Fatalf("ran %d calls with a peak of %d, want 20 with at most 4 at once", done, peak)
+	}
+}
+
+func TestBootstrapContactsSlowPeersConcurrently(t *testing.T) {
+	const n = 16
+	var peers []net.PacketConn
+	var addrs []string
+	for range n {
+		conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		peers = append(peers, conn)
+		addrs = append(addrs, conn.LocalAddr().String())
+	}
+	fc := &fakeConn{writeDelay: 50 * time.Millisecond}
+	s := newTestSessionWith(t, sessionOptions{config: config.Config{Peers: addrs}, listen: listenFake(fc)})
+
+	began := time.Now()
+	s.start()
+	if elapsed := time.Since(began); elapsed > 40*time.Millisecond {
+		t.Fatalf("start blocked for %s", elapsed)
+	}
+	for _, peer := range peers {
+		if msg := readMessage(t, peer); msg.Type != joinMsg {
+			t.Fatalf("%s got %s, want the join", peer.LocalAddr(), msg.Type)
+		}
+	}
+	if elapsed := time.Since(began); elapsed > n*50*time.Millisecond/2 {
+		t.Fatalf("contacting %d peers took %s", n, elapsed)
+	}
+	if peak := fc.peakWrites(); peak > contactWorkers {
+		t.Fatalf("%d joins in flight at once, want at most %d", peak, contactWorkers)
+	}
+}
+
+func TestBootstrapResolvesConcurrently(t *testing.T) {
+	var mu sync.Mutex
+	running, peak := 0, 0
+	resolve := func(target string) (net.Addr, error) {
+		mu.Lock()
+		running++
+		peak = max(peak, running)
+		mu.Unlock()
+		time.Sleep(20 * time.Millisecond)
+		mu.Lock()
+		running--
+		mu.Unlock()
+		_, port, _ := net.SplitHostPort(target)
+		return net.ResolveUDPAddr("udp", net.JoinHostPort("10.0.0.2", port))
+	}
+	s := newTestSessionWith(t, sessionOptions{
+		config:  config.Config{Peers: []string{"a.lan:4001", "b.lan:4002", "c.lan:4003"}},
+		resolve: resolve,
+	})
+	if len(s.bootstrap) != 3 {
+		t.Fatalf("bootstrap = %v, want all three seeds", s.bootstrap)
+	}
+	if peak < 2 {
+		t.Fatalf("at most %d lookup ran at once, want them concurrent", peak)
+	}
+}
+
+func TestBootstrapSkipsUnresolvedSeeds(t *testing.T) {
+	resolve := func(target string) (net.Addr, error) {
+		if target == "gone.lan:4001" {
+			return nil, &net.DNSError{Err: "no such host", Name: target, IsNotFound: true}
+		}
+		return net.ResolveUDPAddr("udp", "10.0.0.2:4002")
+	}
+	s := newTestSessionWith(t, sessionOptions{
+		config:  config.Config{Peers: []string{"gone.lan:4001", "here.lan:4002"}},
+		resolve: resolve,
+	})
+	if len(s.bootstrap) != 1 || s.bootstrap[0].String() != "10.0.0.2:4002" {
+		t.Fatalf("bootstrap = %v, want only the resolved seed", s.bootstrap)
+	}
+	waitEvent(t, s, systemContaining("skipping peer gone.lan:4001"))
+}
+
+func TestBootstrapFailsWhenNoSeedResolves(t *testing.T) {
+	resolve := func(target string) (net.Addr, error) {
+		return nil, &net.DNSError{Err: "no such host", Name: target, IsNotFound: true}
+	}
+	s, err := newSession(sessionOptions{
+		config:  config.Config{Name: "alice", Listen: "127.0.0.1:0", Peers: []string{"a.lan:4001", "b.lan:4002"}},
+		resolve: resolve,
+	})
+	if err == nil {
+		_, _ = s.shutdown()
+		t.Fatal("session started with no resolvable peer")
+	}
+	for _, seed := range []string{`"a.lan:4001"`, `"b.lan:4002"`} {
+		if !strings.Contains(err.Error(), seed) {
+			t.Fatalf("error %q does not name %s", err, seed)
+		}
+	}
+}
+
+func TestResolveWithinTimesOut(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	resolve := func(string) (net.Addr, error) {
+		<-release
+		return nil, errors.New("too late")
+	}
+	s := newTestSessionWith(t, sessionOptions{resolve: resolve})
+	if _, err := s.resolveWithin("slow.lan:4001", 10*time.Millisecond); err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Fatalf("resolveWithin = %v, want a timeout", err)
+	}
+}
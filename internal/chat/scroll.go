@@ -0,0 +1,67 @@
+package chat
+
+import (
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// scrollbackLines renders the history into terminal lines, oldest first.
+func (m *bubbleModel) scrollbackLines() []string {
+	var lines []string
+	for _, blk := range m.history {
+		lines = append(lines, strings.Split(renderBlockString(m.opts, blk), "\n")...)
+	}
+	return lines
+}
+
+// scrollbackRows is how many history lines fit between the pinned section and
+// the prompt; zero means the window height is unknown and everything is shown.
+func (m *bubbleModel) scrollbackRows(pinned string) int {
+	if m.height <= 0 {
+		return 0
+	}
+	rows := m.height - 2
+	if pinned != "" {
+		rows -= strings.Count(pinned, "\n") + 2
+	}
+	return max(rows, 1)
+}
+
+// visibleLines returns the window of lines ending offset lines above the
+// bottom, clamping the offset to the available history.
+func (m *bubbleModel) visibleLines(lines []string, rows int) []string {
+	if rows <= 0 {
+		m.offset = 0
+		return lines
+	}
+	m.offset = min(max(m.offset, 0), max(len(lines)-rows, 0))
+	end := len(lines) - m.offset
+	return lines[max(end-rows, 0):end]
+}
+
+// scroll handles the scrollback keys, reporting whether key was one of them.
+func (m *bubbleModel) scroll(key tea.KeyType) bool {
+	rows := m.scrollbackRows(renderPins(m.opts, m.pins))
+	page := max(rows-1, 1)
+	switch key {
+	case tea.KeyPgUp:
+		m.offset += page
+	case tea.KeyPgDown:
+		m.offset -= page
+	case tea.KeyCtrlHome:
+		m.offset = len(m.scrollbackLines())
+	case tea.KeyCtrlEnd:
+		m.offset = 0
+	default:
+		return false
+	}
+	m.visibleLines(m.scrollbackLines(), rows)
+	return true
+}
+
+// scrollIndicator notes how much history sits below a scrolled-up view.
+func (m *bubbleModel) scrollIndicator() string {
+	return fmt.Sprintf("%s-- %d more lines below --%s", m.opts.theme.timestamp, m.offset, m.opts.theme.reset)
+}
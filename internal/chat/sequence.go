@@ -0,0 +1,47 @@
+package chat
+
+import "net"
+
+// sequenceKey names the sender whose counter a chat message advances: the
+// address of its author, found from the direct source or, for relayed
+// copies, the only member going by its name. Members can share a name, so
+// copies that cannot be pinned to one address return "" and go untracked.
+func (s *session) sequenceKey(name string, source net.Addr) string {
+	if key := s.originKey(name, source); key != "" {
+		return key
+	}
+	if keys, _ := s.memberKeysByName([]string{name}); len(keys) == 1 {
+		return keys[0]
+	}
+	return ""
+}
+
+// trackSequence records the latest sequence number seen from the sender at
+// origin and reports whether one or more earlier messages appear to have been
+// skipped, and whether this one arrived after a later message from the same
+// sender.
+func (s *session) trackSequence(origin string, seq uint64) (gap, late bool) {
+	if origin == "" || seq == 0 {
+		return false, false
+	}
+	s.seqMu.Lock()
+	defer s.seqMu.Unlock()
+	if s.lastSeq == nil {
+		s.lastSeq = make(map[string]uint64)
+	}
+	last, known := s.lastSeq[origin]
+	switch {
+	case !known:
+		// First message since we joined; earlier history is not a gap.
+		s.lastSeq[origin] = seq
+		return false, false
+	case seq == 1 && last > 1:
+		// The sender restarted and its counter began again.
+		s.lastSeq[origin] = seq
+		return false, false
+	case seq <= last:
+		return false, seq < last
+	}
+	s.lastSeq[origin] = seq
+	return seq > last+1, false
+}
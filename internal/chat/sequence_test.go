@@ -0,0 +1,174 @@
+package chat
+
+import (
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+
+	"yap/internal/config"
+)
+
+func TestTrackSequenceFlagsGaps(t *testing.T) {
+	s := newTestSession(t, config.Config{})
+	for _, step := range []struct {
+		from string
+		seq  uint64
+		gap  bool
+	}{
+		{"bob", 5, false}, // first message since joining
+		{"bob", 6, false},
+		{"bob", 9, true},
+		{"bob", 10, false},
+		{"bob", 1, false}, // bob restarted
+		{"bob", 2, false},
+		{"carol", 3, false}, // counters are per sender
+		{"bob", 0, false},   // unsequenced
+	} {
+		if gap, _ := s.trackSequence(step.from, step.seq); gap != step.gap {
+			t.Errorf("%s #%d: gap = %v, want %v", step.from, step.seq, gap, step.gap)
+		}
+	}
+}
+
+func TestPrepareMessageNumbersBroadcastChat(t *testing.T) {
+	s := newTestSession(t, config.Config{})
+	var seqs []uint64
+	for range 3 {
+		msg, _, err := s.transport.prepareMessage(Message{Type: chatMsg, From: "alice", Body: "hi"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		seqs = append(seqs, msg.Seq)
+	}
+	if seqs[0] == 0 || seqs[1] != seqs[0]+1 || seqs[2] != seqs[1]+1 {
+		t.Fatalf("sequence numbers %v are not consecutive", seqs)
+	}
+}
+
+func TestIncomingChatCarriesGap(t *testing.T) {
+	s := newTestSession(t, config.Config{Name: "alice"})
+	peer := listenPeer(t, s, "bob")
+	drainEvents(s)
+	for _, seq := range []uint64{1, 3} {
+		s.handleIncoming(Message{ID: newMessageID(), Type: chatMsg, From: "bob", Seq: seq}, peer.LocalAddr(), nil, true)
+	}
+	var gaps []bool
+	for _, msg := range drainEvents(s) {
+		if msg.Type == chatMsg {
+			gaps = append(gaps, msg.Gap)
+		}
+	}
+	if len(gaps) != 2 || gaps[0] || !gaps[1] {
+		t.Fatalf("gap flags = %v, want [false true]", gaps)
+	}
+}
+
+func TestSameNamedSendersKeepSeparateGaps(t *testing.T) {
+	s := newTestSession(t, config.Config{Name: "alice"})
+	first := listenPeer(t, s, "bob")
+	second := listenPeer(t, s, "bob")
+	drainEvents(s)
+	for _, step := range []struct {
+		from net.PacketConn
+		seq  uint64
+	}{{first, 1}, {second, 10}, {first, 2}, {second, 11}, {first, 3}, {second, 13}} {
+		s.handleIncoming(Message{ID: newMessageID(), Type: chatMsg, From: "bob", Seq: step.seq}, step.from.LocalAddr(), nil, true)
+	}
+	var gaps []bool
+	for _, msg := range drainEvents(s) {
+		if msg.Type == chatMsg {
+			gaps = append(gaps, msg.Gap)
+		}
+	}
+	if want := []bool{false, false, false, false, false, true}; !slices.Equal(gaps, want) {
+		t.Fatalf("gap flags = %v, want %v", gaps, want)
+	}
+
+	// A relayed copy cannot say which bob wrote it, so it is not counted.
+	relay := listenPeer(t, s, "carol")
+	s.handleIncoming(Message{ID: newMessageID(), Type: chatMsg, From: "bob", Seq: 20}, relay.LocalAddr(), nil, true)
+	s.handleIncoming(Message{ID: newMessageID(), Type: chatMsg, From: "bob", Seq: 4}, first.LocalAddr(), nil, true)
+	for _, msg := range drainEvents(s) {
+		if msg.Type == chatMsg && (msg.Gap || msg.Late) {
+			t.Fatalf("#%d flagged gap=%v late=%v", msg.Seq, msg.Gap, msg.Late)
+		}
+	}
+}
+
+func TestTrackSequenceFlagsLateArrivals(t *testing.T) {
+	s := newTestSession(t, config.Config{})
+	for _, step := range []struct {
+		seq       uint64
+		gap, late bool
+	}{
+		{5, false, false},
+		{8, true, false},
+		{6, false, true},  // overtaken by 8
+		{8, false, false}, // a repeat is not late
+		{7, false, true},
+		{9, false, false},
+	} {
+		gap, late := s.trackSequence("bob", step.seq)
+		if gap != step.gap || late != step.late {
+			t.Errorf("#%d: gap, late = %v, %v; want %v, %v", step.seq, gap, late, step.gap, step.late)
+		}
+	}
+}
+
+func TestLateChatIsMarked(t *testing.T) {
+	s := newTestSession(t, config.Config{Name: "alice"})
+	peer := listenPeer(t, s, "bob")
+	drainEvents(s)
+	for _, seq := range []uint64{1, 3, 2} {
+		s.handleIncoming(Message{ID: newMessageID(), Type: chatMsg, From: "bob", Body: "#" + string(rune('0'+seq)), Seq: seq}, peer.LocalAddr(), nil, true)
+	}
+	m := newBubbleModel("alice", nil, nil, uiOptionsFrom(config.Config{Theme: "mono", GroupWindow: "off"}))
+	var late []bool
+	for _, msg := range drainEvents(s) {
+		if msg.Type == chatMsg {
+			late = append(late, msg.Late)
+			m.Update(msg)
+		}
+	}
+	if len(late) != 3 || late[0] || late[1] || !late[2] {
+		t.Fatalf("late flags = %v, want only the third", late)
+	}
+	view := m.View()
+	for _, line := range strings.Split(view, "\n") {
+		if strings.Contains(line, "(out of order)") != strings.Contains(line, "#2 ") {
+			t.Fatalf("late marker misplaced:\n%s", view)
+		}
+	}
+}
+
+func TestLateMarkerIgnoresSameNamedSenders(t *testing.T) {
+	s := newTestSession(t, config.Config{Name: "alice"})
+	first := listenPeer(t, s, "bob")
+	second := listenPeer(t, s, "bob")
+	drainEvents(s)
+	for _, step := range []struct {
+		from net.PacketConn
+		seq  uint64
+	}{{first, 5}, {second, 1}, {first, 7}, {second, 2}, {first, 6}} {
+		s.handleIncoming(Message{ID: newMessageID(), Type: chatMsg, From: "bob", Body: "#" + strconv.FormatUint(step.seq, 10), Seq: step.seq}, step.from.LocalAddr(), nil, true)
+	}
+	m := newBubbleModel("alice", nil, nil, uiOptionsFrom(config.Config{Theme: "mono", GroupWindow: "off"}))
+	var late []bool
+	for _, msg := range drainEvents(s) {
+		if msg.Type == chatMsg {
+			late = append(late, msg.Late)
+			m.Update(msg)
+		}
+	}
+	if want := []bool{false, false, false, false, true}; !slices.Equal(late, want) {
+		t.Fatalf("late flags = %v, want %v", late, want)
+	}
+	view := m.View()
+	for _, line := range strings.Split(view, "\n") {
+		if strings.Contains(line, "(out of order)") != strings.Contains(line, "#6 ") {
+			t.Fatalf("late marker misplaced:\n%s", view)
+		}
+	}
+}
@@ -3,14 +3,39 @@ package chat
 import (
 	"errors"
 	"fmt"
+	"math/rand/v2"
 	"net"
 	"net/netip"
+	"slices"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"yap/internal/config"
 )
 
+const (
+	// defaultKeepalive keeps typical NAT UDP mappings, which expire after ~30s, open.
+	defaultKeepalive = 25 * time.Second
+	// defaultHeartbeat is how often liveness pings are broadcast.
+	defaultHeartbeat = 15 * time.Second
+	// defaultPeerTimeout is how long an active peer may stay silent before demotion.
+	defaultPeerTimeout = time.Minute
+)
+
+// listenAddrs splits a comma-separated listen setting into bind addresses.
+func listenAddrs(raw string) []string {
+	var addrs []string
+	for _, part := range strings.Split(raw, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			addrs = append(addrs, part)
+		}
+	}
+	return addrs
+}
+
 // sessionOptions describe how to initialise a chat session.
 type sessionOptions struct {
 	config  config.Config
@@ -18,26 +43,86 @@ type sessionOptions struct {
 	resolve func(string) (net.Addr, error)
 	cipher  packetCipher
 	store   config.Store
+	// retries and retryDelay tune sendDirectRetry; zero selects the defaults
+	// and negative retries disable retrying.
+	retries    int
+	retryDelay time.Duration
+	// rateLimit is the per-source packets/sec allowance; zero selects the
+	// default and negative disables limiting.
+	rateLimit int
+	// presence enables the typed presence stream.
+	presence bool
+	// discover joins the LAN discovery group; nil selects listenMulticast.
+	discover func(string) (net.PacketConn, error)
+	// now supplies the time for membership and packet timestamps; nil
+	// selects time.Now.
+	now func() time.Time
 }
 
 // session manages the gossip loop, user interaction, and graceful shutdown.
 type session struct {
-	cfg          config.Config
-	bootstrap    []net.Addr
-	store        config.Store
-	transport    *transport
-	closed       chan struct{}
-	shutdownOnce sync.Once
-	startOnce    sync.Once
-	events       chan Message
-	statusMu     sync.RWMutex
-	lastEvent    string
-	membersMu    sync.RWMutex
-	members      map[string]*member
-	localAddr    string
-	localIP      netip.Addr
-	localPort    uint16
-	resolve      func(string) (net.Addr, error)
+	cfg            config.Config
+	bootstrap      []net.Addr
+	store          config.Store
+	transport      *transport
+	closed         chan struct{}
+	shutdownOnce   sync.Once
+	shutdownRes    ShutdownReport
+	shutdownErr    error
+	startOnce      sync.Once
+	events         chan Message
+	emitMu         sync.RWMutex
+	queueMu        sync.Mutex
+	droppedEvents  atomic.Int64
+	eventsClosed   bool
+	presence       chan PresenceEvent
+	eventLog       *eventLog
+	statusMu       sync.RWMutex
+	statusLog      []statusEvent
+	membersMu      sync.RWMutex
+	members        map[string]*member
+	mentions       map[string]map[string]struct{}
+	blocked        map[string]struct{}
+	muteMu         sync.Mutex
+	muted          []string
+	authMu         sync.Mutex
+	authRejects    map[string]int
+	gossipMu       sync.Mutex
+	gossipPending  bool
+	outboxMu       sync.Mutex
+	outbox         map[string][]queuedFrame
+	lastSentID     string
+	recent         []Message
+	historyMu      sync.Mutex
+	history        []Message
+	historyPath    string
+	acks           ackTracker
+	deliveries     deliveryTracker
+	typingAt       atomic.Int64
+	filesMu        sync.Mutex
+	incoming       map[string]*incomingFile
+	held           []heldFile
+	seqMu          sync.Mutex
+	lastSeq        map[string]uint64
+	localAddr      string
+	boundAddrs     []string
+	advertised     string
+	localIP        netip.Addr
+	localPort      uint16
+	resolve        func(string) (net.Addr, error)
+	bind           func(string) (net.PacketConn, error)
+	now            func() time.Time
+	discoverListen func(string) (net.PacketConn, error)
+	discovery      *discovery
+	healthAddr     net.Addr
+	seenPath       string
+	retries        int
+	retryDelay     time.Duration
+	retryMu        sync.Mutex
+	retrying       map[string]struct{}
+	keepalive      time.Duration
+	heartbeat      time.Duration
+	peerTimeout    time.Duration
 }
 
 // newSession creates a new chat session.
@@ -58,49 +143,181 @@ func newSession(opts sessionOptions) (*session, error) {
 		}
 	}
 
-	conn, err := listen(cfg.Listen)
-	if err != nil {
-		return nil, fmt.Errorf("listen on %q: %w", cfg.Listen, err)
+	conns, bindErrs := bindAll(listen, listenAddrs(cfg.Listen))
+	if len(conns) == 0 {
+		if len(bindErrs) == 0 {
+			return nil, fmt.Errorf("listen on %q: no addresses", cfg.Listen)
+		}
+		return nil, errors.Join(bindErrs...)
 	}
 
 	localAddr := ""
-	if conn.LocalAddr() != nil {
-		localAddr = conn.LocalAddr().String()
+	if conns[0].LocalAddr() != nil {
+		localAddr = conns[0].LocalAddr().String()
+	}
+
+	dedupWindow, dedupErr := config.Interval(cfg.DedupWindow, defaultDedupWindow)
+	if dedupWindow <= 0 {
+		dedupWindow = defaultDedupWindow
+	}
+	if opts.cipher != nil && dedupWindow < maxClockSkew {
+		// Replays are only rejected by timestamp beyond maxClockSkew, so IDs
+		// must be remembered at least that long.
+		dedupWindow = maxClockSkew
 	}
 
 	session := &session{
 		cfg:       cfg,
 		bootstrap: make([]net.Addr, 0, len(cfg.Peers)),
 		store:     opts.store,
-		transport: newTransport(cfg.Name, conn, opts.cipher),
+		transport: newTransport(cfg.Name, conns, opts.cipher, dedupWindow),
 		closed:    make(chan struct{}),
 		events:    make(chan Message, 128),
 		resolve:   resolve,
+		bind:      listen,
+		retries:   opts.retries,
+		retrying:  make(map[string]struct{}),
+	}
+	session.now = opts.now
+	if session.now == nil {
+		session.now = time.Now
+	}
+	session.transport.now = session.now
+	session.transport.seen.now = session.now
+	session.discoverListen = opts.discover
+	if session.discoverListen == nil {
+		session.discoverListen = listenMulticast
+	}
+	if opts.presence {
+		session.presence = make(chan PresenceEvent, presenceBuffer)
+	}
+	if session.retries == 0 {
+		session.retries = defaultSendRetries
+	}
+	session.retryDelay = opts.retryDelay
+	if session.retryDelay <= 0 {
+		session.retryDelay = defaultRetryDelay
 	}
 
-	session.resetMembership(localAddr)
-	session.emit(Message{Type: systemMsg, Body: startupLogo})
-	for _, seed := range cfg.Peers {
-		addr, err := session.resolve(seed)
+	session.transport.debug = cfg.Debug
+	session.transport.readBuffer = cfg.ReadBuffer
+	session.transport.redelivered = func(msg Message, addr net.Addr, raw []byte) {
+		session.sendAck(msg.ID, addr)
+		session.reforward(msg, raw, addr)
+	}
+	switch {
+	case opts.rateLimit > 0:
+		session.transport.limiter = newRateLimiter(opts.rateLimit)
+	case opts.rateLimit == 0:
+		session.transport.limiter = newRateLimiter(defaultRateLimit)
+	}
+	if path := strings.TrimSpace(cfg.LogFile); path != "" {
+		log, err := openEventLog(path)
 		if err != nil {
-			session.transport.close()
-			return nil, fmt.Errorf("resolve peer %q: %w", seed, err)
+			defer session.emitSystem("event log disabled: %v", err)
+		} else {
+			session.eventLog = log
+		}
+	}
+	if cfg.TimeFormat != "" && !validTimeFormat(cfg.TimeFormat) {
+		defer session.emitSystem("timeFormat %q has no time fields; using %s", cfg.TimeFormat, defaultTimeFormat)
+	}
+	session.resetMembership(localAddr)
+	session.blockAddrs(cfg.Blocked...)
+	session.setAdvertised(cfg.Advertise)
+	session.setBoundAddrs(session.transport.localAddrs())
+	logo := startupLogo
+	if asciiOnly(cfg) {
+		logo = asciiLogo
+	}
+	session.emit(Message{Type: systemMsg, Body: logo})
+	keepalive, err := config.Interval(cfg.Keepalive, defaultKeepalive)
+	if err != nil {
+		session.emitSystem("keepalive: %v; using %s", err, keepalive)
+	}
+	session.keepalive = keepalive
+	heartbeat, err := config.Interval(cfg.Heartbeat, defaultHeartbeat)
+	if err != nil {
+		session.emitSystem("heartbeat: %v; using %s", err, heartbeat)
+	}
+	session.heartbeat = heartbeat
+	peerTimeout, err := config.Interval(cfg.PeerTimeout, defaultPeerTimeout)
+	if err != nil {
+		session.emitSystem("peer timeout: %v; using %s", err, peerTimeout)
+	}
+	session.peerTimeout = peerTimeout
+	if dedupErr != nil {
+		session.emitSystem("dedup window: %v; using %s", dedupErr, dedupWindow)
+	}
+	if cfg.PersistSeen && !cfg.Ephemeral && opts.store != nil && opts.store.Path() != "" {
+		session.seenPath = statePath(opts.store, cfg.Profile, ".seen")
+		if err := session.transport.seen.load(session.seenPath); err != nil {
+			session.emitSystem("seen set: %v", err)
+		}
+	}
+	if cfg.History > 0 && !cfg.Ephemeral && opts.store != nil && opts.store.Path() != "" {
+		session.historyPath = statePath(opts.store, cfg.Profile, ".history")
+		if err := session.loadHistory(session.historyPath); err != nil {
+			session.emitSystem("history: %v", err)
+		} else if restored := session.historySnapshot(0); len(restored) > 0 {
+			session.emitSystem("restored %d messages", len(restored))
+			for _, entry := range restored {
+				session.emit(entry)
+			}
+		}
+	}
+	seeds, seedErrs := session.resolveSeeds(cfg.Peers)
+	var unresolved []error
+	for i, seed := range cfg.Peers {
+		addr := seeds[i]
+		if err := seedErrs[i]; err != nil {
+			unresolved = append(unresolved, fmt.Errorf("resolve peer %q: %w", seed, err))
+			session.emitSystem("skipping peer %s: %v", seed, err)
+			continue
+		}
+		if session.resolvesToSelf(addr) {
+			session.emitSystem("skipping peer %s: it is this node's own address", seed)
+			continue
 		}
 		session.bootstrap = append(session.bootstrap, addr)
 		session.markPending(addr)
+		session.notePeerHost(seed, addr)
+	}
+	if len(cfg.Peers) > 0 && len(unresolved) == len(cfg.Peers) {
+		session.transport.close()
+		_ = session.eventLog.close()
+		return nil, errors.Join(unresolved...)
 	}
+	session.restorePeerNames(cfg.PeerNames)
 
-	session.emit(Message{Type: systemMsg, Body: fmt.Sprintf("listening on %s as %s", session.transport.localAddr(), cfg.Name)})
-	if len(cfg.Peers) == 0 {
+	for _, err := range bindErrs {
+		session.emitSystem("%v; continuing without it", err)
+	}
+	bound := make([]string, 0, len(conns))
+	for _, addr := range session.transport.localAddrs() {
+		bound = append(bound, addr.String())
+	}
+	session.emit(Message{Type: systemMsg, Body: fmt.Sprintf("listening on %s as %s", strings.Join(bound, ", "), cfg.Name)})
+	if len(session.bootstrap) == 0 {
 		session.emit(Message{Type: systemMsg, Body: "no peers provided, waiting for someone to connect"})
 	}
-	if session.transport.encryptionEnabled() {
-		session.emit(Message{Type: systemMsg, Body: "encryption enabled"})
+	if cipher := session.transport.currentCipher(); cipher != nil {
+		session.emitSystem("encryption enabled (%s, key %s)", cipher.Suite(), cipher.Fingerprint())
 	}
 	session.recordEvent("session ready")
 	return session, nil
 }
 
+// statePath names a state file kept beside the config. Named profiles get
+// their own file so sessions sharing a store do not overwrite each other.
+func statePath(store config.Store, profile, suffix string) string {
+	path := store.Path()
+	if profile = strings.TrimSpace(profile); profile != "" && !strings.EqualFold(profile, "default") {
+		path += "." + profile
+	}
+	return path + suffix
+}
+
 // eventStream returns the events channel.
 func (s *session) eventStream() <-chan Message {
 	return s.events
@@ -110,24 +327,101 @@ func (s *session) eventStream() <-chan Message {
 func (s *session) start() {
 	s.startOnce.Do(func() {
 		s.transport.listen(s.closed, s.handleIncoming, s.handleAuthReject, s.emitSystem)
-		sentDirect := false
-		joinPayload := s.buildJoinPayload()
-		for _, addr := range s.bootstrap {
-			s.markPending(addr)
-			if err := s.sendDirect(addr, joinMsg, joinPayload); err != nil {
-				s.emitSystem("bootstrap to %s failed: %v", addr, err)
-				_ = s.dropPeer(addr, fmt.Sprintf("failed: %v", err))
-				continue
-			}
-			s.markActive(addr, "")
-			sentDirect = true
+		s.every(s.keepalive, s.sendKeepalives)
+		s.every(s.heartbeat, s.heartbeatTick)
+		s.every(ackTimeout/2, s.sweepAcks)
+		s.every(resolveInterval, s.reresolveHosts)
+		go s.bootstrapPeers(append([]net.Addr(nil), s.bootstrap...))
+		if s.cfg.Discover {
+			s.startDiscovery()
+		}
+		if s.cfg.HealthListen != "" {
+			s.startHealthServer()
 		}
-		if !sentDirect {
-			if err := s.broadcast(joinMsg, joinPayload); err != nil {
-				s.emitSystem("failed to announce presence: %v", err)
+	})
+}
+
+// bootstrapPeers joins the seed peers with bounded concurrency so slow peers
+// do not hold up the rest, announcing by broadcast if none could be reached.
+func (s *session) bootstrapPeers(seeds []net.Addr) {
+	var sentDirect atomic.Bool
+	joinPayload := s.buildJoinPayload()
+	forEachLimited(seeds, contactWorkers, func(addr net.Addr) {
+		s.markPending(addr)
+		if err := s.sendDirectRetry(addr, joinMsg, joinPayload); err != nil {
+			if errors.Is(err, errRetryInFlight) {
+				return
 			}
+			_ = s.dropPeer(addr, fmt.Sprintf("failed: %v", err))
+			return
 		}
+		s.markActive(addr, "")
+		sentDirect.Store(true)
 	})
+	if !sentDirect.Load() {
+		if err := s.broadcast(joinMsg, joinPayload); err != nil {
+			s.emitSystem("failed to announce presence: %v", err)
+		}
+	}
+}
+
+// lastSentMessage returns the ID of the most recent chat message we sent.
+func (s *session) lastSentMessage() string {
+	s.statusMu.RLock()
+	defer s.statusMu.RUnlock()
+	return s.lastSentID
+}
+
+// forgetSentMessage clears the last sent ID once it has been retracted.
+func (s *session) forgetSentMessage(id string) {
+	s.statusMu.Lock()
+	if s.lastSentID == id {
+		s.lastSentID = ""
+	}
+	s.statusMu.Unlock()
+}
+
+// every runs fn on each tick of interval until the session closes.
+func (s *session) every(interval time.Duration, fn func()) {
+	if interval <= 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-s.closed:
+				return
+			case <-ticker.C:
+				fn()
+			}
+		}
+	}()
+}
+
+// sendKeepalives refreshes NAT mappings towards every active peer endpoint.
+func (s *session) sendKeepalives() {
+	for _, target := range s.activeEndpoints("") {
+		if udp := net.UDPAddrFromAddrPort(target.ap); udp != nil {
+			_ = s.transport.sendKeepalive(udp)
+		}
+	}
+}
+
+// heartbeatTick broadcasts a liveness ping and demotes peers that went silent.
+func (s *session) heartbeatTick() {
+	// The ping carries its send time so the pong echo yields a round-trip time.
+	if err := s.broadcast(pingMsg, strconv.FormatInt(s.now().UnixNano(), 10)); err != nil {
+		s.emitSystem("heartbeat failed: %v", err)
+	}
+	if s.peerTimeout <= 0 {
+		return
+	}
+	for _, addr := range s.expireMembers(s.peerTimeout) {
+		s.recordPeerEvent(addr, "%s: timed out", addr)
+		s.emitMember(addr, MemberPending, "timed out")
+	}
 }
 
 // Submit submits a message to the chat.
@@ -139,22 +433,55 @@ func (s *session) submit(text string) error {
 	s.start()
 	err := s.handleInput(text)
 	if errors.Is(err, errQuit) {
-		_ = s.shutdown()
+		_, _ = s.shutdown()
 	}
 	return err
 }
 
-// Shutdown shuts down the chat application.
-func (s *session) shutdown() error {
-	var closeErr error
+// ShutdownReport describes how the leave notice fared when a session shut down.
+type ShutdownReport struct {
+	// Peers is the number of active peers the leave notice was addressed to.
+	Peers int
+	// Delivered counts the peers the leave notice was written to successfully.
+	Delivered int
+	// Err joins any errors raised while encoding or sending the leave notice.
+	Err error
+}
+
+// Shutdown shuts down the chat application, reporting leave delivery.
+func (s *session) shutdown() (ShutdownReport, error) {
 	s.shutdownOnce.Do(func() {
-		if err := s.broadcast(leaveMsg, ""); err != nil {
+		_, raw, err := s.transport.prepare(s.cfg.Name, leaveMsg, "")
+		if err != nil {
+			s.shutdownRes.Err = err
 			s.emitSystem("failed to send leave notice: %v", err)
+		} else {
+			res := s.forwardRaw(raw, nil)
+			s.shutdownRes = ShutdownReport{Peers: res.attempted, Delivered: res.delivered, Err: res.err}
+		}
+		if s.seenPath != "" {
+			if err := s.transport.seen.save(s.seenPath); err != nil {
+				s.emitSystem("seen set: %v", err)
+			}
+		}
+		if s.historyPath != "" {
+			if err := s.saveHistory(s.historyPath); err != nil {
+				s.emitSystem("history: %v", err)
+			}
 		}
-		closeErr = s.close()
+		s.shutdownErr = s.close()
+		// Closing s.closed releases any emitter blocked on a full channel, so
+		// the write lock is granted once in-flight sends have returned.
+		s.emitMu.Lock()
+		s.eventsClosed = true
 		close(s.events)
+		if s.presence != nil {
+			close(s.presence)
+		}
+		s.emitMu.Unlock()
+		_ = s.eventLog.close()
 	})
-	return closeErr
+	return s.shutdownRes, s.shutdownErr
 }
 
 // Close closes the chat connection.
@@ -169,13 +496,74 @@ func (s *session) close() error {
 
 // handleIncoming processes inbound messages, updating membership and gossiping them.
 func (s *session) handleIncoming(msg Message, addr net.Addr, raw []byte, authenticated bool) {
+	if s.isBlocked(canonicalNetAddr(addr)) {
+		return
+	}
 	suppressEmit := false
 	activated := false
 
 	switch msg.Type {
 	case peersMsg:
+		// A peer list answers our join, so its sender is reachable.
+		if authenticated {
+			s.markActive(addr, msg.From)
+		}
 		s.handlePeersPayload(msg.Body, addr)
 		return
+	case historyMsg:
+		if authenticated {
+			s.handleHistoryPayload(msg.Body, msg.From)
+		}
+		return
+	case endpointUpdateMsg:
+		if authenticated {
+			s.handleEndpointUpdate(msg, addr)
+		}
+		return
+	case readMsg:
+		if authenticated && s.sentRecently(msg.Ref) {
+			s.emit(Message{ID: msg.ID, Type: readMsg, From: msg.From, Ref: msg.Ref, Timestamp: msg.Timestamp})
+		}
+		return
+	case ackMsg:
+		if authenticated {
+			s.handleAck(msg.Ref, addr)
+		}
+		return
+	case typingMsg:
+		// Typing notices are hop-local: never stored or relayed.
+		if authenticated {
+			s.markActive(addr, msg.From)
+			if !s.isMuted(msg.From) {
+				s.emit(Message{ID: msg.ID, Type: typingMsg, From: msg.From, Timestamp: msg.Timestamp})
+			}
+		}
+		return
+	case pingMsg:
+		// Heartbeats only refresh liveness for the direct sender.
+		if authenticated {
+			s.markActive(addr, msg.From)
+			if msg.Body != "" {
+				_ = s.sendDirect(addr, pongMsg, msg.Body)
+			}
+		}
+		return
+	case pongMsg:
+		// Pongs answer our own pings and are never relayed.
+		if authenticated {
+			s.markActive(addr, msg.From)
+			s.recordRTT(addr, msg.Body)
+		}
+		return
+	case fileMsg:
+		// File chunks are relayed like chat but never shown as messages.
+		if authenticated {
+			s.markActive(addr, msg.From)
+			s.sendAck(msg.ID, addr)
+			s.receiveFileChunk(msg)
+		}
+		s.relay(msg, raw, addr)
+		return
 	case joinMsg:
 		payload := strings.TrimSpace(msg.Body)
 		if payload != "" {
@@ -193,12 +581,18 @@ func (s *session) handleIncoming(msg Message, addr net.Addr, raw []byte, authent
 		}
 		if payload != "" {
 			suppressEmit = true
+			if authenticated && s.cfg.ShareHistory {
+				s.shareHistory(addr)
+			}
 		}
 	}
 
 	if msg.Type == errorMsg {
-		_ = s.dropPeer(addr, msg.Body)
-		s.emit(msg)
+		s.noteAuthReject(addr, msg.Body)
+		// A dropped member is reported by its member event instead.
+		if !s.dropPeer(addr, msg.Body) {
+			s.emit(msg)
+		}
 		return
 	}
 
@@ -206,7 +600,45 @@ func (s *session) handleIncoming(msg Message, addr net.Addr, raw []byte, authent
 		if msg.Type == leaveMsg && msg.From != "" {
 			_ = s.dropPeer(addr, "left the chat")
 		} else {
-			activated = s.markActive(addr, msg.From)
+			// A relayed copy carries its author's name, not the relay's, so
+			// it only names a source we know nothing about yet. Pings keep
+			// the names of direct peers current.
+			name := msg.From
+			if rec, ok := s.lookupMember(canonicalNetAddr(addr)); ok && rec.Name != "" {
+				name = ""
+			}
+			activated = s.markActive(addr, name)
+		}
+	}
+
+	if msg.Type == chatMsg && authenticated {
+		s.sendAck(msg.ID, addr)
+	}
+
+	if msg.Type == chatMsg && (msg.To != "" || msg.Direct) {
+		// Private and direct messages are never relayed; drop copies meant for someone else.
+		if authenticated && (msg.To == "" || s.isLocal(msg.To)) {
+			s.markActive(addr, msg.From)
+			s.rememberRecent(msg)
+			if !s.isMuted(msg.From) {
+				msg.Origin = s.originKey(msg.From, addr)
+				msg.NameTag = s.senderTag(msg.From, msg.Origin)
+				s.emit(msg)
+			}
+		}
+		return
+	}
+
+	if msg.Type == chatMsg && authenticated {
+		msg.Gap, msg.Late = s.trackSequence(s.sequenceKey(msg.From, addr), msg.Seq)
+		s.rememberRecent(msg)
+		s.recordHistory(msg)
+	}
+	if msg.Type == editMsg || msg.Type == deleteMsg {
+		if !authenticated || msg.Ref == "" {
+			suppressEmit = true
+		} else {
+			s.reviseHistory(msg)
 		}
 	}
 
@@ -219,16 +651,28 @@ func (s *session) handleIncoming(msg Message, addr net.Addr, raw []byte, authent
 		suppressEmit = true
 	}
 
+	if msg.Type == chatMsg && s.isMuted(msg.From) {
+		// Muted peers are hidden locally but still relayed to everyone else.
+		suppressEmit = true
+	}
 	if !suppressEmit {
+		switch msg.Type {
+		case chatMsg, editMsg, deleteMsg:
+			msg.Origin = s.originKey(msg.From, addr)
+		}
+		if msg.Type == chatMsg {
+			msg.NameTag = s.senderTag(msg.From, msg.Origin)
+		}
 		s.emit(msg)
 	}
-	s.forwardRaw(raw, addr)
+	s.relay(msg, raw, addr)
 }
 
 // handleAuthReject notes authentication failures and drops the peer.
 func (s *session) handleAuthReject(msg Message, addr net.Addr) {
-	s.emit(msg)
-	_ = s.dropPeer(addr, msg.Body)
+	if !s.dropPeer(addr, msg.Body) {
+		s.emit(msg)
+	}
 }
 
 // buildJoinPayload returns the serialized join envelope for this session.
@@ -246,7 +690,10 @@ func (s *session) contactPeer(addr string) {
 	if addr == "" {
 		return
 	}
-	if s.isLocal(addr) || s.hasMember(addr) {
+	if s.isLocal(addr) || s.hasMember(addr) || s.isBlocked(addr) || s.mismatched(addr) {
+		return
+	}
+	if ap, err := netip.ParseAddrPort(addr); err == nil && checkUnicast(ap) != nil {
 		return
 	}
 	s.addPendingMember(addr)
@@ -255,7 +702,7 @@ func (s *session) contactPeer(addr string) {
 		s.emitSystem("peer hint %s failed: %v", addr, err)
 		return
 	}
-	if s.isLocal(resolved.String()) {
+	if s.isLocal(resolved.String()) || s.isBlocked(canonicalNetAddr(resolved)) || s.mismatched(canonicalNetAddr(resolved)) {
 		return
 	}
 	if ap, ok := addrPortFromNet(resolved); ok {
@@ -265,12 +712,67 @@ func (s *session) contactPeer(addr string) {
 	}
 	joinPayload := s.buildJoinPayload()
 	s.markPending(resolved)
-	if err := s.sendDirect(resolved, joinMsg, joinPayload); err != nil {
-		s.emitSystem("failed to reach %s: %v", resolved, err)
+	if err := s.sendDirectRetry(resolved, joinMsg, joinPayload); err != nil {
+		if errors.Is(err, errRetryInFlight) {
+			return
+		}
 		_ = s.dropPeer(resolved, fmt.Sprintf("failed: %v", err))
 	}
 }
 
+// addPeer resolves a peer address and sends it a join, marking it active
+// once the send succeeds and dropping it if every retry fails.
+func (s *session) addPeer(raw string) error {
+	addr, err := s.resolveAddr(raw)
+	if err != nil {
+		return fmt.Errorf("failed to resolve %s: %w", raw, err)
+	}
+	if s.isBlocked(canonicalNetAddr(addr)) {
+		return fmt.Errorf("%s was kicked and stays blocked for this session", raw)
+	}
+	if s.resolvesToSelf(addr) {
+		return fmt.Errorf("%s is this node's own address", raw)
+	}
+	// Naming a peer explicitly retries it even after a secret mismatch.
+	s.clearAuthRejects(canonicalNetAddr(addr))
+	s.markPending(addr)
+	s.notePeerHost(raw, addr)
+	if err := s.sendDirectRetry(addr, joinMsg, s.buildJoinPayload()); err != nil {
+		if errors.Is(err, errRetryInFlight) {
+			return err
+		}
+		_ = s.dropPeer(addr, fmt.Sprintf("failed: %v", err))
+		return fmt.Errorf("failed to reach %s: %w", raw, err)
+	}
+	s.markActive(addr, "")
+	return nil
+}
+
+// removePeer forgets a member by address or name.
+func (s *session) removePeer(raw string) error {
+	if s.removeMember(raw) {
+		s.recordEvent("removed %s", strings.TrimSpace(raw))
+		return nil
+	}
+	keys, _ := s.memberKeysByName([]string{raw})
+	if len(keys) == 0 {
+		if addr, err := s.resolveAddr(raw); err == nil {
+			keys = append(keys, canonicalNetAddr(addr))
+		}
+	}
+	removed := false
+	for _, key := range keys {
+		if s.removeMember(key) {
+			s.recordEvent("removed %s", key)
+			removed = true
+		}
+	}
+	if !removed {
+		return fmt.Errorf("unknown peer %s", raw)
+	}
+	return nil
+}
+
 // handlePeersPayload merges received peer hints and dials any new addresses.
 func (s *session) handlePeersPayload(body string, source net.Addr) {
 	if strings.TrimSpace(body) == "" {
@@ -289,16 +791,81 @@ func (s *session) handlePeersPayload(body string, source net.Addr) {
 	}
 }
 
+// seedResolveTimeout bounds how long startup waits for one bootstrap peer to
+// resolve.
+const seedResolveTimeout = 10 * time.Second
+
+// resolveSeeds resolves bootstrap peers concurrently, so one slow lookup does
+// not hold up the rest. Results and errors are indexed like seeds.
+func (s *session) resolveSeeds(seeds []string) ([]net.Addr, []error) {
+	addrs := make([]net.Addr, len(seeds))
+	errs := make([]error, len(seeds))
+	indexes := make([]int, len(seeds))
+	for i := range indexes {
+		indexes[i] = i
+	}
+	forEachLimited(indexes, contactWorkers, func(i int) {
+		addrs[i], errs[i] = s.resolveWithin(seeds[i], seedResolveTimeout)
+	})
+	return addrs, errs
+}
+
+// resolveWithin runs resolveAddr but gives up after timeout; a lookup still
+// running then finishes in the background and is discarded.
+func (s *session) resolveWithin(raw string, timeout time.Duration) (net.Addr, error) {
+	type result struct {
+		addr net.Addr
+		err  error
+	}
+	done := make(chan result, 1)
+	go func() {
+		addr, err := s.resolveAddr(raw)
+		done <- result{addr, err}
+	}()
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case res := <-done:
+		return res.addr, res.err
+	case <-timer.C:
+		return nil, fmt.Errorf("timed out after %s", timeout)
+	}
+}
+
 // resolveAddr normalises a textual address via the configured resolver.
 func (s *session) resolveAddr(raw string) (net.Addr, error) {
 	target := strings.TrimSpace(raw)
 	if target == "" {
 		return nil, fmt.Errorf("address cannot be empty")
 	}
-	if s.resolve != nil {
-		return s.resolve(target)
+	resolve := s.resolve
+	if resolve == nil {
+		resolve = func(target string) (net.Addr, error) {
+			return net.ResolveUDPAddr("udp", target)
+		}
+	}
+	addr, err := resolve(target)
+	delay := s.retryDelay
+	for attempt := 0; err != nil && isTransientResolve(err) && attempt < s.retries; attempt++ {
+		timer := time.NewTimer(delay)
+		select {
+		case <-s.closed:
+			timer.Stop()
+			return nil, err
+		case <-timer.C:
+		}
+		addr, err = resolve(target)
+		delay *= 2
+	}
+	if err != nil {
+		return nil, err
 	}
-	return net.ResolveUDPAddr("udp", target)
+	if ap, ok := addrPortFromNet(addr); ok {
+		if err := checkUnicast(ap); err != nil {
+			return nil, err
+		}
+	}
+	return addr, nil
 }
 
 // sendDirect encrypts and delivers a message directly to a peer.
@@ -312,33 +879,206 @@ func (s *session) sendDirect(addr net.Addr, kind msgType, body string) error {
 
 // broadcast gossips an encoded message to every known peer.
 func (s *session) broadcast(kind msgType, body string) error {
-	msg, raw, err := s.transport.prepare(s.cfg.Name, kind, body)
+	msg := Message{Type: kind, Body: body}
+	if kind == chatMsg && s.cfg.RoomColors {
+		msg.Room = s.cfg.Profile
+	}
+	return s.broadcastMessage(msg)
+}
+
+// broadcastMessage encodes a message template and gossips it to every known peer.
+func (s *session) broadcastMessage(tmpl Message) error {
+	tmpl.From = s.cfg.Name
+	msg, raw, err := s.transport.prepareMessage(tmpl)
 	if err != nil {
 		return err
 	}
 
-	if kind == chatMsg {
+	switch msg.Type {
+	case chatMsg, editMsg, deleteMsg:
 		local := msg
-		local.Body = body
+		local.Body = tmpl.Body
 		local.Cipher = ""
 		local.Nonce = ""
+		local.Origin = s.localAddr
+		if local.Type == chatMsg {
+			local.NameTag = s.senderTag(local.From, s.localAddr)
+		}
 		s.emit(local)
 	}
+	if msg.Type == chatMsg {
+		s.statusMu.Lock()
+		s.lastSentID = msg.ID
+		s.statusMu.Unlock()
+		local := msg
+		local.Body = tmpl.Body
+		s.rememberRecent(local)
+		s.recordHistory(local)
+	}
+	if msg.Type == editMsg || msg.Type == deleteMsg {
+		local := msg
+		local.Body = tmpl.Body
+		s.reviseHistory(local)
+	}
 
+	if reliableKind(msg.Type) {
+		targets := s.activeEndpoints()
+		if msg.Type == chatMsg {
+			s.trackDelivery(msg.ID, targets)
+		}
+		s.expectAcks(msg, raw, targets)
+	}
 	s.forwardRaw(raw, nil)
+	if msg.Type == chatMsg {
+		s.queueForPending(raw)
+	}
 	return nil
 }
 
-// forwardRaw rebroadcasts an already encoded packet to active peers.
-func (s *session) forwardRaw(data []byte, exclude net.Addr) {
-	excludeKey := canonicalNetAddr(exclude)
-	for _, target := range s.activeEndpoints(excludeKey) {
-		udp := net.UDPAddrFromAddrPort(target.ap)
-		if udp == nil {
+// sendPrivate delivers a chat message to a single active member without relaying.
+func (s *session) sendPrivate(rawAddr, body string) error {
+	rec, ok := s.lookupMember(rawAddr)
+	if !ok {
+		if keys, _ := s.memberKeysByName([]string{rawAddr}); len(keys) == 1 {
+			rec, ok = s.lookupMember(keys[0])
+		}
+	}
+	if !ok {
+		return fmt.Errorf("unknown peer %s", rawAddr)
+	}
+	ap, hasEndpoint := rec.AddrPort()
+	active := rec.Status == statusActive && hasEndpoint
+	if !active && s.cfg.Outbox <= 0 {
+		return fmt.Errorf("peer %s is pending; private messages need an active peer", rec.Addr)
+	}
+	msg, raw, err := s.transport.prepareMessage(Message{From: s.cfg.Name, Type: chatMsg, Body: body, To: rec.Addr})
+	if err != nil {
+		return err
+	}
+	local := msg
+	local.Body = body
+	local.Cipher = ""
+	local.Nonce = ""
+	s.emit(local)
+	if !active {
+		// The outbox replays the message once the peer connects.
+		s.queueFor([]string{rec.Addr}, raw)
+		s.emitSystem("%s is pending; message queued until it connects", rec.Addr)
+		return nil
+	}
+	target := []memberEndpoint{{key: rec.Addr, ap: ap}}
+	s.trackDelivery(msg.ID, target)
+	if err := s.transport.sendRaw(net.UDPAddrFromAddrPort(ap), raw); err != nil {
+		return fmt.Errorf("send to %s: %w", rec.Addr, err)
+	}
+	s.expectAcks(msg, raw, target)
+	return nil
+}
+
+// sendExcept delivers a chat message directly to every active member other
+// than the excluded names, returning how many peers it reached.
+func (s *session) sendExcept(names []string, body string) (int, error) {
+	excluded, unknown := s.memberKeysByName(names)
+	if len(unknown) > 0 {
+		s.emitSystem("no peer matches %s; not excluded", strings.Join(unknown, ", "))
+	}
+	targets := s.activeEndpoints(excluded...)
+	if len(targets) == 0 {
+		return 0, fmt.Errorf("no other active peers")
+	}
+	msg, raw, err := s.transport.prepareMessage(Message{From: s.cfg.Name, Type: chatMsg, Body: body, Direct: true})
+	if err != nil {
+		return 0, err
+	}
+	local := msg
+	local.Body = body
+	local.Cipher = ""
+	local.Nonce = ""
+	s.rememberRecent(local)
+	s.emit(local)
+	s.trackDelivery(msg.ID, targets)
+	sent := 0
+	var errs []error
+	for _, target := range targets {
+		if err := s.transport.sendRaw(net.UDPAddrFromAddrPort(target.ap), raw); err != nil {
+			errs = append(errs, fmt.Errorf("send to %s: %w", target.key, err))
 			continue
 		}
-		if err := s.transport.sendRaw(udp, data); err != nil {
+		s.expectAcks(msg, raw, []memberEndpoint{target})
+		sent++
+	}
+	return sent, errors.Join(errs...)
+}
+
+// forwardResult tallies the outcome of a fan-out send.
+type forwardResult struct {
+	attempted int
+	delivered int
+	err       error
+}
+
+// relay forwards a received packet to active peers not known to have it yet:
+// the peer it came from, its origin, anyone who sent us a duplicate, and
+// anyone we already relayed it to are skipped. With Config.Fanout set, at
+// most that many randomly chosen peers are sent the relay, unless Config.Relay
+// makes this node a hub. Config.NoForward disables relaying altogether.
+func (s *session) relay(msg Message, raw []byte, source net.Addr) {
+	if s.cfg.NoForward {
+		return
+	}
+	sourceKey := canonicalNetAddr(source)
+	skip := []string{sourceKey}
+	if origin, _ := s.memberKeysByName([]string{msg.From}); len(origin) == 1 {
+		skip = append(skip, origin[0])
+	}
+	s.transport.seen.noteHolders(msg.ID, skip...)
+	holders := s.transport.seen.holding(msg.ID)
+	targets := slices.DeleteFunc(s.activeEndpoints(skip...), func(target memberEndpoint) bool {
+		_, ok := holders[target.key]
+		return ok
+	})
+	if fanout := s.cfg.Fanout; fanout > 0 && len(targets) > fanout && !s.cfg.Relay {
+		rand.Shuffle(len(targets), func(i, j int) { targets[i], targets[j] = targets[j], targets[i] })
+		targets = targets[:fanout]
+	}
+	for _, target := range targets {
+		s.transport.seen.noteHolders(msg.ID, target.key)
+	}
+	res := s.sendToEndpoints(targets, raw)
+	s.transport.stats.forwarded.Add(uint64(res.delivered))
+}
+
+// forwardRaw rebroadcasts an already encoded packet to active peers, writing
+// to up to Config.SendWorkers of them at once so one slow socket write does
+// not hold up the rest.
+func (s *session) forwardRaw(data []byte, exclude net.Addr) forwardResult {
+	return s.sendToEndpoints(s.activeEndpoints(canonicalNetAddr(exclude)), data)
+}
+
+// sendToEndpoints writes data to every target, up to Config.SendWorkers at
+// once, reporting each failure as it happens.
+func (s *session) sendToEndpoints(targets []memberEndpoint, data []byte) forwardResult {
+	var res forwardResult
+	var errs []error
+	var mu sync.Mutex
+	workers := s.cfg.SendWorkers
+	if workers <= 0 {
+		workers = defaultSendWorkers
+	}
+	res.attempted = len(targets)
+	forEachLimited(targets, workers, func(target memberEndpoint) {
+		err := s.transport.sendRaw(net.UDPAddrFromAddrPort(target.ap), data)
+		if err != nil {
 			s.emitSystem("send to %s failed: %v", target.key, err)
 		}
-	}
+		mu.Lock()
+		defer mu.Unlock()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("send to %s: %w", target.key, err))
+			return
+		}
+		res.delivered++
+	})
+	res.err = errors.Join(errs...)
+	return res
 }
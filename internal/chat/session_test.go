@@ -0,0 +1,122 @@
+package chat
+
+import (
+	"runtime"
+	"strconv"
+	"sync"
+	"testing"
+
+	"yap/internal/config"
+)
+
+func TestShutdownReportsLeaveDelivery(t *testing.T) {
+	s := newTestSession(t, config.Config{Name: "alice"})
+	peer := listenPeer(t, s, "bob")
+
+	report, err := s.shutdown()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Peers != 1 || report.Delivered != 1 || report.Err != nil {
+		t.Fatalf("report = %+v, want the leave delivered to 1 of 1 peer", report)
+	}
+	if msg := readMessage(t, peer); msg.Type != leaveMsg {
+		t.Fatalf("peer got %s, want leave", msg.Type)
+	}
+	if again, _ := s.shutdown(); again != report {
+		t.Fatalf("second shutdown reported %+v, want %+v", again, report)
+	}
+}
+
+func TestShutdownWithoutPeers(t *testing.T) {
+	s := newTestSession(t, config.Config{})
+	report, err := s.shutdown()
+	if err != nil || report.Peers != 0 || report.Delivered != 0 {
+		t.Fatalf("report = %+v, err = %v, want nothing addressed", report, err)
+	}
+}
+
+func TestEmitDuringShutdown(t *testing.T) {
+	before := runtime.NumGoroutine()
+	s := newTestSession(t, config.Config{Name: "alice"})
+
+	var wg sync.WaitGroup
+	start := make(chan struct{})
+	for i := range 64 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			for j := range 50 {
+				s.emitSystem("notice %d/%d", i, j)
+			}
+		}()
+	}
+	close(start)
+	if _, err := s.shutdown(); err != nil {
+		t.Fatal(err)
+	}
+	wg.Wait()
+
+	// Emitting after shutdown is a no-op, and the closed stream still drains.
+	s.emitSystem("late")
+	for msg := range s.events {
+		if msg.Body == "late" {
+			t.Fatal("event emitted after shutdown")
+		}
+	}
+	waitUntil(t, func() bool { return runtime.NumGoroutine() <= before })
+}
+
+func TestFullQueueKeepsErrorsAndMembership(t *testing.T) {
+	s := newTestSession(t, config.Config{Name: "alice"})
+	drainEvents(s)
+	flood := func(from, n int) {
+		for i := from; i < from+n; i++ {
+			s.emit(Message{Type: chatMsg, ID: strconv.Itoa(i)})
+		}
+	}
+	flood(0, 100)
+	s.emit(Message{Type: errorMsg, Body: "boom"})
+	s.emit(Message{Type: joinMsg, From: "bob"})
+	flood(100, 300)
+
+	events := drainEvents(s)
+	if len(events) != cap(s.events) {
+		t.Fatalf("drained %d events, want a full queue of %d", len(events), cap(s.events))
+	}
+	var errs, joins int
+	for _, ev := range events {
+		switch ev.Type {
+		case errorMsg:
+			errs++
+		case joinMsg:
+			joins++
+		}
+	}
+	if errs != 1 || joins != 1 {
+		t.Fatalf("kept %d errors and %d joins, want 1 of each", errs, joins)
+	}
+	// The oldest chat went first; the newest survived.
+	if events[0].Type == chatMsg && events[0].ID == "0" {
+		t.Fatal("oldest chat was kept")
+	}
+	if last := events[len(events)-1]; last.ID != "399" {
+		t.Fatalf("newest event = %+v, want chat 399", last)
+	}
+	if got, want := s.droppedEvents.Load(), int64(402-cap(s.events)); got != want {
+		t.Fatalf("dropped = %d, want %d", got, want)
+	}
+}
+
+func TestEvictionIndexPrefersTransientEvents(t *testing.T) {
+	queued := []Message{
+		{Type: errorMsg}, {Type: chatMsg}, {Type: typingMsg}, {Type: systemMsg}, {Type: readMsg},
+	}
+	if got := evictionIndex(queued); got != 2 {
+		t.Fatalf("evicted %d, want the typing notice", got)
+	}
+	if got := evictionIndex([]Message{{Type: leaveMsg}, {Type: systemMsg}, {Type: chatMsg}}); got != 1 {
+		t.Fatalf("evicted %d, want the oldest system notice", got)
+	}
+}
@@ -5,34 +5,93 @@ import (
 	"net"
 	"sort"
 	"strings"
+	"time"
+
+	"yap/internal/config"
 )
 
-// emit attempts to queue a message onto the session's event channel.
+// emit attempts to queue a message onto the session's event channel. The read
+// lock keeps shutdown from closing the channel while a send is in flight, and
+// sends never block: a full queue sheds its least important event instead.
 func (s *session) emit(msg Message) {
-	defer func() {
-		_ = recover()
-	}()
+	if msg.Type != typingMsg && msg.Type != memberMsg && msg.Type != clearMsg {
+		// Typing notices are transient and would flood the log, member
+		// transitions are already logged as peer events, and clearing is
+		// purely cosmetic.
+		s.eventLog.write(logRecord{Type: msg.Type, ID: msg.ID, From: msg.From, Body: msg.Body})
+	}
+	s.emitMu.RLock()
+	defer s.emitMu.RUnlock()
+	if s.eventsClosed {
+		return
+	}
 	select {
 	case <-s.closed:
 		return
 	default:
 	}
 
+	s.queueMu.Lock()
+	defer s.queueMu.Unlock()
 	select {
 	case s.events <- msg:
+		return
 	default:
+	}
+
+	// The queue is full. Emitters are serialized by queueMu and the consumer
+	// only removes events, so the drained events and msg fit back afterwards.
+	var queued []Message
+drain:
+	for {
 		select {
-		case <-s.events:
-		case <-s.closed:
-			return
+		case ev := <-s.events:
+			queued = append(queued, ev)
+		default:
+			break drain
 		}
+	}
+	queued = append(queued, msg)
+	if len(queued) > cap(s.events) {
+		victim := evictionIndex(queued)
+		queued = append(queued[:victim], queued[victim+1:]...)
+		s.droppedEvents.Add(1)
+	}
+	for _, ev := range queued {
 		select {
-		case s.events <- msg:
-		case <-s.closed:
+		case s.events <- ev:
+		default:
+			s.droppedEvents.Add(1)
 		}
 	}
 }
 
+// evictionPriority ranks how readily an event may be dropped from a full
+// queue: lower ranks go first, and membership and error notices go last.
+func evictionPriority(kind msgType) int {
+	switch kind {
+	case typingMsg, deliveryMsg, readMsg:
+		return 0
+	case chatMsg, systemMsg:
+		return 1
+	case errorMsg, joinMsg, leaveMsg, memberMsg:
+		return 3
+	default:
+		return 2
+	}
+}
+
+// evictionIndex picks the oldest event with the lowest eviction priority.
+func evictionIndex(queued []Message) int {
+	victim := 0
+	for i, ev := range queued {
+		if evictionPriority(ev.Type) < evictionPriority(queued[victim].Type) {
+			victim = i
+		}
+	}
+	return victim
+}
+
 // emitSystem formats and emits a system notification message.
 func (s *session) emitSystem(format string, args ...any) {
 	s.emit(Message{Type: systemMsg, Body: fmt.Sprintf(format, args...)})
@@ -43,11 +102,30 @@ func (s *session) emitPromptUpdate(name string) {
 	s.emit(Message{Type: promptMsg, Body: name})
 }
 
+// maxStatusEvents bounds the retained status event history.
+const maxStatusEvents = 50
+
+// statusEvent is a timestamped entry in the session's status log.
+type statusEvent struct {
+	at   time.Time
+	text string
+}
+
 // lastEventValue safely returns the most recent status event string.
 func (s *session) lastEventValue() string {
 	s.statusMu.RLock()
 	defer s.statusMu.RUnlock()
-	return s.lastEvent
+	if len(s.statusLog) == 0 {
+		return ""
+	}
+	return s.statusLog[len(s.statusLog)-1].text
+}
+
+// recentEvents returns a copy of the retained status events, oldest first.
+func (s *session) recentEvents() []statusEvent {
+	s.statusMu.RLock()
+	defer s.statusMu.RUnlock()
+	return append([]statusEvent(nil), s.statusLog...)
 }
 
 // markPending updates membership when we attempt to contact a peer.
@@ -58,7 +136,7 @@ func (s *session) markPending(addr net.Addr) {
 	addrStr := canonicalNetAddr(addr)
 	added := s.addPendingMember(addrStr)
 	if added {
-		s.recordEvent("contacting %s", addrStr)
+		s.recordPeerEvent(addrStr, "contacting %s", addrStr)
 	}
 }
 
@@ -76,17 +154,24 @@ func (s *session) markActive(addr net.Addr, name string) bool {
 	}
 	transitioned := s.markMemberActive(addrStr, name)
 	if transitioned {
-		s.recordEvent("connected %s", addrStr)
+		s.noteConnected(addrStr)
 	}
 	return transitioned
 }
 
+// noteConnected logs and reports a member that just became active.
+func (s *session) noteConnected(addr string) {
+	s.recordPeerEvent(addr, "connected %s", addr)
+	s.emitMember(addr, MemberActive, "")
+}
+
 // dropPeer reacts to peer departure or failure, updating state and events.
 func (s *session) dropPeer(addr net.Addr, reason string) bool {
 	if addr == nil {
 		return false
 	}
 	addrStr := canonicalNetAddr(addr)
+	rec, _ := s.lookupMember(addrStr)
 	var changed bool
 	if reason == "left the chat" {
 		changed = s.removeMember(addrStr)
@@ -96,21 +181,62 @@ func (s *session) dropPeer(addr net.Addr, reason string) bool {
 	if !changed {
 		return false
 	}
+	if reason == "left the chat" {
+		s.emitMemberNamed(addrStr, rec.Name, MemberLeft, "")
+	} else {
+		s.emitMemberNamed(addrStr, rec.Name, MemberFailed, reason)
+	}
 	event := reason
 	if event == "" {
 		event = fmt.Sprintf("disconnected %s", addrStr)
 	} else if !strings.Contains(event, addrStr) {
 		event = fmt.Sprintf("%s: %s", addrStr, event)
 	}
-	s.recordEvent("%s", event)
+	s.recordPeerEvent(addrStr, "%s", event)
 	return true
 }
 
-// recordEvent stores a formatted string as the latest status update.
+// emitMember reports a membership transition of the peer at addr.
+func (s *session) emitMember(addr, status, reason string) {
+	rec, _ := s.lookupMember(addr)
+	s.emitMemberNamed(addr, rec.Name, status, reason)
+}
+
+// emitMemberNamed reports a membership transition for a peer whose record
+// may already be gone.
+func (s *session) emitMemberNamed(addr, name, status, reason string) {
+	s.emit(Message{Type: memberMsg, From: name, Addr: addr, Body: status, Reason: reason, Timestamp: s.now().Unix()})
+}
+
+// recordEvent appends a formatted string to the bounded status log.
 func (s *session) recordEvent(format string, args ...any) {
+	s.recordPeerEvent("", format, args...)
+}
+
+// recordPeerEvent records a status event concerning the peer at addr.
+func (s *session) recordPeerEvent(addr, format string, args ...any) {
+	text := fmt.Sprintf(format, args...)
+	s.eventLog.write(logRecord{Type: eventRecord, Addr: addr, Event: text})
 	s.statusMu.Lock()
 	defer s.statusMu.Unlock()
-	s.lastEvent = fmt.Sprintf(format, args...)
+	s.statusLog = append(s.statusLog, statusEvent{at: s.now(), text: text})
+	if len(s.statusLog) > maxStatusEvents {
+		s.statusLog = s.statusLog[len(s.statusLog)-maxStatusEvents:]
+	}
+}
+
+// eventsSummary lists recent status events, newest last.
+func (s *session) eventsSummary() string {
+	events := s.recentEvents()
+	if len(events) == 0 {
+		return "no events recorded"
+	}
+	lines := make([]string, 0, len(events)+1)
+	lines = append(lines, fmt.Sprintf("recent events (%d):", len(events)))
+	for _, ev := range events {
+		lines = append(lines, fmt.Sprintf("  %s %s", ev.at.Format("15:04:05"), ev.text))
+	}
+	return strings.Join(lines, "\n")
 }
 
 // peersSummary builds a human readable view of connection status.
@@ -126,8 +252,8 @@ func (s *session) peersSummary() string {
 	}
 	if s.transport != nil {
 		state := "disabled"
-		if s.transport.encryptionEnabled() {
-			state = "enabled"
+		if cipher := s.transport.currentCipher(); cipher != nil {
+			state = fmt.Sprintf("enabled (%s, key %s)", cipher.Suite(), cipher.Fingerprint())
 		}
 		lines = append(lines, fmt.Sprintf("encryption: %s", state))
 	}
@@ -137,7 +263,118 @@ func (s *session) peersSummary() string {
 	return strings.Join(lines, "\n")
 }
 
-// formatMemberAddrs renders members with optional names for display.
+// configSummary describes the effective configuration without exposing the secret.
+func (s *session) configSummary() string {
+	profile := s.cfg.Profile
+	switch {
+	case s.cfg.Ephemeral:
+		profile = "ephemeral"
+	case profile == "":
+		profile = "none"
+	}
+	lines := []string{fmt.Sprintf("effective config (profile %s):", profile)}
+	lines = append(lines, config.Summary(s.cfg)...)
+	return strings.Join(lines, "\n")
+}
+
+// whoisSummary describes a single member looked up by address or name.
+func (s *session) whoisSummary(raw string) string {
+	rec, ok := s.lookupMember(raw)
+	if !ok {
+		if keys, _ := s.memberKeysByName([]string{raw}); len(keys) == 1 {
+			rec, ok = s.lookupMember(keys[0])
+		}
+	}
+	if !ok {
+		return fmt.Sprintf("no member matches %s", strings.TrimSpace(raw))
+	}
+	name := rec.Name
+	if name == "" {
+		name = "unknown"
+	}
+	seen := "never"
+	if !rec.LastSeen.IsZero() {
+		seen = fmt.Sprintf("%s ago", s.now().Sub(rec.LastSeen).Round(time.Second))
+	}
+	endpoint := "none cached"
+	if ap, ok := rec.AddrPort(); ok {
+		endpoint = ap.String()
+	}
+	lines := []string{
+		fmt.Sprintf("whois %s:", rec.Addr),
+		fmt.Sprintf("  name: %s", name),
+		fmt.Sprintf("  status: %s", rec.Status),
+		fmt.Sprintf("  last seen: %s", seen),
+		fmt.Sprintf("  endpoint: %s", endpoint),
+	}
+	if rec.Host != "" {
+		lines = append(lines, fmt.Sprintf("  hostname: %s", rec.Host))
+	}
+	if rec.RTT > 0 {
+		lines = append(lines, fmt.Sprintf("  latency: %s", formatRTT(rec.RTT)))
+	} else {
+		lines = append(lines, "  latency: not measured")
+	}
+	return strings.Join(lines, "\n")
+}
+
+// peersUsage documents the /peers arguments.
+const peersUsage = "usage: /peers [active|pending|all] [name|seen]"
+
+// peersListing lists every member in the states selected by filter, one per
+// line, ordered by address, by name, or most recently seen first.
+func (s *session) peersListing(args []string) (string, error) {
+	filter, order := "all", "addr"
+	for _, arg := range args {
+		switch arg = strings.ToLower(arg); arg {
+		case "active", "pending", "all":
+			filter = arg
+		case "name", "seen", "addr":
+			order = arg
+		default:
+			return "", fmt.Errorf("%s", peersUsage)
+		}
+	}
+	active, pending := s.membersSnapshot()
+	var lines []string
+	for _, group := range []struct {
+		status  string
+		members []member
+	}{{"active", active}, {"pending", pending}} {
+		if filter != "all" && filter != group.status {
+			continue
+		}
+		s.orderMembers(group.members, order)
+		lines = append(lines, fmt.Sprintf("%s (%d):", group.status, len(group.members)))
+		for i, label := range formatMemberAddrs(group.members) {
+			if seen := group.members[i].LastSeen; !seen.IsZero() && group.members[i].Addr != s.localAddr {
+				label += fmt.Sprintf(", seen %s ago", s.now().Sub(seen).Round(time.Second))
+			}
+			lines = append(lines, "  "+label)
+		}
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+// orderMembers sorts members by name, unnamed ones last, or by most recent
+// LastSeen; any other order keeps them sorted by address.
+func (s *session) orderMembers(members []member, order string) {
+	switch order {
+	case "name":
+		sort.SliceStable(members, func(i, j int) bool {
+			a, b := nameKey(members[i].Name, s.cfg.FoldNames), nameKey(members[j].Name, s.cfg.FoldNames)
+			if (a == "") != (b == "") {
+				return b == ""
+			}
+			return a < b
+		})
+	case "seen":
+		sort.SliceStable(members, func(i, j int) bool { return members[i].LastSeen.After(members[j].LastSeen) })
+	}
+}
+
+// formatMemberAddrs renders members with optional names for display, keeping
+// their order.
 func formatMemberAddrs(members []member) []string {
 	if len(members) == 0 {
 		return nil
@@ -148,9 +385,11 @@ func formatMemberAddrs(members []member) []string {
 		if member.Name != "" {
 			label = fmt.Sprintf("%s (%s)", member.Addr, member.Name)
 		}
+		if member.RTT > 0 {
+			label += " " + formatRTT(member.RTT)
+		}
 		list = append(list, label)
 	}
-	sort.Strings(list)
 	return list
 }
 
@@ -0,0 +1,140 @@
+package chat
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"yap/internal/config"
+)
+
+func TestConfigCommandHidesSecret(t *testing.T) {
+	s := newTestSession(t, config.Config{Name: "alice", Secret: "hunter22", Profile: "team", Peers: []string{"127.0.0.1:4999"}})
+	drainEvents(s)
+	if err := s.handleInput("/config"); err != nil {
+		t.Fatal(err)
+	}
+	msg := waitEvent(t, s, systemContaining("effective config (profile team)"))
+	for _, want := range []string{"name: alice", "encryption: enabled", "peers: 127.0.0.1:4999"} {
+		if !strings.Contains(msg.Body, want) {
+			t.Errorf("summary lacks %q:\n%s", want, msg.Body)
+		}
+	}
+	if strings.Contains(msg.Body, "hunter22") {
+		t.Fatalf("summary leaks the secret:\n%s", msg.Body)
+	}
+}
+
+func TestStatusLogIsBounded(t *testing.T) {
+	s := newTestSession(t, config.Config{})
+	for i := range maxStatusEvents + 10 {
+		s.recordEvent("event %d", i)
+	}
+	events := s.recentEvents()
+	if len(events) != maxStatusEvents {
+		t.Fatalf("kept %d events, want %d", len(events), maxStatusEvents)
+	}
+	if first, last := events[0].text, events[len(events)-1].text; first != "event 10" || last != fmt.Sprintf("event %d", maxStatusEvents+9) {
+		t.Fatalf("kept %q..%q, want the newest events", first, last)
+	}
+}
+
+func TestEventsCommandListsConnections(t *testing.T) {
+	s := newTestSession(t, config.Config{})
+	peer := listenPeer(t, s, "bob")
+	s.markActive(peer.LocalAddr(), "bob")
+	s.dropPeer(peer.LocalAddr(), "timed out")
+	drainEvents(s)
+
+	if err := s.handleInput("/events"); err != nil {
+		t.Fatal(err)
+	}
+	msg := waitEvent(t, s, systemContaining("recent events"))
+	if !strings.Contains(msg.Body, "timed out") {
+		t.Fatalf("events lack the drop:\n%s", msg.Body)
+	}
+}
+
+func TestWhoisDescribesMember(t *testing.T) {
+	clock := newFakeClock()
+	s := newTestSessionWith(t, sessionOptions{config: config.Config{Name: "alice"}, now: clock.Now})
+	s.markMemberActive("127.0.0.1:4001", "bob")
+	clock.advance(90 * time.Second)
+
+	want := strings.Join([]string{
+		"whois 127.0.0.1:4001:",
+		"  name: bob",
+		"  status: active",
+		"  last seen: 1m30s ago",
+		"  endpoint: 127.0.0.1:4001",
+		"  latency: not measured",
+	}, "\n")
+	if got := s.whoisSummary("bob"); got != want {
+		t.Fatalf("whois bob:\n%s\nwant:\n%s", got, want)
+	}
+	if got := s.whoisSummary("127.0.0.1:4001"); got != want {
+		t.Fatalf("whois by address:\n%s", got)
+	}
+
+	if err := s.handleInput("/whois carol"); err != nil {
+		t.Fatal(err)
+	}
+	waitEvent(t, s, systemContaining("no member matches carol"))
+}
+
+func TestPeersListingFiltersAndSorts(t *testing.T) {
+	clock := newFakeClock()
+	s := newTestSessionWith(t, sessionOptions{config: config.Config{Name: "alice"}, now: clock.Now})
+	s.markMemberActive("10.0.0.3:4000", "carol")
+	clock.advance(time.Second)
+	s.markMemberActive("10.0.0.2:4000", "bob")
+	clock.advance(time.Second)
+	s.markMemberActive("10.0.0.4:4000", "")
+	s.addPendingMember("10.0.0.9:4000")
+	clock.advance(time.Minute)
+
+	peerLines := func(listing string) []string {
+		var out []string
+		for _, line := range strings.Split(listing, "\n") {
+			if strings.HasPrefix(line, "  10.") {
+				out = append(out, strings.TrimSuffix(strings.Fields(line)[0], ","))
+			}
+		}
+		return out
+	}
+	cases := []struct {
+		args []string
+		want string
+	}{
+		{nil, "10.0.0.2:4000 10.0.0.3:4000 10.0.0.4:4000 10.0.0.9:4000"},
+		{[]string{"active", "name"}, "10.0.0.2:4000 10.0.0.3:4000 10.0.0.4:4000"},
+		{[]string{"SEEN", "active"}, "10.0.0.4:4000 10.0.0.2:4000 10.0.0.3:4000"},
+		{[]string{"pending"}, "10.0.0.9:4000"},
+	}
+	for _, tc := range cases {
+		listing, err := s.peersListing(tc.args)
+		if err != nil {
+			t.Fatalf("%v: %v", tc.args, err)
+		}
+		if got := strings.Join(peerLines(listing), " "); got != tc.want {
+			t.Errorf("/peers %v = %s, want %s\n%s", tc.args, got, tc.want, listing)
+		}
+	}
+
+	listing, _ := s.peersListing([]string{"pending"})
+	if strings.Contains(listing, "active (") || !strings.Contains(listing, "pending (1):") {
+		t.Fatalf("pending filter listed:\n%s", listing)
+	}
+	if !strings.Contains(listing, "10.0.0.9:4000, seen 1m0s ago") {
+		t.Fatalf("last seen missing:\n%s", listing)
+	}
+}
+
+func TestPeersCommandRejectsUnknownArguments(t *testing.T) {
+	s := newTestSession(t, config.Config{Name: "alice"})
+	if err := s.handleInput("/peers busy"); err != nil {
+		t.Fatal(err)
+	}
+	waitEvent(t, s, systemContaining(peersUsage))
+}
@@ -0,0 +1,94 @@
+package chat
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+const (
+	// typingInterval throttles outgoing typing notices.
+	typingInterval = 2 * time.Second
+	// typingTimeout is how long a received notice is shown without a follow-up.
+	typingTimeout = 5 * time.Second
+)
+
+// typingExpiredMsg asks the UI to drop stale typing notices.
+type typingExpiredMsg struct{}
+
+// typingNotice records when a peer was last seen composing a message.
+type typingNotice struct {
+	name string
+	at   time.Time
+}
+
+// sendTyping tells directly connected peers that this node is composing a
+// message, at most once per typingInterval. Notices are hop-local: receivers
+// never relay or store them.
+func (s *session) sendTyping() {
+	now := s.now().UnixNano()
+	last := s.typingAt.Load()
+	if now-last < int64(typingInterval) || !s.typingAt.CompareAndSwap(last, now) {
+		return
+	}
+	_, raw, err := s.transport.prepare(s.cfg.Name, typingMsg, "")
+	if err != nil {
+		return
+	}
+	s.forwardRaw(raw, nil)
+}
+
+// noteTyping records a typing notice and schedules its expiry.
+func (m *bubbleModel) noteTyping(msg Message) tea.Cmd {
+	if msg.From == "" || namesEqual(msg.From, m.user, m.opts.foldNames) {
+		return nil
+	}
+	m.clearTyping(msg.From)
+	m.typing = append(m.typing, typingNotice{name: msg.From, at: time.Now()})
+	return tea.Tick(typingTimeout, func(time.Time) tea.Msg { return typingExpiredMsg{} })
+}
+
+// clearTyping forgets the notice from name, e.g. once its message arrives.
+func (m *bubbleModel) clearTyping(name string) {
+	kept := m.typing[:0]
+	for _, notice := range m.typing {
+		if !namesEqual(notice.name, name, m.opts.foldNames) {
+			kept = append(kept, notice)
+		}
+	}
+	m.typing = kept
+}
+
+// expireTyping drops notices older than typingTimeout.
+func (m *bubbleModel) expireTyping() {
+	cutoff := time.Now().Add(-typingTimeout)
+	kept := m.typing[:0]
+	for _, notice := range m.typing {
+		if notice.at.After(cutoff) {
+			kept = append(kept, notice)
+		}
+	}
+	m.typing = kept
+}
+
+// renderTyping describes who is composing a message, or returns "" if nobody is.
+func (m *bubbleModel) renderTyping() string {
+	var text string
+	switch n := len(m.typing); {
+	case n == 0:
+		return ""
+	case n == 1:
+		text = m.typing[0].name + " is typing"
+	case n == 2:
+		text = m.typing[0].name + " and " + m.typing[1].name + " are typing"
+	default:
+		names := make([]string, 0, n)
+		for _, notice := range m.typing {
+			names = append(names, notice.name)
+		}
+		text = fmt.Sprintf("%d people are typing (%s)", n, strings.Join(names, ", "))
+	}
+	return m.opts.theme.timestamp + text + m.opts.glyphs.ellipsis + m.opts.theme.reset
+}
@@ -0,0 +1,81 @@
+package chat
+
+import (
+	"encoding/json"
+	"net"
+	"testing"
+	"time"
+
+	"yap/internal/config"
+)
+
+func TestTypingIsThrottled(t *testing.T) {
+	clock := newFakeClock()
+	s := newTestSessionWith(t, sessionOptions{config: config.Config{Name: "alice"}, now: clock.Now})
+	bob := listenPeer(t, s, "bob")
+
+	for range 3 {
+		s.sendTyping()
+	}
+	if msg := readMessage(t, bob); msg.Type != typingMsg || msg.From != "alice" {
+		t.Fatalf("got %+v, want a typing notice", msg)
+	}
+	expectSilence(t, bob)
+
+	clock.advance(typingInterval)
+	s.sendTyping()
+	if msg := readMessage(t, bob); msg.Type != typingMsg {
+		t.Fatalf("got %+v after the interval, want a typing notice", msg)
+	}
+}
+
+func TestTypingIsHopLocal(t *testing.T) {
+	s := newTestSession(t, config.Config{Name: "bob", History: 10})
+	s.start()
+	carol := listenPeer(t, s, "carol")
+	alice, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer alice.Close()
+
+	raw, err := json.Marshal(Message{ID: newMessageID(), Type: typingMsg, From: "alice", Timestamp: time.Now().Unix(), Version: protocolVersion})
+	if err != nil {
+		t.Fatal(err)
+	}
+	to, _ := net.ResolveUDPAddr("udp", s.localAddr)
+	if _, err := alice.WriteTo(raw, to); err != nil {
+		t.Fatal(err)
+	}
+	waitEvent(t, s, func(m Message) bool { return m.Type == typingMsg && m.From == "alice" })
+
+	// A new sender may trigger membership gossip, but never the notice itself.
+	buf := make([]byte, 64<<10)
+	_ = carol.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+	for {
+		n, _, err := carol.ReadFrom(buf)
+		if err != nil {
+			break
+		}
+		var msg Message
+		if json.Unmarshal(buf[:n], &msg) == nil && msg.Type == typingMsg {
+			t.Fatal("typing notice was relayed")
+		}
+	}
+	if got := historyBodies(s); len(got) != 0 {
+		t.Fatalf("history = %q, want nothing stored", got)
+	}
+}
+
+func TestTypingNoticeClearsOnMessage(t *testing.T) {
+	m := newBubbleModel("alice", nil, nil, uiOptions{})
+	m.noteTyping(Message{Type: typingMsg, From: "bob"})
+	m.noteTyping(Message{Type: typingMsg, From: "alice"})
+	if got := m.renderTyping(); got != "bob is typing"+m.opts.glyphs.ellipsis {
+		t.Fatalf("typing line = %q", got)
+	}
+	m.Update(Message{ID: "1", Type: chatMsg, From: "bob", Body: "hi", Timestamp: time.Now().Unix()})
+	if got := m.renderTyping(); got != "" {
+		t.Fatalf("typing line after message = %q", got)
+	}
+}
@@ -1,36 +1,221 @@
 package chat
 
 import (
+	"cmp"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"hash/fnv"
+	"os"
+	"slices"
+	"strconv"
 	"strings"
 	"time"
+	"unicode/utf8"
 
 	tea "github.com/charmbracelet/bubbletea"
+
+	"yap/internal/config"
 )
 
-const (
-	ansiReset     = "\033[0m"
-	ansiPrompt    = "\033[38;5;180m"
-	ansiName      = "\033[38;5;81m"
-	ansiJoin      = "\033[38;5;47m"
-	ansiLeave     = "\033[38;5;203m"
-	ansiSystem    = "\033[38;5;213m"
-	ansiError     = "\033[38;5;204m"
-	ansiMessage   = "\033[38;5;251m"
-	ansiOwnBody   = "\033[38;5;159m"
-	ansiTimestamp = "\033[38;5;239m"
-	borderSystem  = "\033[38;5;140m"
-	borderOther   = "\033[38;5;24m"
-	borderSelf    = "\033[38;5;39m"
+// theme holds the escape sequences used to color the UI. A theme with empty
+// fields, like mono, prints plain text.
+type theme struct {
+	reset        string
+	prompt       string
+	name         string
+	join         string
+	leave        string
+	system       string
+	error        string
+	message      string
+	ownBody      string
+	timestamp    string
+	highlight    string
+	borderSystem string
+	borderOther  string
+	borderSelf   string
+}
+
+// themes maps the names accepted by Config.Theme to their palettes.
+var themes = map[string]theme{
+	"dark": {
+		reset:        "\033[0m",
+		prompt:       "\033[38;5;180m",
+		name:         "\033[38;5;81m",
+		join:         "\033[38;5;47m",
+		leave:        "\033[38;5;203m",
+		system:       "\033[38;5;213m",
+		error:        "\033[38;5;204m",
+		message:      "\033[38;5;251m",
+		ownBody:      "\033[38;5;159m",
+		timestamp:    "\033[38;5;239m",
+		highlight:    "\033[1;38;5;220m",
+		borderSystem: "\033[38;5;140m",
+		borderOther:  "\033[38;5;24m",
+		borderSelf:   "\033[38;5;39m",
+	},
+	"light": {
+		reset:        "\033[0m",
+		prompt:       "\033[38;5;94m",
+		name:         "\033[38;5;25m",
+		join:         "\033[38;5;28m",
+		leave:        "\033[38;5;160m",
+		system:       "\033[38;5;127m",
+		error:        "\033[38;5;160m",
+		message:      "\033[38;5;236m",
+		ownBody:      "\033[38;5;24m",
+		timestamp:    "\033[38;5;245m",
+		highlight:    "\033[1;38;5;130m",
+		borderSystem: "\033[38;5;97m",
+		borderOther:  "\033[38;5;67m",
+		borderSelf:   "\033[38;5;31m",
+	},
+	"mono": {},
+}
+
+// defaultTheme is used when Config.Theme is empty or unknown.
+const defaultTheme = "dark"
+
+// roomPalette holds the accent colors assigned to room tags.
+var roomPalette = []int{33, 37, 71, 107, 134, 166, 172, 178, 203, 208}
+
+// glyphSet holds the characters used for block borders and the prompt.
+type glyphSet struct {
+	top      string
+	side     string
+	bottom   string
+	prompt   string
+	ellipsis string
+	quote    string
+	// newline stands in for line breaks pasted into the input line.
+	newline string
+}
+
+var (
+	unicodeGlyphs = glyphSet{top: "┌ ", side: "│ ", bottom: "└", prompt: "▸", ellipsis: "…", quote: "↳", newline: "↵"}
+	asciiGlyphs   = glyphSet{top: "+ ", side: "| ", bottom: "`", prompt: ">", ellipsis: "...", quote: ">", newline: "^J"}
 )
 
+// uiOptions tunes how the terminal UI renders events.
+type uiOptions struct {
+	roomColors     bool
+	glyphs         glyphSet
+	theme          theme
+	hideTimestamps bool
+	filters        keywordFilters
+	foldNames      bool
+	// timeFormat is the timestamp layout and utc shows times in UTC.
+	timeFormat string
+	utc        bool
+	// scrollbackSize caps the runes kept in scrollback; zero selects
+	// defaultScrollbackSize.
+	scrollbackSize int
+	// groupWindow coalesces consecutive blocks from one sender sent within
+	// it; zero disables grouping.
+	groupWindow time.Duration
+	// format styles *bold*, _underline_, and `code` spans in chat bodies.
+	format bool
+	// markRead is called for each chat message from others once it is shown.
+	markRead func(Message)
+	// typing is called when the user changes a non-empty input line.
+	typing func()
+}
+
+// uiOptionsFrom derives UI rendering options from the resolved config.
+func uiOptionsFrom(cfg config.Config) uiOptions {
+	opts := uiOptions{
+		roomColors:     cfg.RoomColors,
+		glyphs:         unicodeGlyphs,
+		theme:          themes[defaultTheme],
+		hideTimestamps: cfg.HideTimestamps,
+		timeFormat:     defaultTimeFormat,
+		utc:            cfg.UTC,
+		scrollbackSize: cfg.ScrollbackSize,
+		filters:        keywordFilters{Highlight: cfg.Highlight, Mute: cfg.Mute},
+		foldNames:      cfg.FoldNames,
+		format:         cfg.Format,
+	}
+	if validTimeFormat(cfg.TimeFormat) {
+		opts.timeFormat = cfg.TimeFormat
+	}
+	if asciiOnly(cfg) {
+		opts.glyphs = asciiGlyphs
+	}
+	// Interval falls back to the default on a malformed value.
+	opts.groupWindow, _ = config.Interval(cfg.GroupWindow, defaultGroupWindow)
+	if th, ok := themes[strings.ToLower(strings.TrimSpace(cfg.Theme))]; ok {
+		opts.theme = th
+	}
+	if prompt := strings.TrimSpace(cfg.Prompt); prompt != "" {
+		opts.glyphs.prompt = prompt
+	}
+	if color, ok := paletteColor(cfg.TimestampColor); ok && opts.theme.reset != "" {
+		opts.theme.timestamp = color
+	}
+	return opts
+}
+
+// paletteColor converts a 256-color palette index into a foreground escape.
+func paletteColor(raw string) (string, bool) {
+	n, err := strconv.Atoi(strings.TrimSpace(raw))
+	if err != nil || n < 0 || n > 255 {
+		return "", false
+	}
+	return fmt.Sprintf("\033[38;5;%dm", n), true
+}
+
+// defaultTimeFormat is the timestamp layout used when Config.TimeFormat is unset or invalid.
+const defaultTimeFormat = "15:04:05"
+
+// validTimeFormat reports whether layout is usable as a timestamp format: it
+// must fit on one line and contain at least one time field, since Go treats
+// any other text as a literal.
+func validTimeFormat(layout string) bool {
+	if strings.TrimSpace(layout) == "" || strings.ContainsAny(layout, "\r\n") {
+		return false
+	}
+	ref := time.Date(2001, 2, 3, 4, 5, 6, 0, time.UTC)
+	return ref.Format(layout) != layout
+}
+
+// stampHeader prefixes a header label with the formatted timestamp unless hidden.
+func stampHeader(opts uiOptions, at time.Time, label string) string {
+	if opts.hideTimestamps {
+		return label
+	}
+	if opts.utc {
+		at = at.UTC()
+	}
+	layout := cmp.Or(opts.timeFormat, defaultTimeFormat)
+	return fmt.Sprintf("%s[%s]%s %s", opts.theme.timestamp, at.Format(layout), opts.theme.reset, label)
+}
+
+// asciiOnly reports whether output should avoid non-ASCII glyphs, either because
+// the config asks for it or the locale and terminal do not advertise UTF-8.
+func asciiOnly(cfg config.Config) bool {
+	if cfg.ASCII {
+		return true
+	}
+	switch os.Getenv("TERM") {
+	case "linux", "dumb", "vt100", "vt220":
+		return true
+	}
+	for _, key := range []string{"LC_ALL", "LC_CTYPE", "LANG"} {
+		if value := os.Getenv(key); value != "" {
+			lower := strings.ToLower(value)
+			return !strings.Contains(lower, "utf-8") && !strings.Contains(lower, "utf8")
+		}
+	}
+	return false
+}
+
 // runBubbleUI starts the Bubble Tea interface and blocks until it exits.
-func runBubbleUI(user string, events <-chan Message, submit func(string) error) error {
-	m := newBubbleModel(user, events, submit)
+func runBubbleUI(user string, events <-chan Message, submit func(string) error, opts uiOptions) error {
+	m := newBubbleModel(user, events, submit, opts)
 	program := tea.NewProgram(m)
 	_, err := program.Run()
-	if errors.Is(err, tea.ErrProgramKilled) || errors.Is(err, errQuit) {
+	if errors.Is(err, tea.ErrProgramKilled) || errors.Is(err, tea.ErrInterrupted) || errors.Is(err, errQuit) {
 		return nil
 	}
 	return err
@@ -40,18 +225,28 @@ func runBubbleUI(user string, events <-chan Message, submit func(string) error)
 type bubbleModel struct {
 	user     string
 	input    []rune
+	cursor   int
 	history  []block
+	size     int // runes held in history
+	pins     []blockEntry
 	events   <-chan Message
 	submit   func(string) error
+	opts     uiOptions
 	quitting bool
+	typing   []typingNotice
+	// height is the terminal height from the last WindowSizeMsg, and offset
+	// how many scrollback lines the view sits above the bottom.
+	height int
+	offset int
 }
 
 // newBubbleModel constructs the Bubble Tea state machine for the chat UI.
-func newBubbleModel(user string, events <-chan Message, submit func(string) error) *bubbleModel {
+func newBubbleModel(user string, events <-chan Message, submit func(string) error, opts uiOptions) *bubbleModel {
 	return &bubbleModel{
 		user:    user,
 		events:  events,
 		submit:  submit,
+		opts:    opts,
 		history: make([]block, 0, 256),
 	}
 }
@@ -66,7 +261,7 @@ func waitForEvent(events <-chan Message) tea.Cmd {
 	return func() tea.Msg {
 		msg, ok := <-events
 		if !ok {
-			return tea.Quit
+			return tea.QuitMsg{}
 		}
 		return msg
 	}
@@ -74,8 +269,16 @@ func waitForEvent(events <-chan Message) tea.Cmd {
 
 // Update handles key presses, incoming messages, and terminal signals.
 func (m *bubbleModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	if _, ok := msg.(Message); ok && m.offset > 0 {
+		// Keep a scrolled-up view still while new lines arrive below it.
+		before := len(m.scrollbackLines())
+		defer func() { m.offset += len(m.scrollbackLines()) - before }()
+	}
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
+		if m.scroll(msg.Type) {
+			return m, nil
+		}
 		switch msg.Type {
 		case tea.KeyCtrlC:
 			m.quitting = true
@@ -83,20 +286,17 @@ func (m *bubbleModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		case tea.KeyEnter:
 			text := strings.TrimSpace(string(m.input))
 			m.input = m.input[:0]
+			m.cursor = 0
 			if text != "" && m.submit != nil {
 				if err := m.submit(text); err != nil && !errors.Is(err, errQuit) {
-					m.append(renderSystem(err.Error()))
+					m.append(renderSystem(m.opts, err.Error()))
 				}
 			}
 			return m, nil
-		case tea.KeyBackspace, tea.KeyCtrlH:
-			if len(m.input) > 0 {
-				m.input = m.input[:len(m.input)-1]
-			}
-			return m, nil
 		default:
-			if s := msg.String(); s != "" && len([]rune(s)) == 1 && !msg.Alt {
-				m.input = append(m.input, []rune(s)[0])
+			before := string(m.input)
+			if m.edit(msg) && len(m.input) > 0 && string(m.input) != before && m.opts.typing != nil {
+				m.opts.typing()
 			}
 			return m, nil
 		}
@@ -107,10 +307,57 @@ func (m *bubbleModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				m.user = trimmed
 			}
 			return m, waitForEvent(m.events)
+		case filterMsg:
+			var filters keywordFilters
+			if err := json.Unmarshal([]byte(msg.Body), &filters); err == nil {
+				m.opts.filters = filters
+			}
+			return m, waitForEvent(m.events)
+		case pinMsg:
+			m.applyPin(msg)
+			return m, waitForEvent(m.events)
+		case readMsg:
+			m.applyRead(msg)
+			return m, waitForEvent(m.events)
+		case deliveryMsg:
+			m.applyDelivery(msg)
+			return m, waitForEvent(m.events)
+		case memberMsg:
+			// Join and leave notices already announce these transitions.
+			if msg.Body == MemberActive || msg.Body == MemberLeft {
+				return m, waitForEvent(m.events)
+			}
+		case clearMsg:
+			m.clearHistory()
+			return m, waitForEvent(m.events)
+		case typingMsg:
+			return m, tea.Batch(waitForEvent(m.events), m.noteTyping(msg))
+		case chatMsg:
+			m.clearTyping(msg.From)
+			if !namesEqual(msg.From, m.user, m.opts.foldNames) && matchKeyword(m.opts.filters.Mute, msg.Body) {
+				return m, waitForEvent(m.events)
+			}
+		case editMsg:
+			m.applyEdit(msg)
+			return m, waitForEvent(m.events)
+		case deleteMsg:
+			m.applyDelete(msg)
+			return m, waitForEvent(m.events)
+		}
+		blk := renderMessage(m.opts, m.user, msg)
+		if msg.ReplyTo != "" {
+			m.attachQuote(&blk, msg.ReplyTo)
+		}
+		m.append(blk)
+		if msg.Type == chatMsg && m.opts.markRead != nil && !namesEqual(msg.From, m.user, m.opts.foldNames) {
+			m.opts.markRead(msg)
 		}
-		m.append(renderMessage(m.user, msg))
 		return m, waitForEvent(m.events)
+	case typingExpiredMsg:
+		m.expireTyping()
+		return m, nil
 	case tea.WindowSizeMsg:
+		m.height = msg.Height
 		return m, nil
 	case tea.QuitMsg:
 		m.quitting = true
@@ -122,99 +369,271 @@ func (m *bubbleModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 // View renders the chat history and input prompt.
 func (m *bubbleModel) View() string {
 	var b strings.Builder
-	for _, blk := range m.history {
-		b.WriteString(renderBlockString(blk))
+	pinned := renderPins(m.opts, m.pins)
+	if pinned != "" {
+		b.WriteString(pinned)
+		b.WriteString("\n\n")
+	}
+	for _, line := range m.visibleLines(m.scrollbackLines(), m.scrollbackRows(pinned)) {
+		b.WriteString(line)
 		b.WriteByte('\n')
 	}
+	if m.offset > 0 {
+		b.WriteString(m.scrollIndicator())
+	} else if typing := m.renderTyping(); typing != "" {
+		b.WriteString(typing)
+	}
 	b.WriteByte('\n')
-	b.WriteString(fmt.Sprintf("%s▸ %s%s %s", ansiPrompt, m.user, ansiReset, string(m.input)))
+	b.WriteString(fmt.Sprintf("%s%s %s%s %s", m.opts.theme.prompt, m.opts.glyphs.prompt, m.user, m.opts.theme.reset, m.renderInput()))
 	return b.String()
 }
 
 // append adds a formatted block to the scrollback, coalescing similar entries.
 func (m *bubbleModel) append(blk block) {
+	defer m.trimHistory()
 	if len(m.history) > 0 {
 		last := m.history[len(m.history)-1]
-		if last.key == blk.key && blk.timestamp.Sub(last.timestamp) <= groupWindow {
-			last.lines = append(last.lines, blk.lines...)
+		window := m.opts.groupWindow
+		if window > 0 && last.key == blk.key && blk.timestamp.Sub(last.timestamp) <= window {
+			added := textSize(block{entries: blk.entries})
+			last.entries = append(last.entries, blk.entries...)
 			last.timestamp = blk.timestamp
+			last.size += added
+			m.size += added
 			m.history[len(m.history)-1] = last
 			return
 		}
 	}
-	if len(m.history) > 500 {
-		m.history = m.history[len(m.history)-500:]
+	if len(m.history) >= maxHistoryBlocks {
+		// Make room for blk without exceeding the block cap.
+		excess := len(m.history) - maxHistoryBlocks + 1
+		for _, old := range m.history[:excess] {
+			m.size -= old.size
+		}
+		m.history = m.history[excess:]
 	}
+	blk.size = textSize(blk)
+	m.size += blk.size
 	m.history = append(m.history, blk)
 }
 
+// trimHistory evicts the oldest blocks until the scrollback fits its rune
+// budget. The newest block is always kept, however large.
+func (m *bubbleModel) trimHistory() {
+	budget := m.opts.scrollbackSize
+	if budget <= 0 {
+		budget = defaultScrollbackSize
+	}
+	drop := 0
+	for m.size > budget && drop < len(m.history)-1 {
+		m.size -= m.history[drop].size
+		drop++
+	}
+	if drop > 0 {
+		m.history = slices.Delete(m.history, 0, drop)
+	}
+}
+
+// clearHistory empties the scrollback. Since no block remains, the next one
+// starts a fresh group rather than coalescing into a cleared one.
+func (m *bubbleModel) clearHistory() {
+	clear(m.history)
+	m.history = m.history[:0]
+	m.size = 0
+	m.offset = 0
+}
+
+// findEntry locates the rendered entry for a message ID, newest blocks first.
+func (m *bubbleModel) findEntry(id string) *blockEntry {
+	if id == "" {
+		return nil
+	}
+	for i := len(m.history) - 1; i >= 0; i-- {
+		entries := m.history[i].entries
+		for j := range entries {
+			if entries[j].id == id {
+				return &entries[j]
+			}
+		}
+	}
+	return nil
+}
+
+// sameSender reports whether msg comes from the member that sent entry: the
+// same name from the same address. A name alone is not enough, since any
+// member may pick one already in use.
+func (m *bubbleModel) sameSender(entry *blockEntry, msg Message) bool {
+	return entry.origin == msg.Origin && namesEqual(entry.from, msg.From, m.opts.foldNames)
+}
+
+// applyEdit replaces the body of a previously rendered message in place when
+// the edit comes from the message's original sender.
+func (m *bubbleModel) applyEdit(msg Message) {
+	entry := m.findEntry(msg.Ref)
+	if entry == nil || !m.sameSender(entry, msg) {
+		return
+	}
+	lines := tagEntryID(m.opts.theme, messageLines(m.opts.theme, chatMsg, msg.From, msg.Body, entry.color, m.opts.format), entry.id)
+	lines[len(lines)-1] += m.opts.theme.timestamp + " (edited)" + m.opts.theme.reset
+	entry.lines = lines
+	entry.text = msg.Body
+	m.refreshPin(*entry)
+}
+
+// applyDelete replaces a previously rendered message with a placeholder when
+// the retraction comes from the message's original sender.
+func (m *bubbleModel) applyDelete(msg Message) {
+	entry := m.findEntry(msg.Ref)
+	if entry == nil || !m.sameSender(entry, msg) {
+		return
+	}
+	entry.lines = []string{m.opts.theme.timestamp + "[message deleted]" + m.opts.theme.reset}
+	entry.text = "[message deleted]"
+	m.refreshPin(*entry)
+}
+
+// attachQuote prefixes a reply with a snippet of the message it references.
+func (m *bubbleModel) attachQuote(blk *block, ref string) {
+	if len(blk.entries) == 0 {
+		return
+	}
+	quoted := m.findEntry(ref)
+	if quoted == nil {
+		return
+	}
+	snippet := []rune(strings.ReplaceAll(quoted.text, "\n", " "))
+	if len(snippet) > 40 {
+		snippet = append(snippet[:40], []rune(m.opts.glyphs.ellipsis)...)
+	}
+	line := fmt.Sprintf("%s%s @%s: %s%s", m.opts.theme.timestamp, m.opts.glyphs.quote, quoted.from, string(snippet), m.opts.theme.reset)
+	blk.entries[0].lines = append([]string{line}, blk.entries[0].lines...)
+}
+
 // renderSystem formats a system notification block.
-func renderSystem(text string) block {
-	header := fmt.Sprintf("%s[%s]%s system", ansiTimestamp, time.Now().Format("15:04:05"), ansiReset)
+func renderSystem(opts uiOptions, text string) block {
+	header := stampHeader(opts, time.Now(), "system")
 	lines := strings.Split(text, "\n")
 	colored := make([]string, len(lines))
 	for i, line := range lines {
-		colored[i] = ansiSystem + line + ansiReset
+		colored[i] = opts.theme.system + line + opts.theme.reset
 	}
-	return block{key: "system", border: borderSystem, header: header, lines: colored, timestamp: time.Now()}
+	return block{key: "system", border: opts.theme.borderSystem, header: header, entries: []blockEntry{{lines: colored}}, timestamp: time.Now()}
 }
 
 // renderMessage styles an incoming application message for display.
-func renderMessage(user string, msg Message) block {
+func renderMessage(opts uiOptions, user string, msg Message) block {
 	ts := msg.Timestamp
 	if ts == 0 {
 		ts = time.Now().Unix()
 	}
-	timestamp := time.Unix(ts, 0).Format("15:04:05")
 
-	border := borderOther
-	bodyColor := ansiMessage
-	label := fmt.Sprintf("@%s", msg.From)
-	labelColor := ansiName
+	border := opts.theme.borderOther
+	bodyColor := opts.theme.message
+	from := msg.From
+	if msg.NameTag != "" {
+		from += "#" + msg.NameTag
+	}
+	label := fmt.Sprintf("@%s", from)
+	labelColor := opts.theme.name
 
 	switch msg.Type {
 	case chatMsg:
-		if msg.From == user {
-			border = borderSelf
-			bodyColor = ansiOwnBody
+		own := namesEqual(msg.From, user, opts.foldNames)
+		if own {
+			border = opts.theme.borderSelf
+			bodyColor = opts.theme.ownBody
+		} else if matchKeyword(opts.filters.Highlight, msg.Body) {
+			bodyColor = opts.theme.highlight
+		}
+		if msg.To != "" {
+			if own {
+				label = fmt.Sprintf("@%s → %s (private)", from, msg.To)
+			} else {
+				label = fmt.Sprintf("@%s (private)", from)
+			}
+		} else if msg.Direct {
+			label = fmt.Sprintf("@%s (side)", from)
 		}
 	case joinMsg:
-		border = borderSystem
+		border = opts.theme.borderSystem
 		label = "status"
-		labelColor = ansiSystem
-		bodyColor = ansiJoin
+		labelColor = opts.theme.system
+		bodyColor = opts.theme.join
 	case leaveMsg:
-		border = borderSystem
+		border = opts.theme.borderSystem
 		label = "status"
-		labelColor = ansiSystem
-		bodyColor = ansiLeave
+		labelColor = opts.theme.system
+		bodyColor = opts.theme.leave
 	case errorMsg:
-		border = borderSystem
+		border = opts.theme.borderSystem
 		label = "error"
-		labelColor = ansiSystem
-		bodyColor = ansiError
+		labelColor = opts.theme.system
+		bodyColor = opts.theme.error
 	case systemMsg:
-		border = borderSystem
+		border = opts.theme.borderSystem
 		label = "system"
-		labelColor = ansiSystem
-		bodyColor = ansiSystem
+		labelColor = opts.theme.system
+		bodyColor = opts.theme.system
+	case memberMsg:
+		border = opts.theme.borderSystem
+		label = "peer"
+		labelColor = opts.theme.system
+		bodyColor = opts.theme.leave
+		if msg.Body == MemberActive {
+			bodyColor = opts.theme.join
+		}
+		msg.Body = memberText(msg)
 	default:
-		border = borderSystem
+		border = opts.theme.borderSystem
 		label = strings.ToUpper(string(msg.Type))
-		labelColor = ansiSystem
+		labelColor = opts.theme.system
 	}
 
-	header := fmt.Sprintf("%s[%s]%s %s%s%s", ansiTimestamp, timestamp, ansiReset, labelColor, label, ansiReset)
-	lines := messageLines(msg.Type, msg.From, msg.Body, bodyColor)
+	header := stampHeader(opts, time.Unix(ts, 0), labelColor+label+opts.theme.reset)
+	if opts.roomColors && msg.Room != "" {
+		header += fmt.Sprintf(" %s#%s%s", roomColor(opts.theme, msg.Room), msg.Room, opts.theme.reset)
+	}
+	lines := messageLines(opts.theme, msg.Type, msg.From, msg.Body, bodyColor, opts.format && msg.Type == chatMsg)
+	if msg.Type == chatMsg {
+		lines = tagEntryID(opts.theme, lines, msg.ID)
+	}
+	if msg.Late {
+		lines[len(lines)-1] += opts.theme.timestamp + " (out of order)" + opts.theme.reset
+	}
+	if msg.Gap {
+		lines = append([]string{opts.theme.timestamp + opts.glyphs.ellipsis + " some messages may be missing" + opts.theme.reset}, lines...)
+	}
 	key := string(msg.Type)
 	if msg.Type == chatMsg {
-		key += ":" + msg.From
+		key += ":" + nameKey(msg.From, opts.foldNames) + "/" + msg.NameTag + "#" + msg.Room + ">" + msg.To + strconv.FormatBool(msg.Direct)
+	}
+	entry := blockEntry{id: msg.ID, from: msg.From, origin: msg.Origin, text: msg.Body, color: bodyColor, lines: lines}
+	return block{key: key, border: border, header: header, entries: []blockEntry{entry}, timestamp: time.Unix(ts, 0)}
+}
+
+// tagEntryID appends the dimmed short message ID used by /reply to the first line.
+func tagEntryID(th theme, lines []string, id string) []string {
+	if id == "" || len(lines) == 0 {
+		return lines
+	}
+	lines[0] += fmt.Sprintf(" %s#%s%s", th.timestamp, shortID(id), th.reset)
+	return lines
+}
+
+// roomColor derives a stable accent color escape from a room name; escape-free
+// themes get none.
+func roomColor(th theme, room string) string {
+	if th.reset == "" {
+		return ""
 	}
-	return block{key: key, border: border, header: header, lines: lines, timestamp: time.Unix(ts, 0)}
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(room))
+	return fmt.Sprintf("\033[38;5;%dm", roomPalette[h.Sum32()%uint32(len(roomPalette))])
 }
 
-// messageLines splits and colorizes a message body by type.
-func messageLines(kind msgType, from, body, color string) []string {
+// messageLines splits and colorizes a message body by type, styling inline
+// spans when format is set.
+func messageLines(th theme, kind msgType, from, body, color string, format bool) []string {
 	var text string
 	switch kind {
 	case chatMsg:
@@ -242,37 +661,119 @@ func messageLines(kind msgType, from, body, color string) []string {
 	for i, line := range raw {
 		if line == "" {
 			line = " "
+		} else if format {
+			line = formatInline(th, line, color)
 		}
-		lines[i] = color + line + ansiReset
+		lines[i] = color + line + th.reset
 	}
 	return lines
 }
 
-const groupWindow = 30 * time.Second
+// memberText describes a member event, e.g. "bob (10.0.0.2:4000): timed out".
+func memberText(msg Message) string {
+	who := msg.Addr
+	if msg.From != "" {
+		who = fmt.Sprintf("%s (%s)", msg.From, msg.Addr)
+	}
+	if msg.Reason != "" {
+		return who + ": " + msg.Reason
+	}
+	switch msg.Body {
+	case MemberActive:
+		return who + " connected"
+	case MemberLeft:
+		return who + " left"
+	case MemberPending:
+		return who + " stopped responding"
+	default:
+		return who + " is unreachable"
+	}
+}
+
+const (
+	// maxHistoryBlocks caps how many blocks the scrollback keeps.
+	maxHistoryBlocks = 500
+	// defaultScrollbackSize caps the runes the scrollback keeps when
+	// Config.ScrollbackSize is unset.
+	defaultScrollbackSize = 4 << 20
+)
+
+// defaultGroupWindow is how close consecutive messages from one sender must
+// be to share a block when Config.GroupWindow is blank.
+const defaultGroupWindow = 30 * time.Second
 
 type block struct {
 	key       string
 	border    string
 	header    string
-	lines     []string
+	entries   []blockEntry
 	timestamp time.Time
+	// size is the rune count of the rendered text, charged against the
+	// scrollback budget when the block is appended.
+	size int
+}
+
+// textSize counts the runes in a block's header and entry lines.
+func textSize(blk block) int {
+	n := utf8.RuneCountInString(blk.header)
+	for _, entry := range blk.entries {
+		for _, line := range entry.lines {
+			n += utf8.RuneCountInString(line)
+		}
+	}
+	return n
+}
+
+// blockEntry holds the rendered lines of one message within a block.
+type blockEntry struct {
+	id    string
+	from  string
+	text  string
+	color string
+	lines []string
+	// origin is the sender's address from Message.Origin; edits and deletes
+	// must come from the same one.
+	origin string
+	// readBy lists the peers whose UI has shown this message.
+	readBy []string
+	// status is the delivery count of a message we sent, e.g. "delivered 2/3".
+	status string
+}
+
+// writeEntryStatus appends the delivery and read status after an entry.
+func writeEntryStatus(b *strings.Builder, opts uiOptions, entry blockEntry) {
+	var parts []string
+	if entry.status != "" {
+		parts = append(parts, entry.status)
+	}
+	if len(entry.readBy) > 0 {
+		parts = append(parts, fmt.Sprintf("read by %d", len(entry.readBy)))
+	}
+	if len(parts) > 0 {
+		fmt.Fprintf(b, " %s%s%s", opts.theme.timestamp, strings.Join(parts, ", "), opts.theme.reset)
+	}
 }
 
 // renderBlockString assembles the ANSI bordered block string for output.
-func renderBlockString(blk block) string {
+func renderBlockString(opts uiOptions, blk block) string {
 	var b strings.Builder
 	b.WriteString(blk.border)
-	b.WriteString("┌ ")
+	b.WriteString(opts.glyphs.top)
 	b.WriteString(blk.header)
 	b.WriteString("\n")
-	for _, line := range blk.lines {
-		b.WriteString(blk.border)
-		b.WriteString("│ ")
-		b.WriteString(line)
-		b.WriteString("\n")
+	for _, entry := range blk.entries {
+		for i, line := range entry.lines {
+			b.WriteString(blk.border)
+			b.WriteString(opts.glyphs.side)
+			b.WriteString(line)
+			if i == len(entry.lines)-1 {
+				writeEntryStatus(&b, opts, entry)
+			}
+			b.WriteString("\n")
+		}
 	}
 	b.WriteString(blk.border)
-	b.WriteString("└")
-	b.WriteString(ansiReset)
+	b.WriteString(opts.glyphs.bottom)
+	b.WriteString(opts.theme.reset)
 	return b.String()
 }
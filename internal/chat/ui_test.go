@@ -0,0 +1,405 @@
+package chat
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"yap/internal/config"
+)
+
+// newTestModel returns a UI model for alice that has shown one chat message
+// from bob at origin.
+func newTestModel(t *testing.T, origin string) (*bubbleModel, string) {
+	t.Helper()
+	m := newBubbleModel("alice", nil, nil, uiOptionsFrom(config.Config{}))
+	id := newMessageID()
+	m.Update(Message{ID: id, Type: chatMsg, From: "bob", Origin: origin, Body: "original"})
+	if m.findEntry(id) == nil {
+		t.Fatal("chat message not rendered")
+	}
+	return m, id
+}
+
+func TestEditRequiresOriginalSender(t *testing.T) {
+	m, id := newTestModel(t, "10.0.0.2:4000")
+
+	// Another member calling itself bob cannot rewrite the message.
+	m.Update(Message{Type: editMsg, From: "bob", Origin: "10.0.0.3:4000", Ref: id, Body: "forged"})
+	if got := m.findEntry(id).text; got != "original" {
+		t.Fatalf("impostor edit applied: %q", got)
+	}
+	m.Update(Message{Type: editMsg, From: "carol", Origin: "10.0.0.2:4000", Ref: id, Body: "renamed"})
+	if got := m.findEntry(id).text; got != "original" {
+		t.Fatalf("edit under another name applied: %q", got)
+	}
+
+	m.Update(Message{Type: editMsg, From: "bob", Origin: "10.0.0.2:4000", Ref: id, Body: "fixed"})
+	if got := m.findEntry(id).text; got != "fixed" {
+		t.Fatalf("sender's edit not applied: %q", got)
+	}
+}
+
+func TestIncomingChatRecordsOrigin(t *testing.T) {
+	s := newTestSession(t, config.Config{Name: "alice"})
+	peer := listenPeer(t, s, "bob")
+	drainEvents(s)
+
+	s.handleIncoming(Message{ID: newMessageID(), Type: chatMsg, From: "bob", Body: "hi"}, peer.LocalAddr(), nil, true)
+	msg := waitEvent(t, s, func(msg Message) bool { return msg.Type == chatMsg })
+	if want := canonicalNetAddr(peer.LocalAddr()); msg.Origin != want {
+		t.Fatalf("origin = %q, want %s", msg.Origin, want)
+	}
+}
+
+func TestDeleteRequiresOriginalSender(t *testing.T) {
+	m, id := newTestModel(t, "10.0.0.2:4000")
+
+	m.Update(Message{Type: deleteMsg, From: "bob", Origin: "10.0.0.3:4000", Ref: id})
+	if got := m.findEntry(id).text; got != "original" {
+		t.Fatalf("impostor delete applied: %q", got)
+	}
+
+	m.Update(Message{Type: deleteMsg, From: "bob", Origin: "10.0.0.2:4000", Ref: id})
+	if got := m.findEntry(id).text; got != "[message deleted]" {
+		t.Fatalf("sender's delete not applied: %q", got)
+	}
+}
+
+func TestRoomColorIsStablePerRoom(t *testing.T) {
+	dark := themes["dark"]
+	if roomColor(dark, "team") != roomColor(dark, "team") {
+		t.Fatal("room color changed between calls")
+	}
+	if roomColor(dark, "team") == "" {
+		t.Fatal("no color for a room in the dark theme")
+	}
+	if got := roomColor(themes["mono"], "team"); got != "" {
+		t.Fatalf("mono theme colored a room: %q", got)
+	}
+}
+
+func TestRoomTagShownOnlyWhenEnabled(t *testing.T) {
+	msg := Message{ID: newMessageID(), Type: chatMsg, From: "bob", Room: "team", Body: "hi"}
+	off := renderMessage(uiOptionsFrom(config.Config{}), "alice", msg)
+	on := renderMessage(uiOptionsFrom(config.Config{RoomColors: true}), "alice", msg)
+	if strings.Contains(off.header, "#team") {
+		t.Fatalf("room tag shown with room colors off: %q", off.header)
+	}
+	if !strings.Contains(on.header, "#team") {
+		t.Fatalf("room tag missing: %q", on.header)
+	}
+}
+
+func TestBroadcastTagsChatWithProfile(t *testing.T) {
+	s := newTestSession(t, config.Config{Name: "alice", Profile: "team", RoomColors: true})
+	peer := listenPeer(t, s, "bob")
+	if err := s.handleInput("hello"); err != nil {
+		t.Fatal(err)
+	}
+	if msg := readMessage(t, peer); msg.Room != "team" {
+		t.Fatalf("room = %q, want team", msg.Room)
+	}
+}
+
+func TestASCIIFallback(t *testing.T) {
+	for _, tc := range []struct {
+		name       string
+		ascii      bool
+		term, lang string
+		want       bool
+	}{
+		{"flag", true, "xterm-256color", "en_US.UTF-8", true},
+		{"utf-8 locale", false, "xterm-256color", "en_US.UTF-8", false},
+		{"linux console", false, "linux", "en_US.UTF-8", true},
+		{"latin-1 locale", false, "xterm", "en_US.ISO-8859-1", true},
+		{"no locale", false, "xterm", "", false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv("TERM", tc.term)
+			t.Setenv("LC_ALL", "")
+			t.Setenv("LC_CTYPE", "")
+			t.Setenv("LANG", tc.lang)
+			if got := asciiOnly(config.Config{ASCII: tc.ascii}); got != tc.want {
+				t.Fatalf("asciiOnly = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestASCIIGlyphsRenderWithoutUnicode(t *testing.T) {
+	t.Setenv("TERM", "xterm")
+	opts := uiOptionsFrom(config.Config{ASCII: true, Theme: "mono"})
+	blk := renderMessage(opts, "alice", Message{ID: newMessageID(), Type: chatMsg, From: "bob", Body: "hi"})
+	out := renderBlockString(opts, blk)
+	for _, r := range out {
+		if r > 0x7f {
+			t.Fatalf("non-ASCII %q in %q", r, out)
+		}
+	}
+}
+
+func TestPromptAndTimestampStyle(t *testing.T) {
+	opts := uiOptionsFrom(config.Config{Prompt: " $ ", TimestampColor: "208"})
+	if opts.glyphs.prompt != "$" {
+		t.Fatalf("prompt = %q, want $", opts.glyphs.prompt)
+	}
+	if opts.theme.timestamp != "\033[38;5;208m" {
+		t.Fatalf("timestamp style = %q", opts.theme.timestamp)
+	}
+
+	fallback := uiOptionsFrom(config.Config{TimestampColor: "300"})
+	if fallback.theme.timestamp != themes[defaultTheme].timestamp {
+		t.Fatalf("out-of-range color applied: %q", fallback.theme.timestamp)
+	}
+	mono := uiOptionsFrom(config.Config{Theme: "mono", TimestampColor: "208"})
+	if mono.theme.timestamp != "" {
+		t.Fatalf("mono theme colored timestamps: %q", mono.theme.timestamp)
+	}
+}
+
+func TestPinnedMessageStaysOnScreen(t *testing.T) {
+	m, id := newTestModel(t, "10.0.0.2:4000")
+	m.Update(tea.WindowSizeMsg{Width: 80, Height: 12})
+	m.Update(Message{Type: pinMsg, Body: pinAction, Ref: id})
+
+	for i := range 30 {
+		m.Update(Message{ID: newMessageID(), Type: chatMsg, From: "carol", Body: fmt.Sprintf("filler %d", i)})
+		view := m.View()
+		if !strings.Contains(view, "pinned") || !strings.Contains(view, "original") {
+			t.Fatalf("pin lost after %d messages:\n%s", i+1, view)
+		}
+	}
+
+	m.Update(Message{Type: pinMsg, Body: unpinAction, Ref: id})
+	if view := m.View(); strings.Contains(view, "original") {
+		t.Fatalf("unpinned message still shown:\n%s", view)
+	}
+}
+
+func TestPinCommandTargetsNewestMessage(t *testing.T) {
+	s := newTestSession(t, config.Config{Name: "alice"})
+	if err := s.handleInput("/pin"); err != nil {
+		t.Fatal(err)
+	}
+	waitEvent(t, s, systemContaining("nothing to pin yet"))
+
+	if err := s.handleInput("hello"); err != nil {
+		t.Fatal(err)
+	}
+	sent := waitEvent(t, s, func(m Message) bool { return m.Type == chatMsg })
+	if err := s.handleInput("/pin"); err != nil {
+		t.Fatal(err)
+	}
+	if pin := waitEvent(t, s, func(m Message) bool { return m.Type == pinMsg }); pin.Body != pinAction || pin.Ref != sent.ID {
+		t.Fatalf("pin = %+v, want %s", pin, sent.ID)
+	}
+}
+
+func TestThemeGolden(t *testing.T) {
+	want := map[string]string{
+		"dark": "\x1b[38;5;24m+ \x1b[38;5;239m[22:13:20]\x1b[0m \x1b[38;5;81m@bob\x1b[0m\n" +
+			"\x1b[38;5;24m| \x1b[38;5;251mhi\x1b[0m\n" +
+			"\x1b[38;5;24m| \x1b[38;5;251mthere\x1b[0m\n" +
+			"\x1b[38;5;24m`\x1b[0m",
+		"mono": "+ [22:13:20] @bob\n| hi\n| there\n`",
+	}
+	for name, golden := range want {
+		opts := uiOptionsFrom(config.Config{Theme: name, UTC: true, ASCII: true})
+		blk := renderMessage(opts, "alice", Message{Type: chatMsg, From: "bob", Body: "hi\nthere", Timestamp: 1700000000})
+		if got := renderBlockString(opts, blk); got != golden {
+			t.Errorf("%s theme:\n got %q\nwant %q", name, got, golden)
+		}
+	}
+}
+
+func TestMonoThemeHasNoEscapes(t *testing.T) {
+	opts := uiOptionsFrom(config.Config{Theme: "mono"})
+	for _, msg := range []Message{
+		{Type: chatMsg, From: "alice", Body: "own", Room: "ops"},
+		{Type: chatMsg, From: "bob", Body: "**bold** hi", ID: newMessageID()},
+		{Type: memberMsg, Addr: "10.0.0.2:4000", Body: MemberActive},
+		{Type: errorMsg, Body: "oops"},
+	} {
+		if got := renderBlockString(opts, renderMessage(opts, "alice", msg)); strings.Contains(got, "\x1b") {
+			t.Errorf("mono output has escapes: %q", got)
+		}
+	}
+	if got := renderBlockString(opts, renderSystem(opts, "notice")); strings.Contains(got, "\x1b") {
+		t.Errorf("mono system block has escapes: %q", got)
+	}
+}
+
+func TestScrollbackKeys(t *testing.T) {
+	m := newBubbleModel("alice", nil, nil, uiOptionsFrom(config.Config{HideTimestamps: true, ASCII: true}))
+	m.Update(tea.WindowSizeMsg{Width: 80, Height: 12})
+	for i := range 20 {
+		m.Update(Message{Type: systemMsg, Body: fmt.Sprintf("line %02d", i)})
+	}
+	visible := func() []string {
+		return m.visibleLines(m.scrollbackLines(), m.scrollbackRows(""))
+	}
+	bottom := visible()
+	if !strings.Contains(bottom[len(bottom)-2], "line 19") {
+		t.Fatalf("not at the bottom: %q", bottom)
+	}
+
+	m.Update(tea.KeyMsg{Type: tea.KeyPgUp})
+	page := len(bottom) - 1
+	if m.offset != page {
+		t.Fatalf("offset after PgUp = %d, want %d", m.offset, page)
+	}
+	up := visible()
+	if up[len(up)-1] != bottom[0] {
+		t.Fatalf("PgUp should keep one line of overlap: %q then %q", bottom, up)
+	}
+	if !strings.Contains(m.View(), "more lines below") {
+		t.Fatal("scrolled view lacks the indicator")
+	}
+
+	// New messages keep a scrolled-up view in place.
+	m.Update(Message{Type: systemMsg, Body: "line 20"})
+	if got := visible(); got[len(got)-1] != up[len(up)-1] {
+		t.Fatalf("view moved on a new message: %q", got)
+	}
+
+	m.Update(tea.KeyMsg{Type: tea.KeyCtrlHome})
+	if top := visible(); !strings.Contains(strings.Join(top, "\n"), "line 00") {
+		t.Fatalf("Ctrl+Home did not reach the top: %q", top)
+	}
+	m.Update(tea.KeyMsg{Type: tea.KeyPgDown})
+	m.Update(tea.KeyMsg{Type: tea.KeyCtrlEnd})
+	if m.offset != 0 || !strings.Contains(strings.Join(visible(), "\n"), "line 20") {
+		t.Fatalf("Ctrl+End left offset %d", m.offset)
+	}
+	if strings.Contains(m.View(), "more lines below") {
+		t.Fatal("bottom view shows the indicator")
+	}
+}
+
+func TestGroupWindow(t *testing.T) {
+	for _, tc := range []struct {
+		window string
+		blocks int
+	}{
+		{"", 1},
+		{"30s", 1},
+		{"5s", 2},
+		{"off", 2},
+		{"0s", 2},
+	} {
+		m := newBubbleModel("alice", nil, nil, uiOptionsFrom(config.Config{GroupWindow: tc.window}))
+		m.Update(Message{ID: newMessageID(), Type: chatMsg, From: "bob", Body: "one", Timestamp: 1700000000})
+		m.Update(Message{ID: newMessageID(), Type: chatMsg, From: "bob", Body: "two", Timestamp: 1700000010})
+		if got := len(m.history); got != tc.blocks {
+			t.Errorf("window %q: %d blocks, want %d", tc.window, got, tc.blocks)
+		}
+	}
+}
+
+func TestTimeFormatGolden(t *testing.T) {
+	msg := Message{Type: chatMsg, From: "bob", Body: "hi", Timestamp: 1700000000}
+	for layout, golden := range map[string]string{
+		"":                 "+ [22:13:20] @bob\n| hi\n`",
+		"3:04PM":           "+ [10:13PM] @bob\n| hi\n`",
+		"2006-01-02 15:04": "+ [2023-11-14 22:13] @bob\n| hi\n`",
+		"no fields":        "+ [22:13:20] @bob\n| hi\n`",
+		"15:04\n":          "+ [22:13:20] @bob\n| hi\n`",
+	} {
+		opts := uiOptionsFrom(config.Config{Theme: "mono", UTC: true, ASCII: true, TimeFormat: layout})
+		if got := renderBlockString(opts, renderMessage(opts, "alice", msg)); got != golden {
+			t.Errorf("layout %q:\n got %q\nwant %q", layout, got, golden)
+		}
+	}
+
+	opts := uiOptionsFrom(config.Config{Theme: "mono", UTC: true, ASCII: true, TimeFormat: "3:04PM"})
+	if got := renderBlockString(opts, renderSystem(opts, "notice")); !strings.Contains(got, "AM]") && !strings.Contains(got, "PM]") {
+		t.Errorf("system block ignores the layout: %q", got)
+	}
+}
+
+func TestInvalidTimeFormatWarnsOnce(t *testing.T) {
+	s := newTestSession(t, config.Config{Name: "alice", TimeFormat: "no fields"})
+	var warnings int
+	for _, msg := range drainEvents(s) {
+		if msg.Type == systemMsg && strings.Contains(msg.Body, `timeFormat "no fields"`) {
+			warnings++
+		}
+	}
+	if warnings != 1 {
+		t.Fatalf("%d warnings, want 1", warnings)
+	}
+}
+
+// trackedSize sums the recorded size of every scrollback block.
+func trackedSize(m *bubbleModel) int {
+	n := 0
+	for _, blk := range m.history {
+		n += blk.size
+	}
+	return n
+}
+
+func TestScrollbackRuneBudget(t *testing.T) {
+	const budget = 10000
+	m := newBubbleModel("alice", nil, nil, uiOptionsFrom(config.Config{ScrollbackSize: budget, GroupWindow: "off"}))
+	line := strings.Repeat("é", 1000)
+	for i := range 100 {
+		m.Update(Message{ID: newMessageID(), Type: chatMsg, From: "bob", Body: fmt.Sprintf("%03d %s", i, line)})
+		if m.size > budget {
+			t.Fatalf("after %d blocks size %d exceeds the budget", i+1, m.size)
+		}
+		if m.size != trackedSize(m) {
+			t.Fatalf("size %d disagrees with blocks totalling %d", m.size, trackedSize(m))
+		}
+	}
+	if len(m.history) >= 100 || len(m.history) == 0 {
+		t.Fatalf("%d blocks kept", len(m.history))
+	}
+	if last := m.history[len(m.history)-1]; !strings.Contains(renderBlockString(m.opts, last), "099 ") {
+		t.Fatal("newest block was evicted")
+	}
+
+	// One block larger than the whole budget is still shown.
+	m.Update(Message{ID: newMessageID(), Type: chatMsg, From: "carol", Body: strings.Repeat("x", 2*budget)})
+	if len(m.history) != 1 || m.size != trackedSize(m) {
+		t.Fatalf("oversized block: %d blocks, size %d", len(m.history), m.size)
+	}
+}
+
+func TestScrollbackBlockCap(t *testing.T) {
+	m := newBubbleModel("alice", nil, nil, uiOptionsFrom(config.Config{GroupWindow: "off"}))
+	for i := range maxHistoryBlocks + 100 {
+		m.Update(Message{ID: newMessageID(), Type: chatMsg, From: "bob", Body: strconv.Itoa(i)})
+	}
+	if len(m.history) > maxHistoryBlocks {
+		t.Fatalf("%d blocks kept, cap is %d", len(m.history), maxHistoryBlocks)
+	}
+	if m.size != trackedSize(m) {
+		t.Fatalf("size %d disagrees with blocks totalling %d", m.size, trackedSize(m))
+	}
+}
+
+func TestClearEmptiesScrollback(t *testing.T) {
+	m := newBubbleModel("alice", nil, nil, uiOptionsFrom(config.Config{}))
+	m.Update(Message{ID: newMessageID(), Type: chatMsg, From: "bob", Body: "before"})
+	m.Update(Message{Type: clearMsg})
+	if len(m.history) != 0 || m.size != 0 || m.offset != 0 {
+		t.Fatalf("after clear: %d blocks, size %d, offset %d", len(m.history), m.size, m.offset)
+	}
+	if strings.Contains(m.View(), "before") {
+		t.Fatal("cleared message still rendered")
+	}
+
+	// The next message from the same sender opens a new group.
+	m.Update(Message{ID: newMessageID(), Type: chatMsg, From: "bob", Body: "after"})
+	if len(m.history) != 1 || len(m.history[0].entries) != 1 || m.history[0].header == "" {
+		t.Fatalf("post-clear history = %+v, want one fresh block", m.history)
+	}
+	if m.size != trackedSize(m) {
+		t.Fatalf("size %d disagrees with blocks totalling %d", m.size, trackedSize(m))
+	}
+}
@@ -30,6 +30,16 @@ func (c *CLI) Run(args []string) error {
 		return c.runInit(args[1:])
 	case "with":
 		return c.runWith(args[1:])
+	case "rename":
+		return c.runRename(args[1:])
+	case "delete":
+		return c.runDelete(args[1:])
+	case "list":
+		return c.runList(args[1:])
+	case "export":
+		return c.runExport(args[1:])
+	case "import":
+		return c.runImport(args[1:])
 	default:
 		return c.runChat(args)
 	}
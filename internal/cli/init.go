@@ -34,7 +34,10 @@ func (c *CLI) runInit(args []string) error {
 
 	current, err := config.ResolveProfile(store, "")
 	if err != nil {
-		return err
+		// Let init repair a saved default that no longer validates.
+		fmt.Fprintf(c.stderr(), "%v\n", err)
+		base, _ := store.Default()
+		current = config.Normalize(base)
 	}
 
 	reader := bufio.NewReader(c.stdin())
@@ -59,6 +62,9 @@ func (c *CLI) runInit(args []string) error {
 	peers := parsePeers(peersRaw)
 
 	snapshot := config.Snapshot(name, listen, secret, peers)
+	if err := config.Validate(snapshot); err != nil {
+		return fmt.Errorf("invalid config: %w", err)
+	}
 
 	if err := store.SaveDefault(snapshot); err != nil {
 		return fmt.Errorf("save default config: %w", err)
@@ -0,0 +1,109 @@
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+
+	"yap/internal/config"
+)
+
+func (c *CLI) runRename(args []string) error {
+	fs := flag.NewFlagSet("rename", flag.ContinueOnError)
+	fs.SetOutput(c.stderr())
+	configPath := fs.String("config", config.DefaultPath(), "path to yap config file")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 2 {
+		return errors.New("usage: yap rename [-config path] <old> <new>")
+	}
+
+	store, err := c.openStore(*configPath)
+	if err != nil {
+		return err
+	}
+
+	oldName, newName := fs.Arg(0), fs.Arg(1)
+	if err := store.Rename(oldName, newName); err != nil {
+		return fmt.Errorf("rename config: %w", err)
+	}
+
+	fmt.Fprintf(c.stdout(), "Renamed config %q to %q\n", oldName, newName)
+	return nil
+}
+
+func (c *CLI) runDelete(args []string) error {
+	fs := flag.NewFlagSet("delete", flag.ContinueOnError)
+	fs.SetOutput(c.stderr())
+	configPath := fs.String("config", config.DefaultPath(), "path to yap config file")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return errors.New("usage: yap delete [-config path] <name>")
+	}
+
+	store, err := c.openStore(*configPath)
+	if err != nil {
+		return err
+	}
+
+	name := fs.Arg(0)
+	if err := store.Delete(name); err != nil {
+		return fmt.Errorf("delete config: %w", err)
+	}
+
+	fmt.Fprintf(c.stdout(), "Deleted config %q\n", name)
+	return nil
+}
+
+func (c *CLI) runList(args []string) error {
+	fs := flag.NewFlagSet("list", flag.ContinueOnError)
+	fs.SetOutput(c.stderr())
+	configPath := fs.String("config", config.DefaultPath(), "path to yap config file")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 0 {
+		return errors.New("usage: yap list [-config path]")
+	}
+
+	store, err := c.openStore(*configPath)
+	if err != nil {
+		return err
+	}
+
+	names := store.Names()
+	if len(names) == 0 {
+		fmt.Fprintf(c.stdout(), "No saved configs in %s\n", store.Path())
+		return nil
+	}
+	for _, name := range names {
+		cfg, _ := store.Load(name)
+		marker := " "
+		if name == "default" {
+			marker = "*"
+		}
+		fmt.Fprintf(c.stdout(), "%s %s: %s\n", marker, name, config.Digest(cfg))
+	}
+	return nil
+}
+
+// openStore loads the config store for profile management subcommands.
+func (c *CLI) openStore(path string) (config.Store, error) {
+	if path == "" {
+		return nil, errors.New("config path is required; use -config to set one")
+	}
+	store, err := config.Load(path)
+	if err != nil {
+		return nil, err
+	}
+	if store == nil {
+		return nil, errors.New("config storage unavailable")
+	}
+	return store, nil
+}
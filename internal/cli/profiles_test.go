@@ -0,0 +1,111 @@
+package cli
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"yap/internal/config"
+)
+
+// saveProfiles writes the named profiles to a fresh config file.
+func saveProfiles(t *testing.T, names ...string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "yap.json")
+	store, err := config.Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range names {
+		if err := store.Save(name, config.Config{Name: name}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return path
+}
+
+func TestRenameCommand(t *testing.T) {
+	path := saveProfiles(t, "team")
+	var out bytes.Buffer
+	if err := newTestCLI(&out).Run([]string{"rename", "-config", path, "team", "crew"}); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), `Renamed config "team" to "crew"`) {
+		t.Fatalf("output %q", out.String())
+	}
+	store, err := config.Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := store.Load("crew"); !ok {
+		t.Fatal("profile not renamed on disk")
+	}
+
+	if err := newTestCLI(&out).Run([]string{"rename", "-config", path, "crew"}); err == nil || !strings.Contains(err.Error(), "usage") {
+		t.Fatalf("missing argument err = %v", err)
+	}
+}
+
+func TestDeleteCommand(t *testing.T) {
+	path := saveProfiles(t, "team")
+	var out bytes.Buffer
+	if err := newTestCLI(&out).Run([]string{"delete", "-config", path, "team"}); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), `Deleted config "team"`) {
+		t.Fatalf("output %q", out.String())
+	}
+	store, err := config.Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := store.Load("team"); ok {
+		t.Fatal("profile not deleted on disk")
+	}
+
+	err = newTestCLI(&out).Run([]string{"delete", "-config", path, "team"})
+	if err == nil || !strings.Contains(err.Error(), `unknown config "team"`) {
+		t.Fatalf("missing profile err = %v", err)
+	}
+}
+
+func TestListCommand(t *testing.T) {
+	path := saveProfiles(t)
+	var out bytes.Buffer
+	if err := newTestCLI(&out).Run([]string{"list", "-config", path}); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(out.String(), "No saved configs in ") {
+		t.Fatalf("empty output %q", out.String())
+	}
+
+	path = saveProfiles(t, "team", "crew")
+	store, err := config.Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.SaveDefault(config.Config{Name: "alice", Secret: "s"}); err != nil {
+		t.Fatal(err)
+	}
+	out.Reset()
+	if err := newTestCLI(&out).Run([]string{"list", "-config", path}); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("output %q", out.String())
+	}
+	for i, prefix := range []string{"  crew: crew,", "* default: alice,", "  team: team,"} {
+		if !strings.HasPrefix(lines[i], prefix) {
+			t.Errorf("line %d = %q, want prefix %q", i, lines[i], prefix)
+		}
+	}
+	if !strings.Contains(lines[1], "encryption on") {
+		t.Errorf("default line %q", lines[1])
+	}
+
+	if err := newTestCLI(&out).Run([]string{"list", "-config", path, "extra"}); err == nil || !strings.Contains(err.Error(), "usage") {
+		t.Fatalf("extra argument err = %v", err)
+	}
+}
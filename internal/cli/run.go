@@ -35,21 +35,53 @@ func (c *CLI) resolveArgs(args []string) (config.Config, config.Store, error) {
 
 	name := fs.String("name", "", "your chat display name")
 	listen := fs.String("listen", "", "UDP address to listen on")
+	advertise := fs.String("advertise", "", "externally reachable address to announce to peers")
 	secret := fs.String("secret", "", "shared secret for end-to-end encryption")
 	configPath := fs.String("config", config.DefaultPath(), "path to yap config file")
 	profile := fs.String("group", "", "saved config name to load")
+	ascii := fs.Bool("ascii", false, "draw borders with ASCII characters only")
+	plain := fs.Bool("plain", false, "print messages as plain lines instead of the terminal UI")
+	ephemeral := fs.Bool("ephemeral", false, "run without reading or writing any config file")
+	debug := fs.Bool("debug", false, "enable protocol debugging commands")
+	discover := fs.Bool("discover", false, "find peers on the local network by multicast")
+	health := fs.String("healthz", "", "TCP address to serve HTTP health checks on at /healthz")
 	fs.Var(&peers, "peer", "peer UDP address (repeatable)")
 
 	if err := fs.Parse(args); err != nil {
 		return config.Config{}, nil, err
 	}
 
+	overrides := config.Config{
+		Name:         *name,
+		Listen:       *listen,
+		Secret:       *secret,
+		Peers:        peers.slice(),
+		Advertise:    *advertise,
+		ASCII:        *ascii,
+		Plain:        *plain,
+		Debug:        *debug,
+		Discover:     *discover,
+		HealthListen: *health,
+	}
+
+	trimmedProfile := strings.TrimSpace(*profile)
+	if *ephemeral {
+		if trimmedProfile != "" {
+			return config.Config{}, nil, fmt.Errorf("group %q cannot be loaded in ephemeral mode", trimmedProfile)
+		}
+		if err := config.Validate(overrides); err != nil {
+			return config.Config{}, nil, fmt.Errorf("invalid flags: %w", err)
+		}
+		resolved := config.Normalize(overrides)
+		resolved.Ephemeral = true
+		return resolved, nil, nil
+	}
+
 	store, err := config.Load(*configPath)
 	if err != nil {
 		return config.Config{}, nil, err
 	}
 
-	trimmedProfile := strings.TrimSpace(*profile)
 	if store == nil && trimmedProfile != "" {
 		return config.Config{}, nil, fmt.Errorf("group %q requested but config %q not found", trimmedProfile, *configPath)
 	}
@@ -59,13 +91,9 @@ func (c *CLI) resolveArgs(args []string) (config.Config, config.Store, error) {
 		return config.Config{}, store, err
 	}
 
-	overrides := config.Config{
-		Name:   *name,
-		Listen: *listen,
-		Secret: *secret,
-		Peers:  peers.slice(),
+	if err := config.Validate(overrides); err != nil {
+		return config.Config{}, store, fmt.Errorf("invalid flags: %w", err)
 	}
-
 	merged := config.Merge(base, overrides)
 	return config.Normalize(merged), store, nil
 }
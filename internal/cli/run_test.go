@@ -0,0 +1,70 @@
+package cli
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// newTestCLI returns a CLI whose output is collected in out.
+func newTestCLI(out *bytes.Buffer) *CLI {
+	return New(strings.NewReader(""), out, out, nil)
+}
+
+func TestEphemeralNeverTouchesConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "yap.json")
+	var out bytes.Buffer
+	cfg, store, err := newTestCLI(&out).resolveArgs([]string{"-ephemeral", "-config", path, "-name", "ghost"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if store != nil || !cfg.Ephemeral || cfg.Name != "ghost" {
+		t.Fatalf("got cfg %+v and store %v, want an ephemeral config without a store", cfg, store)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("config file touched: %v", err)
+	}
+}
+
+func TestEphemeralRejectsGroup(t *testing.T) {
+	var out bytes.Buffer
+	_, _, err := newTestCLI(&out).resolveArgs([]string{"-ephemeral", "-group", "team"})
+	if err == nil || !strings.Contains(err.Error(), "ephemeral") {
+		t.Fatalf("err = %v, want an ephemeral-mode error", err)
+	}
+}
+
+func TestInvalidFlagsAreReported(t *testing.T) {
+	var out bytes.Buffer
+	_, _, err := newTestCLI(&out).resolveArgs([]string{"-config", filepath.Join(t.TempDir(), "yap.json"), "-peer", "10.0.0.2:0"})
+	if err == nil || !strings.Contains(err.Error(), "invalid flags") || !strings.Contains(err.Error(), "port 0") {
+		t.Fatalf("err = %v, want an invalid-flags error naming the peer", err)
+	}
+}
+
+func TestPlainFlag(t *testing.T) {
+	var out bytes.Buffer
+	cfg, _, err := newTestCLI(&out).resolveArgs([]string{"-ephemeral", "-plain"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cfg.Plain {
+		t.Fatal("-plain not applied")
+	}
+}
+
+func TestHealthzFlag(t *testing.T) {
+	var out bytes.Buffer
+	cfg, _, err := newTestCLI(&out).resolveArgs([]string{"-ephemeral", "-healthz", "127.0.0.1:8080"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.HealthListen != "127.0.0.1:8080" {
+		t.Fatalf("healthListen = %q", cfg.HealthListen)
+	}
+	if _, _, err := newTestCLI(&out).resolveArgs([]string{"-ephemeral", "-healthz", "nowhere"}); err == nil {
+		t.Fatal("-healthz without a port accepted")
+	}
+}
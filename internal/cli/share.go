@@ -0,0 +1,108 @@
+package cli
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"strings"
+
+	"yap/internal/config"
+)
+
+func (c *CLI) runExport(args []string) error {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	fs.SetOutput(c.stderr())
+	configPath := fs.String("config", config.DefaultPath(), "path to yap config file")
+	noSecret := fs.Bool("no-secret", false, "leave the shared secret out of the blob")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return errors.New("usage: yap export [-config path] [-no-secret] <name>")
+	}
+
+	store, err := c.openStore(*configPath)
+	if err != nil {
+		return err
+	}
+
+	name := fs.Arg(0)
+	cfg, ok := store.Load(name)
+	if !ok {
+		return fmt.Errorf("unknown config %q", name)
+	}
+	blob, err := config.Export(cfg, !*noSecret)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintln(c.stdout(), blob)
+	return nil
+}
+
+func (c *CLI) runImport(args []string) error {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	fs.SetOutput(c.stderr())
+	configPath := fs.String("config", config.DefaultPath(), "path to yap config file")
+	force := fs.Bool("force", false, "overwrite an existing config without asking")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 2 {
+		return errors.New("usage: yap import [-config path] [-force] <blob> <name>")
+	}
+
+	cfg, err := config.Import(fs.Arg(0))
+	if err != nil {
+		return fmt.Errorf("import config: %w", err)
+	}
+
+	store, err := c.openStore(*configPath)
+	if err != nil {
+		return err
+	}
+
+	name := strings.TrimSpace(fs.Arg(1))
+	isDefault := strings.EqualFold(name, "default")
+	if existing, exists := store.Load(name); exists {
+		if !*force {
+			ok, err := c.confirm(fmt.Sprintf("Overwrite existing config %q?", name))
+			if err != nil {
+				return err
+			}
+			if !ok {
+				return errors.New("import cancelled")
+			}
+		}
+		// The blob only carries shareable fields; keep local settings.
+		cfg = config.Merge(existing, cfg)
+	}
+
+	if isDefault {
+		err = store.SaveDefault(cfg)
+	} else {
+		err = store.Save(name, cfg)
+	}
+	if err != nil {
+		return fmt.Errorf("save imported config: %w", err)
+	}
+
+	fmt.Fprintf(c.stdout(), "Imported config %q\n", name)
+	for _, line := range config.Summary(cfg) {
+		fmt.Fprintln(c.stdout(), line)
+	}
+	return nil
+}
+
+// confirm asks a yes/no question on stdin, treating anything but yes as no.
+func (c *CLI) confirm(question string) (bool, error) {
+	answer, err := c.prompt(bufio.NewReader(c.stdin()), question+" [y/N]", "")
+	if err != nil {
+		return false, err
+	}
+	answer = strings.ToLower(answer)
+	return answer == "y" || answer == "yes", nil
+}
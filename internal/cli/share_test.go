@@ -0,0 +1,118 @@
+package cli
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"yap/internal/config"
+)
+
+func TestImportKeepsLocalSettings(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "yap.json")
+	store, err := config.Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	local := config.Config{Name: "me", Secret: "old-secret", Downloads: "/home/me/in", Blocked: []string{"10.0.0.9:4000"}}
+	if err := store.Save("team", local); err != nil {
+		t.Fatal(err)
+	}
+	blob, err := config.Export(config.Config{Name: "them", Secret: "new-secret", Peers: []string{"10.0.0.2:4000"}, AcceptFiles: "auto"}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	c := New(strings.NewReader(""), &out, &out, nil)
+	if err := c.Run([]string{"import", "-config", path, "-force", blob, "team"}); err != nil {
+		t.Fatalf("import: %v\n%s", err, out.String())
+	}
+
+	reloaded, err := config.Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, ok := reloaded.Load("team")
+	if !ok {
+		t.Fatal("profile missing after import")
+	}
+	if got.Secret != "new-secret" || got.Name != "them" {
+		t.Errorf("shared fields not applied: %+v", got)
+	}
+	if got.Downloads != "/home/me/in" || len(got.Blocked) != 1 || got.AcceptFiles != "" {
+		t.Errorf("local settings not kept: %+v", got)
+	}
+}
+
+func TestExportImportCommands(t *testing.T) {
+	src := saveProfiles(t)
+	store, err := config.Load(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Save("team", config.Config{Name: "alice", Secret: "s3cret", Peers: []string{"10.0.0.2:4000", "10.0.0.3:4000"}}); err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	if err := newTestCLI(&out).Run([]string{"export", "-config", src, "team"}); err != nil {
+		t.Fatal(err)
+	}
+	blob := strings.TrimSpace(out.String())
+
+	dst := saveProfiles(t)
+	out.Reset()
+	if err := newTestCLI(&out).Run([]string{"import", "-config", dst, blob, "crew"}); err != nil {
+		t.Fatalf("import: %v\n%s", err, out.String())
+	}
+	imported, err := config.Load(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, ok := imported.Load("crew")
+	if !ok || got.Name != "alice" || got.Secret != "s3cret" || strings.Join(got.Peers, ",") != "10.0.0.2:4000,10.0.0.3:4000" {
+		t.Fatalf("imported profile = %+v, %v", got, ok)
+	}
+}
+
+func TestImportOverDefaultAsks(t *testing.T) {
+	path := saveProfiles(t)
+	store, err := config.Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.SaveDefault(config.Config{Name: "me"}); err != nil {
+		t.Fatal(err)
+	}
+	blob, err := config.Export(config.Config{Name: "them"}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	declined := New(strings.NewReader("n\n"), &out, &out, nil)
+	if err := declined.Run([]string{"import", "-config", path, blob, "default"}); err == nil || !strings.Contains(err.Error(), "cancelled") {
+		t.Fatalf("declined import err = %v", err)
+	}
+	if cfg, _ := mustLoad(t, path).Default(); cfg.Name != "me" {
+		t.Fatalf("default overwritten without confirmation: %+v", cfg)
+	}
+
+	accepted := New(strings.NewReader("y\n"), &out, &out, nil)
+	if err := accepted.Run([]string{"import", "-config", path, blob, "default"}); err != nil {
+		t.Fatal(err)
+	}
+	if cfg, _ := mustLoad(t, path).Default(); cfg.Name != "them" {
+		t.Fatalf("confirmed import not applied: %+v", cfg)
+	}
+}
+
+func mustLoad(t *testing.T, path string) config.Store {
+	t.Helper()
+	store, err := config.Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return store
+}
@@ -1,11 +1,18 @@
 package config
 
 import (
+	"cmp"
+	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"maps"
+	"net"
+	"net/netip"
 	"os"
 	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -13,12 +20,146 @@ import (
 
 const DefaultListen = ":4000"
 
+// DefaultDiscoverGroup is the multicast group used for LAN discovery.
+const DefaultDiscoverGroup = "239.255.42.99:4001"
+
+// maxReadBuffer is the largest useful UDP receive buffer: one maximal datagram.
+const maxReadBuffer = 65535
+
 // Config represents chat runtime configuration.
 type Config struct {
 	Name   string   `json:"name,omitempty"`
-	Listen string   `json:"listen,omitempty"`
+	Listen string   `json:"listen,omitempty"` // comma-separated to bind several sockets
 	Secret string   `json:"secret,omitempty"`
 	Peers  []string `json:"peers,omitempty"`
+	// Cipher selects the encryption suite: "aes-gcm" (default) or
+	// "chacha20-poly1305", which is faster without AES hardware support.
+	// Every peer in a group must use the same one.
+	Cipher string `json:"cipher,omitempty"`
+	// KDF selects how the secret becomes a key: "sha256" (legacy default) or
+	// "argon2id", which resists brute-forcing weak secrets. Every peer in a
+	// group must use the same one.
+	KDF string `json:"kdf,omitempty"`
+	// GroupID salts the argon2id derivation; peers must share it.
+	GroupID string `json:"groupId,omitempty"`
+	// Advertise is the externally reachable address announced to peers when it differs from Listen.
+	Advertise string `json:"advertise,omitempty"`
+	// Keepalive is how often NAT keepalive packets are sent, e.g. "25s"; "off" disables them.
+	Keepalive string `json:"keepalive,omitempty"`
+	// Heartbeat is how often liveness pings are broadcast, e.g. "15s"; "off" disables them.
+	Heartbeat string `json:"heartbeat,omitempty"`
+	// PeerTimeout demotes active peers that have been silent for this long, e.g. "1m".
+	PeerTimeout string `json:"peerTimeout,omitempty"`
+	// DedupWindow is how long message IDs are remembered for duplicate suppression, e.g. "5m".
+	DedupWindow string `json:"dedupWindow,omitempty"`
+	// PersistSeen saves recently seen message IDs beside the config file across restarts.
+	PersistSeen bool `json:"persistSeen,omitempty"`
+	// Outbox is how many chat messages are buffered per pending peer for replay; zero disables it.
+	Outbox int `json:"outbox,omitempty"`
+	// History is how many chat messages are kept for /history and saved beside
+	// the config file; zero disables it.
+	History int `json:"history,omitempty"`
+	// ShareHistory replays recent history to peers that join through us.
+	ShareHistory bool `json:"shareHistory,omitempty"`
+	// RoomColors tags outgoing chat with the active group and color-codes tagged messages.
+	RoomColors bool `json:"roomColors,omitempty"`
+	// ASCII forces plain ASCII borders and glyphs for terminals without Unicode support.
+	ASCII bool `json:"ascii,omitempty"`
+	// Plain prints messages as uncolored lines instead of running the
+	// terminal UI; it is implied when stdout is not a terminal.
+	Plain bool `json:"plain,omitempty"`
+	// Prompt replaces the input prompt glyph.
+	Prompt string `json:"prompt,omitempty"`
+	// TimestampColor is a 256-color palette index used for message timestamps.
+	TimestampColor string `json:"timestampColor,omitempty"`
+	// HideTimestamps omits timestamps from message headers.
+	HideTimestamps bool `json:"hideTimestamps,omitempty"`
+	// HideNameTags stops suffixing names shared by several peers with their
+	// address, e.g. "@bob#4001".
+	HideNameTags bool `json:"hideNameTags,omitempty"`
+	// Format styles *bold*, _underline_, and `code` spans and underlines URLs
+	// in chat messages; a backslash before a marker shows it literally.
+	Format bool `json:"format,omitempty"`
+	// TimeFormat is the Go time layout for message timestamps, e.g.
+	// "Jan 2 3:04PM"; blank or invalid layouts fall back to "15:04:05".
+	TimeFormat string `json:"timeFormat,omitempty"`
+	// UTC shows timestamps in UTC instead of local time.
+	UTC bool `json:"utc,omitempty"`
+	// GroupWindow is how close consecutive messages from one sender must be to
+	// share a block, e.g. "30s" (default); "off" disables grouping.
+	GroupWindow string `json:"groupWindow,omitempty"`
+	// ScrollbackSize caps how many characters of rendered messages the UI
+	// keeps; zero selects about four million.
+	ScrollbackSize int `json:"scrollbackSize,omitempty"`
+	// Theme selects the UI palette: "dark" (default), "light", or "mono" for
+	// output without escape codes.
+	Theme string `json:"theme,omitempty"`
+	// Highlight lists keywords whose chat messages are accented in the UI.
+	Highlight []string `json:"highlight,omitempty"`
+	// Mute lists keywords whose chat messages are hidden in the UI.
+	Mute []string `json:"mute,omitempty"`
+	// FoldNames matches peer names case-insensitively while keeping their display case.
+	FoldNames bool `json:"foldNames,omitempty"`
+	// LogFile appends every message and membership transition to this file as JSON lines.
+	LogFile string `json:"logFile,omitempty"`
+	// Debug enables protocol debugging commands such as /lastpacket.
+	Debug bool `json:"debug,omitempty"`
+	// ReadReceipts tells senders when their messages are shown in this UI.
+	ReadReceipts bool `json:"readReceipts,omitempty"`
+	// Reliable resends chat messages until each direct recipient acknowledges
+	// them, and acknowledges those it receives. Peers must enable it too.
+	Reliable bool `json:"reliable,omitempty"`
+	// Blocked lists peer addresses removed with /kick; they are never contacted
+	// or accepted as members.
+	Blocked []string `json:"blocked,omitempty"`
+	// PeerNames maps peer addresses to the display names last seen for them,
+	// so saved peers are labelled before they speak again.
+	PeerNames map[string]string `json:"peerNames,omitempty"`
+	// ReadBuffer is the UDP receive buffer size in bytes; longer datagrams
+	// are truncated. Zero selects 4096.
+	ReadBuffer int `json:"readBuffer,omitempty"`
+	// GossipQuorum is how many distinct peers must mention an address before it is contacted.
+	GossipQuorum int `json:"gossipQuorum,omitempty"`
+	// MaxPeers caps how many peers are tracked; beyond it the least recently
+	// seen pending peer is forgotten first. Zero means no limit.
+	MaxPeers int `json:"maxPeers,omitempty"`
+	// Fanout caps how many peers each relayed message is forwarded to,
+	// chosen at random; zero forwards to every peer not known to have it.
+	Fanout int `json:"fanout,omitempty"`
+	// SendWorkers bounds how many peers a broadcast writes to at once; zero
+	// selects 8 and 1 sends serially.
+	SendWorkers int `json:"sendWorkers,omitempty"`
+	// NoForward makes this node a spoke: it never relays other peers'
+	// messages and only talks to peers it was given or that contact it,
+	// ignoring addresses learned through gossip. Its messages reach the rest
+	// of the group only through a Relay peer, and it hears only what such a
+	// peer relays to it.
+	NoForward bool `json:"noForward,omitempty"`
+	// Relay makes this node a hub: it forwards every message to all of its
+	// peers, ignoring Fanout, so NoForward spokes reach each other through it.
+	// If the hub is down, spokes cannot hear each other.
+	Relay bool `json:"relay,omitempty"`
+	// Discover announces this node on the local network and contacts other
+	// nodes that announce themselves with the same secret.
+	Discover bool `json:"discover,omitempty"`
+	// DiscoverGroup is the multicast host:port used for discovery; empty
+	// selects DefaultDiscoverGroup.
+	DiscoverGroup string `json:"discoverGroup,omitempty"`
+	// AcceptFiles decides what happens to files peers send with /send: "ask"
+	// (default) holds each one until /accept, "auto" saves it straight away,
+	// and "never" discards it.
+	AcceptFiles string `json:"acceptFiles,omitempty"`
+	// Downloads is the directory received files are saved to; empty selects
+	// DefaultDownloads.
+	Downloads string `json:"downloads,omitempty"`
+	// HealthListen is the TCP host:port serving the /health report over HTTP
+	// at /healthz for headless monitoring; empty disables it.
+	HealthListen string `json:"healthListen,omitempty"`
+
+	// Profile names the saved config the runtime values were resolved from.
+	Profile string `json:"-"`
+	// Ephemeral sessions never read or write the config file.
+	Ephemeral bool `json:"-"`
 }
 
 // Store provides access to persisted configurations.
@@ -27,6 +168,10 @@ type Store interface {
 	Load(name string) (Config, bool)
 	Save(name string, cfg Config) error
 	SaveDefault(cfg Config) error
+	Rename(oldName, newName string) error
+	Delete(name string) error
+	Names() []string
+	Path() string
 }
 
 type fileStore struct {
@@ -78,6 +223,13 @@ func ResolveProfile(store Store, name string) (Config, error) {
 		return Config{}, fmt.Errorf("unknown config %q", trimmed)
 	}
 
+	merged.Profile = "default"
+	if trimmed != "" {
+		merged.Profile = trimmed
+	}
+	if err := Validate(merged); err != nil {
+		return Config{}, fmt.Errorf("config %q: %w", merged.Profile, err)
+	}
 	return Normalize(merged), nil
 }
 
@@ -93,6 +245,149 @@ func Merge(base, overlay Config) Config {
 	if overlay.Secret != "" {
 		result.Secret = overlay.Secret
 	}
+	if overlay.Cipher != "" {
+		result.Cipher = overlay.Cipher
+	}
+	if overlay.KDF != "" {
+		result.KDF = overlay.KDF
+	}
+	if overlay.GroupID != "" {
+		result.GroupID = overlay.GroupID
+	}
+	if overlay.Advertise != "" {
+		result.Advertise = overlay.Advertise
+	}
+	if overlay.Keepalive != "" {
+		result.Keepalive = overlay.Keepalive
+	}
+	if overlay.Heartbeat != "" {
+		result.Heartbeat = overlay.Heartbeat
+	}
+	if overlay.PeerTimeout != "" {
+		result.PeerTimeout = overlay.PeerTimeout
+	}
+	if overlay.DedupWindow != "" {
+		result.DedupWindow = overlay.DedupWindow
+	}
+	if overlay.PersistSeen {
+		result.PersistSeen = true
+	}
+	if overlay.Outbox != 0 {
+		result.Outbox = overlay.Outbox
+	}
+	if overlay.History != 0 {
+		result.History = overlay.History
+	}
+	if overlay.ShareHistory {
+		result.ShareHistory = true
+	}
+	if overlay.RoomColors {
+		result.RoomColors = true
+	}
+	if overlay.ASCII {
+		result.ASCII = true
+	}
+	if overlay.Plain {
+		result.Plain = true
+	}
+	if overlay.Prompt != "" {
+		result.Prompt = overlay.Prompt
+	}
+	if overlay.TimestampColor != "" {
+		result.TimestampColor = overlay.TimestampColor
+	}
+	if overlay.HideTimestamps {
+		result.HideTimestamps = true
+	}
+	if overlay.HideNameTags {
+		result.HideNameTags = true
+	}
+	if overlay.Format {
+		result.Format = true
+	}
+	if overlay.TimeFormat != "" {
+		result.TimeFormat = overlay.TimeFormat
+	}
+	if overlay.UTC {
+		result.UTC = true
+	}
+	if len(overlay.Highlight) > 0 {
+		result.Highlight = append([]string(nil), overlay.Highlight...)
+	}
+	if len(overlay.Mute) > 0 {
+		result.Mute = append([]string(nil), overlay.Mute...)
+	}
+	if overlay.FoldNames {
+		result.FoldNames = true
+	}
+	if overlay.LogFile != "" {
+		result.LogFile = overlay.LogFile
+	}
+	if overlay.GroupWindow != "" {
+		result.GroupWindow = overlay.GroupWindow
+	}
+	if overlay.ScrollbackSize != 0 {
+		result.ScrollbackSize = overlay.ScrollbackSize
+	}
+	if overlay.Theme != "" {
+		result.Theme = overlay.Theme
+	}
+	if overlay.Debug {
+		result.Debug = true
+	}
+	if overlay.ReadReceipts {
+		result.ReadReceipts = true
+	}
+	if overlay.Reliable {
+		result.Reliable = true
+	}
+	if len(overlay.Blocked) > 0 {
+		result.Blocked = append([]string(nil), overlay.Blocked...)
+	}
+	if len(overlay.PeerNames) > 0 {
+		names := maps.Clone(base.PeerNames)
+		if names == nil {
+			names = make(map[string]string, len(overlay.PeerNames))
+		}
+		maps.Copy(names, overlay.PeerNames)
+		result.PeerNames = names
+	}
+	if overlay.ReadBuffer != 0 {
+		result.ReadBuffer = overlay.ReadBuffer
+	}
+	if overlay.GossipQuorum != 0 {
+		result.GossipQuorum = overlay.GossipQuorum
+	}
+	if overlay.MaxPeers != 0 {
+		result.MaxPeers = overlay.MaxPeers
+	}
+	if overlay.Fanout != 0 {
+		result.Fanout = overlay.Fanout
+	}
+	if overlay.SendWorkers != 0 {
+		result.SendWorkers = overlay.SendWorkers
+	}
+	if overlay.NoForward {
+		result.NoForward = true
+	}
+	if overlay.Relay {
+		result.Relay = true
+	}
+	if overlay.Discover {
+		result.Discover = true
+	}
+	if overlay.DiscoverGroup != "" {
+		result.DiscoverGroup = overlay.DiscoverGroup
+	}
+	if overlay.AcceptFiles != "" {
+		result.AcceptFiles = overlay.AcceptFiles
+	}
+	if overlay.Downloads != "" {
+		result.Downloads = overlay.Downloads
+	}
+	if overlay.HealthListen != "" {
+		result.HealthListen = overlay.HealthListen
+	}
 	result.Peers = MergePeers(base.Peers, overlay.Peers)
 	return result
 }
@@ -102,13 +397,119 @@ func Normalize(cfg Config) Config {
 	if cfg.Listen == "" {
 		cfg.Listen = DefaultListen
 	}
+	cfg.Name = strings.Join(strings.Fields(cfg.Name), " ")
 	if cfg.Name == "" {
 		cfg.Name = defaultName()
 	}
 	cfg.Peers = MergePeers(cfg.Peers)
+	// Copy the keyword and block lists and peer names so sessions built from
+	// one Config never share them.
+	cfg.Highlight = slices.Clone(cfg.Highlight)
+	cfg.Mute = slices.Clone(cfg.Mute)
+	cfg.Blocked = slices.Clone(cfg.Blocked)
+	cfg.PeerNames = maps.Clone(cfg.PeerNames)
 	return cfg
 }
 
+// Validate reports malformed fields that would otherwise fail later at bind or
+// resolve time, joining every problem into one error. A blank Listen is
+// accepted because Normalize fills in DefaultListen.
+func Validate(cfg Config) error {
+	var errs []error
+	if strings.ContainsAny(cfg.Name, "\r\n") {
+		errs = append(errs, errors.New("name must not contain newlines"))
+	}
+	for _, part := range strings.Split(cfg.Listen, ",") {
+		if part = strings.TrimSpace(part); part == "" {
+			continue
+		}
+		if err := checkHostPort(part, true); err != nil {
+			errs = append(errs, fmt.Errorf("listen address %q: %w", part, err))
+		}
+	}
+	switch strings.ToLower(strings.TrimSpace(cfg.Cipher)) {
+	case "", "aes-gcm", "chacha20-poly1305":
+	default:
+		errs = append(errs, fmt.Errorf("cipher %q is not aes-gcm or chacha20-poly1305", cfg.Cipher))
+	}
+	switch strings.ToLower(strings.TrimSpace(cfg.KDF)) {
+	case "", "sha256", "argon2id":
+	default:
+		errs = append(errs, fmt.Errorf("kdf %q is not sha256 or argon2id", cfg.KDF))
+	}
+	if cfg.ReadBuffer < 0 || cfg.ReadBuffer > maxReadBuffer {
+		errs = append(errs, fmt.Errorf("readBuffer must be between 0 and %d bytes", maxReadBuffer))
+	}
+	if cfg.ScrollbackSize < 0 {
+		errs = append(errs, errors.New("scrollbackSize must not be negative"))
+	}
+	if cfg.MaxPeers < 0 {
+		errs = append(errs, errors.New("maxPeers must not be negative"))
+	}
+	if cfg.Fanout < 0 {
+		errs = append(errs, errors.New("fanout must not be negative"))
+	}
+	if cfg.SendWorkers < 0 {
+		errs = append(errs, errors.New("sendWorkers must not be negative"))
+	}
+	if cfg.NoForward && cfg.Relay {
+		errs = append(errs, errors.New("noForward and relay cannot both be set"))
+	}
+	if group := strings.TrimSpace(cfg.DiscoverGroup); group != "" {
+		if ap, err := netip.ParseAddrPort(group); err != nil || !ap.Addr().Is4() || !ap.Addr().IsMulticast() || ap.Port() == 0 {
+			errs = append(errs, fmt.Errorf("discoverGroup %q is not an IPv4 multicast host:port", group))
+		}
+	}
+	if health := strings.TrimSpace(cfg.HealthListen); health != "" {
+		if err := checkHostPort(health, true); err != nil {
+			errs = append(errs, fmt.Errorf("healthListen %q: %w", health, err))
+		}
+	}
+	switch strings.ToLower(strings.TrimSpace(cfg.AcceptFiles)) {
+	case "", "ask", "auto", "never":
+	default:
+		errs = append(errs, fmt.Errorf("acceptFiles %q must be ask, auto, or never", cfg.AcceptFiles))
+	}
+	for _, peer := range cfg.Peers {
+		trimmed := strings.TrimSpace(peer)
+		if trimmed == "" {
+			errs = append(errs, errors.New("peer address cannot be empty"))
+			continue
+		}
+		if err := checkHostPort(trimmed, false); err != nil {
+			errs = append(errs, fmt.Errorf("peer %q: %w", trimmed, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// checkHostPort checks that raw is a host:port pair with a numeric port. An
+// empty host, as in ":4000", is only valid for listening.
+func checkHostPort(raw string, listen bool) error {
+	host, port, err := net.SplitHostPort(raw)
+	if err != nil {
+		var addrErr *net.AddrError
+		if errors.As(err, &addrErr) {
+			return errors.New(addrErr.Err)
+		}
+		return err
+	}
+	if host == "" && !listen {
+		return errors.New("missing host")
+	}
+	if strings.ContainsAny(host, " \t\r\n") {
+		return errors.New("host contains whitespace")
+	}
+	n, err := strconv.ParseUint(port, 10, 16)
+	if err != nil {
+		return fmt.Errorf("invalid port %q", port)
+	}
+	if n == 0 && !listen {
+		return errors.New("port 0 cannot be dialed")
+	}
+	return nil
+}
+
 // MergePeers merges peer lists removing duplicates and blanks.
 func MergePeers(parts ...[]string) []string {
 	seen := make(map[string]struct{})
@@ -145,8 +546,21 @@ func Summary(cfg Config) []string {
 		"  name: " + cfg.Name,
 		"  listen: " + cfg.Listen,
 	}
+	if cfg.Advertise != "" {
+		lines = append(lines, "  advertise: "+cfg.Advertise)
+	}
 	if cfg.Secret != "" {
-		lines = append(lines, "  encryption: enabled")
+		var details []string
+		for _, setting := range []string{cfg.Cipher, cfg.KDF} {
+			if setting != "" {
+				details = append(details, setting)
+			}
+		}
+		if len(details) > 0 {
+			lines = append(lines, "  encryption: enabled ("+strings.Join(details, ", ")+")")
+		} else {
+			lines = append(lines, "  encryption: enabled")
+		}
 	} else {
 		lines = append(lines, "  encryption: disabled")
 	}
@@ -155,9 +569,123 @@ func Summary(cfg Config) []string {
 	} else {
 		lines = append(lines, "  peers: none configured yet")
 	}
+	if cfg.Discover {
+		lines = append(lines, "  discovery: "+cmp.Or(cfg.DiscoverGroup, DefaultDiscoverGroup))
+	}
+	if cfg.HealthListen != "" {
+		lines = append(lines, "  health: http://"+cfg.HealthListen+"/healthz")
+	}
+	switch {
+	case cfg.Relay:
+		lines = append(lines, "  forwarding: relay hub")
+	case cfg.NoForward:
+		lines = append(lines, "  forwarding: off (spoke)")
+	}
 	return lines
 }
 
+// Digest condenses a saved profile into one line for listings. Unset fields
+// are shown as the defaults they fall back to.
+func Digest(cfg Config) string {
+	name := cfg.Name
+	if name == "" {
+		name = "(default name)"
+	}
+	listen := cfg.Listen
+	if listen == "" {
+		listen = DefaultListen
+	}
+	encryption := "encryption off"
+	if cfg.Secret != "" {
+		encryption = "encryption on"
+	}
+	peers := fmt.Sprintf("%d peers", len(cfg.Peers))
+	if len(cfg.Peers) == 1 {
+		peers = "1 peer"
+	}
+	return strings.Join([]string{name, listen, encryption, peers}, ", ")
+}
+
+// sharedConfig holds the fields Export shares: what a peer needs to join the
+// group. Local state such as file paths, blocks, and learned names never
+// leaves the machine, and an imported blob cannot set it.
+type sharedConfig struct {
+	Name    string   `json:"name,omitempty"`
+	Listen  string   `json:"listen,omitempty"`
+	Secret  string   `json:"secret,omitempty"`
+	Peers   []string `json:"peers,omitempty"`
+	Cipher  string   `json:"cipher,omitempty"`
+	KDF     string   `json:"kdf,omitempty"`
+	GroupID string   `json:"groupId,omitempty"`
+}
+
+// Export encodes a profile's shareable fields as a single base64 blob,
+// leaving out the secret unless withSecret is set.
+func Export(cfg Config, withSecret bool) (string, error) {
+	shared := sharedConfig{
+		Name:    cfg.Name,
+		Listen:  cfg.Listen,
+		Peers:   slices.Clone(cfg.Peers),
+		Cipher:  cfg.Cipher,
+		KDF:     cfg.KDF,
+		GroupID: cfg.GroupID,
+	}
+	if withSecret {
+		shared.Secret = cfg.Secret
+	}
+	data, err := json.Marshal(shared)
+	if err != nil {
+		return "", fmt.Errorf("encode config: %w", err)
+	}
+	return base64.StdEncoding.EncodeToString(data), nil
+}
+
+// Import decodes a blob produced by Export and validates the profile inside.
+// Only the shareable fields are read; anything else in the blob is ignored.
+func Import(blob string) (Config, error) {
+	data, err := base64.StdEncoding.DecodeString(strings.TrimSpace(blob))
+	if err != nil {
+		return Config{}, fmt.Errorf("decode config: %w", err)
+	}
+	var shared sharedConfig
+	if err := json.Unmarshal(data, &shared); err != nil {
+		return Config{}, fmt.Errorf("parse config: %w", err)
+	}
+	cfg := Config{
+		Name:    shared.Name,
+		Listen:  shared.Listen,
+		Secret:  shared.Secret,
+		Peers:   shared.Peers,
+		Cipher:  shared.Cipher,
+		KDF:     shared.KDF,
+		GroupID: shared.GroupID,
+	}
+	if err := Validate(cfg); err != nil {
+		return Config{}, err
+	}
+	return cfg, nil
+}
+
+// Interval parses a duration setting. Blank values yield fallback, while "off"
+// or any zero duration disables the feature by returning zero.
+func Interval(raw string, fallback time.Duration) (time.Duration, error) {
+	trimmed := strings.TrimSpace(raw)
+	switch {
+	case trimmed == "":
+		return fallback, nil
+	case strings.EqualFold(trimmed, "off"):
+		return 0, nil
+	}
+	d, err := time.ParseDuration(trimmed)
+	if err != nil {
+		return fallback, fmt.Errorf("invalid interval %q: %w", trimmed, err)
+	}
+	if d < 0 {
+		return fallback, fmt.Errorf("invalid interval %q: must not be negative", trimmed)
+	}
+	return d, nil
+}
+
 // DefaultPath returns the default config file path in the user's home directory.
 func DefaultPath() string {
 	dir, err := os.UserHomeDir()
@@ -167,6 +695,20 @@ func DefaultPath() string {
 	return filepath.Join(dir, ".yap.json")
 }
 
+// DefaultDownloads returns the directory received files are saved to when
+// Config.Downloads is empty.
+func DefaultDownloads() string {
+	dir, err := os.UserHomeDir()
+	if err != nil {
+		return "yap-downloads"
+	}
+	return filepath.Join(dir, "Downloads", "yap")
+}
+
+func (f *fileStore) Path() string {
+	return f.path
+}
+
 func (f *fileStore) Save(name string, cfg Config) error {
 	trimmed := strings.TrimSpace(name)
 	if trimmed == "" {
@@ -183,13 +725,55 @@ func (f *fileStore) Save(name string, cfg Config) error {
 		f.data = make(map[string]Config)
 	}
 
-	f.data[trimmed] = Config{
-		Name:   cfg.Name,
-		Listen: cfg.Listen,
-		Secret: cfg.Secret,
-		Peers:  MergePeers(cfg.Peers),
+	f.data[trimmed] = cloneConfig(cfg)
+
+	return f.persist()
+}
+
+func (f *fileStore) Rename(oldName, newName string) error {
+	from := strings.TrimSpace(oldName)
+	to := strings.TrimSpace(newName)
+	if from == "" || to == "" {
+		return errors.New("config name cannot be empty")
+	}
+	if strings.EqualFold(from, "default") || strings.EqualFold(to, "default") {
+		return errors.New("config name \"default\" is reserved")
 	}
 
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	cfg, ok := f.data[from]
+	if !ok {
+		return fmt.Errorf("unknown config %q", from)
+	}
+	if _, exists := f.data[to]; exists {
+		return fmt.Errorf("config %q already exists", to)
+	}
+
+	delete(f.data, from)
+	f.data[to] = cfg
+
+	return f.persist()
+}
+
+func (f *fileStore) Delete(name string) error {
+	trimmed := strings.TrimSpace(name)
+	if trimmed == "" {
+		return errors.New("config name cannot be empty")
+	}
+	if strings.EqualFold(trimmed, "default") {
+		return errors.New("config name \"default\" is reserved")
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, ok := f.data[trimmed]; !ok {
+		return fmt.Errorf("unknown config %q", trimmed)
+	}
+	delete(f.data, trimmed)
+
 	return f.persist()
 }
 
@@ -217,6 +801,18 @@ func (f *fileStore) Load(name string) (Config, bool) {
 	return cloneConfig(cfg), true
 }
 
+// Names lists the saved profiles in sorted order, including "default".
+func (f *fileStore) Names() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	names := make([]string, 0, len(f.data))
+	for name := range f.data {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
 func (f *fileStore) SaveDefault(cfg Config) error {
 	f.mu.Lock()
 	defer f.mu.Unlock()
@@ -225,12 +821,7 @@ func (f *fileStore) SaveDefault(cfg Config) error {
 		f.data = make(map[string]Config)
 	}
 
-	f.data["default"] = Config{
-		Name:   cfg.Name,
-		Listen: cfg.Listen,
-		Secret: cfg.Secret,
-		Peers:  MergePeers(cfg.Peers),
-	}
+	f.data["default"] = cloneConfig(cfg)
 
 	return f.persist()
 }
@@ -260,12 +851,13 @@ func (f *fileStore) persist() error {
 }
 
 func cloneConfig(cfg Config) Config {
-	return Config{
-		Name:   cfg.Name,
-		Listen: cfg.Listen,
-		Secret: cfg.Secret,
-		Peers:  MergePeers(cfg.Peers),
-	}
+	clone := cfg
+	clone.Peers = MergePeers(cfg.Peers)
+	clone.Highlight = append([]string(nil), cfg.Highlight...)
+	clone.Mute = append([]string(nil), cfg.Mute...)
+	clone.Blocked = append([]string(nil), cfg.Blocked...)
+	clone.PeerNames = maps.Clone(cfg.PeerNames)
+	return clone
 }
 
 func defaultName() string {
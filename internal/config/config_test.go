@@ -0,0 +1,360 @@
+package config
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"maps"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExportImportRoundTrip(t *testing.T) {
+	cfg := Config{
+		Name:    "alice",
+		Listen:  "0.0.0.0:4000",
+		Secret:  "hunter22",
+		Peers:   []string{"10.0.0.2:4000", "10.0.0.3:4000"},
+		Cipher:  "chacha20-poly1305",
+		KDF:     "argon2id",
+		GroupID: "team",
+	}
+	blob, err := Export(cfg, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := Import(blob)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Name != cfg.Name || got.Listen != cfg.Listen || got.Secret != cfg.Secret || got.Cipher != cfg.Cipher || got.KDF != cfg.KDF || got.GroupID != cfg.GroupID || !slices.Equal(got.Peers, cfg.Peers) {
+		t.Fatalf("round trip changed the profile: %+v", got)
+	}
+}
+
+func TestExportOmitsSecretByDefault(t *testing.T) {
+	blob, err := Export(Config{Name: "alice", Secret: "hunter22"}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := Import(blob)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Secret != "" {
+		t.Fatalf("secret leaked: %q", got.Secret)
+	}
+}
+
+func TestExportLeavesOutLocalState(t *testing.T) {
+	cfg := Config{
+		Name:         "alice",
+		LogFile:      "/var/log/yap.json",
+		Downloads:    "/home/alice/in",
+		AcceptFiles:  "auto",
+		Blocked:      []string{"10.0.0.9:4000"},
+		PeerNames:    map[string]string{"10.0.0.2:4000": "bob"},
+		Debug:        true,
+		HealthListen: "127.0.0.1:8080",
+	}
+	blob, err := Export(cfg, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := base64.StdEncoding.DecodeString(blob)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fields map[string]any
+	if err := json.Unmarshal(data, &fields); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"logFile", "downloads", "acceptFiles", "blocked", "peerNames", "debug", "healthListen"} {
+		if _, ok := fields[key]; ok {
+			t.Errorf("export includes %s", key)
+		}
+	}
+}
+
+func TestImportIgnoresLocalFields(t *testing.T) {
+	raw := `{"name":"eve","acceptFiles":"auto","downloads":"/etc","logFile":"/tmp/x","blocked":["10.0.0.1:1"]}`
+	got, err := Import(base64.StdEncoding.EncodeToString([]byte(raw)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Name != "eve" || got.AcceptFiles != "" || got.Downloads != "" || got.LogFile != "" || len(got.Blocked) != 0 {
+		t.Fatalf("import applied local fields: %+v", got)
+	}
+}
+
+func TestImportRejectsInvalidProfile(t *testing.T) {
+	raw := `{"listen":"nope"}`
+	if _, err := Import(base64.StdEncoding.EncodeToString([]byte(raw))); err == nil {
+		t.Fatal("invalid listen address accepted")
+	}
+	if _, err := Import("%%%"); err == nil {
+		t.Fatal("malformed blob accepted")
+	}
+}
+
+// newStore opens a config file in a fresh temporary directory.
+func newStore(t *testing.T) (Store, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "yap.json")
+	store, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return store, path
+}
+
+func TestRenamePersists(t *testing.T) {
+	store, path := newStore(t)
+	if err := store.Save("team", Config{Name: "alice"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Rename("team", "crew"); err != nil {
+		t.Fatal(err)
+	}
+	reloaded, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := reloaded.Load("team"); ok {
+		t.Fatal("old name still present")
+	}
+	if cfg, ok := reloaded.Load("crew"); !ok || cfg.Name != "alice" {
+		t.Fatalf("renamed profile = %+v, %v", cfg, ok)
+	}
+}
+
+func TestDeletePersists(t *testing.T) {
+	store, path := newStore(t)
+	if err := store.Save("team", Config{Name: "alice"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Delete(" team "); err != nil {
+		t.Fatal(err)
+	}
+	reloaded, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := reloaded.Load("team"); ok {
+		t.Fatal("deleted profile still present")
+	}
+}
+
+func TestDeleteRefusesMissingAndReserved(t *testing.T) {
+	store, _ := newStore(t)
+	if err := store.SaveDefault(Config{Name: "alice"}); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"missing", "default", "Default", " "} {
+		if err := store.Delete(name); err == nil {
+			t.Errorf("delete %q succeeded", name)
+		}
+	}
+	if _, ok := store.Default(); !ok {
+		t.Fatal("default profile was deleted")
+	}
+}
+
+func TestRenameRefusesConflicts(t *testing.T) {
+	store, _ := newStore(t)
+	for _, name := range []string{"team", "crew"} {
+		if err := store.Save(name, Config{Name: name}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, tc := range []struct{ from, to string }{
+		{"team", "crew"},
+		{"missing", "other"},
+		{"team", "default"},
+		{"team", " "},
+	} {
+		if err := store.Rename(tc.from, tc.to); err == nil {
+			t.Errorf("rename %q to %q succeeded", tc.from, tc.to)
+		}
+	}
+	if cfg, ok := store.Load("crew"); !ok || cfg.Name != "crew" {
+		t.Fatalf("conflicting rename overwrote crew: %+v", cfg)
+	}
+}
+
+func TestInterval(t *testing.T) {
+	const fallback = 25 * time.Second
+	for _, tc := range []struct {
+		raw     string
+		want    time.Duration
+		wantErr bool
+	}{
+		{"", fallback, false},
+		{"off", 0, false},
+		{"OFF", 0, false},
+		{"10s", 10 * time.Second, false},
+		{" 1m ", time.Minute, false},
+		{"soon", fallback, true},
+		{"-5s", fallback, true},
+	} {
+		got, err := Interval(tc.raw, fallback)
+		if got != tc.want || (err != nil) != tc.wantErr {
+			t.Errorf("Interval(%q) = %v, %v; want %v, error %v", tc.raw, got, err, tc.want, tc.wantErr)
+		}
+	}
+}
+
+func TestNormalizeTidiesName(t *testing.T) {
+	if got := Normalize(Config{Name: "  Alice \t Smith "}).Name; got != "Alice Smith" {
+		t.Fatalf("Name = %q, want %q", got, "Alice Smith")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	cases := []struct {
+		name string
+		cfg  Config
+		want string
+	}{
+		{"valid", Config{Name: "alice", Listen: ":4000", Peers: []string{"10.0.0.2:4000", "host.lan:4000"}}, ""},
+		{"bad listen port", Config{Listen: "0.0.0.0:99999"}, `listen address "0.0.0.0:99999": invalid port`},
+		{"listen without port", Config{Listen: "0.0.0.0"}, "missing port"},
+		{"empty peer", Config{Peers: []string{" "}}, "peer address cannot be empty"},
+		{"peer without host", Config{Peers: []string{":4000"}}, "missing host"},
+		{"peer on port zero", Config{Peers: []string{"10.0.0.2:0"}}, "port 0 cannot be dialed"},
+		{"newline in name", Config{Name: "ali\nce"}, "name must not contain newlines"},
+		{"argon2id kdf", Config{KDF: "argon2id"}, ""},
+		{"unknown kdf", Config{KDF: "md5"}, `kdf "md5" is not sha256 or argon2id`},
+		{"jumbo read buffer", Config{ReadBuffer: 9000}, ""},
+		{"negative read buffer", Config{ReadBuffer: -1}, "readBuffer"},
+		{"oversized read buffer", Config{ReadBuffer: 65536}, "readBuffer"},
+		{"peer cap", Config{MaxPeers: 64}, ""},
+		{"negative peer cap", Config{MaxPeers: -1}, "maxPeers must not be negative"},
+		{"relay hub", Config{Relay: true}, ""},
+		{"auto accept files", Config{AcceptFiles: " Auto "}, ""},
+		{"unknown file policy", Config{AcceptFiles: "always"}, `acceptFiles "always" must be ask, auto, or never`},
+		{"health endpoint", Config{HealthListen: "127.0.0.1:8080"}, ""},
+		{"health endpoint on every interface", Config{HealthListen: ":8080"}, ""},
+		{"health endpoint without port", Config{HealthListen: "localhost"}, `healthListen "localhost"`},
+		{"spoke and hub", Config{NoForward: true, Relay: true}, "noForward and relay cannot both be set"},
+	}
+	for _, tc := range cases {
+		err := Validate(tc.cfg)
+		switch {
+		case tc.want == "" && err != nil:
+			t.Errorf("%s: unexpected error %v", tc.name, err)
+		case tc.want != "" && (err == nil || !strings.Contains(err.Error(), tc.want)):
+			t.Errorf("%s: error = %v, want it to mention %q", tc.name, err, tc.want)
+		}
+	}
+}
+
+func TestValidateJoinsErrors(t *testing.T) {
+	err := Validate(Config{Name: "a\nb", Listen: "x:y", Peers: []string{""}})
+	if err == nil {
+		t.Fatal("invalid config accepted")
+	}
+	for _, want := range []string{"newlines", "listen address", "peer address"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error lacks %q: %v", want, err)
+		}
+	}
+}
+
+func TestDigest(t *testing.T) {
+	for _, tc := range []struct {
+		cfg  Config
+		want string
+	}{
+		{Config{}, "(default name), " + DefaultListen + ", encryption off, 0 peers"},
+		{Config{Name: "alice", Listen: ":9000", Secret: "s", Peers: []string{"a:1"}}, "alice, :9000, encryption on, 1 peer"},
+		{Config{Name: "bob", Peers: []string{"a:1", "b:2"}}, "bob, " + DefaultListen + ", encryption off, 2 peers"},
+	} {
+		if got := Digest(tc.cfg); got != tc.want {
+			t.Errorf("Digest(%+v) = %q, want %q", tc.cfg, got, tc.want)
+		}
+	}
+}
+
+func TestNamesAreSorted(t *testing.T) {
+	store, _ := newStore(t)
+	for _, name := range []string{"zeta", "alpha", "mid"} {
+		if err := store.Save(name, Config{Name: name}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := strings.Join(store.Names(), ","); got != "alpha,mid,zeta" {
+		t.Fatalf("Names() = %s", got)
+	}
+}
+
+func TestMergeGroupWindow(t *testing.T) {
+	if got := Merge(Config{GroupWindow: "10s"}, Config{}).GroupWindow; got != "10s" {
+		t.Errorf("blank overlay replaced window: %q", got)
+	}
+	if got := Merge(Config{GroupWindow: "10s"}, Config{GroupWindow: "off"}).GroupWindow; got != "off" {
+		t.Errorf("overlay window not applied: %q", got)
+	}
+}
+
+func TestMergePeerNames(t *testing.T) {
+	base := Config{PeerNames: map[string]string{"10.0.0.2:4000": "bob", "10.0.0.3:4000": "carol"}}
+	merged := Merge(base, Config{PeerNames: map[string]string{"10.0.0.2:4000": "robert"}})
+	want := map[string]string{"10.0.0.2:4000": "robert", "10.0.0.3:4000": "carol"}
+	if !maps.Equal(merged.PeerNames, want) {
+		t.Fatalf("merged names = %v, want %v", merged.PeerNames, want)
+	}
+	if base.PeerNames["10.0.0.2:4000"] != "bob" {
+		t.Fatal("merge modified the base names")
+	}
+	if got := Merge(base, Config{}).PeerNames; !maps.Equal(got, base.PeerNames) {
+		t.Fatalf("empty overlay changed names to %v", got)
+	}
+}
+
+func TestNormalizeCopiesPeerNames(t *testing.T) {
+	cfg := Config{Name: "alice", PeerNames: map[string]string{"10.0.0.2:4000": "bob"}}
+	normalized := Normalize(cfg)
+	normalized.PeerNames["10.0.0.2:4000"] = "mallory"
+	if cfg.PeerNames["10.0.0.2:4000"] != "bob" {
+		t.Fatal("normalized config shares its peer names")
+	}
+}
+
+func TestSummaryShowsHealthEndpoint(t *testing.T) {
+	if got := strings.Join(Summary(Config{HealthListen: "127.0.0.1:8080"}), "\n"); !strings.Contains(got, "health: http://127.0.0.1:8080/healthz") {
+		t.Fatalf("summary:\n%s", got)
+	}
+	if got := strings.Join(Summary(Config{}), "\n"); strings.Contains(got, "health:") {
+		t.Fatalf("summary mentions a disabled endpoint:\n%s", got)
+	}
+}
+
+func TestMergeKeepsHealthListen(t *testing.T) {
+	merged := Merge(Config{HealthListen: "127.0.0.1:8080"}, Config{Name: "alice"})
+	if merged.HealthListen != "127.0.0.1:8080" {
+		t.Fatalf("healthListen = %q after merge", merged.HealthListen)
+	}
+	if merged = Merge(merged, Config{HealthListen: ":9090"}); merged.HealthListen != ":9090" {
+		t.Fatalf("healthListen = %q, want the overlay", merged.HealthListen)
+	}
+}
+
+func TestSummaryShowsForwarding(t *testing.T) {
+	cases := []struct {
+		cfg  Config
+		want string
+	}{
+		{Config{Relay: true}, "forwarding: relay hub"},
+		{Config{NoForward: true}, "forwarding: off (spoke)"},
+		{Config{}, ""},
+	}
+	for _, tc := range cases {
+		got := strings.Join(Summary(tc.cfg), "\n")
+		if tc.want == "" && strings.Contains(got, "forwarding:") || !strings.Contains(got, tc.want) {
+			t.Errorf("summary of %+v:\n%s\nwant %q", tc.cfg, got, tc.want)
+		}
+	}
+}